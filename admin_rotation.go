@@ -0,0 +1,132 @@
+package artifactory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// adminTokenExpiringEvent is the type of the Vault event sent when the admin token expires within
+// expiry_warning_threshold
+const adminTokenExpiringEvent = "artifactory-admin-token-expiring"
+
+// rotateAdminTokenIfDue rotates the admin token once rotation_period has passed since it was last set or rotated, or
+// once it expires within rotation_window. It runs periodically on the active node.
+func (b *backend) rotateAdminTokenIfDue(ctx context.Context, req *logical.Request) error {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	// The mount sharing its config rotates the token
+	if config == nil || config.sharedFrom != "" || config.AccessToken == "" || (config.RotationPeriod <= 0 && config.RotationWindow <= 0) {
+		return nil
+	}
+
+	reason := b.adminRotationReason(*config)
+	if reason == "" {
+		return nil
+	}
+
+	b.Logger().Info("rotating the admin access token", "reason", reason)
+
+	resp, err := b.rotateAdminToken(ctx, req.Storage, config, nil, "")
+	if err != nil {
+		b.Logger().Error("scheduled rotation of the admin access token failed", "err", err)
+		return err
+	}
+
+	if resp != nil {
+		if resp.IsError() {
+			b.Logger().Error("scheduled rotation of the admin access token failed", "err", resp.Error())
+			return resp.Error()
+		}
+		for _, warning := range resp.Warnings {
+			b.Logger().Warn(warning)
+		}
+	}
+
+	return nil
+}
+
+// adminRotationReason says why the admin token is due for rotation, or returns an empty string if it isn't
+func (b *backend) adminRotationReason(config adminConfiguration) string {
+	if config.RotationPeriod > 0 {
+		last := config.RotatedAt
+		if last.IsZero() {
+			last = config.CredentialsUpdatedAt
+		}
+		// Tokens written before the time was recorded are rotated right away
+		if last.IsZero() || time.Since(last) >= config.RotationPeriod {
+			return "rotation_period elapsed"
+		}
+	}
+
+	if config.RotationWindow > 0 {
+		token, err := b.getTokenInfo(config, config.AccessToken)
+		if err != nil {
+			b.Logger().Warn("could not parse the admin access token to check its expiry", "err", err)
+			return ""
+		}
+		if token.Expires > 0 && time.Until(time.Unix(token.Expires, 0)) <= config.RotationWindow {
+			return "token expires within rotation_window"
+		}
+	}
+
+	return ""
+}
+
+// checkAdminTokenExpiry reports the remaining lifetime of the admin token as a gauge and, once it expires within
+// expiry_warning_threshold, logs a warning and sends an event. The warning is sent once per admin token, rather than on
+// every periodic run. Tokens that aren't JWTs, or don't expire, are skipped.
+func (b *backend) checkAdminTokenExpiry(ctx context.Context, req *logical.Request) error {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	if config == nil || config.AccessToken == "" {
+		return nil
+	}
+
+	token, err := b.inspectToken(config.AccessToken)
+	if err != nil || token.Expires <= 0 {
+		return nil
+	}
+
+	expiresAt := time.Unix(token.Expires, 0).UTC()
+	remaining := time.Until(expiresAt)
+	metrics.SetGauge([]string{"artifactory", "admin_token", "remaining_seconds"}, float32(remaining.Seconds()))
+
+	tokenHash := accessTokenSHA256(config.AccessToken)
+	if config.ExpiryWarningThreshold <= 0 || remaining > config.ExpiryWarningThreshold || b.expiryWarnedFor == tokenHash {
+		return nil
+	}
+
+	b.Logger().Warn("the admin access token expires soon, rotate or replace it", "expires_at", expiresAt, "token_id", token.TokenID)
+
+	err = logical.SendEvent(ctx, b, adminTokenExpiringEvent,
+		"expires_at", expiresAt.Format(time.RFC3339),
+		"remaining_seconds", fmt.Sprintf("%d", int64(remaining.Seconds())),
+		"token_id", token.TokenID,
+		"subject", token.Subject)
+	// Without the events system the log line is the only warning
+	if err != nil && !errors.Is(err, framework.ErrNoEvents) {
+		b.Logger().Warn("could not send admin token expiry event", "err", err)
+	}
+
+	b.expiryWarnedFor = tokenHash
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// The periodic function must rotate the admin token only once rotation_period has passed or it nears expiry.
+func TestBackend_RotateAdminTokenIfDue(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	// Before 7.12.0 the root certificate isn't available, so the admin token is parsed without validation
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.11.0", "revision" : "71100900"}`)
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(200, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":    adminJWTAccessToken,
+		"url":             "http://myserver.com:80/artifactory",
+		"rotation_period": "24h",
+	})
+
+	periodicReq := &logical.Request{Storage: config.StorageView}
+
+	// Written just now, so not due
+	err := b.rotateAdminTokenIfDue(context.Background(), periodicReq)
+	assert.NoError(t, err)
+	assert.Zero(t, httpmock.GetCallCountInfo()["POST http://myserver.com:80/artifactory/api/security/token"])
+
+	// The token has expired, which is within any window
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"rotation_window": "1h"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp != nil && resp.IsError())
+
+	err = b.rotateAdminTokenIfDue(context.Background(), periodicReq)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["POST http://myserver.com:80/artifactory/api/security/token"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp.Data["rotated_at"])
+	assert.EqualValues(t, 86400, resp.Data["rotation_period"])
+	assert.EqualValues(t, 3600, resp.Data["rotation_window"])
+
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.Equal(t, "eyXsdgbtybbeeyh...", adminConfig.AccessToken)
+}
+
+// recordingEventSender keeps the events sent through it
+type recordingEventSender struct {
+	events []*logical.EventData
+	types  []logical.EventType
+}
+
+func (s *recordingEventSender) SendEvent(_ context.Context, eventType logical.EventType, event *logical.EventData) error {
+	s.types = append(s.types, eventType)
+	s.events = append(s.events, event)
+	return nil
+}
+
+// An admin token expiring within expiry_warning_threshold must send one event per token.
+func TestBackend_CheckAdminTokenExpiry(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	adminToken := func(expires time.Time) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "jfac@01fr1x1h805xmg0t17xhqr1v7a/users/vault",
+			"jti": "test-admin-token-id",
+			"scp": "applied-permissions/admin",
+			"exp": expires.Unix(),
+		}).SignedString([]byte("test-key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":             adminToken(time.Now().Add(30 * 24 * time.Hour)),
+		"url":                      "http://myserver.com:80",
+		"expiry_warning_threshold": "168h",
+	})
+
+	events := &recordingEventSender{}
+	config.EventsSender = events
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &logical.Request{Storage: config.StorageView}
+
+	assert.NoError(t, b.checkAdminTokenExpiry(context.Background(), req))
+	assert.Empty(t, events.events, "the token doesn't expire within the threshold")
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": adminToken(time.Now().Add(48 * time.Hour)),
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp != nil && resp.IsError())
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, b.checkAdminTokenExpiry(context.Background(), req))
+	}
+	if assert.Len(t, events.events, 1) {
+		assert.Equal(t, logical.EventType(adminTokenExpiringEvent), events.types[0])
+		metadata := events.events[0].Metadata.AsMap()
+		assert.Equal(t, "test-admin-token-id", metadata["token_id"])
+		assert.Equal(t, "jfac@01fr1x1h805xmg0t17xhqr1v7a/users/vault", metadata["subject"])
+		assert.NotEmpty(t, metadata["expires_at"])
+	}
+
+	// Without a threshold nothing is sent
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"expiry_warning_threshold": 0,
+		},
+	})
+	assert.NoError(t, err)
+	b.expiryWarnedFor = ""
+	assert.NoError(t, b.checkAdminTokenExpiry(context.Background(), req))
+	assert.Len(t, events.events, 1)
+}
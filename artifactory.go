@@ -2,9 +2,11 @@ package artifactory
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +14,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	jwt "github.com/golang-jwt/jwt/v4"
 	"github.com/hashicorp/go-version"
@@ -23,6 +26,17 @@ const (
 	defaultUserNameTemplate string = `{{ printf "v-%s-%s" (.RoleName | truncate 24) (random 8) }}` // Docs indicate max length is 256
 )
 
+const healthCheckCacheTTL = 10 * time.Second
+
+// accessAPIPathPrefix is the path prefix of calls served by the JFrog Access service
+const accessAPIPathPrefix = "/access/"
+
+// Header styles used to authenticate with the admin token
+const (
+	authHeaderBearer = "bearer"
+	authHeaderArtApi = "x-jfrog-art-api"
+)
+
 var ErrIncompatibleVersion = errors.New("incompatible version")
 
 type errorResponse struct {
@@ -31,7 +45,7 @@ type errorResponse struct {
 	Detail  string `json:"detail"`
 }
 
-func (b *backend) RevokeToken(config adminConfiguration, secret logical.Secret) error {
+func (b *backend) RevokeToken(ctx context.Context, config adminConfiguration, secret logical.Secret) error {
 	tokenId := secret.InternalData["token_id"].(string)
 	u, err := url.Parse(config.ArtifactoryURL)
 	if err != nil {
@@ -42,7 +56,7 @@ func (b *backend) RevokeToken(config adminConfiguration, secret logical.Secret)
 	var resp *http.Response
 
 	if b.useNewAccessAPI() {
-		resp, err = b.performArtifactoryDelete(config, "/access/api/v1/tokens/"+tokenId)
+		resp, err = b.performArtifactoryDelete(ctx, config, "/access/api/v1/tokens/"+tokenId)
 		if err != nil {
 			b.Logger().Error("error deleting access token", "tokenId", tokenId, "response", resp, "err", err)
 			return err
@@ -52,7 +66,7 @@ func (b *backend) RevokeToken(config adminConfiguration, secret logical.Secret)
 		values := url.Values{}
 		values.Set("token", accessToken)
 
-		resp, err = b.performArtifactoryPost(config, u.Path+"/api/security/token/revoke", values)
+		resp, err = b.performArtifactoryPost(ctx, config, u.Path+"/api/security/token/revoke", values)
 		if err != nil {
 			b.Logger().Error("error deleting token", "tokenId", tokenId, "response", resp, "err", err)
 			return err
@@ -61,6 +75,12 @@ func (b *backend) RevokeToken(config adminConfiguration, secret logical.Secret)
 	//noinspection GoUnhandledErrorResult
 	defer resp.Body.Close()
 
+	// The token is already gone, e.g. revoked from the Artifactory UI or found dead on renewal
+	if resp.StatusCode == http.StatusNotFound && b.useNewAccessAPI() {
+		b.Logger().Info("token was already revoked in Artifactory", "tokenId", tokenId)
+		return nil
+	}
+
 	if resp.StatusCode >= http.StatusBadRequest {
 		e := fmt.Errorf("could not revoke tokenID: %v - HTTP response %v", tokenId, resp.StatusCode)
 
@@ -76,6 +96,47 @@ func (b *backend) RevokeToken(config adminConfiguration, secret logical.Secret)
 	return nil
 }
 
+type tokenInfoResponse struct {
+	TokenId string `json:"token_id"`
+	Expiry  int64  `json:"expiry"`
+}
+
+// tokenActive asks Artifactory whether the token with tokenId still exists and hasn't expired. Versions older than
+// 7.21.1 can't look tokens up by id, so their tokens are always reported active.
+func (b *backend) tokenActive(config adminConfiguration, tokenId string) (bool, error) {
+	if !b.useNewAccessAPI() || len(tokenId) == 0 {
+		return true, nil
+	}
+
+	resp, err := b.performArtifactoryGet(config, "/access/api/v1/tokens/"+tokenId)
+	if err != nil {
+		b.Logger().Error("error looking up access token", "tokenId", tokenId, "err", err)
+		return false, err
+	}
+
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("could not look up tokenID: %v - HTTP response %v", tokenId, resp.StatusCode)
+	}
+
+	var info tokenInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return false, err
+	}
+
+	if info.Expiry > 0 && time.Unix(info.Expiry, 0).Before(time.Now()) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
 type CreateTokenRequest struct {
 	GrantType             string `json:"grant_type,omitempty"`
 	Username              string `json:"username,omitempty"`
@@ -86,9 +147,10 @@ type CreateTokenRequest struct {
 	Audience              string `json:"audience,omitempty"`
 	ForceRevocable        bool   `json:"force_revocable,omitempty"`
 	IncludeReferenceToken bool   `json:"include_reference_token,omitempty"`
+	ProjectKey            string `json:"project_key,omitempty"`
 }
 
-func (b *backend) CreateToken(config adminConfiguration, role artifactoryRole) (*createTokenResponse, error) {
+func (b *backend) CreateToken(ctx context.Context, config adminConfiguration, role artifactoryRole) (*createTokenResponse, error) {
 	request := CreateTokenRequest{
 		GrantType:             role.GrantType,
 		Username:              role.Username,
@@ -97,12 +159,36 @@ func (b *backend) CreateToken(config adminConfiguration, role artifactoryRole) (
 		Description:           role.Description,
 		Refreshable:           role.Refreshable,
 		IncludeReferenceToken: role.IncludeReferenceToken,
+		ProjectKey:            role.ProjectKey,
 	}
 
+	config.roleHeaders = role.RequestHeaders
+
 	if len(request.Username) == 0 {
 		return nil, fmt.Errorf("empty username not allowed, possibly a template error")
 	}
 
+	if len(request.ProjectKey) > 0 && !b.useNewAccessAPI() {
+		return nil, withRemediation(fmt.Errorf("project_key requires the Access token API of Artifactory 7.21.1 or higher, connected version is %s", b.artifactoryVersion()), remediationUpgradeArtifactory)
+	}
+
+	if (len(role.AppliedPermissions) > 0 || len(role.Projects) > 0) && !b.useNewAccessAPI() {
+		return nil, withRemediation(fmt.Errorf("applied_permissions and projects require the Access token API of Artifactory 7.21.1 or higher, connected version is %s", b.artifactoryVersion()), remediationUpgradeArtifactory)
+	}
+
+	// Group tokens are only revocable by token id, which the Access token API returns
+	if role.group() && !b.useNewAccessAPI() {
+		return nil, withRemediation(fmt.Errorf("token_type=group requires the Access token API of Artifactory 7.21.1 or higher, connected version is %s", b.artifactoryVersion()), remediationUpgradeArtifactory)
+	}
+
+	// Identity tokens are used through their reference token, as those generated in the JFrog UI
+	if role.identity() {
+		if !b.checkVersion(referenceTokenVersion) {
+			return nil, withRemediation(fmt.Errorf("token_type=identity requires reference tokens of Artifactory %s or higher, connected version is %s", referenceTokenVersion, b.artifactoryVersion()), remediationUpgradeArtifactory)
+		}
+		request.IncludeReferenceToken = true
+	}
+
 	// Artifactory will not let you revoke a token that has an expiry unless it also meets
 	// criteria that can only be set in its configuration file. The version of Artifactory
 	// I'm testing against will actually delete a token when you ask it to revoke by token_id,
@@ -114,6 +200,11 @@ func (b *backend) CreateToken(config adminConfiguration, role artifactoryRole) (
 		request.ForceRevocable = true
 	}
 
+	// Without a lease, Vault never revokes the token, so it must expire on its own
+	if role.NoLease {
+		request.ExpiresIn = int64(role.MaxTTL.Seconds())
+	}
+
 	u, err := url.Parse(config.ArtifactoryURL)
 	if err != nil {
 		b.Logger().Error("could not parse artifactory url", "url", u, "err", err)
@@ -133,7 +224,7 @@ func (b *backend) CreateToken(config adminConfiguration, role artifactoryRole) (
 		return nil, err
 	}
 
-	resp, err := b.performArtifactoryPostWithJSON(config, path, jsonReq)
+	resp, err := b.performArtifactoryPostWithJSON(ctx, config, path, jsonReq)
 	if err != nil {
 		b.Logger().Error("error making token request", "response", resp, "err", err)
 		return nil, err
@@ -148,10 +239,29 @@ func (b *backend) CreateToken(config adminConfiguration, role artifactoryRole) (
 		var errResp errorResponse
 		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
 			b.Logger().Error("revokenToken could not parse error response body", "err", err)
-			return nil, e
+		} else {
+			b.Logger().Error("createToken got non-200 status code", "statusCode", resp.StatusCode, "body", errResp)
+			e = fmt.Errorf("could not create access token: HTTP response: %s", errResp.Detail)
+		}
+
+		// Artifactory rejects scopes the admin token can't grant with a generic error, so name what is missing
+		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusForbidden {
+			if adminScope, missing := b.scopeBeyondAdmin(config, request.Scope); len(missing) > 0 {
+				return nil, fmt.Errorf("%w: the admin token can't grant '%s', its scope is '%s'", e, strings.Join(missing, " "), adminScope)
+			}
 		}
-		b.Logger().Error("createToken got non-200 status code", "statusCode", resp.StatusCode, "body", errResp)
-		return nil, fmt.Errorf("could not create access token: HTTP response: %s", errResp.Detail)
+
+		// A 400 naming the scope is Artifactory failing to parse it
+		if resp.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(errResp.Detail), "scope") {
+			return nil, withRemediation(e, remediationScopeGrammar)
+		}
+
+		// Artifactory rejects an expired or revoked admin token
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, withRemediation(e, remediationRotateAdminToken)
+		}
+
+		return nil, e
 	}
 
 	var createdToken createTokenResponse
@@ -160,9 +270,41 @@ func (b *backend) CreateToken(config adminConfiguration, role artifactoryRole) (
 		return nil, err
 	}
 
+	// Some deployments are configured to only hand out opaque reference tokens, in which case the
+	// reference token is the only usable credential in the response.
+	if len(createdToken.AccessToken) == 0 {
+		if len(createdToken.ReferenceToken) == 0 {
+			return nil, fmt.Errorf("could not create access token: response did not include an access or reference token")
+		}
+		createdToken.AccessToken = createdToken.ReferenceToken
+		createdToken.ReferenceOnly = true
+	}
+
+	if role.identity() {
+		if len(createdToken.ReferenceToken) == 0 {
+			return nil, fmt.Errorf("could not create identity token: response did not include a reference token")
+		}
+		createdToken.AccessToken = createdToken.ReferenceToken
+	}
+
+	createdToken.Revocable = tokenRevocable(request)
+
 	return &createdToken, nil
 }
 
+// referenceTokenVersion is the first Artifactory version that creates reference tokens
+const referenceTokenVersion = "7.38.10"
+
+// revocableExpiryThreshold is Artifactory's default revocable threshold: a token expiring sooner than this is neither
+// persisted nor revocable unless force_revocable is set.
+const revocableExpiryThreshold = 6 * time.Hour
+
+// tokenRevocable reports whether Artifactory persists, and so can revoke, a token created with request. Tokens that
+// never expire are always persisted.
+func tokenRevocable(request CreateTokenRequest) bool {
+	return request.ExpiresIn == 0 || request.ForceRevocable || request.ExpiresIn >= int64(revocableExpiryThreshold.Seconds())
+}
+
 // supportForceRevocable verifies whether or not the Artifactory version is 7.50.3 or higher.
 // The access API changes in v7.50.3 to support force_revocable to allow us to set the expiration for the tokens.
 // REF: https://www.jfrog.com/confluence/display/JFROG/JFrog+Platform+REST+API#JFrogPlatformRESTAPI-CreateToken
@@ -177,9 +319,48 @@ func (b *backend) useNewAccessAPI() bool {
 	return b.checkVersion("7.21.1")
 }
 
-// getVersion will fetch the current Artifactory version and store it in the backend
-func (b *backend) getVersion(config adminConfiguration) (err error) {
-	resp, err := b.performArtifactoryGet(config, "/artifactory/api/system/version")
+// getVersion will fetch the current Artifactory version and store it in the backend. With disable_version_check, the
+// artifactory_version of the config is used instead.
+func (b *backend) getVersion(config adminConfiguration) error {
+	v, err := b.resolveVersion(b.client(config), config)
+	if err != nil {
+		return err
+	}
+	b.setVersion(v)
+	return nil
+}
+
+// setVersion replaces the version of the setup
+func (b *backend) setVersion(v string) {
+	b.storeSetup(func(s *configSetup) { s.version = v })
+}
+
+// artifactoryVersion returns the version of Artifactory calls are made for
+func (b *backend) artifactoryVersion() string {
+	return b.currentSetup().version
+}
+
+// resolveVersion returns the Artifactory version of config, fetched with client unless disable_version_check is set
+func (b *backend) resolveVersion(client *http.Client, config adminConfiguration) (string, error) {
+	if config.DisableVersionCheck {
+		return config.ArtifactoryVersion, nil
+	}
+	return b.fetchVersionWith(client, config)
+}
+
+// fetchVersion fetches the version of the Artifactory instance config points at
+func (b *backend) fetchVersion(config adminConfiguration) (string, error) {
+	return b.fetchVersionWith(b.client(config), config)
+}
+
+// fetchVersionWith fetches the version of the Artifactory instance config points at with client
+func (b *backend) fetchVersionWith(client *http.Client, config adminConfiguration) (v string, err error) {
+	req, err := b.newArtifactoryGetRequest(config, "/artifactory/api/system/version")
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		b.Logger().Error("error making system version request", "response", resp, "err", err)
 		return
@@ -189,7 +370,7 @@ func (b *backend) getVersion(config adminConfiguration) (err error) {
 
 	if resp.StatusCode != http.StatusOK {
 		b.Logger().Error("got non-200 status code", "statusCode", resp.StatusCode)
-		return fmt.Errorf("could not get the system version: HTTP response %v", resp.StatusCode)
+		return "", fmt.Errorf("could not get the system version: HTTP response %v", resp.StatusCode)
 	}
 
 	var systemVersion systemVersionResponse
@@ -197,16 +378,50 @@ func (b *backend) getVersion(config adminConfiguration) (err error) {
 		b.Logger().Error("could not parse system version response", "response", resp, "err", err)
 		return
 	}
-	b.version = systemVersion.Version
-	return
+	return systemVersion.Version, nil
+}
+
+// checkHealth will probe Artifactory's ping endpoint, caching the outcome per URL for healthCheckCacheTTL
+// so that issuance doesn't double the request rate against Artifactory.
+func (b *backend) checkHealth(config adminConfiguration) error {
+	b.healthMutex.Lock()
+	defer b.healthMutex.Unlock()
+
+	if b.healthCheckedURL == config.ArtifactoryURL && time.Since(b.healthCheckedAt) < healthCheckCacheTTL {
+		return b.healthErr
+	}
+
+	b.healthErr = b.ping(config)
+	b.healthCheckedURL = config.ArtifactoryURL
+	b.healthCheckedAt = time.Now()
+
+	return b.healthErr
+}
+
+func (b *backend) ping(config adminConfiguration) error {
+	resp, err := b.performArtifactoryGet(config, "/artifactory/api/system/ping")
+	if err != nil {
+		b.Logger().Error("error making system ping request", "response", resp, "err", err)
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b.Logger().Error("got non-200 status code", "statusCode", resp.StatusCode)
+		return fmt.Errorf("system ping returned HTTP response %v", resp.StatusCode)
+	}
+
+	return nil
 }
 
 // checkVersion will return a boolean and error to check compatibility before making an API call
 // -- This was formerly "checkSystemStatus" but that was hard-coded, that method now calls this one
 func (b *backend) checkVersion(ver string) (compatible bool) {
-	v1, err := version.NewVersion(b.version)
+	current := b.artifactoryVersion()
+	v1, err := version.NewVersion(current)
 	if err != nil {
-		b.Logger().Error("could not parse Artifactory system version", "ver", b.version, "err", err)
+		b.Logger().Error("could not parse Artifactory system version", "ver", current, "err", err)
 		return
 	}
 
@@ -252,9 +467,7 @@ func (b *backend) parseJWT(config adminConfiguration, token string) (jwtToken *j
 	} else { // SKIP Validation
 		// -- NOTE THIS IGNORES THE SIGNATURE, which is probably bad,
 		//    but it is artifactory's job to validate the token, right?
-		// p := jwt.Parser{}
-		// token, _, err := p.ParseUnverified(oldAccessToken, jwt.MapClaims{})
-		jwtToken, err = jwt.Parse(token, nil, jwt.WithoutClaimsValidation())
+		jwtToken, _, err = jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
 		if err != nil {
 			return
 		}
@@ -268,6 +481,7 @@ type TokenInfo struct {
 	TokenID  string `json:"token_id"`
 	Scope    string `json:"scope"`
 	Username string `json:"username"`
+	Subject  string `json:"subject"`
 	Expires  int64  `json:"expires"`
 }
 
@@ -284,12 +498,27 @@ func (b *backend) getTokenInfo(config adminConfiguration, token string) (info *T
 		return nil, errors.New("error parsing claims in AccessToken")
 	}
 
-	sub := strings.Split(claims["sub"].(string), "/") // sub -> subject (jfac@01fr1x1h805xmg0t17xhqr1v7a/users/admin)
+	return b.tokenInfoFromClaims(claims)
+}
 
-	info = &TokenInfo{
-		TokenID:  claims["jti"].(string),     // jti -> JFrog Token ID
-		Scope:    claims["scp"].(string),     // scp -> scope
-		Username: strings.Join(sub[2:], "/"), // 3rd+ elements (incase username has / in it)
+// tokenInfoFromClaims reads the token id, scope, username and expiry from the claims of a JFrog access token
+func (b *backend) tokenInfoFromClaims(claims jwt.MapClaims) (*TokenInfo, error) {
+	subject, _ := claims["sub"].(string)
+	tokenID, _ := claims["jti"].(string)
+	scope, _ := claims["scp"].(string)
+	if subject == "" || tokenID == "" {
+		return nil, errors.New("AccessToken is missing the sub or jti claim")
+	}
+
+	sub := strings.Split(subject, "/") // sub -> subject (jfac@01fr1x1h805xmg0t17xhqr1v7a/users/admin)
+
+	info := &TokenInfo{
+		TokenID: tokenID, // jti -> JFrog Token ID
+		Scope:   scope,   // scp -> scope
+		Subject: subject, // sub -> subject
+	}
+	if len(sub) > 2 {
+		info.Username = strings.Join(sub[2:], "/") // 3rd+ elements (incase username has / in it)
 	}
 
 	// exp -> expires at (unixtime) - may not be present
@@ -306,11 +535,38 @@ func (b *backend) getTokenInfo(config adminConfiguration, token string) (info *T
 		info.Expires = v
 	}
 
-	return
+	return info, nil
 }
 
-// getRootCert will return the Artifactory access root certificate's public key, for validating token signatures
-func (b *backend) getRootCert(config adminConfiguration) (cert *x509.Certificate, err error) {
+// getRootCert will return the Artifactory access root certificate's public key, for validating token signatures.
+// In offline mode the first outcome, success or failure, is kept until the config is written again.
+func (b *backend) getRootCert(config adminConfiguration) (*x509.Certificate, error) {
+	if !config.OfflineMode {
+		return b.fetchRootCert(config)
+	}
+
+	b.rootCertMutex.Lock()
+	defer b.rootCertMutex.Unlock()
+
+	if !b.rootCertFetched {
+		b.rootCert, b.rootCertErr = b.fetchRootCert(config)
+		b.rootCertFetched = true
+	}
+
+	return b.rootCert, b.rootCertErr
+}
+
+// resetRootCert forgets the root certificate kept in offline mode
+func (b *backend) resetRootCert() {
+	b.rootCertMutex.Lock()
+	defer b.rootCertMutex.Unlock()
+
+	b.rootCertFetched = false
+	b.rootCert = nil
+	b.rootCertErr = nil
+}
+
+func (b *backend) fetchRootCert(config adminConfiguration) (cert *x509.Certificate, err error) {
 	// Verify Artifactory version is at 7.12.0 or higher, prior versions will not work
 	// REF: https://www.jfrog.com/confluence/display/JFROG/Artifactory+REST+API#ArtifactoryRESTAPI-GetRootCertificate
 	if !b.checkVersion("7.12.0") {
@@ -362,7 +618,17 @@ type Usage struct {
 	Features  []Feature `json:"features"`
 }
 
+// sendUsage reports the use of a backend feature to Artifactory, if the config opts in to usage reporting
 func (b *backend) sendUsage(config adminConfiguration, featureId string) {
+	if !config.UsageReporting || config.OfflineMode {
+		return
+	}
+
+	product := productId
+	if len(config.UsageProductID) > 0 {
+		product = config.UsageProductID
+	}
+
 	features := []Feature{
 		{
 			FeatureId: featureId,
@@ -370,7 +636,7 @@ func (b *backend) sendUsage(config adminConfiguration, featureId string) {
 	}
 
 	usage := Usage{
-		productId,
+		product,
 		features,
 	}
 
@@ -380,7 +646,7 @@ func (b *backend) sendUsage(config adminConfiguration, featureId string) {
 		return
 	}
 
-	resp, err := b.performArtifactoryPostWithJSON(config, "artifactory/api/system/usage", jsonReq)
+	resp, err := b.performArtifactoryPostWithJSON(context.Background(), config, "artifactory/api/system/usage", jsonReq)
 	if err != nil {
 		b.Logger().Info("error making call home request", "response", resp, "err", err)
 		return
@@ -391,93 +657,257 @@ func (b *backend) sendUsage(config adminConfiguration, featureId string) {
 }
 
 func (b *backend) performArtifactoryGet(config adminConfiguration, path string) (*http.Response, error) {
-	u, err := parseURLWithDefaultPort(config.ArtifactoryURL)
+	req, err := b.newArtifactoryGetRequest(config, path)
 	if err != nil {
 		return nil, err
 	}
 
-	u.Path = path // replace any path in the URL with the provided path
+	return b.client(config).Do(req)
+}
+
+// newArtifactoryGetRequest builds an authenticated HTTP GET of path for config
+func (b *backend) newArtifactoryGetRequest(config adminConfiguration, path string) (*http.Request, error) {
+	u, err := requestURL(config, path)
+	if err != nil {
+		return nil, err
+	}
 
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", productId)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", config.AccessToken))
+	req.Header.Set("User-Agent", b.userAgent(config))
+	setCustomHeaders(req, config)
+	setRoleHeaders(req, config)
+	setAuthHeader(req, config)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	return b.httpClient.Do(req)
+	return req, nil
 }
 
 // performArtifactoryPost will HTTP POST values to the Artifactory API.
-func (b *backend) performArtifactoryPost(config adminConfiguration, path string, values url.Values) (*http.Response, error) {
-	u, err := parseURLWithDefaultPort(config.ArtifactoryURL)
+func (b *backend) performArtifactoryPost(ctx context.Context, config adminConfiguration, path string, values url.Values) (*http.Response, error) {
+	u, err := requestURL(config, path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Replace URL Path
-	u.Path = path
-
-	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(values.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(values.Encode()))
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", productId)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", config.AccessToken))
+	req.Header.Set("User-Agent", b.userAgent(config))
+	setCustomHeaders(req, config)
+	setRoleHeaders(req, config)
+	setAuthHeader(req, config)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	return b.httpClient.Do(req)
+	return b.client(config).Do(req)
 }
 
 // performArtifactoryPost will HTTP POST data to the Artifactory API.
-func (b *backend) performArtifactoryPostWithJSON(config adminConfiguration, path string, postData []byte) (*http.Response, error) {
-	u, err := parseURLWithDefaultPort(config.ArtifactoryURL)
+func (b *backend) performArtifactoryPostWithJSON(ctx context.Context, config adminConfiguration, path string, postData []byte) (*http.Response, error) {
+	u, err := requestURL(config, path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Replace URL Path
-	u.Path = path
-
 	postDataBuf := bytes.NewBuffer(postData)
-	req, err := http.NewRequest(http.MethodPost, u.String(), postDataBuf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), postDataBuf)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", productId)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", config.AccessToken))
+	req.Header.Set("User-Agent", b.userAgent(config))
+	setCustomHeaders(req, config)
+	setRoleHeaders(req, config)
+	setAuthHeader(req, config)
 	req.Header.Add("Content-Type", "application/json")
 
-	return b.httpClient.Do(req)
+	return b.client(config).Do(req)
 }
 
 // performArtifactoryDelete will HTTP DELETE to the Artifactory API.
 // The path will be appended to the configured configured URL Path (usually /artifactory)
-func (b *backend) performArtifactoryDelete(config adminConfiguration, path string) (*http.Response, error) {
+func (b *backend) performArtifactoryDelete(ctx context.Context, config adminConfiguration, path string) (*http.Response, error) {
 
-	u, err := parseURLWithDefaultPort(config.ArtifactoryURL)
+	u, err := requestURL(config, path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Replace URL Path
-	u.Path = path
-
-	req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
 
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", productId)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", config.AccessToken))
+	req.Header.Set("User-Agent", b.userAgent(config))
+	setCustomHeaders(req, config)
+	setRoleHeaders(req, config)
+	setAuthHeader(req, config)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	return b.httpClient.Do(req)
+	return b.client(config).Do(req)
+}
+
+// userAgent returns the User-Agent of calls to Artifactory. With user_agent_attribution set, it names the mount and
+// the Vault cluster, so access logs of an Artifactory shared by several mounts or clusters can attribute load.
+func (b *backend) userAgent(config adminConfiguration) string {
+	if !config.UserAgentAttribution {
+		return productId
+	}
+
+	b.userAgentMutex.Lock()
+	defer b.userAgentMutex.Unlock()
+
+	cluster := config.ClusterName
+	if len(cluster) == 0 {
+		if len(b.clusterID) == 0 {
+			clusterID, err := b.System().ClusterID(context.Background())
+			if err != nil {
+				b.Logger().Debug("could not get the cluster id for the User-Agent", "err", err)
+			}
+			b.clusterID = clusterID
+		}
+		cluster = b.clusterID
+	}
+
+	var attributes []string
+	if len(b.mountPoint) > 0 {
+		attributes = append(attributes, "mount="+b.mountPoint)
+	}
+	if len(cluster) > 0 {
+		attributes = append(attributes, "cluster="+cluster)
+	}
+	if len(attributes) == 0 {
+		return productId
+	}
+
+	return fmt.Sprintf("%s (%s)", productId, strings.Join(attributes, "; "))
+}
+
+// setCustomHeaders adds the custom headers of the config, e.g. for an auth proxy in front of Artifactory. The request
+// headers of a role are set after them, so a role can override them.
+func setCustomHeaders(req *http.Request, config adminConfiguration) {
+	for name, value := range config.CustomHeaders {
+		req.Header.Set(name, value)
+	}
+}
+
+// setRoleHeaders adds the request headers of the role a call is made for, e.g. for API gateway routing
+func setRoleHeaders(req *http.Request, config adminConfiguration) {
+	for name, value := range config.roleHeaders {
+		req.Header.Set(name, value)
+	}
+}
+
+// setAuthHeader authenticates req with the admin token, using the header style the config asks for. API keys are
+// always sent in the X-JFrog-Art-Api header.
+func setAuthHeader(req *http.Request, config adminConfiguration) {
+	if config.AuthHeader == authHeaderArtApi || config.UsesAPIKey {
+		req.Header.Set("X-JFrog-Art-Api", config.AccessToken)
+		return
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", config.AccessToken))
+}
+
+// requestURL builds the URL for an API path. The path replaces any path in the configured url, except for
+// Access API calls when access_url is set or was discovered: those go there, with the path appended to its own path,
+// so deployments that route the Access service separately from Artifactory work. Anything after a '?' in the path is
+// the query.
+func requestURL(config adminConfiguration, path string) (*url.URL, error) {
+	path, query, hasQuery := strings.Cut(path, "?")
+
+	accessURL := config.AccessURL
+	if len(accessURL) == 0 {
+		accessURL = config.DiscoveredAccessURL
+	}
+
+	if len(accessURL) > 0 && strings.HasPrefix(path, accessAPIPathPrefix) {
+		u, err := parseURLWithDefaultPort(accessURL)
+		if err != nil {
+			return nil, err
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + path
+		if hasQuery {
+			u.RawQuery = query
+		}
+		return u, nil
+	}
+
+	u, err := parseURLWithDefaultPort(config.ArtifactoryURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path
+	if hasQuery {
+		u.RawQuery = query
+	}
+
+	return u, nil
+}
+
+// checkAccessReachable will return an error if the Access service ping endpoint can't be reached
+func (b *backend) checkAccessReachable(config adminConfiguration) error {
+	resp, err := b.performArtifactoryGet(config, accessAPIPathPrefix+"api/v1/system/ping")
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("access ping returned HTTP response %v", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// platformConfiguration is the part of the Artifactory system configuration that sets the platform's custom base URL
+type platformConfiguration struct {
+	URLBase string `xml:"urlBase"`
+}
+
+// discoverAccessURL finds the address of the JFrog Access service for deployments that don't route it through url,
+// from the custom base URL of the platform set in the Artifactory system configuration. It fails if that isn't set,
+// or if Access isn't reachable there either.
+func (b *backend) discoverAccessURL(config adminConfiguration) (string, error) {
+	resp, err := b.performArtifactoryGet(config, "/artifactory/api/system/configuration")
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get the system configuration: HTTP response %v", resp.StatusCode)
+	}
+
+	var platform platformConfiguration
+	if err := xml.NewDecoder(resp.Body).Decode(&platform); err != nil {
+		return "", fmt.Errorf("could not parse the system configuration: %w", err)
+	}
+
+	if len(platform.URLBase) == 0 {
+		return "", fmt.Errorf("the platform has no custom base URL")
+	}
+
+	// The base URL of older instances includes Artifactory's context path, which Access isn't served under
+	candidate := strings.TrimSuffix(strings.TrimSuffix(platform.URLBase, "/"), "/artifactory")
+	if _, err := parseURLWithDefaultPort(candidate); err != nil {
+		return "", fmt.Errorf("invalid custom base URL %q: %w", platform.URLBase, err)
+	}
+
+	probe := config
+	probe.AccessURL = candidate
+	if err := b.checkAccessReachable(probe); err != nil {
+		return "", fmt.Errorf("not reachable at the custom base URL %s either: %w", candidate, err)
+	}
+
+	return candidate, nil
 }
 
 func parseURLWithDefaultPort(rawUrl string) (*url.URL, error) {
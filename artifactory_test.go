@@ -2,6 +2,7 @@ package artifactory
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"testing"
 	"time"
@@ -158,9 +159,10 @@ func TestBackend_CreateTokenUnauthorized(t *testing.T) {
 		Storage:   config.StorageView,
 	})
 
-	// Make sure we get the error.
-	assert.Nil(t, resp)
-	assert.Error(t, err)
+	// Make sure we get the error, with the hint to replace the admin token
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "("+remediationRotateAdminToken.String()+")")
 }
 
 // Test that an error is returned when the nginx in front of Artifactory can't reach Artifactory.
@@ -212,8 +214,8 @@ func TestBackend_CreateTokenArtifactoryMisconfigured(t *testing.T) {
 	})
 
 	// Make sure we get the error.
-	assert.Nil(t, resp)
-	assert.Error(t, err)
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
 }
 
 func TestBackend_RevokeToken(t *testing.T) {
@@ -303,19 +305,8 @@ func TestBackend_RotateAdminToken(t *testing.T) {
 	// Valid jwt Access Token
 	// TokenID: 84c0626b-7973-40c9-9d37-701aaf73cfb4
 	b, config := configuredBackend(t, map[string]interface{}{
-		"access_token": `eyJ2ZXIiOiIyIiwidHlwIjoiSldUIiwiYWxnIjoiUlMyNTYiLCJraW` +
-			`QiOiJkMUxJUFRHbmY0RHZzQ2k0MzhodU9KdWN3bi1lSTBHc0lVR2g0bGhhdE53In0.eyJ` +
-			`zdWIiOiJqZmFjQDAxaDQyNGh2d3B5dHprMWF6eGg2azgwN2U1L3VzZXJzL2FkbWluIiwi` +
-			`c2NwIjoiYXBwbGllZC1wZXJtaXNzaW9ucy9hZG1pbiIsImF1ZCI6IipAKiIsImlzcyI6I` +
-			`mpmZmVAMDFoNDI0aHZ3cHl0emsxYXp4aDZrODA3ZTUiLCJleHAiOjE3NTMyOTM5OTMsIm` +
-			`lhdCI6MTY5MDIyMTk5MywianRpIjoiODRjMDYyNmItNzk3My00MGM5LTlkMzctNzAxYWF` +
-			`mNzNjZmI0In0.VXoZR--oQLRTqTLx3Ogz1UUrzT9hlihWQ8m_JgOucZEYwIjGa2P58wUW` +
-			`vUAxonkiqyvmFfEk8H1vyiaBQ0F9vQ7v16d3D3nfEDW71g09M3NnsKu065-pbjPRGUmSi` +
-			`SvV0WC3Gla5Ui31IA_vVhyc-kPDzoWpHwBWgOMWkJwP0ZrvQ5bwzKrwNQi6YB0SIX2eSH` +
-			`RpReef19W_4BpOUrqMrcDamB3mskwxcYFUMA45FRoV_JVxZsIMOyNNfDlNy01r5bA6ZcY` +
-			`EaseaQpU7skMCW07rUiWq4Z6U0xZEduKPlowJm9xbrBM13FEQTG4b4mW7yyOD4gqQ49wD` +
-			`GGXvhLVFoQ`,
-		"url": "http://myserver.com:80/artifactory",
+		"access_token": adminJWTAccessToken,
+		"url":          "http://myserver.com:80/artifactory",
 	})
 
 	resp, err := b.HandleRequest(context.Background(), &logical.Request{
@@ -326,3 +317,343 @@ func TestBackend_RotateAdminToken(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Nil(t, resp)
 }
+
+// Deployments configured for opaque tokens only return a reference token, which must be surfaced as the access token.
+func TestBackend_CreateTokenReferenceOnly(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, `{
+			"token_id": "4c2d5a8e-0b1f-4d7e-9e3c-2f0b6d1a9c77",
+			"reference_token": "cmVmdGtuOjAxOjE3MDAwMDAwMDA6b3BhcXVl",
+			"expires_in": 0,
+			"scope": "test-scope",
+			"token_type": "Bearer"
+		}`))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.EqualValues(t, "cmVmdGtuOjAxOjE3MDAwMDAwMDA6b3BhcXVl", resp.Data["access_token"])
+	assert.EqualValues(t, "cmVmdGtuOjAxOjE3MDAwMDAwMDA6b3BhcXVl", resp.Data["reference_token"])
+	assert.Len(t, resp.Warnings, 1)
+}
+
+// Identity roles must request the user's own permissions with a reference token, and return the reference token.
+func TestBackend_CreateIdentityToken(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.55.6", "revision" : "75506900"}`)
+
+	var request CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/access/api/v1/tokens",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, `{
+				"token_id": "4c2d5a8e-0b1f-4d7e-9e3c-2f0b6d1a9c77",
+				"access_token": "eyXsdgbtybbeeyh...",
+				"reference_token": "cmVmdGtuOjAxOjE3MDAwMDAwMDA6b3BhcXVl",
+				"expires_in": 0,
+				"scope": "applied-permissions/user",
+				"token_type": "Bearer"
+			}`), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/developer",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":   "test-username",
+			"token_type": "identity",
+			"scope":      "applied-permissions/admin",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/developer",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":   "test-username",
+			"token_type": "identity",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/developer",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "applied-permissions/user", request.Scope)
+	assert.True(t, request.IncludeReferenceToken)
+	assert.EqualValues(t, "cmVmdGtuOjAxOjE3MDAwMDAwMDA6b3BhcXVl", resp.Data["access_token"])
+	assert.Equal(t, "identity", resp.Data["token_type"])
+}
+
+// A response without any usable token must be an error rather than an empty credential.
+func TestBackend_CreateTokenEmptyResponse(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, `{"expires_in": 0, "scope": "test-scope"}`))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.Nil(t, resp)
+	assert.ErrorContains(t, err, "did not include an access or reference token")
+}
+
+// With auth_header=x-jfrog-art-api, the admin token must be sent in the X-JFrog-Art-Api header instead of as a Bearer token.
+func TestBackend_AuthHeaderArtApi(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("X-JFrog-Art-Api") != "test-api-key" || req.Header.Get("Authorization") != "" {
+				return httpmock.NewStringResponse(401, ""), nil
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-api-key",
+		"url":          "http://myserver.com:80/artifactory",
+		"auth_header":  "x-jfrog-art-api",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.False(t, resp.IsError())
+}
+
+func TestBackend_TokenRevocable(t *testing.T) {
+	assert.True(t, tokenRevocable(CreateTokenRequest{}), "non-expiring tokens are persisted")
+	assert.True(t, tokenRevocable(CreateTokenRequest{ExpiresIn: 600, ForceRevocable: true}))
+	assert.True(t, tokenRevocable(CreateTokenRequest{ExpiresIn: 21600}))
+	assert.False(t, tokenRevocable(CreateTokenRequest{ExpiresIn: 600}), "short-lived tokens are below the revocable threshold")
+}
+
+func TestBackend_SendUsageOptIn(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var reported []string
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/system/usage",
+		func(req *http.Request) (*http.Response, error) {
+			var usage Usage
+			if err := json.NewDecoder(req.Body).Decode(&usage); err != nil {
+				return nil, err
+			}
+			reported = append(reported, usage.ProductId)
+			return httpmock.NewStringResponse(200, ""), nil
+		})
+
+	b, _ := makeBackend(t)
+
+	config := adminConfiguration{
+		AccessToken:    "test-access-token",
+		ArtifactoryURL: "http://myserver.com:80",
+	}
+	b.InitializeHttpClient(&config)
+
+	b.sendUsage(config, "test")
+	assert.Empty(t, reported, "usage must not be reported unless opted in")
+
+	config.UsageReporting = true
+	b.sendUsage(config, "test")
+
+	config.UsageProductID = "acme-vault"
+	b.sendUsage(config, "test")
+
+	config.OfflineMode = true
+	b.sendUsage(config, "test")
+
+	assert.Equal(t, []string{productId, "acme-vault"}, reported)
+}
+
+// With user_agent_attribution, calls to Artifactory must name the mount and cluster in the User-Agent.
+func TestBackend_UserAgentAttribution(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var userAgents []string
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/artifactory/api/system/ping",
+		func(req *http.Request) (*http.Response, error) {
+			userAgents = append(userAgents, req.Header.Get("User-Agent"))
+			return httpmock.NewStringResponse(200, "OK"), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.ReadOperation,
+		Path:       "config/admin",
+		Storage:    config.StorageView,
+		MountPoint: "artifactory-prod/",
+	})
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		attribution bool
+		clusterName string
+	}{
+		{false, ""},
+		{true, ""},
+		{true, "vault-prod"},
+	} {
+		adminConfig.UserAgentAttribution = tc.attribution
+		adminConfig.ClusterName = tc.clusterName
+		resp, err := b.performArtifactoryGet(*adminConfig, "/artifactory/api/system/ping")
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// The test system view has no cluster id
+	assert.Equal(t, []string{
+		productId,
+		productId + " (mount=artifactory-prod/)",
+		productId + " (mount=artifactory-prod/; cluster=vault-prod)",
+	}, userAgents)
+}
+
+func TestBackend_CreateTokenProjectKey(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/access/api/v1/tokens",
+		func(req *http.Request) (*http.Response, error) {
+			var request CreateTokenRequest
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			if request.ProjectKey != "payments" {
+				return httpmock.NewStringResponse(400, `{"detail": "missing project_key"}`), nil
+			}
+			return httpmock.NewStringResponse(200, jwtAccessToken), nil
+		})
+
+	b, _ := makeBackend(t)
+
+	config := adminConfiguration{
+		AccessToken:    "test-access-token",
+		ArtifactoryURL: "http://myserver.com:80",
+	}
+	b.InitializeHttpClient(&config)
+
+	role := artifactoryRole{
+		Username:   "test-username",
+		Scope:      "applied-permissions/roles:payments:developer",
+		ProjectKey: "payments",
+	}
+
+	b.setVersion("7.55.6")
+	_, err := b.CreateToken(context.Background(), config, role)
+	assert.NoError(t, err)
+
+	b.setVersion("7.19.10")
+	_, err = b.CreateToken(context.Background(), config, role)
+	assert.ErrorContains(t, err, "project_key requires")
+}
@@ -2,15 +2,23 @@ package artifactory
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/template"
 	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/net/http/httpproxy"
 )
 
 var Version = "v1.0.0"
@@ -20,15 +28,81 @@ type backend struct {
 	*framework.Backend
 	configMutex      sync.RWMutex
 	rolesMutex       sync.RWMutex
-	httpClient       *http.Client
-	usernameProducer template.StringTemplate
-	version          string
+	issuanceLogMutex sync.Mutex
+	changelogMutex   sync.Mutex
+	faultInjection   *faultInjectionConfiguration
+	healthMutex      sync.Mutex
+	healthCheckedURL string
+	healthCheckedAt  time.Time
+	healthErr        error
+	rootCertMutex    sync.Mutex
+	rootCertFetched  bool
+	rootCert         *x509.Certificate
+	rootCertErr      error
+
+	lastRevocationSync         time.Time
+	lastTrackedTokenCompaction time.Time
+	lastMaxLeaseTTL            time.Duration
+
+	// configGeneration is the generation of config/admin the http client, version and username template were set up
+	// from, and configStale is set when Vault invalidates config/admin. Issuance sets them up again when either shows
+	// they are stale, e.g. on a node that missed a config write.
+	configGenerationMutex sync.Mutex
+	configGeneration      uint64
+	configStale           bool
+
+	// expiryWarnedFor is the hash of the admin token an expiry warning was last sent for, so it is sent once per token
+	expiryWarnedFor string
+
+	deprecationMutex sync.Mutex
+	deprecationUsage map[string]int64
+
+	tokenRequestsMutex sync.Mutex
+
+	// entityLimitMutex is held from checking max_tokens_per_entity until the new token is tracked, so concurrent
+	// requests of an entity can't all pass the check
+	entityLimitMutex sync.Mutex
+
+	// groupTokenMutex is held while the current token of a group role is handed out, replaced or rotated
+	groupTokenMutex sync.Mutex
+
+	latency latencyRecorder
+
+	failover failoverState
+
+	userAgentMutex sync.Mutex
+	mountPoint     string
+	clusterID      string
+
+	// envConfig is the config set by JFROG_URL and JFROG_ACCESS_TOKEN when the backend was set up on a mount with the
+	// env_bootstrap option, used while no config/admin is stored
+	envConfig *adminConfiguration
+
+	// setup is the http client, version and username template set up from config/admin. It is replaced as a whole,
+	// under setupMutex, and read without a lock, so a request never sees a client, version and template that don't
+	// belong together.
+	setupMutex sync.Mutex
+	setup      atomic.Pointer[configSetup]
+
+	// backendUUID and storageView are the uuid and storage of the backend's mount, for mounts referencing its config
+	backendUUID string
+	storageView logical.Storage
+}
+
+// configSetup is what calls to Artifactory are made with, set up from config/admin. It is never modified once stored.
+type configSetup struct {
+	httpClient *http.Client
+	// httpClientFingerprint is the transportFingerprint of the config httpClient was built from
+	httpClientFingerprint string
+	version               string
+	usernameProducer      template.StringTemplate
 }
 
 // UsernameMetadata defines the metadata that a user_template can use to dynamically create user account in Artifactory
 type UsernameMetadata struct {
 	DisplayName string
 	RoleName    string
+	AppName     string
 }
 
 // Factory configures and returns Artifactory secrets backends.
@@ -42,50 +116,144 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 		return nil, err
 	}
 
-	if err := b.Backend.Setup(ctx, conf); err != nil {
+	if err := b.Setup(ctx, conf); err != nil {
 		return nil, err
 	}
 
 	return b, nil
 }
 
-func Backend(_ *logical.BackendConfig) (*backend, error) {
+// Setup sets up the framework backend, and only once that succeeded lets other mounts find this one's config/admin.
+// A mount whose setup failed is never served, so it must not stay registered, even from an earlier setup.
+func (b *backend) Setup(ctx context.Context, conf *logical.BackendConfig) error {
+	if err := b.Backend.Setup(ctx, conf); err != nil {
+		b.clean(ctx)
+		return err
+	}
+
+	b.registerSharedConfigOwner(conf)
+
+	return nil
+}
+
+func Backend(conf *logical.BackendConfig) (*backend, error) {
 	b := &backend{}
 
+	if envBootstrapEnabled(conf) {
+		b.envConfig = envConfiguration()
+	}
+
 	up, err := testUsernameTemplate(defaultUserNameTemplate)
 	if err != nil {
 		return nil, err
 	}
-	b.usernameProducer = up
+	b.setup.Store(&configSetup{usernameProducer: up})
 
 	b.Backend = &framework.Backend{
 		Help:           strings.TrimSpace(artifactoryHelp),
 		RunningVersion: Version,
 
 		PathsSpecial: &logical.Paths{
-			SealWrapStorage: []string{"config/admin"},
+			SealWrapStorage: []string{"config/admin", configVersionsStoragePrefix, revocationQueueStoragePrefix, tokenChildrenStoragePrefix, tokenRequestsStoragePrefix, groupTokenStoragePrefix},
 		},
 
 		BackendType:    logical.TypeLogical,
 		InitializeFunc: b.initialize,
 		Invalidate:     b.invalidate,
+		Clean:          b.clean,
+		PeriodicFunc:   b.periodicFunc,
 	}
 	b.Backend.Secrets = append(b.Backend.Secrets, b.secretAccessToken())
 	b.Backend.Paths = append(b.Backend.Paths,
 		b.pathListRoles(),
 		b.pathRoles(),
+		b.pathTokenMulti(),
 		b.pathTokenCreate(),
+		b.pathTokenSimulate(),
+		b.pathHelpersTemplate(),
+		b.pathTokenRequests(),
+		b.pathDelegate(),
 		b.pathUserTokenCreate(),
+		b.pathListTokens(),
+		b.pathListRoleSecrets(),
+		b.pathAnalyzeRoles(),
+		b.pathMigrateReport(),
+		b.pathLogIssuance(),
+		b.pathLogChangelog(),
+		b.pathStats(),
+		b.pathListConfig(),
 		b.pathConfig(),
+		b.pathConfigSummary(),
+		b.pathConfigCredentials(),
+		b.pathListConfigVersions(),
+		b.pathConfigRollback(),
+		b.pathListNamedConfigs(),
+		b.pathNamedConfig(),
 		b.pathConfigRotate(),
-		b.pathConfigUserToken())
+		b.pathConfigUserToken(),
+		b.pathConfigState(),
+		b.pathConfigFaultInjection())
 
 	return b, nil
 }
 
+// HandleRequest converts duration strings in the request to seconds before the framework parses it, so every
+// duration field accepts the formats parseDuration does, not only those TypeDurationSecond understands. Requests
+// using deprecated parameters or paths get a warning, or fail if the config rejects them. Errors with a remediation
+// hint are returned as error responses carrying it. The mount point is kept for the User-Agent of calls to Artifactory.
+func (b *backend) HandleRequest(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+	if len(req.MountPoint) > 0 {
+		b.userAgentMutex.Lock()
+		b.mountPoint = req.MountPoint
+		b.userAgentMutex.Unlock()
+	}
+
+	if err := b.normalizeDurations(req); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	deprecations := b.deprecations(req)
+	if len(deprecations) > 0 {
+		resp, err := b.handleDeprecations(ctx, req, deprecations)
+		if resp != nil || err != nil {
+			return resp, err
+		}
+	}
+
+	resp, err := b.Backend.HandleRequest(ctx, req)
+	if err != nil && resp == nil {
+		if errResp := remediationErrorResponse(err); errResp != nil {
+			resp, err = errResp, nil
+		}
+	}
+	if err != nil || len(deprecations) == 0 {
+		return resp, err
+	}
+
+	if resp == nil {
+		resp = &logical.Response{}
+	}
+	for _, d := range deprecations {
+		resp.AddWarning(d.message)
+	}
+
+	return resp, nil
+}
+
 // initialize will initialize the backend configuration
 func (b *backend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	faultInjection, err := b.fetchFaultInjectionConfiguration(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	b.faultInjection = faultInjection
+
 	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if errors.Is(err, errSharedConfigNotLoaded) {
+		// The config is set up on first use instead, as a stale one
+		b.Logger().Warn("the mount of the shared config isn't loaded yet", "err", err)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -94,55 +262,382 @@ func (b *backend) initialize(ctx context.Context, req *logical.InitializationReq
 		return nil
 	}
 
-	b.InitializeHttpClient(config)
-
-	err = b.getVersion(*config)
+	setup, err := b.newConfigSetup(*config)
 	if err != nil {
 		return err
 	}
+	b.storeSetup(func(s *configSetup) { *s = *setup })
 
-	if len(config.UsernameTemplate) != 0 {
-		up, err := testUsernameTemplate(config.UsernameTemplate)
-		if err != nil {
-			return err
-		}
-		b.usernameProducer = up
+	b.setConfigGeneration(config.Generation)
+
+	return nil
+}
+
+// nextConfigGeneration returns the generation to store config/admin with. It is at least the current time, so it also
+// changes when the config is deleted and written again.
+func nextConfigGeneration(current uint64) uint64 {
+	next := uint64(time.Now().UnixNano())
+	if next <= current {
+		next = current + 1
+	}
+	return next
+}
+
+// setConfigGeneration records that the in-memory state was set up from generation of config/admin
+func (b *backend) setConfigGeneration(generation uint64) {
+	b.configGenerationMutex.Lock()
+	defer b.configGenerationMutex.Unlock()
+
+	b.configGeneration = generation
+	b.configStale = false
+}
+
+// refreshStaleConfiguration sets up the http client, version and username template again if they were set up from
+// another generation of config than the one read from storage, so a node with a stale copy doesn't issue tokens with
+// outdated settings. Config and roles themselves are read from storage for every request.
+func (b *backend) refreshStaleConfiguration(config adminConfiguration) error {
+	b.configGenerationMutex.Lock()
+	defer b.configGenerationMutex.Unlock()
+
+	if !b.configStale && b.configGeneration == config.Generation {
+		return nil
+	}
+
+	b.Logger().Warn("in-memory config is stale, setting it up again", "generation", b.configGeneration, "storedGeneration", config.Generation)
+
+	setup, err := b.newConfigSetup(config)
+	if err != nil {
+		return err
 	}
+	b.storeSetup(func(s *configSetup) { *s = *setup })
+	b.resetRootCert()
+
+	b.configGeneration = config.Generation
+	b.configStale = false
 
 	return nil
 }
 
+// currentSetup returns the http client, version and username template calls to Artifactory are made with
+func (b *backend) currentSetup() *configSetup {
+	return b.setup.Load()
+}
+
+// storeSetup replaces the setup with a copy of the current one changed by update
+func (b *backend) storeSetup(update func(s *configSetup)) {
+	b.setupMutex.Lock()
+	defer b.setupMutex.Unlock()
+
+	setup := *b.setup.Load()
+	update(&setup)
+	b.setup.Store(&setup)
+}
+
+// newConfigSetup sets up the http client, version and username template of config without storing them, so they
+// replace the current ones at once
+func (b *backend) newConfigSetup(config adminConfiguration) (*configSetup, error) {
+	setup := &configSetup{
+		httpClient:            b.newHttpClient(&config),
+		httpClientFingerprint: config.transportFingerprint(),
+	}
+
+	version, err := b.resolveVersion(setup.httpClient, config)
+	if err != nil {
+		return nil, err
+	}
+	setup.version = version
+
+	template := config.UsernameTemplate
+	if len(template) == 0 {
+		template = defaultUserNameTemplate
+	}
+	setup.usernameProducer, err = testUsernameTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return setup, nil
+}
+
 func (b *backend) InitializeHttpClient(config *adminConfiguration) {
-	if config.BypassArtifactoryTLSVerification {
+	client := b.newHttpClient(config)
+	b.storeSetup(func(s *configSetup) {
+		s.httpClient = client
+		s.httpClientFingerprint = config.transportFingerprint()
+	})
+}
+
+// newHttpClient builds the http client for config
+func (b *backend) newHttpClient(config *adminConfiguration) *http.Client {
+	var httpClient *http.Client
+
+	tlsConfig, err := artifactoryTLSConfig(*config)
+	if err != nil {
+		// Written configs were validated, so this only happens if storage was tampered with
+		b.Logger().Error("invalid TLS settings, using the defaults", "err", err)
+	}
+
+	proxy, err := artifactoryProxy(*config)
+	if err != nil {
+		b.Logger().Error("invalid proxy settings, using the environment", "err", err)
+	}
+
+	if tlsConfig != nil || proxy != nil {
+		if proxy == nil {
+			proxy = http.ProxyFromEnvironment
+		}
 		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: config.BypassArtifactoryTLSVerification,
-			},
+			TLSClientConfig: tlsConfig,
+			Proxy:           proxy,
 		}
 
-		b.httpClient = &http.Client{Transport: tr}
+		httpClient = &http.Client{Transport: tr}
 	} else {
-		b.httpClient = http.DefaultClient
+		httpClient = http.DefaultClient
+	}
+
+	maxResponseSize := config.MaxResponseSize
+	if maxResponseSize <= 0 {
+		maxResponseSize = defaultMaxResponseSize
+	}
+	httpClient = &http.Client{
+		Transport: &responseLimitingTransport{
+			limit: maxResponseSize,
+			next:  httpClient.Transport,
+		},
+	}
+
+	if b.faultInjection != nil && b.faultInjection.Enabled {
+		b.Logger().Warn("fault injection is enabled for calls to Artifactory")
+		httpClient = &http.Client{
+			Transport: &faultInjectingTransport{
+				config: *b.faultInjection,
+				next:   httpClient.Transport,
+			},
+		}
+	}
+
+	// Latency is recorded per attempt, so retries don't hide how long Artifactory takes to respond
+	var transport http.RoundTripper = &latencyRecordingTransport{
+		recorder: &b.latency,
+		next:     httpClient.Transport,
+	}
+
+	if len(config.URLs) > 1 {
+		transport = &failoverTransport{
+			state: &b.failover,
+			urls:  config.URLs,
+			next:  transport,
+		}
 	}
+
+	return b.retryingClient(*config, transport)
+}
+
+// periodicFunc runs the backend's periodic tasks on the active node
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	if err := b.processRevocationQueue(ctx, req); err != nil {
+		return err
+	}
+
+	if err := b.checkMountTTLs(ctx, req); err != nil {
+		return err
+	}
+
+	if err := b.expireTokenRequests(ctx, req); err != nil {
+		return err
+	}
+
+	if err := b.syncRevokedTokens(ctx, req); err != nil {
+		return err
+	}
+
+	if err := b.revokeRetiredRoleTokens(ctx, req); err != nil {
+		return err
+	}
+
+	if err := b.rotateGroupTokens(ctx, req); err != nil {
+		return err
+	}
+
+	if err := b.compactTrackedTokens(ctx, req); err != nil {
+		return err
+	}
+
+	if err := b.checkAdminTokenExpiry(ctx, req); err != nil {
+		return err
+	}
+
+	return b.rotateAdminTokenIfDue(ctx, req)
+}
+
+// artifactoryTLSConfig returns the TLS settings for calls to Artifactory, or nil if the defaults apply
+func artifactoryTLSConfig(config adminConfiguration) (*tls.Config, error) {
+	if !config.BypassArtifactoryTLSVerification && (len(config.ClientCert) == 0 || len(config.ClientKey) == 0) && len(config.CACertPEM) == 0 && len(config.TLSPinnedSPKIHashes) == 0 && len(config.TLSMinVersion) == 0 && len(config.TLSCipherSuites) == 0 && !config.FIPSMode {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.BypassArtifactoryTLSVerification,
+	}
+
+	// The certificate and its key are written to different paths, so one may be set before the other
+	if len(config.ClientCert) > 0 && len(config.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair([]byte(config.ClientCert), []byte(config.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client_cert or client_key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// The bundle is trusted in addition to the system roots, for Artifactory behind an internal CA
+	if len(config.CACertPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(config.CACertPEM)) {
+			return nil, fmt.Errorf("invalid ca_cert_pem: no PEM encoded certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(config.TLSPinnedSPKIHashes) > 0 {
+		if config.BypassArtifactoryTLSVerification || len(config.CACertPEM) > 0 {
+			return nil, fmt.Errorf("tls_pinned_spki_hashes replaces CA verification, so it can't be combined with bypass_artifactory_tls_verification or ca_cert_pem")
+		}
+
+		pins, err := parseSPKIPins(config.TLSPinnedSPKIHashes)
+		if err != nil {
+			return nil, err
+		}
+
+		// Chains are verified by pin instead of by CA, so the default verification is replaced by VerifyConnection
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifySPKIPins(cs, pins)
+		}
+	}
+
+	if err := applyTLSVersionSettings(tlsConfig, config); err != nil {
+		return nil, err
+	}
+
+	if config.FIPSMode {
+		if err := applyFIPSTLSConfig(tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseSPKIPins decodes base64 sha256 hashes of SubjectPublicKeyInfo, optionally prefixed with "sha256/" as curl's
+// --pinnedpubkey takes them
+func parseSPKIPins(hashes []string) (map[[sha256.Size]byte]bool, error) {
+	pins := make(map[[sha256.Size]byte]bool, len(hashes))
+	for _, hash := range hashes {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(hash), "sha256/"))
+		if err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid tls_pinned_spki_hashes entry '%s': must be a base64 encoded sha256 hash", hash)
+		}
+		pins[[sha256.Size]byte(decoded)] = true
+	}
+	return pins, nil
+}
+
+// verifySPKIPins accepts a connection if the leaf certificate is valid for the server name, and it or a certificate of
+// the chain Artifactory presented has a pinned public key
+func verifySPKIPins(cs tls.ConnectionState, pins map[[sha256.Size]byte]bool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("artifactory presented no certificate")
+	}
+
+	leaf := cs.PeerCertificates[0]
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return fmt.Errorf("certificate of artifactory is not valid at %s", now.UTC().Format(time.RFC3339))
+	}
+
+	if len(cs.ServerName) > 0 {
+		if err := leaf.VerifyHostname(cs.ServerName); err != nil {
+			return err
+		}
+	}
+
+	for i, cert := range cs.PeerCertificates {
+		// Each certificate must be signed by the next, so a pinned intermediate vouches for the leaf
+		if i > 0 {
+			if err := cs.PeerCertificates[i-1].CheckSignatureFrom(cert); err != nil {
+				return fmt.Errorf("certificate chain of artifactory is broken: %w", err)
+			}
+		}
+		if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no certificate presented by artifactory matches tls_pinned_spki_hashes")
+}
+
+// artifactoryProxy returns how calls to Artifactory pick their proxy if config overrides any of the proxy environment
+// variables, or nil to use the environment as is
+func artifactoryProxy(config adminConfiguration) (func(*http.Request) (*url.URL, error), error) {
+	if len(config.HTTPProxy) == 0 && len(config.HTTPSProxy) == 0 && len(config.NoProxy) == 0 {
+		return nil, nil
+	}
+
+	proxyConfig := httpproxy.FromEnvironment()
+
+	for name, proxyURL := range map[string]string{"http_proxy": config.HTTPProxy, "https_proxy": config.HTTPSProxy} {
+		if len(proxyURL) == 0 {
+			continue
+		}
+		if _, err := url.Parse(proxyURL); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	if len(config.HTTPProxy) > 0 {
+		proxyConfig.HTTPProxy = config.HTTPProxy
+	}
+	if len(config.HTTPSProxy) > 0 {
+		proxyConfig.HTTPSProxy = config.HTTPSProxy
+	}
+	if len(config.NoProxy) > 0 {
+		proxyConfig.NoProxy = config.NoProxy
+	}
+
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+// redactProxyURL hides the password of a proxy URL for config reads
+func redactProxyURL(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return proxyURL
+	}
+	return u.Redacted()
 }
 
 // invalidate clears an existing client configuration in
 // the backend
 func (b *backend) invalidate(ctx context.Context, key string) {
-	if key == "config" {
-		b.reset()
+	switch key {
+	case "config/admin":
+		b.configGenerationMutex.Lock()
+		defer b.configGenerationMutex.Unlock()
+		b.configStale = true
+	case "config/fault_injection":
+		b.reloadFaultInjection(ctx)
 	}
 }
 
-// reset clears any client configuration for a new
-// backend to be configured
-func (b *backend) reset() {
-	b.configMutex.Lock()
-	defer b.configMutex.Unlock()
-	b.httpClient = nil
-}
-
-// fetchAdminConfiguration will return nil,nil if there's no configuration
+// fetchAdminConfiguration will return nil,nil if there's no configuration. Without a stored config, the one set by the
+// environment is returned, if any. A config referencing another mount's with shared_config returns that one.
 func (b *backend) fetchAdminConfiguration(ctx context.Context, storage logical.Storage) (*adminConfiguration, error) {
 	var config adminConfiguration
 
@@ -153,13 +648,17 @@ func (b *backend) fetchAdminConfiguration(ctx context.Context, storage logical.S
 	}
 
 	if entry == nil {
-		return nil, nil
+		return b.envAdminConfiguration(), nil
 	}
 
 	if err := entry.DecodeJSON(&config); err != nil {
 		return nil, err
 	}
 
+	if config.SharedConfig != "" {
+		return b.resolveSharedConfiguration(ctx, config.SharedConfig)
+	}
+
 	return &config, nil
 }
 
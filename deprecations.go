@@ -0,0 +1,95 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// deprecation is a use of a deprecated parameter or operation by a request
+type deprecation struct {
+	// key identifies what is deprecated in the usage counts, e.g. "config/admin:access_token"
+	key     string
+	message string
+}
+
+// deprecations returns the deprecated parameters and operations req uses, as marked by Deprecated on their field
+// schema or operation. The notice after "Deprecated." in a field description tells users what to use instead.
+func (b *backend) deprecations(req *logical.Request) []deprecation {
+	path := b.Route(req.Path)
+	if path == nil {
+		return nil
+	}
+
+	var found []deprecation
+
+	if op, ok := path.Operations[req.Operation]; ok && op.Properties().Deprecated {
+		found = append(found, deprecation{
+			key:     req.Path,
+			message: strings.TrimSpace(fmt.Sprintf("%s on %s is deprecated. %s", req.Operation, req.Path, op.Properties().Description)),
+		})
+	}
+
+	for name := range req.Data {
+		schema, ok := path.Fields[name]
+		if !ok || !schema.Deprecated {
+			continue
+		}
+
+		notice := strings.TrimSpace(strings.TrimPrefix(schema.Description, "Deprecated."))
+		found = append(found, deprecation{
+			key:     req.Path + ":" + name,
+			message: strings.TrimSpace(fmt.Sprintf("parameter '%s' of %s is deprecated. %s", name, req.Path, notice)),
+		})
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].key < found[j].key })
+
+	return found
+}
+
+// handleDeprecations counts and logs the deprecations a request uses, and returns an error response if the config
+// rejects them
+func (b *backend) handleDeprecations(ctx context.Context, req *logical.Request, found []deprecation) (*logical.Response, error) {
+	b.deprecationMutex.Lock()
+	if b.deprecationUsage == nil {
+		b.deprecationUsage = map[string]int64{}
+	}
+	for _, d := range found {
+		b.deprecationUsage[d.key]++
+	}
+	b.deprecationMutex.Unlock()
+
+	messages := make([]string, 0, len(found))
+	for _, d := range found {
+		b.Logger().Warn(d.message)
+		messages = append(messages, d.message)
+	}
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config != nil && config.RejectDeprecated {
+		return logical.ErrorResponse("%s Deprecated parameters and paths are rejected because reject_deprecated is set on config/admin.", strings.Join(messages, " ")), nil
+	}
+
+	return nil, nil
+}
+
+// deprecationUsageCounts returns how often each deprecated parameter and operation was used since the backend started
+func (b *backend) deprecationUsageCounts() map[string]int64 {
+	b.deprecationMutex.Lock()
+	defer b.deprecationMutex.Unlock()
+
+	counts := make(map[string]int64, len(b.deprecationUsage))
+	for key, count := range b.deprecationUsage {
+		counts[key] = count
+	}
+
+	return counts
+}
@@ -0,0 +1,64 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Deprecated parameters must keep working with a warning and be counted, until reject_deprecated makes them fail.
+func TestBackend_Deprecations(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := makeBackend(t)
+
+	configData := map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      configData,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, []string{
+		"parameter 'access_token' of config/admin is deprecated. Write the administrator token to config/admin/credentials instead.",
+	}, resp.Warnings)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"reject_deprecated": true},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      configData,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "reject_deprecated")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "stats",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"config/admin:access_token": 2}, resp.Data["deprecated_usage"])
+}
@@ -0,0 +1,100 @@
+package artifactory
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// dryRunCheckOK is the result of a check of a dry run that passed
+const dryRunCheckOK = "ok"
+
+// dryRunAdminConfiguration runs every check against config that writing it would, and the preflight check whether or
+// not preflight_check is set, without saving it. The checks run on a backend of their own, with an http client and
+// Artifactory version set up from config, so issuance isn't affected. The response lists the result of each check,
+// and "valid" is set if they all passed.
+func (b *backend) dryRunAdminConfiguration(ctx context.Context, config *adminConfiguration) (*logical.Response, error) {
+	setup := &configSetup{
+		httpClient:            b.newHttpClient(config),
+		httpClientFingerprint: config.transportFingerprint(),
+	}
+
+	// The username template, TLS and proxy settings were validated when the request was parsed
+	checks := map[string]interface{}{
+		"username_template": dryRunCheckOK,
+		"tls":               dryRunCheckOK,
+	}
+
+	version, err := b.resolveVersion(setup.httpClient, *config)
+	if err != nil {
+		checks["version"] = err.Error()
+	} else {
+		checks["version"] = dryRunCheckOK
+		setup.version = version
+	}
+
+	probe := b.dryRunBackend(setup)
+
+	switch {
+	case checks["version"] != dryRunCheckOK:
+		checks["access"] = "skipped, the version check failed"
+	case config.OfflineMode:
+		checks["access"] = "skipped, offline_mode is set"
+	case !probe.useNewAccessAPI():
+		checks["access"] = "skipped, Artifactory " + setup.version + " doesn't use the Access token API"
+	default:
+		if err := probe.checkAccessReachable(*config); err != nil {
+			checks["access"] = err.Error()
+		} else {
+			checks["access"] = dryRunCheckOK
+		}
+	}
+
+	if checks["version"] != dryRunCheckOK {
+		checks["token_management"] = "skipped, the version check failed"
+	} else if missing := probe.preflightCheck(ctx, *config); len(missing) > 0 {
+		checks["token_management"] = "the admin token can't: " + strings.Join(missing, "; ")
+	} else {
+		checks["token_management"] = dryRunCheckOK
+	}
+
+	valid := true
+	for _, result := range checks {
+		if result != dryRunCheckOK && !strings.HasPrefix(result.(string), "skipped") {
+			valid = false
+		}
+	}
+
+	response := &logical.Response{
+		Data: map[string]interface{}{
+			"dry_run": true,
+			"valid":   valid,
+			"checks":  checks,
+		},
+	}
+
+	if checks["version"] == dryRunCheckOK {
+		response.Data["artifactory_version"] = setup.version
+	}
+
+	return response, nil
+}
+
+// dryRunBackend returns a backend that calls Artifactory with setup and shares no state with b but its logger, fault
+// injection and User-Agent, so the checks of a dry run don't change the http client, version, root certificate,
+// latency or failover state issuance uses
+func (b *backend) dryRunBackend(setup *configSetup) *backend {
+	probe := &backend{
+		Backend:        b.Backend,
+		faultInjection: b.faultInjection,
+	}
+
+	b.userAgentMutex.Lock()
+	probe.mountPoint, probe.clusterID = b.mountPoint, b.clusterID
+	b.userAgentMutex.Unlock()
+
+	probe.setup.Store(setup)
+
+	return probe
+}
@@ -0,0 +1,82 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// A dry run must report the result of each check against the proposed config, without saving it or changing the
+// version and http client issuance uses, not even for the duration of the checks.
+func TestBackend_PathConfigDryRun(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+	setup := b.currentSetup()
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://other.example.org:80/artifactory/api/system/version",
+		httpmock.NewStringResponder(200, `{"version" : "7.11.0", "revision" : "71100900"}`))
+
+	dryRun := func() *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/admin",
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"url":          "http://other.example.org:80/artifactory",
+				"access_token": "new-access-token",
+				"dry_run":      true,
+			},
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		return resp
+	}
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://other.example.org:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(http.StatusForbidden, `{"detail": "forbidden"}`))
+
+	resp := dryRun()
+	assert.Equal(t, false, resp.Data["valid"])
+	checks := resp.Data["checks"].(map[string]interface{})
+	assert.Equal(t, dryRunCheckOK, checks["version"])
+	assert.Contains(t, checks["token_management"], "create access tokens")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://other.example.org:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(http.StatusOK, canonicalAccessToken))
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://other.example.org:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(http.StatusOK, ""))
+
+	resp = dryRun()
+	assert.Equal(t, true, resp.Data["valid"])
+	assert.Equal(t, "7.11.0", resp.Data["artifactory_version"])
+	checks = resp.Data["checks"].(map[string]interface{})
+	assert.Equal(t, dryRunCheckOK, checks["token_management"])
+	assert.Contains(t, checks["access"], "skipped")
+
+	// Nothing was saved, and issuance still uses the current config
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://myserver.com:80/artifactory", adminConfig.ArtifactoryURL)
+	assert.Equal(t, "test-access-token", adminConfig.AccessToken)
+	assert.Same(t, setup, b.currentSetup())
+	assert.Equal(t, "7.19.10", b.artifactoryVersion())
+}
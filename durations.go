@@ -0,0 +1,82 @@
+package artifactory
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// extendedDurationUnits matches the week and day components of a duration such as "1w2d12h", which time.ParseDuration
+// doesn't understand
+var extendedDurationUnits = regexp.MustCompile(`(\d+)([wd])`)
+
+// parseDuration parses a duration given as seconds ("3600"), as a Go duration ("90m", "1h30m"), or as a Go duration
+// extended with days and weeks ("7d", "1w", "1w2d12h")
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	var extended time.Duration
+	rest := extendedDurationUnits.ReplaceAllStringFunc(s, func(match string) string {
+		value, _ := strconv.ParseInt(match[:len(match)-1], 10, 64)
+		unit := 24 * time.Hour
+		if strings.HasSuffix(match, "w") {
+			unit *= 7
+		}
+		extended += time.Duration(value) * unit
+		return ""
+	})
+
+	if rest == "" {
+		return extended, nil
+	}
+
+	d, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: use seconds or a duration such as 90m, 12h, 7d or 1w", s)
+	}
+
+	return extended + d, nil
+}
+
+func (b *backend) normalizeDurations(req *logical.Request) error {
+	if len(req.Data) == 0 {
+		return nil
+	}
+
+	path := b.Route(req.Path)
+	if path == nil {
+		return nil
+	}
+
+	for name, schema := range path.Fields {
+		if schema.Type != framework.TypeDurationSecond {
+			continue
+		}
+
+		raw, ok := req.Data[name].(string)
+		if !ok {
+			continue
+		}
+
+		d, err := parseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		req.Data[name] = int(d / time.Second)
+	}
+
+	return nil
+}
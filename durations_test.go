@@ -0,0 +1,78 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":        0,
+		"3600":    time.Hour,
+		"90m":     90 * time.Minute,
+		"1h30m":   90 * time.Minute,
+		"12h":     12 * time.Hour,
+		"7d":      7 * 24 * time.Hour,
+		"1w":      7 * 24 * time.Hour,
+		"1w2d12h": 9*24*time.Hour + 12*time.Hour,
+	}
+
+	for input, expected := range tests {
+		actual, err := parseDuration(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	for _, input := range []string{"soon", "1.5d", "1y"} {
+		_, err := parseDuration(input)
+		assert.Error(t, err, input)
+	}
+}
+
+// Duration fields must accept day and week units.
+func TestBackend_ExtendedDurations(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":       "test-scope",
+			"default_ttl": "1d12h",
+			"max_ttl":     "1w",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	role, err := b.Role(context.Background(), config.StorageView, "test-role")
+	assert.NoError(t, err)
+	assert.Equal(t, 36*time.Hour, role.DefaultTTL)
+	assert.Equal(t, 7*24*time.Hour, role.MaxTTL)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"max_ttl": "a fortnight",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "max_ttl")
+}
@@ -0,0 +1,84 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// entityRoleTokens returns the tracking ids of the active tracked tokens an entity holds from a role, oldest first
+func (b *backend) entityRoleTokens(ctx context.Context, storage logical.Storage, roleName string, entityID string) ([]string, map[string]*trackedToken, error) {
+	return b.activeIndexedTrackedTokens(ctx, storage, entityTokenIndexPrefix(roleName, entityID))
+}
+
+// enforceEntityLimit makes room for another token of the role for entityID under the role's max_tokens_per_entity.
+// Async token requests of the entity whose token hasn't been picked up yet count against the limit. An entity at the
+// limit is refused, unless the role sets revoke_oldest_on_entity_limit: then its oldest tokens are revoked in
+// Artifactory. Their leases outlive them, so they stay tracked, marked as revoked for the entity limit, until their
+// leases are revoked. The caller holds entityLimitMutex until the new token is tracked, or its request is pending.
+func (b *backend) enforceEntityLimit(ctx context.Context, storage logical.Storage, config adminConfiguration, roleName string, role artifactoryRole, entityID string) error {
+	ids, tokens, err := b.entityRoleTokens(ctx, storage, roleName, entityID)
+	if err != nil {
+		return err
+	}
+
+	pending, err := storage.List(ctx, pendingTokenRequestIndexPrefix(roleName, entityID))
+	if err != nil {
+		return err
+	}
+
+	held := len(ids) + len(pending)
+	excess := held - role.MaxTokensPerEntity + 1
+	if excess <= 0 {
+		return nil
+	}
+
+	if !role.RevokeOldestOnEntityLimit {
+		return fmt.Errorf("entity '%s' already holds %d tokens from role '%s', its max_tokens_per_entity: revoke one first", entityID, held, roleName)
+	}
+
+	// Pending requests have no token to revoke yet
+	if excess > len(ids) {
+		return fmt.Errorf("entity '%s' already holds %d tokens from role '%s', its max_tokens_per_entity, %d of them still being issued", entityID, held, roleName, len(pending))
+	}
+
+	for _, trackingID := range ids[:excess] {
+		token := tokens[trackingID]
+
+		// Legacy Artifactory versions only revoke tokens given the token itself, which isn't tracked
+		if token.TokenID == "" || !b.useNewAccessAPI() {
+			return fmt.Errorf("entity '%s' already holds %d tokens from role '%s', its max_tokens_per_entity, and the oldest can't be revoked without its token id", entityID, len(ids), roleName)
+		}
+
+		secret := logical.Secret{InternalData: map[string]interface{}{
+			"role":        roleName,
+			"token_id":    token.TokenID,
+			"tracking_id": trackingID,
+			"config_name": token.ConfigName,
+		}}
+
+		tokenConfig, err := b.withSecret(ctx, storage, config, secret.InternalData)
+		if err == nil {
+			revokeCtx, cancel := revokeContext(ctx)
+			err = b.RevokeToken(revokeCtx, tokenConfig, secret)
+			cancel()
+		}
+		if err != nil {
+			return fmt.Errorf("could not revoke the oldest token of entity '%s' from role '%s': %w", entityID, roleName, err)
+		}
+
+		b.Logger().Info("revoked oldest token for max_tokens_per_entity", "role", roleName, "entity", entityID, "tokenId", token.TokenID)
+
+		if err := b.revokeChildren(ctx, storage, config, trackingID); err != nil {
+			return err
+		}
+
+		token.RevokedForEntityLimit = true
+		if err := b.putTrackedToken(ctx, storage, trackingID, *token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// An entity at the role's max_tokens_per_entity must be refused, or have its oldest token revoked when the role asks
+// for that. Other entities are unaffected.
+func TestBackend_MaxTokensPerEntity(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.55.6", "revision" : "75506900"}`)
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/access/api/v1/tokens",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	httpmock.RegisterResponder(
+		http.MethodDelete,
+		"http://myserver.com:80/access/api/v1/tokens/oldest-token",
+		httpmock.NewStringResponder(200, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":              "test-username",
+			"scope":                 "api:*",
+			"max_tokens_per_entity": 2,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	now := time.Now()
+	for i, tokenID := range []string{"oldest-token", "newer-token"} {
+		token := trackedToken{
+			TokenID:   tokenID,
+			Role:      "test-role",
+			Username:  "test-username",
+			EntityID:  "ci-runner",
+			IssuedAt:  now.Add(time.Duration(i-2) * time.Minute),
+			ExpiresAt: now.Add(time.Hour),
+		}
+		assert.NoError(t, b.putTrackedToken(context.Background(), config.StorageView, tokenID, token))
+		assert.NoError(t, b.indexTrackedToken(context.Background(), config.StorageView, tokenID, token))
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		EntityID:  "ci-runner",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "max_tokens_per_entity")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		EntityID:  "other-runner",
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"revoke_oldest_on_entity_limit": true,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		EntityID:  "ci-runner",
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["DELETE http://myserver.com:80/access/api/v1/tokens/oldest-token"])
+
+	oldest, err := b.fetchTrackedToken(context.Background(), config.StorageView, "oldest-token")
+	assert.NoError(t, err)
+	assert.True(t, oldest.RevokedForEntityLimit)
+
+	newer, err := b.fetchTrackedToken(context.Background(), config.StorageView, "newer-token")
+	assert.NoError(t, err)
+	assert.False(t, newer.RevokedForEntityLimit)
+}
+
+func TestBackend_MaxTokensPerEntityConflicts(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for _, data := range []map[string]interface{}{
+		{"scope": "api:*", "max_tokens_per_entity": -1},
+		{"scope": "api:*", "max_tokens_per_entity": 1, "generate_lease": false},
+		{"scope": "api:*", "revoke_oldest_on_entity_limit": true},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/test-role",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError(), "%v", data)
+	}
+}
+
+// Async token requests must count against max_tokens_per_entity from the moment they are made, not only once their
+// token is picked up.
+func TestBackend_MaxTokensPerEntityCountsPendingRequests(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":              "test-username",
+			"scope":                 "test-scope",
+			"max_tokens_per_entity": 1,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	issue := func(async bool) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/test-role",
+			Storage:   config.StorageView,
+			EntityID:  "ci-runner",
+			Data:      map[string]interface{}{"async": async},
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp = issue(true)
+	assert.False(t, resp.IsError())
+	requestID := resp.Data["request_id"].(string)
+
+	resp = issue(false)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "max_tokens_per_entity")
+
+	assert.Eventually(t, func() bool {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token-requests/" + requestID,
+			Storage:   config.StorageView,
+			EntityID:  "ci-runner",
+		})
+		return err == nil && resp.Data["status"] != tokenRequestPending
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NotNil(t, resp.Secret)
+
+	// Once picked up, the token itself counts
+	resp = issue(false)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "max_tokens_per_entity")
+}
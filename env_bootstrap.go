@@ -0,0 +1,70 @@
+package artifactory
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Environment variables that configure a mount without a stored config/admin, for dev servers and demos
+const (
+	envArtifactoryURL = "JFROG_URL"
+	envAccessToken    = "JFROG_ACCESS_TOKEN"
+)
+
+// envBootstrapOption is the mount option that opts a mount in to the environment variables, so Vault servers that
+// happen to have them set, such as those running the acceptance tests, don't configure every mount with them
+const envBootstrapOption = "env_bootstrap"
+
+// Sources of the effective config/admin, returned by config reads
+const (
+	configSourceStorage = "storage"
+	configSourceEnv     = "env"
+)
+
+// envBootstrapEnabled reports whether the mount was enabled with env_bootstrap=true
+func envBootstrapEnabled(conf *logical.BackendConfig) bool {
+	if conf == nil {
+		return false
+	}
+	enabled, _ := strconv.ParseBool(conf.Config[envBootstrapOption])
+	return enabled
+}
+
+// envConfiguration returns the config set by JFROG_URL and JFROG_ACCESS_TOKEN, or nil unless both are set
+func envConfiguration() *adminConfiguration {
+	url := strings.TrimSpace(os.Getenv(envArtifactoryURL))
+	accessToken := strings.TrimSpace(os.Getenv(envAccessToken))
+	if len(url) == 0 || len(accessToken) == 0 {
+		return nil
+	}
+
+	return &adminConfiguration{
+		ArtifactoryURL: url,
+		AccessToken:    accessToken,
+		fromEnv:        true,
+	}
+}
+
+// envAdminConfiguration returns a copy of the config read from the environment when the backend was set up, or nil if
+// there was none
+func (b *backend) envAdminConfiguration() *adminConfiguration {
+	if b.envConfig == nil {
+		return nil
+	}
+	config := *b.envConfig
+	return &config
+}
+
+// configSource returns where config was read from
+func configSource(config adminConfiguration) string {
+	if config.sharedFrom != "" {
+		return configSourceShared
+	}
+	if config.fromEnv {
+		return configSourceEnv
+	}
+	return configSourceStorage
+}
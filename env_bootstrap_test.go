@@ -0,0 +1,74 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mounts with env_bootstrap must use the environment until a config is written; other mounts must ignore it.
+func TestBackend_EnvBootstrap(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	t.Setenv(envArtifactoryURL, "http://myserver.com:80/artifactory")
+	t.Setenv(envAccessToken, "test-access-token")
+
+	b, config := makeBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "backend not configured")
+
+	config = logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.Config[envBootstrapOption] = "true"
+	b, err = Backend(config)
+	assert.NoError(t, err)
+	assert.NoError(t, b.Setup(context.Background(), config))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, configSourceEnv, resp.Data["source"])
+	assert.Equal(t, "http://myserver.com:80/artifactory", resp.Data["url"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"username_template": "v-{{.RoleName}}-{{random 8}}"},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, configSourceStorage, resp.Data["source"])
+	assert.Equal(t, "http://myserver.com:80/artifactory", resp.Data["url"])
+}
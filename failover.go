@@ -0,0 +1,174 @@
+package artifactory
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// failbackInterval is how long calls stay on a failover url before the first url is tried again
+const failbackInterval = 5 * time.Minute
+
+// failoverState remembers which of the urls of config/admin calls currently go to. It lives on the backend, so it
+// survives the http client being rebuilt, and is reset when the urls change.
+type failoverState struct {
+	mutex    sync.Mutex
+	urls     []string
+	active   int
+	failedAt time.Time
+}
+
+// start returns the index of the url to try first for urls, and resets the state if urls changed
+func (s *failoverState) start(urls []string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !equalStrings(s.urls, urls) {
+		s.urls = append([]string(nil), urls...)
+		s.active = 0
+		s.failedAt = time.Time{}
+	}
+
+	// Fail back to the first url once it has had time to recover
+	if s.active != 0 && time.Since(s.failedAt) >= failbackInterval {
+		return 0
+	}
+
+	return s.active
+}
+
+// succeeded records that a call to the url at index succeeded, making it the active url. failedOver says whether the
+// urls tried before it failed, which restarts the wait before failing back.
+func (s *failoverState) succeeded(index int, failedOver bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.active = index
+	if failedOver {
+		s.failedAt = time.Now()
+	}
+}
+
+// activeURL returns the url calls currently go to, or an empty string before any call was made
+func (s *failoverState) activeURL() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.active < len(s.urls) {
+		return s.urls[s.active]
+	}
+	return ""
+}
+
+// validateFailoverURLs checks that every url of a failover list is an absolute http or https url, and that there are
+// no duplicates
+func validateFailoverURLs(urls []string) error {
+	seen := map[string]bool{}
+	for _, rawURL := range urls {
+		u, err := parseURLWithDefaultPort(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid url '%s': %w", rawURL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid url '%s': must be http or https", rawURL)
+		}
+		if seen[u.Scheme+"://"+u.Host] {
+			return fmt.Errorf("url '%s' is listed more than once", rawURL)
+		}
+		seen[u.Scheme+"://"+u.Host] = true
+	}
+	return nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// failoverTransport wraps an http.RoundTripper, sending calls for any of urls to the active one, and trying the next
+// ones in order when a call fails with a connection error or a 5xx response. Calls for other hosts, such as a
+// separately routed Access service, are passed through.
+type failoverTransport struct {
+	state *failoverState
+	urls  []string
+	next  http.RoundTripper
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	targets := make([]*url.URL, 0, len(t.urls))
+	matched := false
+	for _, rawURL := range t.urls {
+		u, err := parseURLWithDefaultPort(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, u)
+		if u.Scheme == req.URL.Scheme && u.Host == req.URL.Host {
+			matched = true
+		}
+	}
+
+	if !matched || len(targets) < 2 {
+		return next.RoundTrip(req)
+	}
+
+	// The body is sent again to each url tried
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	start := t.state.start(t.urls)
+
+	var resp *http.Response
+	var err error
+	for i := range targets {
+		index := (start + i) % len(targets)
+
+		attempt := req.Clone(req.Context())
+		attempt.URL.Scheme = targets[index].Scheme
+		attempt.URL.Host = targets[index].Host
+		attempt.Host = ""
+		if body != nil {
+			attempt.Body = io.NopCloser(bytes.NewReader(body))
+			attempt.ContentLength = int64(len(body))
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		resp, err = next.RoundTrip(attempt)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			t.state.succeeded(index, i > 0)
+			return resp, nil
+		}
+
+		if req.Context().Err() != nil {
+			break
+		}
+	}
+
+	return resp, err
+}
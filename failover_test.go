@@ -0,0 +1,117 @@
+package artifactory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Calls must fail over to the next url on 5xx responses, stay there, and fail back to the first url later.
+func TestBackend_FailoverURLs(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/artifactory/api/system/version",
+		httpmock.NewStringResponder(503, ""))
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://backup.example.org:80/artifactory/api/system/version",
+		httpmock.NewStringResponder(200, artVersion))
+
+	b, config := makeBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "test-access-token",
+			"urls":         "http://myserver.com:80,ftp://backup.example.org",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "test-access-token",
+			"urls":         "http://myserver.com:80/artifactory,http://backup.example.org:80/artifactory",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://myserver.com:80/artifactory", resp.Data["url"])
+	assert.Equal(t, []string{"http://myserver.com:80/artifactory", "http://backup.example.org:80/artifactory"}, resp.Data["urls"])
+	assert.Equal(t, "http://backup.example.org:80/artifactory", resp.Data["active_url"])
+
+	calls := httpmock.GetCallCountInfo()
+	assert.Equal(t, 1, calls["GET http://myserver.com:80/artifactory/api/system/version"])
+
+	// The active url is called directly
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.NoError(t, b.getVersion(*adminConfig))
+	calls = httpmock.GetCallCountInfo()
+	assert.Equal(t, 1, calls["GET http://myserver.com:80/artifactory/api/system/version"])
+	assert.Equal(t, 2, calls["GET http://backup.example.org:80/artifactory/api/system/version"])
+
+	// Once the first url has had time to recover, it is tried again
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/artifactory/api/system/version",
+		httpmock.NewStringResponder(200, artVersion))
+	b.failover.failedAt = time.Now().Add(-failbackInterval)
+
+	assert.NoError(t, b.getVersion(*adminConfig))
+	assert.Equal(t, "http://myserver.com:80/artifactory", b.failover.activeURL())
+
+	// Bodies are sent again to the next url
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(502, ""))
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://backup.example.org:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	_, err = b.CreateToken(context.Background(), *adminConfig, artifactoryRole{Username: "test-user", Scope: "test-scope"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-scope", createRequest.Scope)
+	assert.Equal(t, "http://backup.example.org:80/artifactory", b.failover.activeURL())
+
+	// Changing the urls on config/admin clears the token, as changing url does
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"urls": "http://elsewhere.example.org:80"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "access_token is required")
+}
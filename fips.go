@@ -0,0 +1,79 @@
+package artifactory
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"fmt"
+	"slices"
+)
+
+// fipsCipherSuites are the TLS 1.2 cipher suites approved by FIPS 140 (NIST SP 800-52r2): ECDHE key exchange with
+// AES-GCM. Connections are limited to them, and to TLS 1.2 or higher, in fips_mode.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the FIPS approved curves for key exchange, which leave out X25519
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// applyFIPSTLSConfig restricts tlsConfig to FIPS approved protocol versions, cipher suites and curves, and checks
+// that it presents no client certificate with a key FIPS doesn't approve and sets no weaker version or cipher suite
+func applyFIPSTLSConfig(tlsConfig *tls.Config) error {
+	if tlsConfig.InsecureSkipVerify && tlsConfig.VerifyConnection == nil {
+		return fmt.Errorf("bypass_artifactory_tls_verification is not allowed in fips_mode")
+	}
+
+	for _, cert := range tlsConfig.Certificates {
+		if err := checkFIPSKey(cert.PrivateKey); err != nil {
+			return fmt.Errorf("client_key is not allowed in fips_mode: %w", err)
+		}
+	}
+
+	// tls_min_version and tls_cipher_suites may narrow the approved settings further, but not widen them
+	switch {
+	case tlsConfig.MinVersion == 0:
+		tlsConfig.MinVersion = tls.VersionTLS12
+	case tlsConfig.MinVersion < tls.VersionTLS12:
+		return fmt.Errorf("tls_min_version below tls12 is not allowed in fips_mode")
+	}
+
+	if len(tlsConfig.CipherSuites) == 0 {
+		tlsConfig.CipherSuites = fipsCipherSuites
+	}
+	for _, suite := range tlsConfig.CipherSuites {
+		if !slices.Contains(fipsCipherSuites, suite) {
+			return fmt.Errorf("tls_cipher_suites entry '%s' is not allowed in fips_mode", tls.CipherSuiteName(suite))
+		}
+	}
+
+	tlsConfig.CurvePreferences = fipsCurves
+
+	return nil
+}
+
+// checkFIPSKey returns an error unless key is an RSA key of at least 2048 bits, or an ECDSA key on a FIPS approved
+// curve
+func checkFIPSKey(key interface{}) error {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return fmt.Errorf("RSA keys must be at least 2048 bits, got %d", key.N.BitLen())
+		}
+		return nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			return nil
+		}
+		return fmt.Errorf("ECDSA keys must use curve P-256, P-384 or P-521, got %s", key.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return fmt.Errorf("Ed25519 keys are not approved")
+	}
+	return fmt.Errorf("unsupported key type %T", key)
+}
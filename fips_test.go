@@ -0,0 +1,96 @@
+package artifactory
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fips_mode must restrict the TLS settings to approved algorithms, and reject settings and client keys that aren't.
+func TestBackend_FIPSMode(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	tlsConfig, err := artifactoryTLSConfig(adminConfiguration{FIPSMode: true})
+	assert.NoError(t, err)
+	assert.EqualValues(t, tls.VersionTLS12, tlsConfig.MinVersion)
+	assert.Equal(t, fipsCipherSuites, tlsConfig.CipherSuites)
+	assert.NotContains(t, tlsConfig.CurvePreferences, tls.X25519)
+
+	cert, key := testCertificate(t, "vault")
+	tlsConfig, err = artifactoryTLSConfig(adminConfiguration{FIPSMode: true, ClientCert: cert, ClientKey: key})
+	assert.NoError(t, err)
+	assert.Len(t, tlsConfig.Certificates, 1)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	edDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "vault"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, &x509.Certificate{SerialNumber: big.NewInt(1)}, edKey.Public(), edKey)
+	assert.NoError(t, err)
+	edKeyDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	assert.NoError(t, err)
+	edCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: edDER}))
+	edKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edKeyDER}))
+
+	_, err = artifactoryTLSConfig(adminConfiguration{ClientCert: edCert, ClientKey: edKeyPEM})
+	assert.NoError(t, err)
+	_, err = artifactoryTLSConfig(adminConfiguration{FIPSMode: true, ClientCert: edCert, ClientKey: edKeyPEM})
+	assert.ErrorContains(t, err, "Ed25519 keys are not approved")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, checkFIPSKey(rsaKey), "at least 2048 bits")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"fips_mode":                           true,
+			"bypass_artifactory_tls_verification": true,
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "not allowed in fips_mode")
+
+	// Stored directly, since the restricted http client doesn't go through the mocks
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.False(t, adminConfig.FIPSMode)
+	adminConfig.FIPSMode = true
+	entry, err := logical.StorageEntryJSON("config/admin", adminConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, config.StorageView.Put(context.Background(), entry))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, true, resp.Data["fips_mode"])
+}
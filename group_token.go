@@ -0,0 +1,305 @@
+package artifactory
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// groupTokenStoragePrefix holds the current credential of each group role, under the role's name. It is handed out to
+// the role's requests until it is due for rotation.
+const groupTokenStoragePrefix = "group_tokens/"
+
+// groupToken is the credential a group role currently hands out
+type groupToken struct {
+	TrackingID     string    `json:"tracking_id"`
+	TokenID        string    `json:"token_id"`
+	AccessToken    string    `json:"access_token"`
+	RefreshToken   string    `json:"refresh_token,omitempty"`
+	ReferenceToken string    `json:"reference_token,omitempty"`
+	Scope          string    `json:"scope,omitempty"`
+	Username       string    `json:"username"`
+	Revocable      bool      `json:"revocable"`
+	ConfigName     string    `json:"config_name,omitempty"`
+	IssuedAt       time.Time `json:"issued_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+
+	// Leases is the number of leases handing out the token that haven't been revoked. The token is revoked in
+	// Artifactory with the last of them.
+	Leases int `json:"leases"`
+}
+
+// dueForRotation reports whether half the token's lifetime has passed at now, so the next request gets a new token
+// and every lease handed out lasts at least half of it
+func (t groupToken) dueForRotation(now time.Time) bool {
+	return !now.Before(t.IssuedAt.Add(t.ExpiresAt.Sub(t.IssuedAt) / 2))
+}
+
+func (b *backend) fetchGroupToken(ctx context.Context, storage logical.Storage, roleName string) (*groupToken, error) {
+	entry, err := storage.Get(ctx, groupTokenStoragePrefix+roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var token groupToken
+	if err := entry.DecodeJSON(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (b *backend) putGroupToken(ctx context.Context, storage logical.Storage, roleName string, token groupToken) error {
+	entry, err := logical.StorageEntryJSON(groupTokenStoragePrefix+roleName, token)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, entry)
+}
+
+// currentGroupToken returns the credential a group role hands out, or nil if it has none that is still valid at now.
+// The caller holds groupTokenMutex.
+func (b *backend) currentGroupToken(ctx context.Context, storage logical.Storage, roleName string, now time.Time) (*groupToken, error) {
+	current, err := b.fetchGroupToken(ctx, storage, roleName)
+	if err != nil || current == nil {
+		return nil, err
+	}
+
+	if !now.Before(current.ExpiresAt) {
+		return nil, nil
+	}
+
+	tracked, err := b.fetchTrackedToken(ctx, storage, current.TrackingID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revoked in Artifactory since, e.g. with its role or found dead by the revocation sync
+	if tracked == nil || tracked.RevokedInArtifactory || tracked.RevokedWithRole || tracked.RevokedForRotation {
+		return nil, nil
+	}
+
+	return current, nil
+}
+
+// storeGroupToken makes the token of response, just issued for a group role, the one the role hands out. The caller
+// holds groupTokenMutex.
+func (b *backend) storeGroupToken(ctx context.Context, storage logical.Storage, roleName string, resp *createTokenResponse, response *logical.Response) error {
+	trackingID, _ := response.Secret.InternalData["tracking_id"].(string)
+	configName, _ := response.Secret.InternalData["config_name"].(string)
+
+	maxTTL := response.Secret.MaxTTL
+	if maxTTL == 0 {
+		maxTTL = b.System().MaxLeaseTTL()
+	}
+
+	now := time.Now()
+	return b.putGroupToken(ctx, storage, roleName, groupToken{
+		TrackingID:     trackingID,
+		TokenID:        resp.TokenId,
+		AccessToken:    resp.AccessToken,
+		RefreshToken:   resp.RefreshToken,
+		ReferenceToken: resp.ReferenceToken,
+		Scope:          resp.Scope,
+		Username:       response.Data["username"].(string),
+		Revocable:      resp.Revocable,
+		ConfigName:     configName,
+		IssuedAt:       now,
+		ExpiresAt:      now.Add(maxTTL),
+		Leases:         1,
+	})
+}
+
+// groupTokenResponse hands out the current token of a group role with a lease of its own, which ends when the token
+// does. The caller holds groupTokenMutex.
+func (b *backend) groupTokenResponse(ctx context.Context, req *logical.Request, roleName string, role artifactoryRole, current *groupToken, ttl time.Duration) (*logical.Response, error) {
+	response := b.Secret(SecretArtifactoryAccessTokenType).Response(map[string]interface{}{
+		"access_token":    current.AccessToken,
+		"refresh_token":   current.RefreshToken,
+		"role":            roleName,
+		"scope":           current.Scope,
+		"token_id":        current.TokenID,
+		"username":        current.Username,
+		"reference_token": current.ReferenceToken,
+		"revocable":       current.Revocable,
+		"token_type":      role.TokenType,
+	}, map[string]interface{}{
+		"role":            roleName,
+		"access_token":    current.AccessToken,
+		"refresh_token":   current.RefreshToken,
+		"token_id":        current.TokenID,
+		"username":        current.Username,
+		"reference_token": current.ReferenceToken,
+		"revocable":       current.Revocable,
+		"tracking_id":     current.TrackingID,
+		"config_name":     current.ConfigName,
+		"group_token":     true,
+	})
+
+	remaining := time.Until(current.ExpiresAt)
+	if ttl == 0 {
+		ttl = b.System().DefaultLeaseTTL()
+	}
+	if ttl > remaining {
+		ttl = remaining
+	}
+	response.Secret.TTL = ttl
+	response.Secret.MaxTTL = remaining
+
+	current.Leases++
+	if err := b.putGroupToken(ctx, req.Storage, roleName, *current); err != nil {
+		return nil, err
+	}
+
+	// The token stays tracked as active while any lease handing it out does
+	tracked, err := b.fetchTrackedToken(ctx, req.Storage, current.TrackingID)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt := time.Now().Add(ttl); tracked != nil && expiresAt.After(tracked.ExpiresAt) {
+		tracked.ExpiresAt = expiresAt
+		if err := b.putTrackedToken(ctx, req.Storage, current.TrackingID, *tracked); err != nil {
+			return nil, err
+		}
+	}
+
+	b.recordIssuance(ctx, req, roleName, role, current.TokenID)
+
+	applyResponseKeyMapping(response.Data, role.ResponseKeyMapping)
+
+	return response, nil
+}
+
+// releaseGroupToken counts the revocation of a lease handing out the current token of a group role. It reports
+// whether other leases still hand it out, in which case the token must not be revoked in Artifactory. Tokens that
+// are no longer current are revoked with their first lease, as rotation revokes them anyway.
+func (b *backend) releaseGroupToken(ctx context.Context, storage logical.Storage, roleName string, trackingID string) (bool, error) {
+	b.groupTokenMutex.Lock()
+	defer b.groupTokenMutex.Unlock()
+
+	current, err := b.fetchGroupToken(ctx, storage, roleName)
+	if err != nil {
+		return false, err
+	}
+
+	if current == nil || current.TrackingID != trackingID {
+		return false, nil
+	}
+
+	current.Leases--
+	if current.Leases > 0 {
+		return true, b.putGroupToken(ctx, storage, roleName, *current)
+	}
+
+	return false, storage.Delete(ctx, groupTokenStoragePrefix+roleName)
+}
+
+// rotateGroupTokens rotates the credentials of group roles: tokens a newer one replaced, and those past their
+// lifetime, are revoked in Artifactory. It runs periodically on the active node.
+func (b *backend) rotateGroupTokens(ctx context.Context, req *logical.Request) error {
+	b.rolesMutex.RLock()
+	defer b.rolesMutex.RUnlock()
+
+	roleNames, err := req.Storage.List(ctx, "roles/")
+	if err != nil {
+		return err
+	}
+
+	b.groupTokenMutex.Lock()
+	defer b.groupTokenMutex.Unlock()
+
+	now := time.Now()
+	for _, roleName := range roleNames {
+		role, err := b.Role(ctx, req.Storage, roleName)
+		if err != nil {
+			return err
+		}
+		if role == nil || !role.group() {
+			continue
+		}
+
+		current, err := b.currentGroupToken(ctx, req.Storage, roleName, now)
+		if err != nil {
+			return err
+		}
+
+		var currentID string
+		if current != nil {
+			currentID = current.TrackingID
+		} else if err := req.Storage.Delete(ctx, groupTokenStoragePrefix+roleName); err != nil {
+			return err
+		}
+
+		if err := b.rotateGroupToken(ctx, req.Storage, roleName, currentID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateGroupToken revokes, in Artifactory, the tokens of a group role other than the current one tracked under
+// currentID, so the role's group has a single shared credential. Their leases outlive them, so they stay tracked,
+// marked as rotated, until their leases are revoked. Failed revocations are queued for retry.
+func (b *backend) rotateGroupToken(ctx context.Context, storage logical.Storage, roleName string, currentID string) error {
+	config, err := b.fetchAdminConfiguration(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	keys, tokens, err := b.activeIndexedTrackedTokens(ctx, storage, roleTokenIndexPrefix(roleName))
+	if err != nil {
+		return err
+	}
+
+	for _, trackingID := range keys {
+		token := tokens[trackingID]
+		if trackingID == currentID || token.ParentID != "" {
+			continue
+		}
+
+		secret := logical.Secret{InternalData: map[string]interface{}{
+			"role":        roleName,
+			"token_id":    token.TokenID,
+			"tracking_id": trackingID,
+			"config_name": token.ConfigName,
+		}}
+
+		tokenConfig, err := b.withSecret(ctx, storage, *config, secret.InternalData)
+		if err == nil {
+			revokeCtx, cancel := revokeContext(ctx)
+			err = b.RevokeToken(revokeCtx, tokenConfig, secret)
+			cancel()
+		}
+
+		if err != nil {
+			b.Logger().Warn("could not revoke rotated group token, queued for retry", "role", roleName, "tokenId", token.TokenID, "err", err)
+			if err := b.queueRevocation(context.WithoutCancel(ctx), storage, secret, err); err != nil {
+				return err
+			}
+		} else {
+			b.Logger().Info("revoked rotated group token", "role", roleName, "tokenId", token.TokenID)
+		}
+
+		if err := b.revokeChildren(ctx, storage, *config, trackingID); err != nil {
+			return err
+		}
+
+		token.RevokedForRotation = true
+		if err := b.putTrackedToken(ctx, storage, trackingID, *token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
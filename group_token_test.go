@@ -0,0 +1,154 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tokens of a group role must be issued to the group's service identity and handed out to every request until they
+// are due for rotation. The periodic function revokes the role's previous tokens, and a shared token is revoked in
+// Artifactory with the last lease handing it out.
+func TestBackend_GroupTokenRotation(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.55.6", "revision" : "75506900"}`)
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/access/api/v1/tokens",
+		httpmock.NewStringResponder(200, `{"token_id": "group-token", "access_token": "group-access-token", "scope": "applied-permissions/groups:squad-a"}`))
+
+	httpmock.RegisterResponder(
+		http.MethodDelete,
+		"http://myserver.com:80/access/api/v1/tokens/previous-token",
+		httpmock.NewStringResponder(200, ""))
+
+	httpmock.RegisterResponder(
+		http.MethodDelete,
+		"http://myserver.com:80/access/api/v1/tokens/group-token",
+		httpmock.NewStringResponder(200, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/squad-a",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"token_type": "group",
+			"groups":     "squad-a",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	now := time.Now()
+	previousToken := trackedToken{
+		TokenID:   "previous-token",
+		Role:      "squad-a",
+		Username:  "group-squad-a",
+		IssuedAt:  now.Add(-time.Hour),
+		ExpiresAt: now.Add(time.Hour),
+	}
+	assert.NoError(t, b.putTrackedToken(context.Background(), config.StorageView, "previous-token", previousToken))
+	assert.NoError(t, b.indexTrackedToken(context.Background(), config.StorageView, "previous-token", previousToken))
+
+	var secrets []*logical.Secret
+	for i := 0; i < 2; i++ {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/squad-a",
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		assert.Equal(t, "group-squad-a", resp.Data["username"])
+		assert.Equal(t, "group", resp.Data["token_type"])
+		assert.Equal(t, "group-access-token", resp.Data["access_token"])
+		secrets = append(secrets, resp.Secret)
+	}
+
+	// The second request got the token of the first, and neither revoked the previous token
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["POST http://myserver.com:80/access/api/v1/tokens"])
+	assert.Equal(t, secrets[0].InternalData["tracking_id"], secrets[1].InternalData["tracking_id"])
+	assert.Equal(t, 0, httpmock.GetCallCountInfo()["DELETE http://myserver.com:80/access/api/v1/tokens/previous-token"])
+
+	assert.NoError(t, b.rotateGroupTokens(context.Background(), &logical.Request{Storage: config.StorageView}))
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["DELETE http://myserver.com:80/access/api/v1/tokens/previous-token"])
+
+	previous, err := b.fetchTrackedToken(context.Background(), config.StorageView, "previous-token")
+	assert.NoError(t, err)
+	assert.True(t, previous.RevokedForRotation)
+
+	current, err := b.fetchTrackedToken(context.Background(), config.StorageView, secrets[0].InternalData["tracking_id"].(string))
+	assert.NoError(t, err)
+	assert.True(t, current.active(time.Now()))
+
+	for i, secret := range secrets {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, i, httpmock.GetCallCountInfo()["DELETE http://myserver.com:80/access/api/v1/tokens/group-token"])
+	}
+
+	// With no lease handing it out, the next request issues a new token
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/squad-a",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, 2, httpmock.GetCallCountInfo()["POST http://myserver.com:80/access/api/v1/tokens"])
+}
+
+// A group token is due for rotation once half its lifetime has passed
+func TestGroupToken_DueForRotation(t *testing.T) {
+	now := time.Now()
+	token := groupToken{IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(3 * time.Hour)}
+	assert.False(t, token.dueForRotation(now))
+	assert.True(t, token.dueForRotation(now.Add(time.Hour)))
+}
+
+func TestBackend_GroupTokenConflicts(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for _, data := range []map[string]interface{}{
+		{"token_type": "group"},
+		{"token_type": "group", "groups": "squad-a,squad-b"},
+		{"token_type": "group", "groups": "squad-a", "scope": "api:*"},
+		{"token_type": "group", "groups": "squad-a", "generate_lease": false, "max_ttl": 3600},
+		{"token_type": "group", "groups": "squad-a", "max_tokens_per_entity": 1},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/squad-a",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError(), "%v", data)
+	}
+}
@@ -0,0 +1,80 @@
+package artifactory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// transportSettings are the settings of config/admin the http client is built from
+type transportSettings struct {
+	URLs                             []string      `json:"urls,omitempty"`
+	BypassArtifactoryTLSVerification bool          `json:"bypass_artifactory_tls_verification,omitempty"`
+	FIPSMode                         bool          `json:"fips_mode,omitempty"`
+	CACertPEM                        string        `json:"ca_cert_pem,omitempty"`
+	TLSPinnedSPKIHashes              []string      `json:"tls_pinned_spki_hashes,omitempty"`
+	TLSMinVersion                    string        `json:"tls_min_version,omitempty"`
+	TLSCipherSuites                  []string      `json:"tls_cipher_suites,omitempty"`
+	ClientCert                       string        `json:"client_cert,omitempty"`
+	ClientKey                        string        `json:"client_key,omitempty"`
+	HTTPProxy                        string        `json:"http_proxy,omitempty"`
+	HTTPSProxy                       string        `json:"https_proxy,omitempty"`
+	NoProxy                          string        `json:"no_proxy,omitempty"`
+	RequestTimeout                   time.Duration `json:"request_timeout,omitempty"`
+	MaxRetries                       int           `json:"max_retries,omitempty"`
+	RetryBackoff                     time.Duration `json:"retry_backoff,omitempty"`
+	MaxResponseSize                  int64         `json:"max_response_size,omitempty"`
+}
+
+// transportFingerprint returns a hash of the settings the http client is built from, which changes whenever the client
+// must be built again
+func (c adminConfiguration) transportFingerprint() string {
+	settings, err := json.Marshal(transportSettings{
+		URLs:                             c.URLs,
+		BypassArtifactoryTLSVerification: c.BypassArtifactoryTLSVerification,
+		FIPSMode:                         c.FIPSMode,
+		CACertPEM:                        c.CACertPEM,
+		TLSPinnedSPKIHashes:              c.TLSPinnedSPKIHashes,
+		TLSMinVersion:                    c.TLSMinVersion,
+		TLSCipherSuites:                  c.TLSCipherSuites,
+		ClientCert:                       c.ClientCert,
+		ClientKey:                        c.ClientKey,
+		HTTPProxy:                        c.HTTPProxy,
+		HTTPSProxy:                       c.HTTPSProxy,
+		NoProxy:                          c.NoProxy,
+		RequestTimeout:                   c.RequestTimeout,
+		MaxRetries:                       c.MaxRetries,
+		RetryBackoff:                     c.RetryBackoff,
+		MaxResponseSize:                  c.MaxResponseSize,
+	})
+	if err != nil {
+		// Never happens for these types; an empty fingerprint makes every call build the client again
+		return ""
+	}
+	sum := sha256.Sum256(settings)
+	return hex.EncodeToString(sum[:])
+}
+
+// client returns the http client for a call made with config. It is built again first if the transport settings of
+// config differ from those it was built from, e.g. because config/admin was written on another node or the config of
+// another mount is shared, so TLS, proxy, timeout and retry changes apply to the next call without a plugin reload.
+func (b *backend) client(config adminConfiguration) *http.Client {
+	setup := b.currentSetup()
+
+	fingerprint := config.transportFingerprint()
+	if setup.httpClient == nil || fingerprint == "" || fingerprint != setup.httpClientFingerprint {
+		if setup.httpClient != nil {
+			b.Logger().Info("transport settings of the config changed, setting up the http client again")
+		}
+		client := b.newHttpClient(&config)
+		b.storeSetup(func(s *configSetup) {
+			s.httpClient = client
+			s.httpClientFingerprint = fingerprint
+		})
+		return client
+	}
+
+	return setup.httpClient
+}
@@ -0,0 +1,62 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Transport settings written to storage by another node must apply to the next call, and the client must be reused
+// while they don't change.
+func TestBackend_HttpClientHotReload(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/artifactory/api/system/ping",
+		httpmock.NewStringResponder(200, "OK"))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+
+	client := b.currentSetup().httpClient
+	resp, err := b.performArtifactoryGet(*adminConfig, "/artifactory/api/system/ping")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Same(t, client, b.currentSetup().httpClient)
+
+	// Written on another node, which only updates storage
+	adminConfig.RequestTimeout = 7 * time.Second
+	entry, err := logical.StorageEntryJSON("config/admin", adminConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, config.StorageView.Put(context.Background(), entry))
+
+	adminConfig, err = b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+
+	resp, err = b.performArtifactoryGet(*adminConfig, "/artifactory/api/system/ping")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotSame(t, client, b.currentSetup().httpClient)
+	assert.Equal(t, 7*time.Second, b.currentSetup().httpClient.Timeout)
+
+	// Settings that don't shape the transport don't set up the client again
+	client = b.currentSetup().httpClient
+	adminConfig.UsernameTemplate = "v-{{.RoleName}}-{{random 8}}"
+	_, err = b.performArtifactoryGet(*adminConfig, "/artifactory/api/system/ping")
+	assert.NoError(t, err)
+	assert.Same(t, client, b.currentSetup().httpClient)
+}
@@ -0,0 +1,139 @@
+package artifactory
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyWindowSize is how many of the most recent calls to each Artifactory endpoint latency percentiles cover
+const latencyWindowSize = 1000
+
+// latencyEndpoint names the Artifactory endpoint a call is made to, for the latency breakdown of the stats endpoint
+func latencyEndpoint(req *http.Request) string {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+
+	switch {
+	case strings.HasSuffix(path, "/api/security/token/revoke"),
+		req.Method == http.MethodDelete && strings.Contains(path, "/access/api/v1/tokens/"):
+		return "token_revoke"
+	case strings.HasSuffix(path, "/api/security/token"), strings.HasSuffix(path, "/access/api/v1/tokens"):
+		return "token_create"
+	case strings.Contains(path, "/access/api/v1/tokens/"):
+		return "token_info"
+	case strings.HasSuffix(path, "/api/system/version"):
+		return "version"
+	case strings.HasSuffix(path, "/api/v1/cert/root"):
+		return "root_cert"
+	case strings.HasSuffix(path, "/system/ping"):
+		return "ping"
+	case strings.HasSuffix(path, "/api/system/usage"):
+		return "usage"
+	case strings.Contains(path, "/api/storage/"):
+		return "permissions"
+	}
+
+	return "other"
+}
+
+// latencyRecorder keeps the durations of the most recent calls to each Artifactory endpoint. It lives on the backend,
+// so the samples survive the http client being rebuilt when config/admin changes.
+type latencyRecorder struct {
+	mutex     sync.Mutex
+	endpoints map[string]*latencyWindow
+}
+
+// latencyWindow is a ring buffer of call durations, with running totals since the plugin started
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	count   int64
+	errors  int64
+}
+
+func (r *latencyRecorder) record(endpoint string, d time.Duration, failed bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.endpoints == nil {
+		r.endpoints = map[string]*latencyWindow{}
+	}
+
+	w, ok := r.endpoints[endpoint]
+	if !ok {
+		w = &latencyWindow{}
+		r.endpoints[endpoint] = w
+	}
+
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+	} else {
+		w.samples[w.next] = d
+		w.next = (w.next + 1) % latencyWindowSize
+	}
+
+	w.count++
+	if failed {
+		w.errors++
+	}
+}
+
+// snapshot returns, for each endpoint called, the call and error counts and the latency percentiles in milliseconds
+func (r *latencyRecorder) snapshot() map[string]interface{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := make(map[string]interface{}, len(r.endpoints))
+	for endpoint, w := range r.endpoints {
+		sorted := make([]time.Duration, len(w.samples))
+		copy(sorted, w.samples)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		stats[endpoint] = map[string]interface{}{
+			"count":   w.count,
+			"errors":  w.errors,
+			"samples": len(sorted),
+			"p50_ms":  milliseconds(percentile(sorted, 50)),
+			"p90_ms":  milliseconds(percentile(sorted, 90)),
+			"p99_ms":  milliseconds(percentile(sorted, 99)),
+			"max_ms":  milliseconds(sorted[len(sorted)-1]),
+		}
+	}
+
+	return stats
+}
+
+// percentile returns the nearest-rank percentile p of sorted, which must not be empty
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// latencyRecordingTransport wraps an http.RoundTripper, recording how long Artifactory takes to return response
+// headers for each call. Time spent in Vault, such as waiting for locks, is not included.
+type latencyRecordingTransport struct {
+	recorder *latencyRecorder
+	next     http.RoundTripper
+}
+
+func (t *latencyRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := next.RoundTrip(req)
+	t.recorder.record(latencyEndpoint(req), time.Since(start), err != nil || resp.StatusCode >= http.StatusInternalServerError)
+
+	return resp, err
+}
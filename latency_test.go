@@ -0,0 +1,71 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Calls must be attributed to their endpoint, with percentiles over the recorded window.
+func TestLatencyRecorder(t *testing.T) {
+	for path, endpoint := range map[string]string{
+		"/artifactory/api/security/token":        "token_create",
+		"/access/api/v1/tokens":                  "token_create",
+		"/artifactory/api/security/token/revoke": "token_revoke",
+		"/artifactory/api/system/version":        "version",
+		"/access/api/v1/cert/root":               "root_cert",
+		"/artifactory/api/system/ping":           "ping",
+		"/artifactory/api/other":                 "other",
+	} {
+		req, err := http.NewRequest(http.MethodPost, "http://myserver.com:80"+path, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, endpoint, latencyEndpoint(req), path)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, "http://myserver.com:80/access/api/v1/tokens/abc", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "token_revoke", latencyEndpoint(req))
+
+	var recorder latencyRecorder
+	for i := 1; i <= latencyWindowSize+100; i++ {
+		recorder.record("version", time.Duration(i)*time.Millisecond, i%10 == 0)
+	}
+
+	stats := recorder.snapshot()["version"].(map[string]interface{})
+	assert.EqualValues(t, latencyWindowSize+100, stats["count"])
+	assert.EqualValues(t, (latencyWindowSize+100)/10, stats["errors"])
+	assert.Equal(t, latencyWindowSize, stats["samples"])
+	// The first 100 calls dropped out of the window
+	assert.Equal(t, 600.0, stats["p50_ms"])
+	assert.Equal(t, 1090.0, stats["p99_ms"])
+	assert.Equal(t, 1100.0, stats["max_ms"])
+}
+
+// Calls to Artifactory must show up in the latency breakdown of the stats endpoint.
+func TestBackend_PathStatsLatency(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "stats",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+
+	latency := resp.Data["artifactory_latency"].(map[string]interface{})
+	assert.Contains(t, latency, "version")
+	assert.GreaterOrEqual(t, latency["version"].(map[string]interface{})["count"], int64(1))
+}
@@ -0,0 +1,151 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathAnalyzeRoles() *framework.Path {
+	return &framework.Path{
+		Pattern: "analyze/roles",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathAnalyzeRolesRead,
+				Summary:  `Report roles with identical or overlapping definitions.`,
+			},
+		},
+		HelpSynopsis: `Report roles with identical or overlapping definitions.`,
+		HelpDescription: `
+Compares every configured role and reports:
+
+"identical" - groups of roles with the same scope, TTLs, and token options, which could be consolidated into one.
+
+"overlapping" - roles whose scope is a strict subset of another role's scope, so tokens from the other role can do
+everything tokens from this role can.
+
+"ttl_out_of_bounds" - roles whose default_ttl or max_ttl exceeds the mount's max lease ttl, for example after the mount
+was tuned, so their tokens are issued with shorter TTLs than the role says.
+
+Scopes are compared as sets of space-delimited entries, so ordering and duplicate whitespace are ignored.
+`,
+	}
+}
+
+// roleSignature is the part of a role definition that determines what its tokens can do and how long they live
+func roleSignature(role artifactoryRole) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s|%v|%v|%s|%s",
+		strings.Join(scopeSet(role.Scope), " "),
+		strings.Join(scopeSet(role.EscalatedScope), " "),
+		role.ProjectKey,
+		role.GrantType,
+		role.Username,
+		role.Audience,
+		role.Refreshable,
+		role.IncludeReferenceToken,
+		role.DefaultTTL,
+		role.MaxTTL)
+}
+
+// scopeSet returns the sorted, de-duplicated entries of a space-delimited scope
+func scopeSet(scope string) []string {
+	return strutil.RemoveDuplicates(strings.Fields(scope), false)
+}
+
+func (b *backend) pathAnalyzeRolesRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
+	defer b.rolesMutex.RUnlock()
+
+	roleNames, err := req.Storage.List(ctx, "roles/")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(roleNames)
+
+	roles := map[string]artifactoryRole{}
+	bySignature := map[string][]string{}
+	for _, roleName := range roleNames {
+		role, err := b.Role(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			continue
+		}
+		role.Scope = b.roleScope(*role)
+		roles[roleName] = *role
+		signature := roleSignature(*role)
+		bySignature[signature] = append(bySignature[signature], roleName)
+	}
+
+	identical := []interface{}{}
+	for _, names := range bySignature {
+		if len(names) > 1 {
+			identical = append(identical, map[string]interface{}{
+				"roles": names,
+				"scope": strings.Join(scopeSet(roles[names[0]].Scope), " "),
+			})
+		}
+	}
+	sort.Slice(identical, func(i, j int) bool {
+		return identical[i].(map[string]interface{})["roles"].([]string)[0] < identical[j].(map[string]interface{})["roles"].([]string)[0]
+	})
+
+	maxLeaseTTL := b.System().MaxLeaseTTL()
+	outOfBounds := []string{}
+	for _, roleName := range roleNames {
+		if role, ok := roles[roleName]; ok && len(role.ttlBoundsWarnings(maxLeaseTTL)) > 0 {
+			outOfBounds = append(outOfBounds, roleName)
+		}
+	}
+
+	overlapping := []interface{}{}
+	for _, roleName := range roleNames {
+		role, ok := roles[roleName]
+		if !ok {
+			continue
+		}
+
+		coveredBy := []string{}
+		for _, otherName := range roleNames {
+			other, ok := roles[otherName]
+			if !ok || otherName == roleName {
+				continue
+			}
+			if scopeContains(other.Scope, role.Scope) && !scopeContains(role.Scope, other.Scope) {
+				coveredBy = append(coveredBy, otherName)
+			}
+		}
+
+		if len(coveredBy) > 0 {
+			overlapping = append(overlapping, map[string]interface{}{
+				"role":       roleName,
+				"covered_by": coveredBy,
+			})
+		}
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"role_count":        len(roles),
+			"identical":         identical,
+			"overlapping":       overlapping,
+			"ttl_out_of_bounds": outOfBounds,
+		},
+	}
+
+	if len(outOfBounds) > 0 {
+		resp.AddWarning(fmt.Sprintf("%d role(s) have TTLs exceeding the mount's max lease ttl of %s: %s", len(outOfBounds), maxLeaseTTL, strings.Join(outOfBounds, ", ")))
+	}
+
+	if len(identical) > 0 {
+		resp.AddWarning(fmt.Sprintf("%d group(s) of roles have identical definitions and could be consolidated", len(identical)))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,59 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Roles with the same definition must be reported as identical, and roles whose scope is contained in
+// another's as overlapping.
+func TestBackend_PathAnalyzeRoles(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	roles := map[string]string{
+		"readers":       "applied-permissions/groups:readers",
+		"readers-copy":  "applied-permissions/groups:readers  ",
+		"readers-admin": "applied-permissions/groups:readers applied-permissions/groups:admins",
+	}
+	for roleName, scope := range roles {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data:      map[string]interface{}{"scope": scope},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "analyze/roles",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Len(t, resp.Warnings, 1)
+	assert.Equal(t, 3, resp.Data["role_count"])
+
+	identical := resp.Data["identical"].([]interface{})
+	assert.Len(t, identical, 1)
+	assert.Equal(t, []string{"readers", "readers-copy"}, identical[0].(map[string]interface{})["roles"])
+
+	overlapping := resp.Data["overlapping"].([]interface{})
+	assert.Len(t, overlapping, 2)
+	assert.Equal(t, "readers", overlapping[0].(map[string]interface{})["role"])
+	assert.Equal(t, []string{"readers-admin"}, overlapping[0].(map[string]interface{})["covered_by"])
+}
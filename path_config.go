@@ -2,10 +2,13 @@ package artifactory
 
 import (
 	"context"
-	"crypto/sha256"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -16,14 +19,22 @@ func (b *backend) pathConfig() *framework.Path {
 		Fields: map[string]*framework.FieldSchema{
 			"access_token": {
 				Type:        framework.TypeString,
-				Required:    true,
-				Description: "Administrator token to access Artifactory",
+				Deprecated:  true,
+				Description: "Deprecated. Write the administrator token to config/admin/credentials instead.",
 			},
 			"url": {
 				Type:        framework.TypeString,
 				Required:    true,
 				Description: "Address of the Artifactory instance",
 			},
+			"urls": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Optional. Ordered addresses of the same Artifactory instance, e.g. an HA pair behind separate DNS names, instead of url. Calls fail over to the next one on connection errors or 5xx responses. Like url, changing them clears the stored access token.",
+			},
+			"access_url": {
+				Type:        framework.TypeString,
+				Description: "Optional. Address of the JFrog Access service, for deployments that route it separately from the platform url (e.g. https://access.example.org). Defaults to the platform url, or to the platform's custom base URL if Access isn't reachable through url.",
+			},
 			"username_template": {
 				Type:        framework.TypeString,
 				Description: "Optional. Vault Username Template for dynamically generating usernames.",
@@ -37,6 +48,172 @@ func (b *backend) pathConfig() *framework.Path {
 				Default:     false,
 				Description: "Optional. Bypass certification verification for TLS connection with Artifactory. Default to `false`.",
 			},
+			"allow_tls_bypass": {
+				Type:        framework.TypeBool,
+				Default:     true,
+				Description: "Optional. Set to `false` to reject bypass_artifactory_tls_verification on this mount, e.g. for production mounts. Default to `true`.",
+			},
+			"ca_cert_pem": {
+				Type:        framework.TypeString,
+				Description: "Optional. PEM encoded CA certificates trusted, in addition to the system roots, to verify Artifactory's TLS certificate, for instances using an internal CA.",
+			},
+			"tls_pinned_spki_hashes": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Optional. Base64 encoded sha256 hashes of the public keys (SubjectPublicKeyInfo) of Artifactory's certificate or an intermediate of its chain. Replaces CA verification.",
+			},
+			"tls_min_version": {
+				Type:        framework.TypeString,
+				Description: "Optional. Minimum TLS version of connections to Artifactory: 'tls10', 'tls11', 'tls12' or 'tls13'. Defaults to that of Go, TLS 1.2.",
+			},
+			"tls_cipher_suites": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Optional. IANA names of the cipher suites allowed for TLS 1.2 and lower connections to Artifactory (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256). TLS 1.3 suites aren't configurable. Defaults to those of Go.",
+			},
+			"client_cert": {
+				Type:        framework.TypeString,
+				Description: "Optional. PEM encoded client certificate presented to Artifactory, for ingresses that require mutual TLS. Its client_key is written to config/admin/credentials.",
+			},
+			"http_proxy": {
+				Type:        framework.TypeString,
+				Description: "Optional. Proxy for http calls to Artifactory, overriding the HTTP_PROXY environment variable of Vault.",
+			},
+			"https_proxy": {
+				Type:        framework.TypeString,
+				Description: "Optional. Proxy for https calls to Artifactory, overriding the HTTPS_PROXY environment variable of Vault.",
+			},
+			"no_proxy": {
+				Type:        framework.TypeString,
+				Description: "Optional. Comma-separated hosts, domains and CIDRs called without a proxy, overriding the NO_PROXY environment variable of Vault.",
+			},
+			"custom_headers": {
+				Type:        framework.TypeKVPairs,
+				Description: "Optional. Extra HTTP headers sent on every call to Artifactory (e.g. 'X-Proxy-Auth=secret' for an auth proxy in front of it). Authentication headers can't be set. Reads return only the header names.",
+			},
+			"shared_config": {
+				Type:        framework.TypeString,
+				Description: "Optional. Uuid of another mount of this plugin in the same namespace whose configuration this mount uses instead of its own, as listed by 'vault read sys/mounts/<path>'. That mount must list this mount's uuid in shared_with. Can't be combined with other parameters.",
+			},
+			"shared_with": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Optional. Uuids of the mounts allowed to use this configuration with shared_config.",
+			},
+			"auth_header": {
+				Type:        framework.TypeString,
+				Default:     authHeaderBearer,
+				Description: "Optional. How the admin token is sent to Artifactory: 'bearer' (Authorization: Bearer) or 'x-jfrog-art-api' (X-JFrog-Art-Api, for API key admin credentials and 6.x endpoints that reject Bearer). Default to `bearer`.",
+			},
+			"check_health_before_issuance": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. Probe Artifactory's system/ping endpoint before issuing tokens and fail fast if it is unhealthy. The result is cached briefly. Default to `false`.",
+			},
+			"usage_reporting": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. Report which backend features are used to Artifactory's usage endpoint. Default to `false`.",
+			},
+			"usage_product_id": {
+				Type:        framework.TypeString,
+				Description: "Optional. Product name reported with usage when usage_reporting is enabled. Defaults to the plugin name and version.",
+			},
+			"revocation_sync_interval": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. How often to look up tracked tokens in Artifactory and mark those revoked or expired there, whose leases then aren't renewed. Requires Artifactory 7.21.1 or higher. Default to 0, disabled.",
+			},
+			"tracked_token_retention": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. How long the metadata of a tracked token is kept after its lease expired, before compaction removes it. Default to 24h.",
+			},
+			"max_tracked_tokens": {
+				Type:        framework.TypeInt,
+				Description: "Optional. Maximum number of tracked tokens kept in storage. Compaction removes the metadata of expired and revoked tokens beyond it, oldest first. Default to 0, unlimited.",
+			},
+			"max_config_versions": {
+				Type:        framework.TypeInt,
+				Description: "Optional. How many versions of this configuration are kept for config/admin/rollback. Default to 10.",
+			},
+			"max_response_size": {
+				Type:        framework.TypeInt,
+				Description: "Optional. Maximum size in bytes of an Artifactory response body read into memory. Larger bodies, such as error pages from a misconfigured proxy, are truncated and the request fails. Default to 1048576 (1 MiB).",
+			},
+			"preflight_check": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. On every write of the config or its credentials, create and revoke a short-lived token to verify the admin token can manage tokens, and reject the write if it can't. Default to `false`.",
+			},
+			"disable_version_check": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. Don't call api/system/version, for admin tokens scoped too narrowly to read it, and use artifactory_version instead. Default to `false`.",
+			},
+			"artifactory_version": {
+				Type:        framework.TypeString,
+				Description: "Optional. Artifactory version to use when disable_version_check is set, which selects the token APIs the backend calls. Required with disable_version_check.",
+			},
+			"offline_mode": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. For air-gapped installs: skip optional calls to Artifactory (usage reporting, repeated version checks, the Access reachability check and root certificate fetch retries). Default to `false`.",
+			},
+			"rotation_period": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. Rotate the admin token automatically once this long has passed since it was written or last rotated. Default to 0, disabled.",
+			},
+			"rotation_window": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. Rotate the admin token automatically once it expires within this long. Requires a JWT admin token. Default to 0, disabled.",
+			},
+			"expiry_warning_threshold": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. Log a warning and send an artifactory-admin-token-expiring event once the admin token expires within this long. Requires a JWT admin token. Default to 0, disabled.",
+			},
+			"default_ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. Ceiling on the default_ttl of every role, also used as the default_ttl of roles that don't set one. Default to 0, no ceiling.",
+			},
+			"max_ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. Ceiling on the max_ttl of every role, also used as the max_ttl of roles that don't set one. Default to 0, no ceiling.",
+			},
+			"request_timeout": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. Timeout of each attempt of a call to Artifactory. Default to 0, no timeout.",
+			},
+			"max_retries": {
+				Type:        framework.TypeInt,
+				Description: "Optional. How many times a call to Artifactory is retried after a connection error, a 429 or a 5xx response. Default to 0, no retries.",
+			},
+			"retry_backoff": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. Wait before the first retry of a call to Artifactory, doubled for each further retry. Default to 1 second.",
+			},
+			"user_agent_attribution": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. Add the mount path and Vault cluster name to the User-Agent of calls to Artifactory, so its access logs can attribute load to mounts and clusters. Default to `false`.",
+			},
+			"cluster_name": {
+				Type:        framework.TypeString,
+				Description: "Optional. Cluster name reported in the User-Agent when user_agent_attribution is enabled. Defaults to the Vault cluster id.",
+			},
+			"reject_deprecated": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. Fail requests that use deprecated parameters or paths, instead of serving them with a warning. Default to `false`.",
+			},
+			"fips_mode": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. Restrict connections to Artifactory to FIPS 140 approved TLS versions, cipher suites, curves and client keys, and reject settings that violate them. Default to `false`.",
+			},
+			"dry_run": {
+				Type:        framework.TypeBool,
+				Description: "Update only. Run the version, Access, token management and template checks against the configuration the write results in, and return their results without saving it.",
+			},
+			"force": {
+				Type:        framework.TypeBool,
+				Description: "Delete only. Delete the configuration even though roles are defined or leases are active, which it strands.",
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
@@ -59,15 +236,151 @@ Configure the parameters used to connect to the Artifactory server integrated wi
 The two main parameters are "url" which is the absolute URL to the Artifactory server. Note that "/artifactory/api"
 is prepended by the individual calls, so do not include it in the URL here.
 
-The second is "access_token" which must be an access token powerful enough to generate the other access tokens you'll
-be using. This value is stored seal wrapped when available. Once set, the access token cannot be retrieved, but the backend
-will send a sha256 hash of the token so you can compare it to your notes. If the token is a JWT Access Token, it will return
-additional information such as jfrog_token_id, username and scope.
+The second is "access_token", which is written to config/admin/credentials so that policies can grant access to these
+settings without access to any path that accepts or describes the token. Writing "access_token" here still works, but is
+deprecated, and rejected once "reject_deprecated" is set. Reads of this path don't describe the token.
+Changing "url" clears the stored access token, so a new one must be written to config/admin/credentials along with it.
+
+An optional "access_url" parameter sets the address of the JFrog Access service for deployments where it is not routed
+through the platform "url" (e.g. a separate access.example.org). Access API calls ("/access/api/...") are sent there,
+appended to its path. When it is unset, the backend checks that Access is reachable through "url". If it isn't, the
+Access address is discovered from the custom base URL of the platform, stored with the config, and returned by reads as
+"discovered_access_url"; the write warns if neither reaches Access.
+
+An optional "urls" parameter lists several addresses of the same Artifactory (e.g. a primary and a DR site) in
+order, instead of "url". Calls go to the first url, and move to the next one when a call fails with a connection error
+or a 5xx response. The first url is tried again 5 minutes after failing over. Reads return the url calls currently go
+to as "active_url". Writing "urls" clears the stored access token like "url", unless written to config/admin/credentials.
 
 An optional "username_template" parameter will override the built-in default username_template for dynamically generating
 usernames if a static one is not provided.
 
 An optional "bypass_artifactory_tls_verification" parameter will enable bypassing the TLS connection verification with Artifactory.
+While it is set, config reads and every token issued return a warning, so it doesn't linger unnoticed. Setting
+"allow_tls_bypass" to false rejects it, and turning it on, on this mount.
+
+An optional "fips_mode" parameter restricts connections to Artifactory to what FIPS 140 approves: TLS 1.2 or higher,
+ECDHE key exchange with AES-GCM cipher suites on the P-256, P-384 or P-521 curves, and RSA client keys of at least 2048
+bits or ECDSA client keys on those curves. Settings that violate it, such as "bypass_artifactory_tls_verification",
+are rejected. Hashes the backend computes, of pinned keys and issued tokens, are sha256. The crypto modules themselves
+are FIPS validated only if the plugin is built with a validated Go crypto module (GOFIPS140).
+
+An optional "ca_cert_pem" parameter sets a bundle of PEM encoded CA certificates that are trusted, in addition to the
+system roots, to verify Artifactory's certificate, so an internal CA doesn't require bypassing verification.
+
+An optional "tls_pinned_spki_hashes" parameter pins Artifactory's certificate instead of verifying it against CAs: a
+connection is accepted if the leaf certificate is valid for the host and it, or an intermediate of the chain, has a
+public key whose base64 encoded sha256 hash (as printed by "openssl x509 -pubkey | openssl pkey -pubin -outform der |
+openssl dgst -sha256 -binary | base64") is listed. It can't be combined with "bypass_artifactory_tls_verification" or
+"ca_cert_pem".
+
+Optional "tls_min_version" and "tls_cipher_suites" parameters restrict the TLS connections to Artifactory, e.g.
+"tls_min_version=tls13" for compliance regimes that require TLS 1.3. Cipher suites are named as IANA does, and apply to
+TLS 1.2 and lower, since TLS 1.3 suites aren't configurable in Go. Suites Go considers insecure are rejected. In
+"fips_mode", they may narrow the approved versions and suites, but not widen them.
+
+An optional "client_cert" parameter sets a PEM encoded client certificate, presented to Artifactory for ingresses that
+enforce mutual TLS along with its private key, which is a credential and written to config/admin/credentials as
+"client_key". Reads return the certificate. Write it as an empty string to stop presenting a certificate.
+
+Optional "http_proxy", "https_proxy" and "no_proxy" parameters set the forward proxy used to reach Artifactory, in
+the format of the environment variables of the same name, which they override for this mount. Those not set are taken
+from Vault's environment. Reads redact proxy passwords.
+
+An optional "auth_header" parameter selects how the admin token is sent: "bearer" (the default) uses the Authorization
+header, "x-jfrog-art-api" uses the X-JFrog-Art-Api header, for API key admin credentials and older Artifactory 6.x
+endpoints that reject Bearer authentication.
+
+An optional "custom_headers" parameter sets extra HTTP headers sent on every call to Artifactory, e.g. a header an
+auth proxy in front of it requires. Headers the backend sets itself, such as "Authorization", can't be overridden. A
+role's "request_headers" take precedence over them. Since the values are often credentials, reads return only the
+header names.
+
+An optional "check_health_before_issuance" parameter will probe Artifactory's system/ping endpoint before issuing tokens,
+so an unhealthy Artifactory fails fast with a clear error instead of a confusing token API error.
+
+An optional "usage_reporting" parameter opts in to reporting which backend features are used to Artifactory's
+api/system/usage endpoint. Nothing is reported unless it is set. "usage_product_id" overrides the product name reported
+with it, which defaults to the plugin name and version.
+
+An optional "revocation_sync_interval" parameter periodically looks up the tracked tokens in Artifactory, and marks
+those revoked there (e.g. from the Artifactory UI) or expired, so that tokens/ shows them and their leases are not
+renewed.
+
+The metadata of issued tokens is compacted hourly: tokens tracked before storage was sharded are moved into their shard,
+and tokens whose lease expired more than "tracked_token_retention" (24h by default) ago are removed. An optional
+"max_tracked_tokens" parameter bounds how many tracked tokens are kept, by removing those expired or revoked in
+Artifactory, oldest first, beyond it. Tokens with an active lease are never removed.
+
+Every write stores a version of the configuration, of which the last "max_config_versions" (10 by default) are kept.
+List them at config/admin/versions, and restore one with config/admin/rollback after a bad write.
+
+An optional "max_response_size" parameter bounds how many bytes of each Artifactory response body are read into
+memory, so multi-megabyte error pages from a misconfigured proxy don't cause memory spikes. It defaults to 1 MiB.
+
+An optional "preflight_check" parameter verifies, whenever the config or its credentials are written, that the admin
+token can actually create and revoke access tokens, rather than only read the version: a token is issued to the
+"vault-preflight" user with the scope of the default "readers" group and revoked at once. If either fails, the write is
+rejected with an error listing what the admin token can't do, along with Artifactory's error for each.
+
+An optional "disable_version_check" parameter skips the api/system/version call, for admin tokens scoped so narrowly
+that it fails, and uses the version set in "artifactory_version" instead, which is then required. The version selects
+the token APIs the backend calls, so it must match the connected Artifactory. Usage reporting is already not sent
+unless "usage_reporting" is set.
+
+Writing with "dry_run" set validates the configuration the write would result in without saving it: the TLS, proxy
+and username template settings are parsed, and the version is read, Access reachability checked and a token created and
+revoked with the proposed url and admin token. The response lists the result of each check under "checks", and sets
+"valid" if they all passed, so changes can be validated in CI before they are applied.
+
+Deleting the configuration is refused while roles are defined or leases are active, which it would strand, unless
+"force" is set.
+
+An optional "offline_mode" parameter disables the calls to Artifactory the backend doesn't need to issue tokens, for
+air-gapped installs where those endpoints are firewalled: usage reporting is not sent, the version is only fetched if
+it isn't known yet, the Access reachability check is skipped, and the root certificate is fetched at most once per
+configuration write instead of every time a token is inspected.
+
+Optional "rotation_period" and "rotation_window" parameters rotate the admin token automatically, as config/rotate does,
+once "rotation_period" has passed since it was written or last rotated, or once the token expires within
+"rotation_window". The time of the last rotation is returned as "rotated_at".
+
+An optional "expiry_warning_threshold" parameter makes an expiring admin token alertable: once it expires within the
+threshold, a warning is logged and an "artifactory-admin-token-expiring" Vault event is sent, once per admin token.
+The remaining lifetime of JWT admin tokens is reported as the "artifactory.admin_token.remaining_seconds" gauge either
+way.
+
+Optional "default_ttl" and "max_ttl" parameters are ceilings on the TTLs of every role of the mount, as a guardrail for
+the teams writing roles: roles with higher TTLs are issued and renewed with those of the config instead, and reading
+them returns a warning. Roles that don't set a TTL use that of the config.
+
+Optional "request_timeout", "max_retries" and "retry_backoff" parameters tune calls to Artifactory: each attempt times
+out after "request_timeout", and calls failing with a connection error, a 429 or a 5xx response are retried up to
+"max_retries" times, waiting "retry_backoff" before the first retry and twice as long before each further one (or as
+long as a Retry-After header asks). Retries are off by default, since a token creation that fails after Artifactory
+created the token would create a second one on retry.
+
+An optional "user_agent_attribution" parameter adds the mount path and the Vault cluster to the User-Agent of calls to
+Artifactory, e.g. "vault-plugin-secrets-artifactory/1.0.0 (mount=artifactory/; cluster=vault-prod)", so the access
+logs of an Artifactory shared by several mounts or clusters can attribute load to them. "cluster_name" sets the
+cluster name reported, which defaults to the Vault cluster id.
+
+An optional "reject_deprecated" parameter makes requests that use deprecated parameters or paths fail, instead of
+being served with a deprecation warning, to verify that clients have migrated.
+
+On mounts enabled with the "env_bootstrap=true" option, while no configuration is stored, one is taken from the
+JFROG_URL and JFROG_ACCESS_TOKEN environment variables of Vault, if both were set when the plugin started, so dev
+servers and demos need no setup. Reads return where the effective configuration comes from as "source", "env" or
+"storage". Writing the configuration stores the values of the environment along with the changes, and from then on the
+stored configuration is used. A configuration taken from the environment can't be deleted.
+
+An optional "shared_config" parameter makes the mount use the configuration of another mount of this plugin instead,
+so teams can run their own mounts and roles while a platform team owns the single admin token on its mount. It takes
+the uuid of that mount, which must be in the same namespace and list this mount's uuid in its "shared_with" parameter.
+The namespace is taken from the path the mount is served at, and recorded on writes of that mount's config/admin, so
+mounts sharing a configuration must be mounted at paths with the same parent. The configuration is read
+from that mount on every use, so its rotations and changes apply at once, and can only be changed there. Reads return
+"shared" as "source". Delete this mount's config/admin to stop using the shared configuration.
 
 No renewals or new tokens will be issued if the backend configuration (config/admin) is deleted.
 `,
@@ -75,11 +388,66 @@ No renewals or new tokens will be issued if the backend configuration (config/ad
 }
 
 type adminConfiguration struct {
-	AccessToken                      string `json:"access_token"`
-	ArtifactoryURL                   string `json:"artifactory_url"`
-	UsernameTemplate                 string `json:"username_template,omitempty"`
-	UseExpiringTokens                bool   `json:"use_expiring_tokens,omitempty"`
-	BypassArtifactoryTLSVerification bool   `json:"bypass_artifactory_tls_verification,omitempty"`
+	AccessToken                      string            `json:"access_token"`
+	ArtifactoryURL                   string            `json:"artifactory_url"`
+	URLs                             []string          `json:"urls,omitempty"`
+	AccessURL                        string            `json:"access_url,omitempty"`
+	DiscoveredAccessURL              string            `json:"discovered_access_url,omitempty"`
+	UsernameTemplate                 string            `json:"username_template,omitempty"`
+	UseExpiringTokens                bool              `json:"use_expiring_tokens,omitempty"`
+	BypassArtifactoryTLSVerification bool              `json:"bypass_artifactory_tls_verification,omitempty"`
+	FIPSMode                         bool              `json:"fips_mode,omitempty"`
+	DenyTLSBypass                    bool              `json:"deny_tls_bypass,omitempty"`
+	CACertPEM                        string            `json:"ca_cert_pem,omitempty"`
+	TLSPinnedSPKIHashes              []string          `json:"tls_pinned_spki_hashes,omitempty"`
+	TLSMinVersion                    string            `json:"tls_min_version,omitempty"`
+	TLSCipherSuites                  []string          `json:"tls_cipher_suites,omitempty"`
+	ClientCert                       string            `json:"client_cert,omitempty"`
+	HTTPProxy                        string            `json:"http_proxy,omitempty"`
+	HTTPSProxy                       string            `json:"https_proxy,omitempty"`
+	NoProxy                          string            `json:"no_proxy,omitempty"`
+	CustomHeaders                    map[string]string `json:"custom_headers,omitempty"`
+	SharedConfig                     string            `json:"shared_config,omitempty"`
+	SharedWith                       []string          `json:"shared_with,omitempty"`
+	Namespace                        string            `json:"namespace,omitempty"`
+	ClientKey                        string            `json:"client_key,omitempty"`
+	CheckHealthBeforeIssuance        bool              `json:"check_health_before_issuance,omitempty"`
+	AuthHeader                       string            `json:"auth_header,omitempty"`
+	UsesAPIKey                       bool              `json:"uses_api_key,omitempty"`
+	OfflineMode                      bool              `json:"offline_mode,omitempty"`
+	DisableVersionCheck              bool              `json:"disable_version_check,omitempty"`
+	ArtifactoryVersion               string            `json:"artifactory_version,omitempty"`
+	PreflightCheck                   bool              `json:"preflight_check,omitempty"`
+	RejectDeprecated                 bool              `json:"reject_deprecated,omitempty"`
+	UsageReporting                   bool              `json:"usage_reporting,omitempty"`
+	UsageProductID                   string            `json:"usage_product_id,omitempty"`
+	UserAgentAttribution             bool              `json:"user_agent_attribution,omitempty"`
+	ClusterName                      string            `json:"cluster_name,omitempty"`
+	RevocationSyncInterval           time.Duration     `json:"revocation_sync_interval,omitempty"`
+	RotationPeriod                   time.Duration     `json:"rotation_period,omitempty"`
+	RotationWindow                   time.Duration     `json:"rotation_window,omitempty"`
+	ExpiryWarningThreshold           time.Duration     `json:"expiry_warning_threshold,omitempty"`
+	DefaultTTL                       time.Duration     `json:"default_ttl,omitempty"`
+	MaxTTL                           time.Duration     `json:"max_ttl,omitempty"`
+	RequestTimeout                   time.Duration     `json:"request_timeout,omitempty"`
+	MaxRetries                       int               `json:"max_retries,omitempty"`
+	RetryBackoff                     time.Duration     `json:"retry_backoff,omitempty"`
+	MaxResponseSize                  int64             `json:"max_response_size,omitempty"`
+	TrackedTokenRetention            time.Duration     `json:"tracked_token_retention,omitempty"`
+	MaxTrackedTokens                 int               `json:"max_tracked_tokens,omitempty"`
+	MaxConfigVersions                int               `json:"max_config_versions,omitempty"`
+	CredentialsUpdatedAt             time.Time         `json:"credentials_updated_at,omitempty"`
+	Generation                       uint64            `json:"generation,omitempty"`
+	RotatedAt                        time.Time         `json:"rotated_at,omitempty"`
+
+	// roleHeaders are the request headers of the role a call is made for. They are set per call and never stored.
+	roleHeaders map[string]string
+
+	// fromEnv is set on the config read from JFROG_URL and JFROG_ACCESS_TOKEN while none is stored
+	fromEnv bool
+
+	// sharedFrom is the uuid of the mount the config was read from, for a config/admin referencing it with shared_config
+	sharedFrom string
 }
 
 func (b *backend) pathConfigUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -91,26 +459,65 @@ func (b *backend) pathConfigUpdate(ctx context.Context, req *logical.Request, da
 		return nil, err
 	}
 
+	if val, ok := data.GetOk("shared_config"); ok {
+		return b.referenceSharedConfiguration(ctx, req, data, config, val.(string))
+	}
+
 	if config == nil {
 		config = &adminConfiguration{}
 	}
 
+	if config.sharedFrom != "" {
+		return sharedConfigResponse(*config), nil
+	}
+
+	// The key is a credential, so policies granting this path must not grant setting it
+	if _, ok := req.Data["client_key"]; ok {
+		return logical.ErrorResponse("client_key is not accepted on config/admin, write it to config/admin/credentials"), nil
+	}
+
+	if val, ok := data.GetOk("shared_with"); ok {
+		config.SharedWith = val.([]string)
+	}
+
+	// Mounts referencing this one with shared_config must be in its namespace
+	if len(req.MountPoint) > 0 {
+		config.Namespace = mountNamespace(req.MountPoint)
+	}
+
 	if val, ok := data.GetOk("url"); ok {
 		config.ArtifactoryURL = val.(string)
+		config.URLs = nil
 		config.AccessToken = "" // clear access token if URL changes, requires setting access_token and url together for security reasons
 	}
 
+	if val, ok := data.GetOk("urls"); ok {
+		if resp := setFailoverURLs(config, data, val.([]string)); resp != nil {
+			return resp, nil
+		}
+		config.AccessToken = "" // as for url, the token must not be sent to new urls without being written along with them
+	}
+
 	if val, ok := data.GetOk("access_token"); ok {
 		config.AccessToken = val.(string)
+		config.UsesAPIKey = false
+		config.CredentialsUpdatedAt = time.Now()
+	}
+
+	if val, ok := data.GetOk("access_url"); ok {
+		config.AccessURL = val.(string)
+		if len(config.AccessURL) > 0 {
+			if _, err := parseURLWithDefaultPort(config.AccessURL); err != nil {
+				return logical.ErrorResponse("invalid access_url: %s", err), nil
+			}
+		}
 	}
 
 	if val, ok := data.GetOk("username_template"); ok {
 		config.UsernameTemplate = val.(string)
-		up, err := testUsernameTemplate(config.UsernameTemplate)
-		if err != nil {
+		if _, err := testUsernameTemplate(config.UsernameTemplate); err != nil {
 			return logical.ErrorResponse("username_template error"), err
 		}
-		b.usernameProducer = up
 	}
 
 	if val, ok := data.GetOk("use_expiring_tokens"); ok {
@@ -121,39 +528,291 @@ func (b *backend) pathConfigUpdate(ctx context.Context, req *logical.Request, da
 		config.BypassArtifactoryTLSVerification = val.(bool)
 	}
 
+	if val, ok := data.GetOk("allow_tls_bypass"); ok {
+		config.DenyTLSBypass = !val.(bool)
+	}
+
+	if val, ok := data.GetOk("fips_mode"); ok {
+		config.FIPSMode = val.(bool)
+	}
+
+	if config.DenyTLSBypass && config.BypassArtifactoryTLSVerification {
+		return logical.ErrorResponse("bypass_artifactory_tls_verification is not allowed on this mount, allow_tls_bypass is false"), nil
+	}
+
+	if val, ok := data.GetOk("ca_cert_pem"); ok {
+		config.CACertPEM = val.(string)
+	}
+
+	if val, ok := data.GetOk("tls_pinned_spki_hashes"); ok {
+		config.TLSPinnedSPKIHashes = val.([]string)
+	}
+
+	if val, ok := data.GetOk("tls_min_version"); ok {
+		config.TLSMinVersion = val.(string)
+	}
+
+	if val, ok := data.GetOk("tls_cipher_suites"); ok {
+		config.TLSCipherSuites = val.([]string)
+	}
+
+	if val, ok := data.GetOk("client_cert"); ok {
+		config.ClientCert = val.(string)
+	}
+
+	if _, err := artifactoryTLSConfig(*config); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if val, ok := data.GetOk("http_proxy"); ok {
+		config.HTTPProxy = val.(string)
+	}
+
+	if val, ok := data.GetOk("https_proxy"); ok {
+		config.HTTPSProxy = val.(string)
+	}
+
+	if val, ok := data.GetOk("no_proxy"); ok {
+		config.NoProxy = val.(string)
+	}
+
+	if _, err := artifactoryProxy(*config); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if val, ok := data.GetOk("custom_headers"); ok {
+		config.CustomHeaders = val.(map[string]string)
+		if err := validateRequestHeaders("custom_headers", config.CustomHeaders); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if val, ok := data.GetOk("check_health_before_issuance"); ok {
+		config.CheckHealthBeforeIssuance = val.(bool)
+	}
+
+	if val, ok := data.GetOk("usage_reporting"); ok {
+		config.UsageReporting = val.(bool)
+	}
+
+	if val, ok := data.GetOk("usage_product_id"); ok {
+		config.UsageProductID = val.(string)
+	}
+
+	if val, ok := data.GetOk("user_agent_attribution"); ok {
+		config.UserAgentAttribution = val.(bool)
+	}
+
+	if val, ok := data.GetOk("cluster_name"); ok {
+		config.ClusterName = val.(string)
+	}
+
+	if val, ok := data.GetOk("revocation_sync_interval"); ok {
+		config.RevocationSyncInterval = time.Duration(val.(int)) * time.Second
+	}
+
+	if val, ok := data.GetOk("rotation_period"); ok {
+		config.RotationPeriod = time.Duration(val.(int)) * time.Second
+	}
+
+	if val, ok := data.GetOk("rotation_window"); ok {
+		config.RotationWindow = time.Duration(val.(int)) * time.Second
+	}
+
+	if config.RotationPeriod < 0 || config.RotationWindow < 0 {
+		return logical.ErrorResponse("rotation_period and rotation_window must not be negative"), nil
+	}
+
+	if val, ok := data.GetOk("expiry_warning_threshold"); ok {
+		config.ExpiryWarningThreshold = time.Duration(val.(int)) * time.Second
+		if config.ExpiryWarningThreshold < 0 {
+			return logical.ErrorResponse("expiry_warning_threshold must not be negative"), nil
+		}
+	}
+
+	if val, ok := data.GetOk("default_ttl"); ok {
+		config.DefaultTTL = time.Duration(val.(int)) * time.Second
+	}
+
+	if val, ok := data.GetOk("max_ttl"); ok {
+		config.MaxTTL = time.Duration(val.(int)) * time.Second
+	}
+
+	if config.DefaultTTL < 0 || config.MaxTTL < 0 {
+		return logical.ErrorResponse("default_ttl and max_ttl must not be negative"), nil
+	}
+
+	if config.DefaultTTL > 0 && config.MaxTTL > 0 && config.DefaultTTL > config.MaxTTL {
+		return logical.ErrorResponse("default_ttl (%s) must not exceed max_ttl (%s)", config.DefaultTTL, config.MaxTTL), nil
+	}
+
+	if val, ok := data.GetOk("request_timeout"); ok {
+		config.RequestTimeout = time.Duration(val.(int)) * time.Second
+	}
+
+	if val, ok := data.GetOk("max_retries"); ok {
+		config.MaxRetries = val.(int)
+	}
+
+	if val, ok := data.GetOk("retry_backoff"); ok {
+		config.RetryBackoff = time.Duration(val.(int)) * time.Second
+	}
+
+	if config.RequestTimeout < 0 || config.MaxRetries < 0 || config.RetryBackoff < 0 {
+		return logical.ErrorResponse("request_timeout, max_retries and retry_backoff must not be negative"), nil
+	}
+
+	if val, ok := data.GetOk("max_response_size"); ok {
+		config.MaxResponseSize = int64(val.(int))
+		if config.MaxResponseSize < 0 {
+			return logical.ErrorResponse("max_response_size must not be negative"), nil
+		}
+	}
+
+	if val, ok := data.GetOk("tracked_token_retention"); ok {
+		config.TrackedTokenRetention = time.Duration(val.(int)) * time.Second
+	}
+
+	if val, ok := data.GetOk("max_tracked_tokens"); ok {
+		config.MaxTrackedTokens = val.(int)
+	}
+
+	if config.TrackedTokenRetention < 0 || config.MaxTrackedTokens < 0 {
+		return logical.ErrorResponse("tracked_token_retention and max_tracked_tokens must not be negative"), nil
+	}
+
+	if val, ok := data.GetOk("max_config_versions"); ok {
+		config.MaxConfigVersions = val.(int)
+		if config.MaxConfigVersions < 0 {
+			return logical.ErrorResponse("max_config_versions must not be negative"), nil
+		}
+	}
+
+	if val, ok := data.GetOk("offline_mode"); ok {
+		config.OfflineMode = val.(bool)
+	}
+
+	if val, ok := data.GetOk("preflight_check"); ok {
+		config.PreflightCheck = val.(bool)
+	}
+
+	if val, ok := data.GetOk("disable_version_check"); ok {
+		config.DisableVersionCheck = val.(bool)
+	}
+
+	if val, ok := data.GetOk("artifactory_version"); ok {
+		config.ArtifactoryVersion = strings.TrimSpace(val.(string))
+		if len(config.ArtifactoryVersion) > 0 {
+			if _, err := version.NewVersion(config.ArtifactoryVersion); err != nil {
+				return logical.ErrorResponse("invalid artifactory_version: %s", err), nil
+			}
+		}
+	}
+
+	if config.DisableVersionCheck && len(config.ArtifactoryVersion) == 0 {
+		return logical.ErrorResponse("artifactory_version is required when disable_version_check is set"), nil
+	}
+
+	if val, ok := data.GetOk("reject_deprecated"); ok {
+		config.RejectDeprecated = val.(bool)
+	}
+
+	if val, ok := data.GetOk("auth_header"); ok {
+		config.AuthHeader = val.(string)
+		if config.AuthHeader != authHeaderBearer && config.AuthHeader != authHeaderArtApi {
+			return logical.ErrorResponse("auth_header must be '%s' or '%s'", authHeaderBearer, authHeaderArtApi), nil
+		}
+	}
+
 	if config.AccessToken == "" {
-		return logical.ErrorResponse("access_token is required"), nil
+		return logical.ErrorResponse("access_token is required, write it to config/admin/credentials"), nil
 	}
 
 	if config.ArtifactoryURL == "" {
 		return logical.ErrorResponse("url is required"), nil
 	}
 
-	b.InitializeHttpClient(config)
-
 	go b.sendUsage(*config, "pathConfigRotateUpdate")
 
-	err = b.getVersion(*config)
+	if data.Get("dry_run").(bool) {
+		return b.dryRunAdminConfiguration(ctx, config)
+	}
+
+	return b.saveAdminConfiguration(ctx, req.Storage, config)
+}
+
+// saveAdminConfiguration initializes the backend for a validated config and stores it, returning any warnings raised
+// while checking the config against Artifactory
+func (b *backend) saveAdminConfiguration(ctx context.Context, storage logical.Storage, config *adminConfiguration) (*logical.Response, error) {
+	// Offline, the version is only fetched once
+	setupConfig := *config
+	if current := b.artifactoryVersion(); config.OfflineMode && len(current) > 0 {
+		setupConfig.DisableVersionCheck = true
+		setupConfig.ArtifactoryVersion = current
+	}
+
+	setup, err := b.newConfigSetup(setupConfig)
 	if err != nil {
 		return logical.ErrorResponse("Unable to get Artifactory Version. Check url and access_token fields. TLS connection verification with Artifactory can be skipped by setting bypass_artifactory_tls_verification field to 'true'"), err
 	}
+	b.storeSetup(func(s *configSetup) { *s = *setup })
+	b.resetRootCert()
+
+	var warnings []string
+	if (len(config.ClientCert) > 0) != (len(config.ClientKey) > 0) {
+		warnings = append(warnings, "No client certificate is presented to Artifactory until both client_cert, on config/admin, and client_key, on config/admin/credentials, are set.")
+	}
+
+	// The Access address is discovered again on every write, as the platform may have been rerouted since
+	config.DiscoveredAccessURL = ""
+	if b.useNewAccessAPI() && !config.OfflineMode {
+		if err := b.checkAccessReachable(*config); err != nil {
+			b.Logger().Warn("Access service not reachable", "err", err)
+			if len(config.AccessURL) > 0 {
+				warnings = append(warnings, fmt.Sprintf("JFrog Access service is not reachable at access_url: %s", err))
+			} else if discovered, discoverErr := b.discoverAccessURL(*config); discoverErr != nil {
+				b.Logger().Warn("Access service not discovered", "err", discoverErr)
+				warnings = append(warnings, fmt.Sprintf("JFrog Access service is not reachable through url: %s, and was not discovered: %s. If Access is routed separately, set access_url.", err, discoverErr))
+			} else {
+				b.Logger().Info("discovered Access service", "url", discovered)
+				config.DiscoveredAccessURL = discovered
+			}
+		}
+	}
+
+	if config.PreflightCheck {
+		if missing := b.preflightCheck(ctx, *config); len(missing) > 0 {
+			return logical.ErrorResponse("preflight check failed, the admin token can't: %s", strings.Join(missing, "; ")), nil
+		}
+	}
+
+	config.Generation = nextConfigGeneration(config.Generation)
 
 	entry, err := logical.StorageEntryJSON("config/admin", config)
 	if err != nil {
 		return nil, err
 	}
 
-	err = req.Storage.Put(ctx, entry)
+	err = storage.Put(ctx, entry)
 	if err != nil {
 		return nil, err
 	}
 
+	b.setConfigGeneration(config.Generation)
+	b.recordConfigVersion(ctx, storage, *config)
+
+	if len(warnings) > 0 {
+		return &logical.Response{Warnings: warnings}, nil
+	}
+
 	return nil, nil
 }
 
-func (b *backend) pathConfigDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+func (b *backend) pathConfigDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
 	b.configMutex.Lock()
 	defer b.configMutex.Unlock()
+	defer b.rolesMutex.RUnlock()
 
 	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
 	if err != nil {
@@ -166,6 +825,30 @@ func (b *backend) pathConfigDelete(ctx context.Context, req *logical.Request, _
 
 	go b.sendUsage(*config, "pathConfigDelete")
 
+	if config.fromEnv {
+		return logical.ErrorResponse("the configuration is set by the %s and %s environment variables, unset them to remove it", envArtifactoryURL, envAccessToken), nil
+	}
+
+	// Without the config, roles can't issue tokens and leases can't revoke theirs
+	if !data.Get("force").(bool) {
+		roleNames, err := req.Storage.List(ctx, "roles/")
+		if err != nil {
+			return nil, err
+		}
+
+		leases, err := b.activeTokens(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(roleNames) > 0 || leases > 0 {
+			message := fmt.Sprintf("%d roles are defined and %d leases are active, which deleting the configuration would "+
+				"strand: the leases could no longer revoke their tokens in Artifactory. Delete the roles and revoke the "+
+				"leases first, or delete the configuration with force=true", len(roleNames), leases)
+			return logical.ErrorResponse(message), logical.CodedError(http.StatusConflict, message)
+		}
+	}
+
 	if err := req.Storage.Delete(ctx, "config/admin"); err != nil {
 		return nil, err
 	}
@@ -188,41 +871,209 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, _ *f
 
 	go b.sendUsage(*config, "pathConfigRead")
 
-	// I'm not sure if I should be returning the access token, so I'll hash it.
-	accessTokenHash := sha256.Sum256([]byte(config.AccessToken))
-
 	configMap := map[string]interface{}{
-		"access_token_sha256":                 fmt.Sprintf("%x", accessTokenHash[:]),
+		"source":                              configSource(*config),
 		"url":                                 config.ArtifactoryURL,
-		"version":                             b.version,
+		"version":                             b.artifactoryVersion(),
 		"bypass_artifactory_tls_verification": config.BypassArtifactoryTLSVerification,
+		"allow_tls_bypass":                    !config.DenyTLSBypass,
+		"fips_mode":                           config.FIPSMode,
+		"check_health_before_issuance":        config.CheckHealthBeforeIssuance,
+		"offline_mode":                        config.OfflineMode,
+		"disable_version_check":               config.DisableVersionCheck,
+		"preflight_check":                     config.PreflightCheck,
+		"reject_deprecated":                   config.RejectDeprecated,
+		"usage_reporting":                     config.UsageReporting,
+		"user_agent_attribution":              config.UserAgentAttribution,
+		"auth_header":                         authHeaderBearer,
 	}
 
-	// Optionally include username_template
-	if len(config.UsernameTemplate) > 0 {
-		configMap["username_template"] = config.UsernameTemplate
+	if len(config.AuthHeader) > 0 {
+		configMap["auth_header"] = config.AuthHeader
 	}
 
-	// Optionally include token info if it parses properly
-	token, err := b.getTokenInfo(*config, config.AccessToken)
-	if err != nil {
-		b.Logger().Warn("Error parsing AccessToken: " + err.Error())
-	} else {
-		configMap["token_id"] = token.TokenID
-		configMap["username"] = token.Username
-		configMap["scope"] = token.Scope
-		if token.Expires > 0 {
-			configMap["exp"] = token.Expires
-			tm := time.Unix(token.Expires, 0)
-			configMap["expires"] = tm.Local()
+	if len(config.ArtifactoryVersion) > 0 {
+		configMap["artifactory_version"] = config.ArtifactoryVersion
+	}
+
+	if len(config.URLs) > 0 {
+		configMap["urls"] = config.URLs
+		configMap["active_url"] = b.failover.activeURL()
+		if configMap["active_url"] == "" {
+			configMap["active_url"] = config.URLs[0]
+		}
+	}
+
+	if len(config.AccessURL) > 0 {
+		configMap["access_url"] = config.AccessURL
+	}
+
+	if len(config.DiscoveredAccessURL) > 0 {
+		configMap["discovered_access_url"] = config.DiscoveredAccessURL
+	}
+
+	if len(config.CACertPEM) > 0 {
+		configMap["ca_cert_pem"] = config.CACertPEM
+	}
+
+	if len(config.TLSPinnedSPKIHashes) > 0 {
+		configMap["tls_pinned_spki_hashes"] = config.TLSPinnedSPKIHashes
+	}
+
+	if len(config.TLSMinVersion) > 0 {
+		configMap["tls_min_version"] = config.TLSMinVersion
+	}
+
+	if len(config.TLSCipherSuites) > 0 {
+		configMap["tls_cipher_suites"] = config.TLSCipherSuites
+	}
+
+	if len(config.ClientCert) > 0 {
+		configMap["client_cert"] = config.ClientCert
+	}
+
+	if len(config.HTTPProxy) > 0 {
+		configMap["http_proxy"] = redactProxyURL(config.HTTPProxy)
+	}
+
+	if len(config.HTTPSProxy) > 0 {
+		configMap["https_proxy"] = redactProxyURL(config.HTTPSProxy)
+	}
+
+	if len(config.NoProxy) > 0 {
+		configMap["no_proxy"] = config.NoProxy
+	}
+
+	if len(config.CustomHeaders) > 0 {
+		names := make([]string, 0, len(config.CustomHeaders))
+		for name := range config.CustomHeaders {
+			names = append(names, name)
 		}
+		sort.Strings(names)
+		configMap["custom_headers"] = names
+	}
+
+	if config.sharedFrom != "" {
+		configMap["shared_config"] = config.sharedFrom
+	}
+
+	if len(config.SharedWith) > 0 {
+		configMap["shared_with"] = config.SharedWith
+	}
+
+	if len(config.UsageProductID) > 0 {
+		configMap["usage_product_id"] = config.UsageProductID
+	}
+
+	if len(config.ClusterName) > 0 {
+		configMap["cluster_name"] = config.ClusterName
+	}
+
+	if !config.CredentialsUpdatedAt.IsZero() {
+		configMap["credentials_updated_at"] = config.CredentialsUpdatedAt
+	}
+
+	if !config.RotatedAt.IsZero() {
+		configMap["rotated_at"] = config.RotatedAt
+	}
+
+	if config.MaxResponseSize > 0 {
+		configMap["max_response_size"] = config.MaxResponseSize
+	}
+
+	if config.TrackedTokenRetention > 0 {
+		configMap["tracked_token_retention"] = config.TrackedTokenRetention.Seconds()
+	}
+
+	if config.MaxTrackedTokens > 0 {
+		configMap["max_tracked_tokens"] = config.MaxTrackedTokens
+	}
+
+	if config.MaxConfigVersions > 0 {
+		configMap["max_config_versions"] = config.MaxConfigVersions
+	}
+
+	if config.RevocationSyncInterval > 0 {
+		configMap["revocation_sync_interval"] = config.RevocationSyncInterval.Seconds()
+	}
+
+	if config.RotationPeriod > 0 {
+		configMap["rotation_period"] = config.RotationPeriod.Seconds()
+	}
+
+	if config.RotationWindow > 0 {
+		configMap["rotation_window"] = config.RotationWindow.Seconds()
+	}
+
+	if config.ExpiryWarningThreshold > 0 {
+		configMap["expiry_warning_threshold"] = config.ExpiryWarningThreshold.Seconds()
+	}
+
+	if config.DefaultTTL > 0 {
+		configMap["default_ttl"] = config.DefaultTTL.Seconds()
+	}
+
+	if config.MaxTTL > 0 {
+		configMap["max_ttl"] = config.MaxTTL.Seconds()
+	}
+
+	if config.RequestTimeout > 0 {
+		configMap["request_timeout"] = config.RequestTimeout.Seconds()
+	}
+
+	if config.MaxRetries > 0 {
+		configMap["max_retries"] = config.MaxRetries
+		configMap["retry_backoff"] = config.retryBackoff().Seconds()
+	}
+
+	// Optionally include username_template
+	if len(config.UsernameTemplate) > 0 {
+		configMap["username_template"] = config.UsernameTemplate
 	}
 
 	if b.supportForceRevocable() {
 		configMap["use_expiring_tokens"] = config.UseExpiringTokens
 	}
 
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: configMap,
-	}, nil
+	}
+	addTLSBypassWarning(resp, *config)
+
+	return resp, nil
+}
+
+// tlsBypassWarning is returned by config reads and token issuance while TLS verification of Artifactory is bypassed
+const tlsBypassWarning = "TLS verification of Artifactory is bypassed (bypass_artifactory_tls_verification=true): " +
+	"admin and issued tokens are sent over connections that can be intercepted."
+
+// addTLSBypassWarning warns on a successful resp if config bypasses TLS verification
+func addTLSBypassWarning(resp *logical.Response, config adminConfiguration) {
+	if resp != nil && !resp.IsError() && config.BypassArtifactoryTLSVerification {
+		resp.AddWarning(tlsBypassWarning)
+	}
+}
+
+// setFailoverURLs sets the failover urls of config, and its url to the first of them. It returns an error response if
+// they are invalid, or url was written along with a different first url.
+func setFailoverURLs(config *adminConfiguration, data *framework.FieldData, urls []string) *logical.Response {
+	if len(urls) == 0 {
+		return logical.ErrorResponse("urls must not be empty")
+	}
+
+	if err := validateFailoverURLs(urls); err != nil {
+		return logical.ErrorResponse("invalid urls: %s", err)
+	}
+
+	if val, ok := data.GetOk("url"); ok && val.(string) != urls[0] {
+		return logical.ErrorResponse("url and urls were both written, but url isn't the first of urls")
+	}
+
+	config.ArtifactoryURL = urls[0]
+	config.URLs = urls
+	if len(urls) == 1 {
+		config.URLs = nil
+	}
+
+	return nil
 }
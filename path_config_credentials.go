@@ -0,0 +1,227 @@
+package artifactory
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathConfigCredentials() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/admin/credentials",
+		Fields: map[string]*framework.FieldSchema{
+			"access_token": {
+				Type:        framework.TypeString,
+				Description: "Administrator token to access Artifactory. Required unless api_key is set.",
+			},
+			"api_key": {
+				Type:        framework.TypeString,
+				Description: "Optional. Administrator API key to access Artifactory instead of access_token, for older Artifactory versions. Sent in the X-JFrog-Art-Api header.",
+			},
+			"url": {
+				Type:        framework.TypeString,
+				Description: "Optional. Address of the Artifactory instance. Required if the backend is not configured yet. Since changing the url clears the stored access token, a new url must be written here along with its access_token.",
+			},
+			"urls": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Optional. Ordered addresses of the same Artifactory instance, instead of url. Calls fail over to the next one on connection errors or 5xx responses.",
+			},
+			"client_key": {
+				Type:        framework.TypeString,
+				Description: "Optional. PEM encoded private key of the client_cert of config/admin, for ingresses that require mutual TLS. It is stored seal wrapped when available, and never returned. May be written without access_token once the backend is configured.",
+			},
+			"bootstrap": {
+				Type:        framework.TypeBool,
+				Description: "Optional. Treat access_token as a short-lived bootstrap token: use it once to create a non-expiring admin token with the same scope and username, which replaces it, and revoke it.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigCredentialsUpdate,
+				Summary:  "Set the administrator token used to access Artifactory.",
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigCredentialsRead,
+				Summary:  "Examine the administrator token used to access Artifactory.",
+			},
+		},
+		HelpSynopsis: `Interact with the Artifactory secrets backend credentials.`,
+		HelpDescription: `
+Sets the "access_token" used to access Artifactory. It must be an access token powerful enough to generate the other
+access tokens you'll be using. This value is stored seal wrapped when available.
+
+Credentials have their own path so that policies can let operators view and tune the settings at config/admin without
+granting access to any path that accepts or describes the token.
+
+Once set, the access token cannot be retrieved, but reading this path returns a sha256 hash of the token so you can
+compare it to your notes. If the token is a JWT Access Token, it will return additional information such as token_id,
+username and scope.
+
+A "client_key" sets the private key of the "client_cert" of config/admin, presented to Artifactory for ingresses that
+enforce mutual TLS. Like the token, it is never returned. It can be written on its own once the backend is configured.
+
+Older Artifactory versions that manage automation with API keys can be configured with an "api_key" instead of an
+"access_token". The key is stored in its place and always sent in the X-JFrog-Art-Api header, whatever "auth_header"
+says. API keys can't be rotated or used to bootstrap. Writing an access token later switches back to it.
+
+With "bootstrap", the access token is only used to create the admin token the backend keeps, as config/rotate does:
+write a short-lived token created for the purpose, and the long-lived admin token never exists outside Vault. The new
+token has the bootstrap token's scope and username, and doesn't expire; set "rotation_period" to rotate it regularly.
+`,
+	}
+}
+
+func (b *backend) pathConfigCredentialsUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		config = &adminConfiguration{}
+	}
+
+	if config.sharedFrom != "" {
+		return sharedConfigResponse(*config), nil
+	}
+
+	if val, ok := data.GetOk("url"); ok {
+		config.ArtifactoryURL = val.(string)
+		config.URLs = nil
+	}
+
+	if val, ok := data.GetOk("urls"); ok {
+		if resp := setFailoverURLs(config, data, val.([]string)); resp != nil {
+			return resp, nil
+		}
+	}
+
+	accessToken := data.Get("access_token").(string)
+	apiKey := data.Get("api_key").(string)
+	clientKey, clientKeySet := data.GetOk("client_key")
+	_, urlSet := data.GetOk("url")
+	_, urlsSet := data.GetOk("urls")
+
+	switch {
+	case accessToken != "" && apiKey != "":
+		return logical.ErrorResponse("access_token and api_key are mutually exclusive"), nil
+	case apiKey != "":
+		if data.Get("bootstrap").(bool) {
+			return logical.ErrorResponse("an api_key can't be used to bootstrap, write an access_token"), nil
+		}
+		config.AccessToken = apiKey
+		config.UsesAPIKey = true
+	case accessToken != "":
+		config.AccessToken = accessToken
+		config.UsesAPIKey = false
+	case clientKeySet && config.AccessToken != "" && !urlSet && !urlsSet && !data.Get("bootstrap").(bool):
+		// Only the client key changes, the stored token is kept
+	default:
+		return logical.ErrorResponse("access_token is required"), nil
+	}
+	if accessToken != "" || apiKey != "" {
+		config.CredentialsUpdatedAt = time.Now()
+	}
+
+	if clientKeySet {
+		config.ClientKey = clientKey.(string)
+		if _, err := artifactoryTLSConfig(*config); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if config.ArtifactoryURL == "" {
+		return logical.ErrorResponse("url is required"), nil
+	}
+
+	go b.sendUsage(*config, "pathConfigCredentialsUpdate")
+
+	resp, err := b.saveAdminConfiguration(ctx, req.Storage, config)
+	if err != nil || (resp != nil && resp.IsError()) || !data.Get("bootstrap").(bool) {
+		return resp, err
+	}
+
+	rotateResp, err := b.rotateAdminToken(ctx, req.Storage, config, nil, "Admin token minted from a bootstrap token for artifactory-secrets plugin in Vault")
+	if err != nil || (rotateResp != nil && rotateResp.IsError()) {
+		b.Logger().Error("creating an admin token from the bootstrap token failed, the bootstrap token is in use", "err", err)
+		return rotateResp, err
+	}
+
+	if rotateResp != nil {
+		if resp == nil {
+			return rotateResp, nil
+		}
+		resp.Warnings = append(resp.Warnings, rotateResp.Warnings...)
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathConfigCredentialsRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	go b.sendUsage(*config, "pathConfigCredentialsRead")
+
+	return &logical.Response{
+		Data: b.credentialsInfo(*config),
+	}, nil
+}
+
+// credentialsInfo describes the configured access token without revealing it
+func (b *backend) credentialsInfo(config adminConfiguration) map[string]interface{} {
+	// I'm not sure if I should be returning the access token, so I'll hash it.
+	accessTokenHash := sha256.Sum256([]byte(config.AccessToken))
+
+	info := map[string]interface{}{
+		"access_token_sha256": fmt.Sprintf("%x", accessTokenHash[:]),
+	}
+
+	// API keys have no claims to describe
+	if config.UsesAPIKey {
+		info["uses_api_key"] = true
+		return info
+	}
+
+	// Optionally include token info if it parses properly
+	token, err := b.getTokenInfo(config, config.AccessToken)
+	if err != nil {
+		b.Logger().Warn("Error parsing AccessToken: " + err.Error())
+	} else {
+		info["token_id"] = token.TokenID
+		info["username"] = token.Username
+		info["scope"] = token.Scope
+		if token.Expires > 0 {
+			info["exp"] = token.Expires
+			tm := time.Unix(token.Expires, 0)
+			info["expires"] = tm.Local()
+		}
+	}
+
+	// Decoded without verifying the signature, so the expiry of the admin token shows even when the root certificate
+	// can't be fetched to verify it
+	if token, err := b.inspectToken(config.AccessToken); err == nil {
+		info["token_subject"] = token.Subject
+		if token.Expires > 0 {
+			info["access_token_expires_at"] = time.Unix(token.Expires, 0).UTC()
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,250 @@
+package artifactory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Credentials written to config/admin/credentials must configure the backend, and settings must then be tunable on
+// config/admin without the access token.
+func TestBackend_PathConfigCredentials(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	const correctSHA256 = "597480d4b62ca612193f19e73fe4cc3ad17f0bf9cfc16a7cbf4b5064131c4805"
+
+	b, config := makeBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "test-access-token",
+			"url":          "http://myserver.com:80",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username_template": "v-{{.RoleName}}-{{random 8}}",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.EqualValues(t, correctSHA256, resp.Data["access_token_sha256"])
+	assert.NotContains(t, resp.Data, "url")
+
+	// Changing the url clears the token, so it must be done through config/admin/credentials
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"url": "http://elsewhere.example.org:80",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "config/admin/credentials")
+
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://myserver.com:80", adminConfig.ArtifactoryURL)
+	assert.Equal(t, "test-access-token", adminConfig.AccessToken)
+}
+
+// Reads must decode the subject and expiry of the admin token, even though its signature can't be verified.
+func TestBackend_PathConfigCredentialsExpiry(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	expires := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "jfac@01fr1x1h805xmg0t17xhqr1v7a/users/vault",
+		"jti": "test-admin-token-id",
+		"scp": "applied-permissions/admin",
+		"exp": expires.Unix(),
+	}).SignedString([]byte("test-key"))
+	assert.NoError(t, err)
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": token,
+		"url":          "http://myserver.com:80",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "jfac@01fr1x1h805xmg0t17xhqr1v7a/users/vault", resp.Data["token_subject"])
+	assert.Equal(t, expires.UTC(), resp.Data["access_token_expires_at"])
+
+	// Tokens that aren't JWTs, such as API keys, have neither
+	b, config = configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, resp.Data, "token_subject")
+	assert.NotContains(t, resp.Data, "access_token_expires_at")
+}
+
+// A bootstrap token must be replaced by a new admin token with its scope, and revoked.
+func TestBackend_PathConfigCredentialsBootstrap(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	// Before 7.12.0 the root certificate isn't available, so the bootstrap token is parsed without validation
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.11.0", "revision" : "71100900"}`)
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(200, ""))
+
+	b, config := makeBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": adminJWTAccessToken,
+			"url":          "http://myserver.com:80/artifactory",
+			"bootstrap":    true,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	assert.Equal(t, "applied-permissions/admin", createRequest.Scope)
+	assert.Equal(t, "admin", createRequest.Username)
+	assert.Zero(t, createRequest.ExpiresIn)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["POST http://myserver.com:80/artifactory/api/security/token/revoke"])
+
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.Equal(t, "eyXsdgbtybbeeyh...", adminConfig.AccessToken)
+	assert.False(t, adminConfig.RotatedAt.IsZero())
+}
+
+// An api_key must be sent in the X-JFrog-Art-Api header, and an access_token written later in the Authorization header.
+func TestBackend_PathConfigCredentialsAPIKey(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var header http.Header
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/artifactory/api/system/version",
+		func(req *http.Request) (*http.Response, error) {
+			header = req.Header.Clone()
+			return httpmock.NewStringResponse(200, artVersion), nil
+		})
+
+	b, config := makeBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "test-access-token",
+			"api_key":      "test-api-key",
+			"url":          "http://myserver.com:80",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"api_key": "test-api-key",
+			"url":     "http://myserver.com:80",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+	assert.Equal(t, "test-api-key", header.Get("X-JFrog-Art-Api"))
+	assert.Empty(t, header.Get("Authorization"))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, true, resp.Data["uses_api_key"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "API key")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "test-access-token",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+	assert.Equal(t, "Bearer test-access-token", header.Get("Authorization"))
+	assert.Empty(t, header.Get("X-JFrog-Art-Api"))
+}
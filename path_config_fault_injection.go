@@ -0,0 +1,297 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathConfigFaultInjection() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/fault_injection",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "Optional. Defaults to 'false'. Enable fault injection on calls to Artifactory. Never enable this on a production mount.",
+			},
+			"error_rate": {
+				Type:        framework.TypeFloat,
+				Description: "Optional. Probability (0.0 to 1.0) that a call to Artifactory is answered with an injected error status instead of being sent.",
+			},
+			"error_status_codes": {
+				Type:        framework.TypeCommaIntSlice,
+				Default:     []int{http.StatusTooManyRequests, http.StatusInternalServerError},
+				Description: "Optional. HTTP status codes chosen at random for injected errors. Defaults to '429,500'.",
+			},
+			"latency": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Optional. Delay added to every call to Artifactory.",
+			},
+			"revoke_failure_rate": {
+				Type:        framework.TypeFloat,
+				Description: "Optional. Probability (0.0 to 1.0) that a token revocation call fails with an injected 500.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigFaultInjectionUpdate,
+				Summary:  "Configure fault injection for calls to Artifactory.",
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigFaultInjectionRead,
+				Summary:  "Examine the fault injection configuration.",
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathConfigFaultInjectionDelete,
+				Summary:  "Disable fault injection.",
+			},
+		},
+		HelpSynopsis: `Configure fault injection for testing resilience to Artifactory failures.`,
+		HelpDescription: `
+Injects failures into the calls this backend makes to Artifactory, for use in acceptance tests and staging mounts.
+
+When "enabled" is true, every call is delayed by "latency", then answered with a random status from
+"error_status_codes" with probability "error_rate". Token revocation calls additionally fail with a 500 with
+probability "revoke_failure_rate". Injected responses never reach Artifactory.
+`,
+	}
+}
+
+type faultInjectionConfiguration struct {
+	Enabled           bool          `json:"enabled"`
+	ErrorRate         float64       `json:"error_rate,omitempty"`
+	ErrorStatusCodes  []int         `json:"error_status_codes,omitempty"`
+	Latency           time.Duration `json:"latency,omitempty"`
+	RevokeFailureRate float64       `json:"revoke_failure_rate,omitempty"`
+}
+
+// fetchFaultInjectionConfiguration will return nil,nil if there's no configuration
+func (b *backend) fetchFaultInjectionConfiguration(ctx context.Context, storage logical.Storage) (*faultInjectionConfiguration, error) {
+	var config faultInjectionConfiguration
+
+	entry, err := storage.Get(ctx, "config/fault_injection")
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// reloadFaultInjection sets up fault injection again from the mount's storage, on nodes that didn't serve the write
+// or delete that changed it
+func (b *backend) reloadFaultInjection(ctx context.Context) {
+	if b.storageView == nil {
+		return
+	}
+
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+
+	config, err := b.fetchFaultInjectionConfiguration(ctx, b.storageView)
+	if err != nil {
+		b.Logger().Warn("could not reload the fault injection configuration", "err", err)
+		return
+	}
+
+	b.faultInjection = config
+
+	adminConfig, err := b.fetchAdminConfiguration(ctx, b.storageView)
+	if err != nil {
+		b.Logger().Warn("could not set up the http client with the reloaded fault injection configuration", "err", err)
+		return
+	}
+
+	if adminConfig != nil {
+		b.InitializeHttpClient(adminConfig)
+	}
+}
+
+func (b *backend) pathConfigFaultInjectionUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+
+	config, err := b.fetchFaultInjectionConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		config = &faultInjectionConfiguration{
+			ErrorStatusCodes: data.GetDefaultOrZero("error_status_codes").([]int),
+		}
+	}
+
+	if val, ok := data.GetOk("enabled"); ok {
+		config.Enabled = val.(bool)
+	}
+
+	if val, ok := data.GetOk("error_rate"); ok {
+		config.ErrorRate = val.(float64)
+	}
+
+	if val, ok := data.GetOk("error_status_codes"); ok {
+		config.ErrorStatusCodes = val.([]int)
+	}
+
+	if val, ok := data.GetOk("latency"); ok {
+		config.Latency = time.Duration(val.(int)) * time.Second
+	}
+
+	if val, ok := data.GetOk("revoke_failure_rate"); ok {
+		config.RevokeFailureRate = val.(float64)
+	}
+
+	if config.ErrorRate < 0 || config.ErrorRate > 1 {
+		return logical.ErrorResponse("error_rate must be between 0.0 and 1.0"), nil
+	}
+
+	if config.RevokeFailureRate < 0 || config.RevokeFailureRate > 1 {
+		return logical.ErrorResponse("revoke_failure_rate must be between 0.0 and 1.0"), nil
+	}
+
+	if config.ErrorRate > 0 && len(config.ErrorStatusCodes) == 0 {
+		return logical.ErrorResponse("error_status_codes is required when error_rate is set"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config/fault_injection", config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	b.faultInjection = config
+
+	adminConfig, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if adminConfig != nil {
+		b.InitializeHttpClient(adminConfig)
+	}
+
+	if config.Enabled {
+		return &logical.Response{
+			Warnings: []string{"Fault injection is enabled; calls to Artifactory from this mount will fail on purpose."},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigFaultInjectionRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	config, err := b.fetchFaultInjectionConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		config = &faultInjectionConfiguration{}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":             config.Enabled,
+			"error_rate":          config.ErrorRate,
+			"error_status_codes":  config.ErrorStatusCodes,
+			"latency":             config.Latency.Seconds(),
+			"revoke_failure_rate": config.RevokeFailureRate,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigFaultInjectionDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+
+	if err := req.Storage.Delete(ctx, "config/fault_injection"); err != nil {
+		return nil, err
+	}
+
+	b.faultInjection = nil
+
+	adminConfig, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if adminConfig != nil {
+		b.InitializeHttpClient(adminConfig)
+	}
+
+	return nil, nil
+}
+
+// faultInjectingTransport wraps an http.RoundTripper, injecting latency and errors according to its configuration.
+type faultInjectingTransport struct {
+	config faultInjectionConfiguration
+	next   http.RoundTripper
+}
+
+func (t *faultInjectingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.config.Latency > 0 {
+		select {
+		case <-time.After(t.config.Latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	if isRevokeRequest(req) && rand.Float64() < t.config.RevokeFailureRate {
+		return injectedResponse(req, http.StatusInternalServerError), nil
+	}
+
+	if len(t.config.ErrorStatusCodes) > 0 && rand.Float64() < t.config.ErrorRate {
+		statusCode := t.config.ErrorStatusCodes[rand.Intn(len(t.config.ErrorStatusCodes))]
+		return injectedResponse(req, statusCode), nil
+	}
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return next.RoundTrip(req)
+}
+
+// isRevokeRequest reports whether req revokes a token, using either the Access API or the legacy Artifactory API
+func isRevokeRequest(req *http.Request) bool {
+	return (req.Method == http.MethodDelete && strings.Contains(req.URL.Path, "/access/api/v1/tokens/")) ||
+		strings.HasSuffix(req.URL.Path, "/api/security/token/revoke")
+}
+
+func injectedResponse(req *http.Request, statusCode int) *http.Response {
+	body := fmt.Sprintf(`{"code":"%d","message":"fault injected by vault-plugin-secrets-artifactory","detail":"fault injected by vault-plugin-secrets-artifactory"}`, statusCode)
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode: statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
@@ -0,0 +1,123 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// With error_rate=1 every call to Artifactory must fail, and deleting the configuration must restore normal calls.
+func TestBackend_FaultInjectionErrorRate(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/fault_injection",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"enabled":            true,
+			"error_rate":         1.0,
+			"error_status_codes": "503",
+		},
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Warnings)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/fault_injection",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, true, resp.Data["enabled"])
+	assert.Equal(t, []int{503}, resp.Data["error_status_codes"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.Nil(t, resp)
+	assert.ErrorContains(t, err, "fault injected")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/fault_injection",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+}
+
+func TestBackend_FaultInjectionRejectsBadRates(t *testing.T) {
+	b, config := makeBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/fault_injection",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"enabled":    true,
+			"error_rate": 1.5,
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "error_rate")
+}
+
+// A fault injection config written on another node must be loaded when Vault invalidates it.
+func TestBackend_FaultInjectionInvalidate(t *testing.T) {
+	b, config := makeBackend(t)
+	b.storageView = config.StorageView
+
+	entry, err := logical.StorageEntryJSON("config/fault_injection", faultInjectionConfiguration{Enabled: true, ErrorRate: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, config.StorageView.Put(context.Background(), entry))
+
+	b.invalidate(context.Background(), "config/fault_injection")
+	assert.NotNil(t, b.faultInjection)
+	assert.True(t, b.faultInjection.Enabled)
+
+	assert.NoError(t, config.StorageView.Delete(context.Background(), "config/fault_injection"))
+
+	b.invalidate(context.Background(), "config/fault_injection")
+	assert.Nil(t, b.faultInjection)
+}
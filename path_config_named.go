@@ -0,0 +1,381 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// namedConfigStoragePrefix holds the named admin configurations, as config/admin/<name>. It is under config/admin, so
+// it is seal wrapped too.
+const namedConfigStoragePrefix = "config/admin/"
+
+func (b *backend) pathListNamedConfigs() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/admin/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathNamedConfigList,
+				Summary:  `List the named admin configurations.`,
+			},
+		},
+		HelpSynopsis: `List the named admin configurations.`,
+	}
+}
+
+func (b *backend) pathNamedConfig() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/admin/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `Name of the admin configuration, which roles reference with 'config_name'.`,
+			},
+			"url": {
+				Type:        framework.TypeString,
+				Description: "Address of the Artifactory instance.",
+			},
+			"access_token": {
+				Type:        framework.TypeString,
+				Description: "Administrator token to access the Artifactory instance.",
+			},
+			"access_url": {
+				Type:        framework.TypeString,
+				Description: "Optional. Address of the JFrog Access service of the instance, if it is routed separately from the url.",
+			},
+			"auth_header": {
+				Type:        framework.TypeString,
+				Description: "Optional. How the admin token is sent: 'bearer' or 'x-jfrog-art-api'. Defaults to that of config/admin.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathNamedConfigWrite,
+				Summary:  "Configure an additional Artifactory instance.",
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathNamedConfigRead,
+				Summary:  "Examine a named admin configuration.",
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathNamedConfigDelete,
+				Summary:  "Delete a named admin configuration.",
+			},
+		},
+		HelpSynopsis: `Configure additional Artifactory instances for roles to issue tokens from.`,
+		HelpDescription: `
+Configures another Artifactory instance (e.g. a DR site or a SaaS instance) for the same mount, so its roles and
+policies don't have to be duplicated across mounts. A role uses it by setting 'config_name' to its name.
+
+A named configuration sets the 'url' and 'access_token' of the instance, and optionally its 'access_url' and
+'auth_header'. Every other setting, such as the username template, TLS verification and usage reporting, is that of
+config/admin, which must be written first. The instance must run an Artifactory version that uses the same token API
+as the instance of config/admin.
+
+The access token cannot be retrieved; reading returns its sha256 hash. A configuration used by a role can't be
+deleted. The names 'credentials', 'versions', 'rollback' and 'verify' are reserved for other config/admin paths.
+
+Tokens are renewed and revoked through the configuration they were issued from, even if their role has changed
+since. While that configuration is missing, their renewals and revocations fail.
+`,
+	}
+}
+
+// reservedNamedConfigNames are the names of paths under config/admin/, which would shadow named configurations of the
+// same name
+var reservedNamedConfigNames = []string{"credentials", "versions", "rollback", "verify"}
+
+type namedConfiguration struct {
+	ArtifactoryURL       string    `json:"artifactory_url"`
+	AccessURL            string    `json:"access_url,omitempty"`
+	AccessToken          string    `json:"access_token"`
+	AuthHeader           string    `json:"auth_header,omitempty"`
+	Version              string    `json:"version"`
+	CredentialsUpdatedAt time.Time `json:"credentials_updated_at,omitempty"`
+}
+
+// apply returns config with the instance settings of the named configuration
+func (named namedConfiguration) apply(config adminConfiguration) adminConfiguration {
+	config.ArtifactoryURL = named.ArtifactoryURL
+	config.AccessURL = named.AccessURL
+	// The Access address discovered for config/admin belongs to its instance
+	config.DiscoveredAccessURL = ""
+	config.AccessToken = named.AccessToken
+	config.CredentialsUpdatedAt = named.CredentialsUpdatedAt
+	if len(named.AuthHeader) > 0 {
+		config.AuthHeader = named.AuthHeader
+	}
+	return config
+}
+
+func (b *backend) fetchNamedConfiguration(ctx context.Context, storage logical.Storage, name string) (*namedConfiguration, error) {
+	entry, err := storage.Get(ctx, namedConfigStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var named namedConfiguration
+	if err := entry.DecodeJSON(&named); err != nil {
+		return nil, err
+	}
+
+	return &named, nil
+}
+
+// roleConfiguration returns config for calls made for role: the named admin configuration the role uses, if any, and
+// the role's request headers
+func (b *backend) roleConfiguration(ctx context.Context, storage logical.Storage, config adminConfiguration, role artifactoryRole) (adminConfiguration, error) {
+	config.roleHeaders = role.RequestHeaders
+
+	if len(role.ConfigName) == 0 {
+		return config, nil
+	}
+
+	named, err := b.fetchNamedConfiguration(ctx, storage, role.ConfigName)
+	if err != nil {
+		return config, err
+	}
+
+	if named == nil {
+		return config, fmt.Errorf("admin configuration '%s' of the role does not exist", role.ConfigName)
+	}
+
+	return named.apply(config), nil
+}
+
+// withSecret returns config set up for calls made for the token of a secret: with the admin configuration the token
+// was issued from, and the request headers of its role. Tokens keep using their admin configuration after their role
+// changes or is deleted, and a missing configuration is an error rather than a fallback to config/admin, which would
+// make calls for the token to the wrong Artifactory instance.
+func (b *backend) withSecret(ctx context.Context, storage logical.Storage, config adminConfiguration, internalData map[string]interface{}) (adminConfiguration, error) {
+	roleName, _ := internalData["role"].(string)
+	configName, ok := internalData["config_name"].(string)
+
+	// Leases issued before every lease recorded its admin configuration use that of their role
+	if !ok {
+		return b.withRole(ctx, storage, config, roleName), nil
+	}
+
+	var role *artifactoryRole
+	if roleName != "" {
+		var err error
+		role, err = b.Role(ctx, storage, roleName)
+		if err != nil {
+			b.Logger().Warn("could not read role for its request headers", "role", roleName, "err", err)
+		}
+	}
+
+	if role == nil {
+		role = &artifactoryRole{}
+	}
+	role.ConfigName = configName
+
+	roleConfig, err := b.roleConfiguration(ctx, storage, config, *role)
+	if err != nil {
+		return config, fmt.Errorf("could not get the admin configuration '%s' the token was issued from: %w", configName, err)
+	}
+
+	return roleConfig, nil
+}
+
+func (b *backend) pathNamedConfigList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	keys, err := req.Storage.List(ctx, namedConfigStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(keys), nil
+}
+
+func (b *backend) pathNamedConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured, write config/admin first"), nil
+	}
+
+	go b.sendUsage(*config, "pathNamedConfigWrite")
+
+	name := data.Get("name").(string)
+
+	if strutil.StrListContains(reservedNamedConfigNames, name) {
+		return logical.ErrorResponse("'%s' is reserved for config/admin/%s and can't name an admin configuration", name, name), nil
+	}
+
+	named, err := b.fetchNamedConfiguration(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if named == nil {
+		named = &namedConfiguration{}
+	}
+
+	if val, ok := data.GetOk("url"); ok {
+		named.ArtifactoryURL = val.(string)
+		named.AccessToken = "" // as with config/admin, a new url requires its access_token
+	}
+
+	if val, ok := data.GetOk("access_token"); ok {
+		named.AccessToken = val.(string)
+		named.CredentialsUpdatedAt = time.Now()
+	}
+
+	if val, ok := data.GetOk("access_url"); ok {
+		named.AccessURL = val.(string)
+		if len(named.AccessURL) > 0 {
+			if _, err := parseURLWithDefaultPort(named.AccessURL); err != nil {
+				return logical.ErrorResponse("invalid access_url: %s", err), nil
+			}
+		}
+	}
+
+	if val, ok := data.GetOk("auth_header"); ok {
+		named.AuthHeader = val.(string)
+		if named.AuthHeader != "" && named.AuthHeader != authHeaderBearer && named.AuthHeader != authHeaderArtApi {
+			return logical.ErrorResponse("auth_header must be '%s' or '%s'", authHeaderBearer, authHeaderArtApi), nil
+		}
+	}
+
+	if named.ArtifactoryURL == "" {
+		return logical.ErrorResponse("url is required"), nil
+	}
+
+	if named.AccessToken == "" {
+		return logical.ErrorResponse("access_token is required"), nil
+	}
+
+	named.Version, err = b.fetchVersion(named.apply(*config))
+	if err != nil {
+		return logical.ErrorResponse("Unable to get the Artifactory version of '%s'. Check url and access_token fields.", name), err
+	}
+
+	same, err := sameTokenAPI(b.artifactoryVersion(), named.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if !same {
+		return logical.ErrorResponse("Artifactory %s of '%s' uses a different token API than Artifactory %s of config/admin", named.Version, name, b.artifactoryVersion()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(namedConfigStoragePrefix+name, named)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathNamedConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	named, err := b.fetchNamedConfiguration(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if named == nil {
+		return nil, nil
+	}
+
+	accessTokenHash := accessTokenSHA256(named.AccessToken)
+
+	configMap := map[string]interface{}{
+		"url":                    named.ArtifactoryURL,
+		"version":                named.Version,
+		"access_token_sha256":    accessTokenHash,
+		"credentials_updated_at": named.CredentialsUpdatedAt,
+	}
+
+	if len(named.AccessURL) > 0 {
+		configMap["access_url"] = named.AccessURL
+	}
+
+	if len(named.AuthHeader) > 0 {
+		configMap["auth_header"] = named.AuthHeader
+	}
+
+	return &logical.Response{
+		Data: configMap,
+	}, nil
+}
+
+func (b *backend) pathNamedConfigDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+	defer b.rolesMutex.RUnlock()
+
+	name := data.Get("name").(string)
+
+	roleNames, err := req.Storage.List(ctx, "roles/")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, roleName := range roleNames {
+		role, err := b.Role(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && (role.ConfigName == name || strutil.StrListContains(role.AllowedConfigNames, name)) {
+			return logical.ErrorResponse("admin configuration '%s' is used by role '%s'", name, roleName), nil
+		}
+	}
+
+	if err := req.Storage.Delete(ctx, namedConfigStoragePrefix+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// tokenAPIVersions are the Artifactory versions at which the token API the backend uses changes
+var tokenAPIVersions = []string{"7.12.0", "7.21.1", referenceTokenVersion, "7.50.3"}
+
+// sameTokenAPI reports whether two Artifactory versions use the same token API, so calls for a named configuration
+// can rely on the version detected for config/admin
+func sameTokenAPI(v1, v2 string) (bool, error) {
+	ver1, err := version.NewVersion(v1)
+	if err != nil {
+		return false, fmt.Errorf("could not parse Artifactory version '%s': %w", v1, err)
+	}
+
+	ver2, err := version.NewVersion(v2)
+	if err != nil {
+		return false, fmt.Errorf("could not parse Artifactory version '%s': %w", v2, err)
+	}
+
+	for _, threshold := range tokenAPIVersions {
+		t := version.Must(version.NewVersion(threshold))
+		if ver1.GreaterThanOrEqual(t) != ver2.GreaterThanOrEqual(t) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,256 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// A role with config_name must issue its tokens from the Artifactory instance of that named configuration.
+func TestBackend_PathNamedConfig(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	drVersion := artVersion
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://dr.example.org:80/artifactory/api/system/version",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(200, drVersion), nil
+		})
+
+	var authorization string
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://dr.example.org:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get("Authorization")
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/dr",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"url":          "http://dr.example.org:80/artifactory",
+			"access_token": "dr-access-token",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin/dr",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://dr.example.org:80/artifactory", resp.Data["url"])
+	assert.Equal(t, accessTokenSHA256("dr-access-token"), resp.Data["access_token_sha256"])
+	assert.NotContains(t, resp.Data, "access_token")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "config/admin/",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dr"}, resp.Data["keys"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":    "test-username",
+			"scope":       "test-scope",
+			"config_name": "missing",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":    "test-username",
+			"scope":       "test-scope",
+			"config_name": "dr",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "Bearer dr-access-token", authorization)
+	assert.Equal(t, "dr", resp.Secret.InternalData["config_name"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/admin/dr",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "test-role")
+
+	// An instance on another token API is rejected
+	drVersion = `{"version" : "7.55.0", "revision" : "75500900"}`
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/saas",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"url":          "http://dr.example.org:80/artifactory",
+			"access_token": "saas-access-token",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "different token API")
+
+	// Names of other config/admin paths are rejected
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/verify",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"url":          "http://dr.example.org:80/artifactory",
+			"access_token": "verify-access-token",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "reserved")
+}
+
+// A token request may choose one of the role's allowed_config_names, and its token is revoked through that instance.
+func TestBackend_TokenConfigOverride(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://eu.example.org:80/artifactory/api/system/version",
+		httpmock.NewStringResponder(200, artVersion))
+
+	var authorization string
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://eu.example.org:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get("Authorization")
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/eu",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"url":          "http://eu.example.org:80/artifactory",
+			"access_token": "eu-access-token",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":             "test-username",
+			"scope":                "test-scope",
+			"allowed_config_names": "missing",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":             "test-username",
+			"scope":                "test-scope",
+			"allowed_config_names": "eu",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"config": "us"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "allowed_config_names")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"config": "eu"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "Bearer eu-access-token", authorization)
+	assert.Equal(t, "eu", resp.Data["config_name"])
+	secret := resp.Secret
+
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	tokenConfig, err := b.withSecret(context.Background(), config.StorageView, *adminConfig, resp.Secret.InternalData)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://eu.example.org:80/artifactory", tokenConfig.ArtifactoryURL)
+
+	tracked, err := b.fetchTrackedToken(context.Background(), config.StorageView, resp.Secret.InternalData["tracking_id"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, "eu", tracked.ConfigName)
+
+	// The configuration can't be deleted while the role allows it
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/admin/eu",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	// Once the configuration is gone, the token isn't revoked against config/admin instead
+	assert.NoError(t, config.StorageView.Delete(context.Background(), namedConfigStoragePrefix+"eu"))
+	_, err = b.withSecret(context.Background(), config.StorageView, *adminConfig, secret.InternalData)
+	assert.Error(t, err)
+}
@@ -2,6 +2,8 @@ package artifactory
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -9,7 +11,7 @@ import (
 
 func (b *backend) pathConfigRotate() *framework.Path {
 	return &framework.Path{
-		Pattern: "config/rotate",
+		Pattern: "config/rotate(-root)?",
 		Fields: map[string]*framework.FieldSchema{
 			"username": {
 				Type:        framework.TypeString,
@@ -26,8 +28,14 @@ func (b *backend) pathConfigRotate() *framework.Path {
 				Summary:  "Rotate the Artifactory Access Token.",
 			},
 		},
-		HelpSynopsis:    `Rotate the Artifactory Access Token.`,
-		HelpDescription: `This will rotate the "access_token" used to access artifactory from this plugin. A new access token is created first then revokes the old access token.`,
+		HelpSynopsis: `Rotate the Artifactory Access Token.`,
+		HelpDescription: `
+This will rotate the "access_token" used to access artifactory from this plugin. A new access token is created first
+then revokes the old access token. If revoking the old token fails, its revocation is queued and retried, and a
+warning is returned; the new token is already in use.
+
+The path is also available as config/rotate-root, the name other Vault secrets engines use.
+`,
 	}
 }
 
@@ -44,8 +52,34 @@ func (b *backend) pathConfigRotateWrite(ctx context.Context, req *logical.Reques
 		return logical.ErrorResponse("backend not configured"), nil
 	}
 
+	if config.sharedFrom != "" {
+		return sharedConfigResponse(*config), nil
+	}
+
 	go b.sendUsage(*config, "pathConfigRotateWrite")
 
+	var username *string
+	if val, ok := data.GetOk("username"); ok {
+		value := val.(string)
+		username = &value
+	}
+
+	var description string
+	if val, ok := data.GetOk("description"); ok {
+		description = val.(string)
+	}
+
+	return b.rotateAdminToken(ctx, req.Storage, config, username, description)
+}
+
+// rotateAdminToken replaces the admin token with a new one with the same scope, stores it, and revokes the old one.
+// The new token keeps the old one's username unless username is set, and gets the default description if description
+// is empty. If revoking the old token fails, the revocation is queued and the response carries a warning.
+func (b *backend) rotateAdminToken(ctx context.Context, storage logical.Storage, config *adminConfiguration, username *string, description string) (*logical.Response, error) {
+	if config.UsesAPIKey {
+		return logical.ErrorResponse("the admin credential is an API key, which can't be rotated: write a new one to config/admin/credentials"), nil
+	}
+
 	oldAccessToken := config.AccessToken
 
 	// Parse Current Token (to get tokenID/scope)
@@ -55,8 +89,8 @@ func (b *backend) pathConfigRotateWrite(ctx context.Context, req *logical.Reques
 	}
 
 	// Check for submitted username
-	if val, ok := data.GetOk("username"); ok {
-		token.Username = val.(string)
+	if username != nil {
+		token.Username = *username
 	}
 
 	if len(token.Username) == 0 {
@@ -70,31 +104,35 @@ func (b *backend) pathConfigRotateWrite(ctx context.Context, req *logical.Reques
 	}
 
 	// Check for new description
-	if val, ok := data.GetOk("description"); ok {
-		role.Description = val.(string)
-	} else {
+	role.Description = description
+	if len(role.Description) == 0 {
 		role.Description = "Rotated access token for artifactory-secrets plugin in Vault"
 	}
 
 	// Create a new token
-	resp, err := b.CreateToken(*config, *role)
+	resp, err := b.CreateToken(ctx, *config, *role)
 	if err != nil {
 		return logical.ErrorResponse("error creating new access token"), err
 	}
 
 	// Set new token
 	config.AccessToken = resp.AccessToken
+	config.RotatedAt = time.Now()
+	config.CredentialsUpdatedAt = config.RotatedAt
 
 	// Save new config
+	config.Generation = nextConfigGeneration(config.Generation)
 	entry, err := logical.StorageEntryJSON("config/admin", config)
 	if err != nil {
 		return nil, err
 	}
 
-	err = req.Storage.Put(ctx, entry)
+	err = storage.Put(ctx, entry)
 	if err != nil {
 		return nil, err
 	}
+	b.setConfigGeneration(config.Generation)
+	b.recordConfigVersion(ctx, storage, *config)
 
 	// Invalidate Old Token
 	oldSecret := logical.Secret{
@@ -103,9 +141,19 @@ func (b *backend) pathConfigRotateWrite(ctx context.Context, req *logical.Reques
 			"token_id":     token.TokenID,
 		},
 	}
-	err = b.RevokeToken(*config, oldSecret)
-	if err != nil {
-		return logical.ErrorResponse("error revoking existing access token %s", token.TokenID), err
+	revokeCtx, cancel := revokeContext(ctx)
+	defer cancel()
+
+	// The new token is stored already, so a failed revocation must not leave the old one live for good
+	if err := b.RevokeToken(revokeCtx, *config, oldSecret); err != nil {
+		if err := b.queueRevocation(context.WithoutCancel(ctx), storage, oldSecret, err); err != nil {
+			return logical.ErrorResponse("error revoking existing access token %s", token.TokenID), err
+		}
+
+		b.Logger().Warn("revoking the rotated access token failed, queued for retry", "tokenId", token.TokenID, "err", err)
+		resp := &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("The new access token is in use, but revoking the old one (%s) failed and is queued for retry: %s", token.TokenID, err))
+		return resp, nil
 	}
 
 	return nil, nil
@@ -1,9 +1,12 @@
 package artifactory
 
 import (
+	"context"
+	"net/http"
 	"testing"
 
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -39,37 +42,37 @@ func (e *accTestEnv) PathConfigRotateUnconfigured(t *testing.T) {
 }
 
 func (e *accTestEnv) PathConfigRotateEmpty(t *testing.T) {
-	before := e.ReadConfigAdmin(t)
+	before := e.ReadConfigAdminCredentials(t)
 	e.UpdateConfigRotate(t, testData{}) // empty write
-	after := e.ReadConfigAdmin(t)
-	assert.NotEqual(t, before["access_token_sha256sum"], after["access_token_sha256"])
+	after := e.ReadConfigAdminCredentials(t)
+	assert.NotEqual(t, before["access_token_sha256"], after["access_token_sha256"])
 }
 
 func (e *accTestEnv) PathConfigRotateZeroLengthUsername(t *testing.T) {
 	e.UpdateConfigRotate(t, testData{
 		"username": "",
 	}) // empty write
-	after := e.ReadConfigAdmin(t)
+	after := e.ReadConfigAdminCredentials(t)
 	assert.Equal(t, "admin-vault-secrets-artifactory", after["username"])
 }
 
 func (e *accTestEnv) PathConfigRotateWithDetails(t *testing.T) {
 	newUsername := "vault-acceptance-test-changed"
 	description := "Artifactory Secrets Engine Accceptance Test"
-	before := e.ReadConfigAdmin(t)
+	before := e.ReadConfigAdminCredentials(t)
 	e.UpdateConfigRotate(t, testData{
 		"username":    newUsername,
 		"description": description,
 	})
-	after := e.ReadConfigAdmin(t)
-	assert.NotEqual(t, before["access_token_sha256sum"], after["access_token_sha256"])
+	after := e.ReadConfigAdminCredentials(t)
+	assert.NotEqual(t, before["access_token_sha256"], after["access_token_sha256"])
 	assert.Equal(t, newUsername, after["username"])
 	// Not testing Description, because it is not returned in the token (yet)
 }
 
 func (e *accTestEnv) PathConfigRotateCreateTokenErr(t *testing.T) {
 	tokenId, accessToken := e.createNewNonAdminTestToken(t)
-	e.UpdateConfigAdmin(t, testData{
+	e.UpdateConfigAdminCredentials(t, testData{
 		"access_token": accessToken,
 		"url":          e.URL,
 	})
@@ -94,3 +97,46 @@ func (e *accTestEnv) PathConfigRotateBadAccessToken(t *testing.T) {
 	assert.Contains(t, resp.Data["error"], "could not get the certificate")
 	assert.Error(t, err)
 }
+
+// A failed revocation of the old token must not fail the rotation, since the new token is stored already.
+func TestBackend_PathConfigRotateRootQueuesRevocation(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	// Before 7.12.0 the root certificate isn't available, so the admin token is parsed without validation
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.11.0", "revision" : "71100900"}`)
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(500, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": adminJWTAccessToken,
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Len(t, resp.Warnings, 1)
+	assert.Contains(t, resp.Warnings[0], "queued for retry")
+
+	queued, err := config.StorageView.List(context.Background(), revocationQueueStoragePrefix)
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.Equal(t, "eyXsdgbtybbeeyh...", adminConfig.AccessToken)
+	assert.False(t, adminConfig.RotatedAt.IsZero())
+}
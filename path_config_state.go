@@ -0,0 +1,161 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathConfigState() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/state",
+		Fields: map[string]*framework.FieldSchema{
+			"disabled": {
+				Type:        framework.TypeBool,
+				Description: "Optional. Defaults to 'false'. Put the backend in maintenance: requests for new tokens fail, while config and roles can still be read and leases renewed and revoked.",
+			},
+			"reason": {
+				Type:        framework.TypeString,
+				Description: "Optional. Why the backend is in maintenance, e.g. an Artifactory upgrade, returned with the errors of refused requests.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigStateUpdate,
+				Summary:  "Put the backend in or out of maintenance.",
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigStateRead,
+				Summary:  "Examine whether the backend is in maintenance.",
+			},
+		},
+		HelpSynopsis: `Freeze token issuance, e.g. during Artifactory upgrades.`,
+		HelpDescription: `
+With "disabled" set, the backend is in maintenance: token/<role>, token (for several roles), delegate and
+user_token/<username> fail with a 503 "backend in maintenance" error, along with the "reason". Reads of config and
+roles still work, and leases can still be renewed and revoked. Set "disabled" to false to resume issuance.
+`,
+	}
+}
+
+type stateConfiguration struct {
+	Disabled   bool      `json:"disabled"`
+	Reason     string    `json:"reason,omitempty"`
+	DisabledAt time.Time `json:"disabled_at,omitempty"`
+}
+
+// fetchStateConfiguration will return nil,nil if there's no configuration
+func (b *backend) fetchStateConfiguration(ctx context.Context, storage logical.Storage) (*stateConfiguration, error) {
+	var state stateConfiguration
+
+	entry, err := storage.Get(ctx, "config/state")
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	if err := entry.DecodeJSON(&state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// checkMaintenance refuses requests for new tokens while the backend is in maintenance
+func (b *backend) checkMaintenance(ctx context.Context, storage logical.Storage) (*logical.Response, error) {
+	state, err := b.fetchStateConfiguration(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil || !state.Disabled {
+		return nil, nil
+	}
+
+	message := "backend in maintenance"
+	if len(state.Reason) > 0 {
+		message += ": " + state.Reason
+	}
+	return logical.ErrorResponse(message), logical.CodedError(http.StatusServiceUnavailable, message)
+}
+
+func (b *backend) pathConfigStateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+
+	state, err := b.fetchStateConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil {
+		state = &stateConfiguration{}
+	}
+
+	if val, ok := data.GetOk("disabled"); ok {
+		disabled := val.(bool)
+		if disabled && !state.Disabled {
+			state.DisabledAt = time.Now()
+		}
+		if !disabled {
+			state.DisabledAt = time.Time{}
+			state.Reason = ""
+		}
+		state.Disabled = disabled
+	}
+
+	if val, ok := data.GetOk("reason"); ok {
+		state.Reason = val.(string)
+	}
+
+	entry, err := logical.StorageEntryJSON("config/state", state)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	if state.Disabled {
+		b.Logger().Warn("backend in maintenance, token issuance is refused", "reason", state.Reason)
+	} else {
+		b.Logger().Info("backend out of maintenance, token issuance resumed")
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigStateRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	state, err := b.fetchStateConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil {
+		state = &stateConfiguration{}
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"disabled": state.Disabled,
+		},
+	}
+	if len(state.Reason) > 0 {
+		resp.Data["reason"] = state.Reason
+	}
+	if !state.DisabledAt.IsZero() {
+		resp.Data["disabled_at"] = state.DisabledAt
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,110 @@
+package artifactory
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathListConfig() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathConfigList,
+				Summary:  `List the configurations of this backend.`,
+			},
+		},
+		HelpSynopsis: `List the configurations of this backend.`,
+		HelpDescription: `
+Lists the configuration entries that are set, e.g. "admin", "user_token" and "fault_injection". Read config/summary for
+a description of the Artifactory integration that is safe to show auditors.
+`,
+	}
+}
+
+func (b *backend) pathConfigSummary() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/summary",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigSummaryRead,
+				Summary:  `Describe the Artifactory integration without revealing any secret.`,
+			},
+		},
+		HelpSynopsis: `Describe the Artifactory integration without revealing any secret.`,
+		HelpDescription: `
+Returns the Artifactory url and version, whether Artifactory is healthy, the username template, and when the
+administrator token was last set and rotated. Nothing derived from the token is included, not even its hash, so a
+read-only policy on this path lets auditors inventory integrations across mounts.
+`,
+	}
+}
+
+func (b *backend) pathConfigList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	keys, err := req.Storage.List(ctx, "config/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(keys), nil
+}
+
+func (b *backend) pathConfigSummaryRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	go b.sendUsage(*config, "pathConfigSummaryRead")
+
+	summary := map[string]interface{}{
+		"url":               config.ArtifactoryURL,
+		"version":           b.artifactoryVersion(),
+		"username_template": defaultUserNameTemplate,
+		"auth_header":       authHeaderBearer,
+	}
+
+	if len(config.UsernameTemplate) > 0 {
+		summary["username_template"] = config.UsernameTemplate
+	}
+
+	if len(config.AuthHeader) > 0 {
+		summary["auth_header"] = config.AuthHeader
+	}
+
+	if len(config.AccessURL) > 0 {
+		summary["access_url"] = config.AccessURL
+	}
+
+	if !config.CredentialsUpdatedAt.IsZero() {
+		summary["credentials_updated_at"] = config.CredentialsUpdatedAt
+	}
+
+	if !config.RotatedAt.IsZero() {
+		summary["rotated_at"] = config.RotatedAt
+	}
+
+	if !config.OfflineMode {
+		summary["healthy"] = true
+		if err := b.checkHealth(*config); err != nil {
+			summary["healthy"] = false
+			summary["health_error"] = err.Error()
+		}
+	}
+
+	return &logical.Response{
+		Data: summary,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackend_PathConfigSummary(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/artifactory/api/system/ping",
+		httpmock.NewStringResponder(200, "OK"))
+
+	b, config := makeBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"url":          "http://myserver.com:80/artifactory",
+			"access_token": "test-access-token",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"username_template": "v-{{.RoleName}}"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp != nil && resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "config/",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, resp.Data["keys"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/summary",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "http://myserver.com:80/artifactory", resp.Data["url"])
+	assert.Equal(t, "v-{{.RoleName}}", resp.Data["username_template"])
+	assert.Equal(t, true, resp.Data["healthy"])
+	assert.Contains(t, resp.Data, "credentials_updated_at")
+	assert.NotContains(t, resp.Data, "rotated_at")
+	assert.NotContains(t, resp.Data, "access_token_sha256")
+}
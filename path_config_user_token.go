@@ -49,8 +49,13 @@ func (b *backend) pathConfigUserToken() *framework.Path {
 				Summary:  "Examine the Artifactory secrets configuration.",
 			},
 		},
-		HelpSynopsis:    `Configuration for issuing user tokens.`,
-		HelpDescription: `Configures default values for the user_token/<user name> path.`,
+		HelpSynopsis: `Configuration for issuing user tokens.`,
+		HelpDescription: `
+Configures default values for the user_token/<user name> path: "default_ttl", "max_ttl", "default_description",
+"audience", "refreshable" and "include_reference_token". Each can be overridden per request, within "max_ttl".
+
+It is stored apart from config/admin, so policies can let a team tune user token defaults without access to the admin
+token or the connection settings. It can be written and read before config/admin is.`,
 	}
 }
 
@@ -128,6 +133,10 @@ func (b *backend) pathConfigUserTokenUpdate(ctx context.Context, req *logical.Re
 		userTokenConfig.DefaultDescription = val.(string)
 	}
 
+	if userTokenConfig.MaxTTL != 0 && userTokenConfig.DefaultTTL > userTokenConfig.MaxTTL {
+		return logical.ErrorResponse("default_ttl cannot exceed max_ttl"), nil
+	}
+
 	entry, err := logical.StorageEntryJSON("config/user_token", userTokenConfig)
 	if err != nil {
 		return nil, err
@@ -151,7 +160,7 @@ func (b *backend) pathConfigUserTokenRead(ctx context.Context, req *logical.Requ
 	}
 
 	if config == nil {
-		return logical.ErrorResponse("backend not configured"), nil
+		config = &adminConfiguration{}
 	}
 
 	go b.sendUsage(*config, "pathConfigUserTokenRead")
@@ -170,18 +179,20 @@ func (b *backend) pathConfigUserTokenRead(ctx context.Context, req *logical.Requ
 		"default_description":     userTokenConfig.DefaultDescription,
 	}
 
-	// Optionally include token info if it parses properly
-	token, err := b.getTokenInfo(*config, config.AccessToken)
-	if err != nil {
-		b.Logger().Warn("Error parsing AccessToken: " + err.Error())
-	} else {
-		configMap["token_id"] = token.TokenID
-		configMap["username"] = token.Username
-		configMap["scope"] = token.Scope
-		if token.Expires > 0 {
-			configMap["exp"] = token.Expires
-			tm := time.Unix(token.Expires, 0)
-			configMap["expires"] = tm.Local()
+	// Optionally include token info if the admin token is set and parses properly
+	if config.AccessToken != "" {
+		token, err := b.getTokenInfo(*config, config.AccessToken)
+		if err != nil {
+			b.Logger().Warn("Error parsing AccessToken: " + err.Error())
+		} else {
+			configMap["token_id"] = token.TokenID
+			configMap["username"] = token.Username
+			configMap["scope"] = token.Scope
+			if token.Expires > 0 {
+				configMap["exp"] = token.Expires
+				tm := time.Unix(token.Expires, 0)
+				configMap["expires"] = tm.Local()
+			}
 		}
 	}
 
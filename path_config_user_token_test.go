@@ -1,8 +1,14 @@
 package artifactory
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -85,3 +91,104 @@ func (e *accTestEnv) pathConfigUserTokenUpdateDurationField(t *testing.T, fieldN
 	data = e.ReadConfigUserToken(t)
 	assert.Equal(t, 4.0, data[fieldName])
 }
+
+func TestBackend_PathConfigUserTokenDefaults(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.55.6", "revision" : "75506900"}`)
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/access/api/v1/tokens",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := makeBackend(t)
+
+	// The defaults can be managed before config/admin is written
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/user_token",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"default_ttl": "2h",
+			"max_ttl":     "1h",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "default_ttl cannot exceed max_ttl")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/user_token",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"default_ttl":         "30m",
+			"max_ttl":             "1h",
+			"default_description": "issued by vault",
+			"audience":            "jfrt@*",
+			"refreshable":         true,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/user_token",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "jfrt@*", resp.Data["audience"])
+	assert.Equal(t, float64(1800), resp.Data["default_ttl"])
+	assert.NotContains(t, resp.Data, "token_id")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "test-access-token",
+			"url":          "http://myserver.com:80",
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+
+	// The defaults are sent with user tokens, and the request overrides them
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "user_token/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "jfrt@*", createRequest.Audience)
+	assert.True(t, createRequest.Refreshable)
+	assert.Equal(t, "issued by vault", createRequest.Description)
+	assert.Equal(t, 30*time.Minute, resp.Secret.TTL)
+
+	createRequest = CreateTokenRequest{}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "user_token/admin",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"audience":    "jfrt@01abc",
+			"refreshable": false,
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "jfrt@01abc", createRequest.Audience)
+	assert.False(t, createRequest.Refreshable)
+}
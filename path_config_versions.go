@@ -0,0 +1,247 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// configVersionsStoragePrefix holds every stored version of config/admin, as config-versions/<version>, zero-padded
+	// so keys sort in write order. They hold the admin token, so the prefix is seal wrapped.
+	configVersionsStoragePrefix = "config-versions/"
+
+	// defaultMaxConfigVersions is how many versions of config/admin are kept unless max_config_versions is set
+	defaultMaxConfigVersions = 10
+)
+
+func (b *backend) pathListConfigVersions() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/admin/versions/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathConfigVersionsList,
+				Summary:  `List the kept versions of config/admin.`,
+			},
+		},
+		HelpSynopsis: `List the kept versions of config/admin.`,
+		HelpDescription: `
+Lists the versions of config/admin kept for rollback, newest last, with when each was written, its url, and whether it
+is the current one. Every write of config/admin or its credentials, and every rotation of the admin token, stores a new
+version. The "max_config_versions" of config/admin bounds how many are kept, 10 by default.
+`,
+	}
+}
+
+func (b *backend) pathConfigRollback() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/admin/rollback",
+		Fields: map[string]*framework.FieldSchema{
+			"version": {
+				Type:        framework.TypeInt,
+				Required:    true,
+				Description: `The version of config/admin to restore, as listed by config/admin/versions.`,
+			},
+			"restore_credentials": {
+				Type:        framework.TypeBool,
+				Description: `Optional. Defaults to 'false'. Also restore the admin token of the version, instead of keeping the current one.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigRollbackWrite,
+				Summary:  `Restore a previous version of config/admin.`,
+			},
+		},
+		HelpSynopsis: `Restore a previous version of config/admin.`,
+		HelpDescription: `
+Restores the settings of a version of config/admin listed by config/admin/versions, after a bad write broke issuance.
+The restored configuration is checked against Artifactory as a write of config/admin is, and stored as a new version,
+so a rollback can be rolled back too. Versions outlive a deletion of config/admin, which can be undone by restoring one
+with its credentials.
+
+The current admin token is kept, since rotations revoke the tokens of older versions. Set "restore_credentials" to
+restore the token of the version instead, e.g. to undo a write of a wrong token. A version with a different url
+requires "restore_credentials", so the current token is never sent to another Artifactory instance.
+`,
+	}
+}
+
+// configVersion is a version of config/admin kept for rollback
+type configVersion struct {
+	Version   uint64             `json:"version"`
+	WrittenAt time.Time          `json:"written_at"`
+	Config    adminConfiguration `json:"config"`
+}
+
+// configVersionKey returns the storage key of version
+func configVersionKey(version uint64) string {
+	return fmt.Sprintf("%s%020d", configVersionsStoragePrefix, version)
+}
+
+// configVersionKeys returns the keys under configVersionsStoragePrefix, oldest first
+func configVersionKeys(ctx context.Context, storage logical.Storage) ([]string, error) {
+	keys, err := storage.List(ctx, configVersionsStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// recordConfigVersion keeps config, just stored at config/admin, as its next version, and removes the oldest versions
+// beyond max_config_versions. Failures are logged, since config/admin itself was already written.
+func (b *backend) recordConfigVersion(ctx context.Context, storage logical.Storage, config adminConfiguration) {
+	keys, err := configVersionKeys(ctx, storage)
+	if err != nil {
+		b.Logger().Warn("could not record config version", "err", err)
+		return
+	}
+
+	var latest uint64
+	if len(keys) > 0 {
+		latest, err = strconv.ParseUint(keys[len(keys)-1], 10, 64)
+		if err != nil {
+			b.Logger().Warn("could not record config version", "err", err)
+			return
+		}
+	}
+
+	version := configVersion{
+		Version:   latest + 1,
+		WrittenAt: time.Now(),
+		Config:    config,
+	}
+	entry, err := logical.StorageEntryJSON(configVersionKey(version.Version), version)
+	if err != nil {
+		b.Logger().Warn("could not record config version", "err", err)
+		return
+	}
+	if err := storage.Put(ctx, entry); err != nil {
+		b.Logger().Warn("could not record config version", "err", err)
+		return
+	}
+	keys = append(keys, fmt.Sprintf("%020d", version.Version))
+
+	maxVersions := config.MaxConfigVersions
+	if maxVersions == 0 {
+		maxVersions = defaultMaxConfigVersions
+	}
+	for len(keys) > maxVersions {
+		if err := storage.Delete(ctx, configVersionsStoragePrefix+keys[0]); err != nil {
+			b.Logger().Warn("could not remove config version", "key", keys[0], "err", err)
+			return
+		}
+		keys = keys[1:]
+	}
+}
+
+// fetchConfigVersion returns the kept version of config/admin, or nil if it isn't kept
+func fetchConfigVersion(ctx context.Context, storage logical.Storage, version uint64) (*configVersion, error) {
+	entry, err := storage.Get(ctx, configVersionKey(version))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var kept configVersion
+	if err := entry.DecodeJSON(&kept); err != nil {
+		return nil, err
+	}
+	return &kept, nil
+}
+
+func (b *backend) pathConfigVersionsList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := configVersionKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	keyInfo := map[string]interface{}{}
+	for _, key := range keys {
+		version, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		kept, err := fetchConfigVersion(ctx, req.Storage, version)
+		if err != nil {
+			return nil, err
+		}
+		if kept == nil {
+			continue
+		}
+
+		name := strconv.FormatUint(version, 10)
+		versions = append(versions, name)
+		keyInfo[name] = map[string]interface{}{
+			"written_at": kept.WrittenAt,
+			"url":        kept.Config.ArtifactoryURL,
+			"current":    config != nil && !config.fromEnv && kept.Config.Generation == config.Generation,
+		}
+	}
+
+	return logical.ListResponseWithInfo(versions, keyInfo), nil
+}
+
+func (b *backend) pathConfigRollbackWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config != nil && config.sharedFrom != "" {
+		return sharedConfigResponse(*config), nil
+	}
+
+	versionNumber := data.Get("version").(int)
+	if versionNumber <= 0 {
+		return logical.ErrorResponse("version must be positive"), nil
+	}
+
+	kept, err := fetchConfigVersion(ctx, req.Storage, uint64(versionNumber))
+	if err != nil {
+		return nil, err
+	}
+	if kept == nil {
+		return logical.ErrorResponse("version %d of config/admin is not kept, list config/admin/versions", versionNumber), nil
+	}
+
+	restored := kept.Config
+	if !data.Get("restore_credentials").(bool) {
+		if config == nil {
+			return logical.ErrorResponse("backend not configured, so the admin token of version %d must be restored with restore_credentials=true", versionNumber), nil
+		}
+		if restored.ArtifactoryURL != config.ArtifactoryURL {
+			return logical.ErrorResponse("version %d has url '%s' instead of '%s', so its admin token must be restored with restore_credentials=true", versionNumber, restored.ArtifactoryURL, config.ArtifactoryURL), nil
+		}
+		restored.AccessToken = config.AccessToken
+		restored.UsesAPIKey = config.UsesAPIKey
+		restored.CredentialsUpdatedAt = config.CredentialsUpdatedAt
+		restored.RotatedAt = config.RotatedAt
+	}
+	if config != nil {
+		restored.Generation = config.Generation
+	}
+
+	go b.sendUsage(restored, "pathConfigRollbackWrite")
+
+	b.Logger().Info("rolling back config/admin", "version", versionNumber, "restore_credentials", data.Get("restore_credentials").(bool))
+
+	return b.saveAdminConfiguration(ctx, req.Storage, &restored)
+}
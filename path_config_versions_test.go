@@ -0,0 +1,150 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// A bad config write must be undone by restoring a kept version, which keeps the current admin token.
+func TestBackend_ConfigRollback(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":      "test-access-token",
+		"url":               "http://myserver.com:80/artifactory",
+		"username_template": "v-{{.RoleName}}-{{random 8}}",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username_template": "bad-{{.RoleName}}-{{random 8}}",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/credentials",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "new-access-token",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "config/admin/versions/",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, resp.Data["keys"])
+	keyInfo := resp.Data["key_info"].(map[string]interface{})
+	assert.Equal(t, false, keyInfo["1"].(map[string]interface{})["current"])
+	assert.Equal(t, true, keyInfo["3"].(map[string]interface{})["current"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/rollback",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"version": 1},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.Equal(t, "v-{{.RoleName}}-{{random 8}}", adminConfig.UsernameTemplate)
+	assert.Equal(t, "new-access-token", adminConfig.AccessToken)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/rollback",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"version": 1, "restore_credentials": true},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	adminConfig, err = b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-access-token", adminConfig.AccessToken)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/rollback",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"version": 42},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+}
+
+// Only the last max_config_versions versions are kept, and a version of another url needs its own credentials.
+func TestBackend_ConfigVersionsPruned(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://other.com:80/artifactory/api/system/version",
+		httpmock.NewStringResponder(200, artVersion))
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://other.com:80/artifactory/api/system/usage",
+		httpmock.NewStringResponder(200, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":        "test-access-token",
+		"url":                 "http://myserver.com:80/artifactory",
+		"max_config_versions": 2,
+	})
+
+	for _, url := range []string{"http://myserver.com:80/artifactory", "http://other.com:80/artifactory"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/admin/credentials",
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"url":          url,
+				"access_token": "test-access-token",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "config/admin/versions/",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "3"}, resp.Data["keys"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin/rollback",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"version": 2},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "restore_credentials")
+}
@@ -0,0 +1,197 @@
+package artifactory
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathDelegate() *framework.Path {
+	return &framework.Path{
+		Pattern: "delegate",
+		Fields: map[string]*framework.FieldSchema{
+			"access_token": {
+				Type:        framework.TypeString,
+				Required:    true,
+				Description: `Access token issued by this backend to delegate from.`,
+			},
+			"scope": {
+				Type:        framework.TypeString,
+				Required:    true,
+				Description: `Space-delimited scope of the child token. Every entry must be in the scope of the parent token.`,
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: `TTL of the child token. Defaults to, and cannot exceed, the remaining ttl of the parent token.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathDelegateWrite,
+				Summary:  `Exchange an access token for a child token with a narrower scope.`,
+			},
+		},
+		HelpSynopsis: `Exchange an access token for a child token with a narrower scope.`,
+		HelpDescription: `
+Creates a child access token from an access token issued by this backend, so a token holder can hand a narrower
+credential to a sub-process. The parent is identified by presenting its 'access_token', and must still have a lease.
+
+The child's 'scope' must be a subset of the parent's, and its 'ttl' cannot outlive the parent's lease; renewals can't
+extend it past that either. The child is issued for the parent's role and username, and records the parent's tracking
+id, shown as "parent_id" by tokens/. Children can delegate in turn.
+`,
+	}
+}
+
+func (b *backend) pathDelegateWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+	defer b.rolesMutex.RUnlock()
+
+	if resp, err := b.checkMaintenance(ctx, req.Storage); resp != nil || err != nil {
+		return resp, err
+	}
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	go b.sendUsage(*config, "pathDelegateWrite")
+
+	if err := b.refreshStaleConfiguration(*config); err != nil {
+		return nil, err
+	}
+
+	accessToken := data.Get("access_token").(string)
+	if accessToken == "" {
+		return logical.ErrorResponse("missing access_token"), nil
+	}
+
+	parentID, parent, err := b.findTrackedToken(ctx, req.Storage, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if parent == nil {
+		return logical.ErrorResponse("access_token was not issued by this backend, or its lease was revoked"), nil
+	}
+
+	if parent.RevokedInArtifactory {
+		return logical.ErrorResponse("parent token was revoked or has expired in Artifactory"), nil
+	}
+
+	remaining := time.Until(parent.ExpiresAt).Truncate(time.Second)
+	if remaining <= 0 {
+		return logical.ErrorResponse("parent token has expired"), nil
+	}
+
+	scope, err := normalizeScope(data.Get("scope").(string))
+	if err != nil {
+		return remediationResponse(remediationScopeGrammar, err.Error()), nil
+	}
+	if scope == "" {
+		return logical.ErrorResponse("missing scope"), nil
+	}
+
+	parentScope, err := normalizeScope(parent.Scope)
+	if err != nil {
+		return logical.ErrorResponse("scope of the parent token can't be delegated: %s", err), nil
+	}
+
+	// Both scopes are normalized, so they parse
+	parentEntries, _ := parseScope(parentScope)
+	entries, _ := parseScope(scope)
+	for _, entry := range entries {
+		if !scopeCovers(parentEntries, entry) {
+			return logical.ErrorResponse("scope '%s' is not in the scope of the parent token", entry), nil
+		}
+	}
+
+	ttl := remaining
+	if value, ok := data.GetOk("ttl"); ok {
+		ttl = time.Duration(value.(int)) * time.Second
+		if ttl <= 0 || ttl > remaining {
+			return logical.ErrorResponse("ttl must be positive and cannot exceed the remaining ttl of the parent token (%s)", remaining), nil
+		}
+	}
+
+	role, err := b.Role(ctx, req.Storage, parent.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return logical.ErrorResponse("role '%s' of the parent token no longer exists", parent.Role), nil
+	}
+
+	if err := role.checkRetired(parent.Role, time.Now()); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Delegated tokens are issued by the Artifactory instance of their parent
+	if parent.ConfigName != "" {
+		role.ConfigName = parent.ConfigName
+	}
+
+	roleConfig, err := b.roleConfiguration(ctx, req.Storage, *config, *role)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	config = &roleConfig
+
+	role.Username = parent.Username
+	role.Scope = scope
+	role.Description = "delegated from: " + parentID
+	role.MaxTTL = ttl
+
+	resp, err := b.CreateToken(ctx, *config, *role)
+	if err != nil {
+		return nil, err
+	}
+
+	response := b.Secret(SecretArtifactoryAccessTokenType).Response(map[string]interface{}{
+		"access_token":    resp.AccessToken,
+		"refresh_token":   resp.RefreshToken,
+		"role":            parent.Role,
+		"scope":           resp.Scope,
+		"token_id":        resp.TokenId,
+		"username":        role.Username,
+		"reference_token": resp.ReferenceToken,
+		"revocable":       resp.Revocable,
+		"parent_id":       parentID,
+	}, map[string]interface{}{
+		"role":               parent.Role,
+		"access_token":       resp.AccessToken,
+		"refresh_token":      resp.RefreshToken,
+		"token_id":           resp.TokenId,
+		"username":           role.Username,
+		"reference_token":    resp.ReferenceToken,
+		"revocable":          resp.Revocable,
+		"parent_tracking_id": parentID,
+	})
+
+	if resp.ReferenceOnly {
+		response.AddWarning(referenceOnlyWarning)
+	}
+
+	if parent.ConfigName != "" {
+		response.Secret.InternalData["config_name"] = parent.ConfigName
+	}
+
+	response.Secret.TTL = ttl
+	response.Secret.MaxTTL = ttl
+
+	b.trackSecret(ctx, req, response, parent.Role)
+
+	addTLSBypassWarning(response, *config)
+
+	return response, nil
+}
@@ -0,0 +1,116 @@
+package artifactory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockDelegationTokens responds to token requests with a distinct access token each time, carrying the requested
+// scope, so parent and child tokens can be told apart
+func mockDelegationTokens() *[]CreateTokenRequest {
+	var requests []CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			var createRequest CreateTokenRequest
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			requests = append(requests, createRequest)
+			return httpmock.NewJsonResponse(200, map[string]interface{}{
+				"access_token": fmt.Sprintf("access-token-%d", len(requests)),
+				"scope":        createRequest.Scope,
+				"token_type":   "Bearer",
+			})
+		})
+	return &requests
+}
+
+// A tracked token must be exchangeable for a child token with a subset of its scope, and a ttl within its own.
+func TestBackend_PathDelegate(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+	requests := mockDelegationTokens()
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":    "test-username",
+			"scope":       "api:* member-of-groups:readers,deployers",
+			"default_ttl": "1h",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	parentID := resp.Secret.InternalData["tracking_id"]
+
+	delegate := func(data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "delegate",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp = delegate(map[string]interface{}{"access_token": "access-token-1", "scope": "api:* member-of-groups:admins"})
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "member-of-groups:admins")
+
+	resp = delegate(map[string]interface{}{"access_token": "access-token-1", "scope": "api:*", "ttl": "2h"})
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "remaining ttl")
+
+	resp = delegate(map[string]interface{}{"access_token": "unknown-token", "scope": "api:*"})
+	assert.True(t, resp.IsError())
+
+	resp = delegate(map[string]interface{}{"access_token": "access-token-1", "scope": "api:*", "ttl": "30m"})
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "access-token-2", resp.Data["access_token"])
+	assert.Equal(t, parentID, resp.Data["parent_id"])
+	assert.Equal(t, 30*time.Minute, resp.Secret.TTL)
+	assert.Equal(t, "api:*", (*requests)[1].Scope)
+	assert.Equal(t, "test-username", (*requests)[1].Username)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "tokens/",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Data["keys"], 2)
+	parentCount := 0
+	for _, info := range resp.Data["key_info"].(map[string]interface{}) {
+		if info.(map[string]interface{})["parent_id"] == parentID {
+			parentCount++
+		}
+	}
+	assert.Equal(t, 1, parentCount)
+}
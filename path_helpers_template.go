@@ -0,0 +1,176 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathHelpersTemplate() *framework.Path {
+	return &framework.Path{
+		Pattern: "helpers/template/" + framework.GenericNameWithAtRegex("role"),
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: `Use the configuration of the specified role.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathHelpersTemplateRead,
+				Summary:  `Return Vault Agent templates rendering tokens of the role in common client formats.`,
+			},
+		},
+		HelpSynopsis: `Return Vault Agent templates rendering tokens of the role in common client formats.`,
+		HelpDescription: `
+Returns ready-to-paste Vault Agent (or Agent Injector) templates for tokens of the role, generated from the current
+role and config: the mount path, the Artifactory host of the role's config, the response keys as renamed by
+'response_key_mapping', and placeholders for the parameters the role requires, such as 'app_name' or 'change_ref'.
+
+"templates" holds one template per format:
+
+"env" - shell exports of ARTIFACTORY_USER and ARTIFACTORY_ACCESS_TOKEN.
+
+"netrc" - a .netrc entry for the Artifactory host, for curl, pip and similar clients.
+
+"docker_config" - a Docker config.json with credentials for the Artifactory host.
+
+"parameters" lists the placeholders to replace in the templates, if any.
+`,
+	}
+}
+
+// templateIdentifier matches keys that can be accessed as fields in Go templates
+var templateIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateField returns the Go template expression for key of the data of a secret
+func templateField(key string) string {
+	if templateIdentifier.MatchString(key) {
+		return ".Data." + key
+	}
+	return fmt.Sprintf("(index .Data %q)", key)
+}
+
+// requiredTokenParameters returns the parameters a token request for role must pass, with placeholder values
+func requiredTokenParameters(role artifactoryRole) map[string]string {
+	parameters := map[string]string{}
+	if len(role.AllowedAppNames) > 0 {
+		parameters["app_name"] = "APP_NAME"
+	}
+	if role.RequireChangeRef {
+		parameters["change_ref"] = "CHANGE_REF"
+	}
+	if role.RequireProvenance {
+		parameters["pipeline_id"] = "PIPELINE_ID"
+		parameters["commit_sha"] = "COMMIT_SHA"
+	}
+	return parameters
+}
+
+func (b *backend) pathHelpersTemplateRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+	defer b.rolesMutex.RUnlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	go b.sendUsage(*config, "pathHelpersTemplateRead")
+
+	roleName := data.Get("role").(string)
+
+	role, err := b.Role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return logical.ErrorResponse("no such role"), nil
+	}
+
+	roleConfig, err := b.roleConfiguration(ctx, req.Storage, *config, *role)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	artifactoryURL, err := url.Parse(roleConfig.ArtifactoryURL)
+	if err != nil {
+		return logical.ErrorResponse("invalid url of the role's config: %s", err), nil
+	}
+	host := artifactoryURL.Hostname()
+
+	mountPoint := req.MountPoint
+	if mountPoint == "" {
+		mountPoint = "artifactory/"
+	}
+	secretPath := mountPoint + "token/" + roleName
+
+	parameters := requiredTokenParameters(*role)
+	parameterNames := make([]string, 0, len(parameters))
+	for name := range parameters {
+		parameterNames = append(parameterNames, name)
+	}
+	sort.Strings(parameterNames)
+
+	secretArgs := []string{fmt.Sprintf("%q", secretPath)}
+	for _, name := range parameterNames {
+		secretArgs = append(secretArgs, fmt.Sprintf("%q", name+"="+parameters[name]))
+	}
+	with := "{{ with secret " + strings.Join(secretArgs, " ") + " }}"
+
+	responseKey := func(key string) string {
+		if renamed, ok := role.ResponseKeyMapping[key]; ok {
+			return templateField(renamed)
+		}
+		return templateField(key)
+	}
+	username := responseKey("username")
+	accessToken := responseKey("access_token")
+
+	templates := map[string]interface{}{
+		"env": fmt.Sprintf(`%s
+export ARTIFACTORY_USER="{{ %s }}"
+export ARTIFACTORY_ACCESS_TOKEN="{{ %s }}"
+{{ end }}`, with, username, accessToken),
+		"netrc": fmt.Sprintf(`%s
+machine %s
+login {{ %s }}
+password {{ %s }}
+{{ end }}`, with, host, username, accessToken),
+		"docker_config": fmt.Sprintf(`%s
+{
+  "auths": {
+    "%s": {
+      "auth": "{{ printf "%%s:%%s" %s %s | base64Encode }}"
+    }
+  }
+}
+{{ end }}`, with, host, username, accessToken),
+	}
+
+	response := &logical.Response{
+		Data: map[string]interface{}{
+			"secret_path": secretPath,
+			"templates":   templates,
+		},
+	}
+
+	if len(parameters) > 0 {
+		response.Data["parameters"] = parameterNames
+	}
+
+	return response, nil
+}
@@ -0,0 +1,104 @@
+package artifactory
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// renderAgentTemplate renders a Vault Agent template with a stub of the secret function returning data, and the
+// parameters it was called with
+func renderAgentTemplate(t *testing.T, text string, data map[string]interface{}) (string, []string) {
+	var args []string
+	tmpl, err := template.New("agent").Funcs(template.FuncMap{
+		"secret": func(path string, params ...string) map[string]interface{} {
+			args = append([]string{path}, params...)
+			return map[string]interface{}{"Data": data}
+		},
+		"base64Encode": func(s string) string { return "base64(" + s + ")" },
+	}).Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), args
+}
+
+// helpers/template/<role> must return templates that render tokens of the role, with renamed response keys and the
+// parameters the role requires.
+func TestBackend_PathHelpersTemplate(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "helpers/template/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":                "test-scope",
+			"allowed_app_names":    "billing",
+			"response_key_mapping": "access_token=artifactory-token",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.ReadOperation,
+		Path:       "helpers/template/test-role",
+		MountPoint: "ci/artifactory/",
+		Storage:    config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "ci/artifactory/token/test-role", resp.Data["secret_path"])
+	assert.Equal(t, []string{"app_name"}, resp.Data["parameters"])
+
+	tokenData := map[string]interface{}{
+		"username":          "v-test-role",
+		"artifactory-token": "test-token",
+	}
+	templates := resp.Data["templates"].(map[string]interface{})
+
+	out, args := renderAgentTemplate(t, templates["env"].(string), tokenData)
+	assert.Equal(t, []string{"ci/artifactory/token/test-role", "app_name=APP_NAME"}, args)
+	assert.Contains(t, out, `export ARTIFACTORY_USER="v-test-role"`)
+	assert.Contains(t, out, `export ARTIFACTORY_ACCESS_TOKEN="test-token"`)
+
+	out, _ = renderAgentTemplate(t, templates["netrc"].(string), tokenData)
+	assert.Contains(t, out, "machine myserver.com\nlogin v-test-role\npassword test-token")
+
+	out, _ = renderAgentTemplate(t, templates["docker_config"].(string), tokenData)
+	var dockerConfig struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out), &dockerConfig))
+	assert.Equal(t, "base64(v-test-role:test-token)", dockerConfig.Auths["myserver.com"].Auth)
+}
@@ -0,0 +1,268 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// changelogStoragePrefix holds an entry for every token issued, as log/changelog/<sequence>, zero-padded so keys
+	// sort in issuance order
+	changelogStoragePrefix = "log/changelog/"
+
+	// changelogSequenceKey holds the sequence number of the last issuance
+	changelogSequenceKey = "log/changelog-sequence"
+
+	// changelogRetention is how long changelog entries are kept for export before compaction removes them
+	changelogRetention = 7 * 24 * time.Hour
+
+	// changelogMaxExport is the most entries a single export returns
+	changelogMaxExport = 1000
+)
+
+func (b *backend) pathLogChangelog() *framework.Path {
+	return &framework.Path{
+		Pattern: "log/changelog",
+		Fields: map[string]*framework.FieldSchema{
+			"since": {
+				Type:        framework.TypeInt,
+				Description: `Optional. Only return issuances with a sequence number greater than this, e.g. the 'next_since' of the previous export. Defaults to 0, from the oldest retained issuance.`,
+			},
+			"limit": {
+				Type:        framework.TypeInt,
+				Description: fmt.Sprintf(`Optional. Maximum number of issuances to return, oldest first. Defaults to and is capped at %d.`, changelogMaxExport),
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathLogChangelogRead,
+				Summary:  `Export the issued tokens in issuance order.`,
+			},
+		},
+		HelpSynopsis: `Export the issued tokens in issuance order.`,
+		HelpDescription: fmt.Sprintf(`
+Returns every token issued by this mount in the order it was issued, for export to a SIEM. Each issuance gets the next
+sequence number of the mount, with no gaps, which is also stored with the tracked token. Pass the 'next_since' of an
+export as 'since' to fetch the issuances after it.
+
+Issuances are kept for %s. The response includes 'oldest_sequence', the oldest issuance still kept, and
+'latest_sequence', the newest: if 'oldest_sequence' is more than one past the 'since' of a cursor, issuances were
+removed before they were exported.
+`, changelogRetention),
+	}
+}
+
+// changelogEntry is an issuance in the changelog
+type changelogEntry struct {
+	Sequence   uint64    `json:"sequence"`
+	TrackingID string    `json:"tracking_id,omitempty"`
+	TokenID    string    `json:"token_id,omitempty"`
+	Role       string    `json:"role,omitempty"`
+	Username   string    `json:"username"`
+	Scope      string    `json:"scope,omitempty"`
+	EntityID   string    `json:"entity_id,omitempty"`
+	ParentID   string    `json:"parent_id,omitempty"`
+	IssuedAt   time.Time `json:"issued_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	NoLease    bool      `json:"no_lease,omitempty"`
+}
+
+// changelogKey returns the storage key of the changelog entry with sequence
+func changelogKey(sequence uint64) string {
+	return fmt.Sprintf("%s%020d", changelogStoragePrefix, sequence)
+}
+
+// appendChangelog stores entry with the next sequence number and returns it. The sequence only advances once the entry
+// is stored, so it has no gaps. Failures are logged and return 0, since the token already exists in Artifactory.
+func (b *backend) appendChangelog(ctx context.Context, storage logical.Storage, entry changelogEntry) uint64 {
+	b.changelogMutex.Lock()
+	defer b.changelogMutex.Unlock()
+
+	latest, err := b.latestChangelogSequence(ctx, storage)
+	if err != nil {
+		b.Logger().Warn("could not append to the changelog", "err", err)
+		return 0
+	}
+
+	entry.Sequence = latest + 1
+	storageEntry, err := logical.StorageEntryJSON(changelogKey(entry.Sequence), entry)
+	if err != nil {
+		b.Logger().Warn("could not append to the changelog", "err", err)
+		return 0
+	}
+	if err := storage.Put(ctx, storageEntry); err != nil {
+		b.Logger().Warn("could not append to the changelog", "err", err)
+		return 0
+	}
+
+	if err := storage.Put(ctx, &logical.StorageEntry{Key: changelogSequenceKey, Value: []byte(strconv.FormatUint(entry.Sequence, 10))}); err != nil {
+		b.Logger().Warn("could not append to the changelog", "err", err)
+		// Without the sequence stored, the next issuance would overwrite the entry
+		if err := storage.Delete(ctx, changelogKey(entry.Sequence)); err != nil {
+			b.Logger().Warn("could not remove changelog entry", "sequence", entry.Sequence, "err", err)
+		}
+		return 0
+	}
+
+	return entry.Sequence
+}
+
+// appendUntrackedChangelog appends a token issued without a lease, which isn't tracked, to the changelog
+func (b *backend) appendUntrackedChangelog(ctx context.Context, req *logical.Request, response *logical.Response, roleName string, ttl time.Duration) {
+	now := time.Now()
+	entry := changelogEntry{
+		Role:      roleName,
+		EntityID:  req.EntityID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+		NoLease:   true,
+	}
+	entry.TokenID, _ = response.Data["token_id"].(string)
+	entry.Username, _ = response.Data["username"].(string)
+	entry.Scope, _ = response.Data["scope"].(string)
+
+	b.appendChangelog(ctx, req.Storage, entry)
+}
+
+// latestChangelogSequence returns the sequence number of the last issuance, or 0 if there was none
+func (b *backend) latestChangelogSequence(ctx context.Context, storage logical.Storage) (uint64, error) {
+	entry, err := storage.Get(ctx, changelogSequenceKey)
+	if err != nil || entry == nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(entry.Value), 10, 64)
+}
+
+// pruneChangelog removes changelog entries issued more than changelogRetention before now, oldest first
+func (b *backend) pruneChangelog(ctx context.Context, storage logical.Storage, now time.Time) error {
+	b.changelogMutex.Lock()
+	defer b.changelogMutex.Unlock()
+
+	keys, err := storage.List(ctx, changelogStoragePrefix)
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+
+	removed := 0
+	for _, key := range keys {
+		entry, err := storage.Get(ctx, changelogStoragePrefix+key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var issuance changelogEntry
+		if err := entry.DecodeJSON(&issuance); err != nil {
+			return err
+		}
+
+		// Entries are in issuance order, so the rest are recent enough too
+		if issuance.IssuedAt.Add(changelogRetention).After(now) {
+			break
+		}
+
+		if err := storage.Delete(ctx, changelogStoragePrefix+key); err != nil {
+			return err
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		b.Logger().Info("pruned the changelog", "removed", removed)
+	}
+
+	return nil
+}
+
+func (b *backend) pathLogChangelogRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	since := data.Get("since").(int)
+	limit := data.Get("limit").(int)
+
+	if since < 0 || limit < 0 {
+		return logical.ErrorResponse("since and limit must not be negative"), nil
+	}
+	if limit == 0 || limit > changelogMaxExport {
+		limit = changelogMaxExport
+	}
+
+	b.changelogMutex.Lock()
+	defer b.changelogMutex.Unlock()
+
+	latest, err := b.latestChangelogSequence(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := req.Storage.List(ctx, changelogStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	var oldest uint64
+	if len(keys) > 0 {
+		oldest, err = strconv.ParseUint(keys[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cursor := fmt.Sprintf("%020d", since)
+	nextSince := uint64(since)
+	events := []interface{}{}
+	for _, key := range keys {
+		if key <= cursor {
+			continue
+		}
+
+		entry, err := req.Storage.Get(ctx, changelogStoragePrefix+key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var issuance changelogEntry
+		if err := entry.DecodeJSON(&issuance); err != nil {
+			return nil, err
+		}
+
+		events = append(events, map[string]interface{}{
+			"sequence":    issuance.Sequence,
+			"tracking_id": issuance.TrackingID,
+			"token_id":    issuance.TokenID,
+			"role":        issuance.Role,
+			"username":    issuance.Username,
+			"scope":       issuance.Scope,
+			"entity_id":   issuance.EntityID,
+			"parent_id":   issuance.ParentID,
+			"issued_at":   issuance.IssuedAt,
+			"expires_at":  issuance.ExpiresAt,
+			"no_lease":    issuance.NoLease,
+		})
+		nextSince = issuance.Sequence
+
+		if len(events) >= limit {
+			break
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"events":          events,
+			"next_since":      nextSince,
+			"oldest_sequence": oldest,
+			"latest_sequence": latest,
+		},
+	}, nil
+}
@@ -0,0 +1,86 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Every issuance must get the next sequence number, stored with its tracked token, and be exported after a cursor.
+func TestBackend_PathLogChangelog(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	for i := 0; i < 3; i++ {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/test-role",
+			Storage:   config.StorageView,
+			EntityID:  "test-entity",
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+	}
+
+	trackingID := resp.Secret.InternalData["tracking_id"].(string)
+	token, err := b.fetchTrackedToken(context.Background(), config.StorageView, trackingID)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, token.Sequence)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "log/changelog",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"since": 1},
+	})
+	assert.NoError(t, err)
+	events := resp.Data["events"].([]interface{})
+	assert.Len(t, events, 2)
+	assert.EqualValues(t, 2, events[0].(map[string]interface{})["sequence"])
+	assert.Equal(t, "test-entity", events[0].(map[string]interface{})["entity_id"])
+	assert.EqualValues(t, 3, resp.Data["next_since"])
+	assert.EqualValues(t, 1, resp.Data["oldest_sequence"])
+	assert.EqualValues(t, 3, resp.Data["latest_sequence"])
+
+	// Pruning removes the oldest entries, which a cursor behind them detects through oldest_sequence
+	assert.NoError(t, b.pruneChangelog(context.Background(), config.StorageView, time.Now().Add(changelogRetention+time.Minute)))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "log/changelog",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Data["events"])
+	assert.EqualValues(t, 0, resp.Data["oldest_sequence"])
+	assert.EqualValues(t, 3, resp.Data["latest_sequence"])
+}
@@ -0,0 +1,167 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	issuanceLogStoragePrefix = "log/issuance/"
+	issuanceLogMaxEntries    = 1000
+)
+
+func (b *backend) pathLogIssuance() *framework.Path {
+	return &framework.Path{
+		Pattern: "log/issuance",
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: `Optional. Only return events for this role.`,
+			},
+			"limit": {
+				Type:        framework.TypeInt,
+				Description: `Optional. Maximum number of events to return, newest first. Defaults to all.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathLogIssuanceRead,
+				Summary:  `Read sampled token issuance events.`,
+			},
+		},
+		HelpSynopsis: `Read sampled token issuance events.`,
+		HelpDescription: fmt.Sprintf(`
+Returns the token issuance events recorded for roles with a non-zero 'issuance_log_sample_rate', newest first.
+Each event has the role, requesting entity, time, token id, and username. Only the most recent %d events are
+kept. This is a convenience for quick forensic queries, not a replacement for a Vault audit device.
+`, issuanceLogMaxEntries),
+	}
+}
+
+type issuanceEvent struct {
+	Role        string    `json:"role"`
+	EntityID    string    `json:"entity_id,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Time        time.Time `json:"time"`
+	TokenID     string    `json:"token_id,omitempty"`
+	Username    string    `json:"username"`
+}
+
+// recordIssuance samples an issuance event into the issuance log according to the role's issuance_log_sample_rate,
+// pruning the oldest events beyond issuanceLogMaxEntries. Failures are logged, never returned.
+func (b *backend) recordIssuance(ctx context.Context, req *logical.Request, roleName string, role artifactoryRole, tokenID string) {
+	if role.IssuanceLogSampleRate <= 0 || rand.Float64() >= role.IssuanceLogSampleRate {
+		return
+	}
+
+	suffix, err := uuid.GenerateUUID()
+	if err != nil {
+		b.Logger().Warn("could not record issuance event", "err", err)
+		return
+	}
+
+	now := time.Now()
+	event := issuanceEvent{
+		Role:        roleName,
+		EntityID:    req.EntityID,
+		DisplayName: req.DisplayName,
+		Time:        now,
+		TokenID:     tokenID,
+		Username:    role.Username,
+	}
+
+	// Keys sort by time, so pruning and newest-first reads are a matter of sorting the listing
+	key := fmt.Sprintf("%s%020d-%s", issuanceLogStoragePrefix, now.UnixNano(), suffix[:8])
+	entry, err := logical.StorageEntryJSON(key, event)
+	if err != nil {
+		b.Logger().Warn("could not record issuance event", "err", err)
+		return
+	}
+
+	b.issuanceLogMutex.Lock()
+	defer b.issuanceLogMutex.Unlock()
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		b.Logger().Warn("could not record issuance event", "err", err)
+		return
+	}
+
+	keys, err := req.Storage.List(ctx, issuanceLogStoragePrefix)
+	if err != nil {
+		b.Logger().Warn("could not prune issuance log", "err", err)
+		return
+	}
+	sort.Strings(keys)
+
+	for len(keys) > issuanceLogMaxEntries {
+		if err := req.Storage.Delete(ctx, issuanceLogStoragePrefix+keys[0]); err != nil {
+			b.Logger().Warn("could not prune issuance log", "err", err)
+			return
+		}
+		keys = keys[1:]
+	}
+}
+
+func (b *backend) pathLogIssuanceRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+	limit := data.Get("limit").(int)
+
+	if limit < 0 {
+		return logical.ErrorResponse("limit must not be negative"), nil
+	}
+
+	b.issuanceLogMutex.Lock()
+	defer b.issuanceLogMutex.Unlock()
+
+	keys, err := req.Storage.List(ctx, issuanceLogStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	events := []interface{}{}
+	for _, key := range keys {
+		entry, err := req.Storage.Get(ctx, issuanceLogStoragePrefix+key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var event issuanceEvent
+		if err := entry.DecodeJSON(&event); err != nil {
+			return nil, err
+		}
+
+		if roleName != "" && event.Role != roleName {
+			continue
+		}
+
+		events = append(events, map[string]interface{}{
+			"role":         event.Role,
+			"entity_id":    event.EntityID,
+			"display_name": event.DisplayName,
+			"time":         event.Time,
+			"token_id":     event.TokenID,
+			"username":     event.Username,
+		})
+
+		if limit > 0 && len(events) >= limit {
+			break
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"events": events,
+		},
+	}, nil
+}
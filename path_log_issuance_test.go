@@ -0,0 +1,84 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Issuances for a role with issuance_log_sample_rate=1 must all be logged; a role without a rate must not be.
+func TestBackend_PathLogIssuance(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for roleName, rate := range map[string]float64{"sampled": 1, "unsampled": 0} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"username":                 "test-username",
+				"scope":                    "test-scope",
+				"issuance_log_sample_rate": rate,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	for _, roleName := range []string{"sampled", "sampled", "unsampled"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/" + roleName,
+			Storage:   config.StorageView,
+			EntityID:  "test-entity",
+		})
+		assert.NoError(t, err)
+		assert.NotNil(t, resp)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "log/issuance",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	events := resp.Data["events"].([]interface{})
+	assert.Len(t, events, 2)
+	assert.Equal(t, "sampled", events[0].(map[string]interface{})["role"])
+	assert.Equal(t, "test-entity", events[0].(map[string]interface{})["entity_id"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "log/issuance",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"limit": 1},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Data["events"], 1)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/sampled",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"issuance_log_sample_rate": 2},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+}
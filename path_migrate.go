@@ -0,0 +1,165 @@
+package artifactory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathMigrateReport() *framework.Path {
+	return &framework.Path{
+		Pattern: "migrate/report",
+		Fields: map[string]*framework.FieldSchema{
+			"tokens": {
+				Type:        framework.TypeKVPairs,
+				Required:    true,
+				Description: `Static tokens to migrate, as '<kv path>=<token>' pairs naming where each is stored.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathMigrateReportWrite,
+				Summary:  `Report which roles can replace static Artifactory tokens.`,
+			},
+		},
+		HelpSynopsis: `Report which roles can replace static Artifactory tokens.`,
+		HelpDescription: `
+Produces a cutover report for static Artifactory tokens kept in Vault KV, to plan replacing them with tokens from
+this backend. Plugins can't read other mounts, so the tokens are passed in 'tokens', keyed by the KV path they are
+stored at, e.g. by a script that reads the KV paths to migrate.
+
+For each token, the report gives its token id, username, scope and expiry, read from the token without contacting
+Artifactory, and the roles whose tokens have the same scope ("roles") or a broader one ("covering_roles"), along with
+a recommendation. Tokens that aren't JWT access tokens, such as reference tokens and API keys, can't be inspected.
+Nothing is issued, and the tokens are not stored or returned.
+`,
+	}
+}
+
+func (b *backend) pathMigrateReportWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+	defer b.rolesMutex.RUnlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	go b.sendUsage(*config, "pathMigrateReportWrite")
+
+	tokens := data.Get("tokens").(map[string]string)
+	if len(tokens) == 0 {
+		return logical.ErrorResponse("missing tokens"), nil
+	}
+
+	roleNames, err := req.Storage.List(ctx, "roles/")
+	if err != nil {
+		return nil, err
+	}
+
+	roleScopes := make(map[string][]string, len(roleNames))
+	for _, roleName := range roleNames {
+		role, err := b.Role(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil {
+			roleScopes[roleName] = strings.Fields(b.roleScope(*role))
+		}
+	}
+	sort.Strings(roleNames)
+
+	report := make(map[string]interface{}, len(tokens))
+	summary := map[string]int{
+		"total":         len(tokens),
+		"matched":       0,
+		"covered":       0,
+		"unmatched":     0,
+		"uninspectable": 0,
+		"expired":       0,
+	}
+
+	for path, token := range tokens {
+		entry := map[string]interface{}{
+			"access_token_sha256": accessTokenSHA256(token),
+		}
+		report[path] = entry
+
+		info, err := b.inspectToken(token)
+		if err != nil {
+			summary["uninspectable"]++
+			entry["recommendation"] = "Not a JWT access token, so its scope is unknown: find its owner and pick a role by hand."
+			continue
+		}
+
+		entry["token_id"] = info.TokenID
+		entry["username"] = info.Username
+		entry["scope"] = info.Scope
+		if info.Expires > 0 {
+			entry["expires"] = time.Unix(info.Expires, 0).UTC()
+			if time.Now().Unix() >= info.Expires {
+				entry["expired"] = true
+				summary["expired"]++
+			}
+		}
+
+		var matching, covering []string
+		tokenScopes := strings.Fields(info.Scope)
+		for _, roleName := range roleNames {
+			scopes, ok := roleScopes[roleName]
+			if !ok {
+				continue
+			}
+			switch {
+			case strutil.EquivalentSlices(scopes, tokenScopes):
+				matching = append(matching, roleName)
+			case strutil.StrListSubset(scopes, tokenScopes):
+				covering = append(covering, roleName)
+			}
+		}
+
+		switch {
+		case len(matching) > 0:
+			summary["matched"]++
+			entry["roles"] = matching
+			entry["recommendation"] = "Replace with tokens from token/" + matching[0] + "."
+		case len(covering) > 0:
+			summary["covered"]++
+			entry["covering_roles"] = covering
+			entry["recommendation"] = "Replace with tokens from token/" + covering[0] + ", which grant more than this token, or create a role with its scope."
+		default:
+			summary["unmatched"]++
+			entry["recommendation"] = "No role grants this scope: create one with scope=\"" + info.Scope + "\"."
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"report":  report,
+			"summary": summary,
+		},
+	}, nil
+}
+
+// inspectToken reads the claims of a token without validating it, since tokens being migrated may have been issued
+// by another instance or already expired
+func (b *backend) inspectToken(token string) (*TokenInfo, error) {
+	jwtToken, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.tokenInfoFromClaims(jwtToken.Claims.(jwt.MapClaims))
+}
@@ -0,0 +1,79 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// The migration report must match static tokens to roles by scope, without returning the tokens.
+func TestBackend_PathMigrateReport(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for roleName, scope := range map[string]string{
+		"admins":  "applied-permissions/admin",
+		"readers": "applied-permissions/groups:readers",
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data:      map[string]interface{}{"scope": scope},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "migrate/report",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"tokens": []string{
+				"secret/ci/artifactory=" + adminJWTAccessToken,
+				"secret/legacy/api-key=AKCp8legacyapikey",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+
+	report := resp.Data["report"].(map[string]interface{})
+
+	ci := report["secret/ci/artifactory"].(map[string]interface{})
+	assert.Equal(t, "84c0626b-7973-40c9-9d37-701aaf73cfb4", ci["token_id"])
+	assert.Equal(t, "admin", ci["username"])
+	assert.Equal(t, []string{"admins"}, ci["roles"])
+	assert.Equal(t, true, ci["expired"])
+	assert.Contains(t, ci["recommendation"], "token/admins")
+
+	legacy := report["secret/legacy/api-key"].(map[string]interface{})
+	assert.Equal(t, accessTokenSHA256("AKCp8legacyapikey"), legacy["access_token_sha256"])
+	assert.NotContains(t, legacy, "roles")
+
+	assert.Equal(t, map[string]int{
+		"total":         2,
+		"matched":       1,
+		"covered":       0,
+		"unmatched":     0,
+		"uninspectable": 1,
+		"expired":       1,
+	}, resp.Data["summary"])
+
+	for _, entry := range report {
+		for _, value := range entry.(map[string]interface{}) {
+			assert.NotEqual(t, adminJWTAccessToken, value)
+		}
+	}
+}
@@ -2,9 +2,15 @@ package artifactory
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -39,8 +45,43 @@ func (b *backend) pathRoles() *framework.Path {
 			},
 			"scope": {
 				Type:        framework.TypeString,
-				Required:    true,
-				Description: `Required. Space-delimited list. See the JFrog Artifactory REST documentation on "Create Token" for a full and up to date description.`,
+				Description: `Required unless 'applied_permissions', 'groups', 'projects', 'repositories', 'builds' or 'release_bundles' are set, which are preferred as they are validated and compiled into the right syntax. Space-delimited list, parsed against the Access scope grammar and stored in canonical form. See the JFrog Artifactory REST documentation on "Create Token" for a full and up to date description.`,
+			},
+			"applied_permissions": {
+				Type:        framework.TypeString,
+				Description: `Optional. 'user' to issue tokens with the permissions of their user, or 'admin' for admin tokens. Compiled into 'applied-permissions/<value>' and added to 'scope'. Requires Artifactory 7.21.1 or higher.`,
+			},
+			"groups": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Groups whose permissions tokens are issued with. Compiled into the scope syntax of the connected Artifactory version and added to 'scope'.`,
+			},
+			"projects": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. JFrog Project roles tokens are issued with, as '<project key>:<role>', e.g. 'payments:Developer'. Compiled into 'applied-permissions/roles:<project key>:<roles>' scopes and added to 'scope'. Requires Artifactory 7.21.1 or higher.`,
+			},
+			"repositories": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Repositories tokens are granted 'permissions' on. Compiled into artifact scopes and added to 'scope'.`,
+			},
+			"permissions": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Permissions granted on 'repositories': any of read, annotate, deploy, delete and manage.`,
+			},
+			"builds": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Build names tokens are granted 'build_permissions' on, e.g. to publish build info. Compiled into artifact scopes on the build info repository of the role's project and added to 'scope'.`,
+			},
+			"build_permissions": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Permissions granted on 'builds': any of read, annotate, deploy, delete and manage. Publishing build info takes deploy.`,
+			},
+			"release_bundles": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Release bundle names tokens are granted 'release_bundle_permissions' on, for promotion and distribution flows. Compiled into artifact scopes on the release bundle repositories and added to 'scope'.`,
+			},
+			"release_bundle_permissions": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Permissions granted on 'release_bundles': any of read, annotate, deploy, delete and manage. Creating and promoting release bundles takes deploy.`,
 			},
 			"refreshable": {
 				Type:        framework.TypeBool,
@@ -56,6 +97,20 @@ func (b *backend) pathRoles() *framework.Path {
 				Default:     false,
 				Description: `Optional. Defaults to 'false'. Generate a Reference Token (alias to Access Token) in addition to the full token (available from Artifactory 7.38.10). A reference token is a shorter, 64-character string, which can be used as a bearer token, a password, or with the "X-JFrog-Art-Api" header. Note: Using the reference token might have performance implications over a full length token.`,
 			},
+			"token_type": {
+				Type:        framework.TypeString,
+				Default:     tokenTypeAccess,
+				Description: `Optional. Defaults to 'access'. Set to 'identity' to issue identity tokens for developers, as the JFrog UI and Set Me Up flows generate: tokens with the user's own permissions ('applied-permissions/user'), returned as their reference token. Identity roles must not set scope, groups, repositories or escalated_scope. Requires Artifactory 7.38.10 or higher. Set to 'group' to issue one shared, rotating credential for a team: tokens whose subject is the service identity of the role's single group ('group-<group>' unless username is set) rather than the requesting user. Requests get the role's current token until half its lifetime has passed; the periodic function then revokes the tokens a newer one replaced. Group roles must set exactly one group, no scope, repositories, builds, release_bundles or escalated_scope, and require generate_lease and Artifactory 7.21.1 or higher.`,
+			},
+			"project_key": {
+				Type:        framework.TypeString,
+				Description: `Optional. Key of the JFrog Project tokens are issued in, for scopes that apply to the project's roles (e.g. 'applied-permissions/roles:<project>:<role>'). Requires Artifactory 7.21.1 or higher.`,
+			},
+			"generate_lease": {
+				Type:        framework.TypeBool,
+				Default:     true,
+				Description: `Optional. Defaults to 'true'. When 'false', tokens are issued without a Vault lease: they expire in Artifactory after their ttl, and can't be renewed or revoked through Vault. Reduces the load on Vault's expiration manager at high issuance volumes.`,
+			},
 			"default_ttl": {
 				Type:        framework.TypeDurationSecond,
 				Description: `Default TTL for issued access tokens. If unset, uses the backend's default_ttl. Cannot exceed max_ttl.`,
@@ -64,6 +119,108 @@ func (b *backend) pathRoles() *framework.Path {
 				Type:        framework.TypeDurationSecond,
 				Description: `Maximum TTL that an access token can be renewed for. If unset, uses the backend's max_ttl. Cannot exceed backend's max_ttl.`,
 			},
+			"require_change_ref": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: `Optional. Defaults to 'false'. When 'true', token requests for this role must include a 'change_ref' parameter, which is recorded in the token description and the lease.`,
+			},
+			"change_ref_pattern": {
+				Type:        framework.TypeString,
+				Description: `Optional. Regular expression a 'change_ref' must match (e.g. '^CR-[0-9]+$'). If unset, any non-empty value is accepted.`,
+			},
+			"require_provenance": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: `Optional. Defaults to 'false'. When 'true', token requests for this role must include 'pipeline_id' and 'commit_sha' parameters, which are recorded in the token description, the response and the lease for build provenance.`,
+			},
+			"pipeline_id_pattern": {
+				Type:        framework.TypeString,
+				Description: `Optional. Regular expression a 'pipeline_id' must match. If unset, any non-empty value is accepted.`,
+			},
+			"commit_sha_pattern": {
+				Type:        framework.TypeString,
+				Description: `Optional. Regular expression a 'commit_sha' must match. Defaults to a 7 to 64 character hexadecimal commit id.`,
+			},
+			"request_headers": {
+				Type:        framework.TypeKVPairs,
+				Description: `Optional. Extra HTTP headers sent on the calls to Artifactory made for this role's tokens (e.g. 'X-Tenant-ID=acme' for API gateway routing). Authentication headers can't be set.`,
+			},
+			"required_entity_metadata": {
+				Type:        framework.TypeKVPairs,
+				Description: `Optional. Key/value pairs that must all be present in the requesting Vault entity's metadata for a token to be issued (e.g. team=payments). Values may use a leading or trailing '*' as a glob.`,
+			},
+			"config_name": {
+				Type:        framework.TypeString,
+				Description: `Optional. Name of the admin configuration (config/admin/<name>) whose Artifactory instance issues this role's tokens. Defaults to config/admin.`,
+			},
+			"allowed_config_names": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Names of further admin configurations (config/admin/<name>) a token request may choose with 'config', e.g. region-local Artifactory replicas.`,
+			},
+			"max_auth_age": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Optional. Maximum time since the requesting Vault token was created, i.e. since the client authenticated, for a token to be issued. Makes interactive users log in again, and pass MFA, before getting tokens for this role. Unset means no limit.`,
+			},
+			"response_key_mapping": {
+				Type:        framework.TypeKVPairs,
+				Description: `Optional. Renames keys in the token response, for compatibility with consumers written for other secret engines (e.g. access_token=password). Keys not listed keep their names.`,
+			},
+			"issuance_log_sample_rate": {
+				Type:        framework.TypeFloat,
+				Description: `Optional. Defaults to '0'. Fraction (0.0 to 1.0) of token issuances for this role recorded in the log/issuance storage log.`,
+			},
+			"refresh_after": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Optional. How long after issuance Vault Agent, consul-template, and other caching clients should fetch a new token. When set, token responses include 'ttl' and 'refresh_after' (in seconds) and a 'Cache-Control: max-age' header. Cannot exceed max_ttl.`,
+			},
+			"escalated_scope": {
+				Type:        framework.TypeString,
+				Description: `Optional. Space-delimited scope issued instead of 'scope' when a token request sets 'break_glass=true' with a 'justification', for emergencies. Unset means the role has no break glass.`,
+			},
+			"break_glass_ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Optional. Defaults to 15 minutes. TTL of break glass tokens. They cannot be renewed past it.`,
+			},
+			"allowed_path_prefixes": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Repository path prefixes a token request for this role may pass as 'path_prefix', to narrow its repositories to the paths under it. A prefix also allows the paths under it. Requires repositories, and no scope or groups, which the prefix couldn't narrow.`,
+			},
+			"max_tokens_per_entity": {
+				Type:        framework.TypeInt,
+				Description: `Optional. Defaults to '0', unlimited. Most live tokens a single Vault entity may hold from this role at once. Requires generate_lease, since only leased tokens are tracked.`,
+			},
+			"revoke_oldest_on_entity_limit": {
+				Type:        framework.TypeBool,
+				Description: `Optional. Defaults to 'false'. When an entity at its max_tokens_per_entity requests a token, revoke its oldest token from this role in Artifactory instead of refusing the request.`,
+			},
+			"retire_at": {
+				Type:        framework.TypeTime,
+				Description: `Optional. RFC3339 time from which the role is retired: token requests are refused with the retirement_message. Existing leases are unaffected until revoke_at.`,
+			},
+			"retirement_message": {
+				Type:        framework.TypeString,
+				Description: `Optional. Message returned to token requests for the role once it is retired, e.g. which role to migrate to.`,
+			},
+			"revoke_at": {
+				Type:        framework.TypeTime,
+				Description: `Optional. RFC3339 time, not before retire_at, from which the outstanding tokens of the role are revoked in Artifactory. Their leases can't be renewed after it.`,
+			},
+			"force": {
+				Type:        framework.TypeBool,
+				Description: `Delete only. Delete the role even though it has active leases, which are then revoked without the role.`,
+			},
+			"check_admin_scope": {
+				Type:        framework.TypeBool,
+				Description: `Optional. Defaults to 'false'. Reject writes of the role if its scope, or escalated_scope, holds entries the admin token can't grant, as read from the admin token's own scope.`,
+			},
+			"multi_allowed_groups": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Names or ids of the Vault identity groups whose members may combine this role with others in token/multi. When unset, the role can't be used in token/multi.`,
+			},
+			"allowed_app_names": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Optional. Application names a token request for this role may pass as 'app_name'. When set, 'app_name' is required; when unset, it is rejected. The name is available to the username_template as '{{.AppName}}' and recorded in the token description.`,
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
@@ -89,15 +246,126 @@ func (b *backend) pathRoles() *framework.Path {
 }
 
 type artifactoryRole struct {
-	GrantType             string        `json:"grant_type,omitempty"`
-	Username              string        `json:"username,omitempty"`
-	Scope                 string        `json:"scope"`
-	Refreshable           bool          `json:"refreshable"`
-	Audience              string        `json:"audience,omitempty"`
-	Description           string        `json:"description,omitempty"`
-	IncludeReferenceToken bool          `json:"include_reference_token"`
-	DefaultTTL            time.Duration `json:"default_ttl,omitempty"`
-	MaxTTL                time.Duration `json:"max_ttl,omitempty"`
+	GrantType                 string            `json:"grant_type,omitempty"`
+	Username                  string            `json:"username,omitempty"`
+	Scope                     string            `json:"scope"`
+	Groups                    []string          `json:"groups,omitempty"`
+	Repositories              []string          `json:"repositories,omitempty"`
+	Permissions               []string          `json:"permissions,omitempty"`
+	Builds                    []string          `json:"builds,omitempty"`
+	BuildPermissions          []string          `json:"build_permissions,omitempty"`
+	ReleaseBundles            []string          `json:"release_bundles,omitempty"`
+	ReleaseBundlePermissions  []string          `json:"release_bundle_permissions,omitempty"`
+	AppliedPermissions        string            `json:"applied_permissions,omitempty"`
+	Projects                  []string          `json:"projects,omitempty"`
+	Refreshable               bool              `json:"refreshable"`
+	Audience                  string            `json:"audience,omitempty"`
+	Description               string            `json:"description,omitempty"`
+	IncludeReferenceToken     bool              `json:"include_reference_token"`
+	ProjectKey                string            `json:"project_key,omitempty"`
+	TokenType                 string            `json:"token_type,omitempty"`
+	NoLease                   bool              `json:"no_lease,omitempty"`
+	DefaultTTL                time.Duration     `json:"default_ttl,omitempty"`
+	MaxTTL                    time.Duration     `json:"max_ttl,omitempty"`
+	RequireChangeRef          bool              `json:"require_change_ref,omitempty"`
+	ChangeRefPattern          string            `json:"change_ref_pattern,omitempty"`
+	RequireProvenance         bool              `json:"require_provenance,omitempty"`
+	PipelineIDPattern         string            `json:"pipeline_id_pattern,omitempty"`
+	CommitSHAPattern          string            `json:"commit_sha_pattern,omitempty"`
+	RequiredEntityMetadata    map[string]string `json:"required_entity_metadata,omitempty"`
+	MaxAuthAge                time.Duration     `json:"max_auth_age,omitempty"`
+	ConfigName                string            `json:"config_name,omitempty"`
+	AllowedConfigNames        []string          `json:"allowed_config_names,omitempty"`
+	RequestHeaders            map[string]string `json:"request_headers,omitempty"`
+	ResponseKeyMapping        map[string]string `json:"response_key_mapping,omitempty"`
+	IssuanceLogSampleRate     float64           `json:"issuance_log_sample_rate,omitempty"`
+	AllowedAppNames           []string          `json:"allowed_app_names,omitempty"`
+	RefreshAfter              time.Duration     `json:"refresh_after,omitempty"`
+	EscalatedScope            string            `json:"escalated_scope,omitempty"`
+	BreakGlassTTL             time.Duration     `json:"break_glass_ttl,omitempty"`
+	CheckAdminScope           bool              `json:"check_admin_scope,omitempty"`
+	AllowedPathPrefixes       []string          `json:"allowed_path_prefixes,omitempty"`
+	MaxTokensPerEntity        int               `json:"max_tokens_per_entity,omitempty"`
+	RevokeOldestOnEntityLimit bool              `json:"revoke_oldest_on_entity_limit,omitempty"`
+	RetireAt                  time.Time         `json:"retire_at,omitempty"`
+	RetirementMessage         string            `json:"retirement_message,omitempty"`
+	RevokeAt                  time.Time         `json:"revoke_at,omitempty"`
+	MultiAllowedGroups        []string          `json:"multi_allowed_groups,omitempty"`
+
+	// pathPrefix narrows the repositories of a token to a path prefix requested for it. It is never stored.
+	pathPrefix string
+}
+
+const (
+	tokenTypeAccess   = "access"
+	tokenTypeIdentity = "identity"
+	tokenTypeGroup    = "group"
+
+	// groupTokenUsernamePrefix prefixes the group of a group role to name the service identity its tokens are issued to
+	groupTokenUsernamePrefix = "group-"
+
+	// identityTokenScope is the scope of identity tokens: the permissions of their user
+	identityTokenScope = scopeUser
+)
+
+// identity reports whether the role issues identity tokens rather than access tokens
+func (role artifactoryRole) identity() bool {
+	return role.TokenType == tokenTypeIdentity
+}
+
+// structuredScope reports whether the role sets any of the fields its scope is compiled from
+func (role artifactoryRole) structuredScope() bool {
+	return role.AppliedPermissions != "" || len(role.Groups) > 0 || len(role.Projects) > 0 || len(role.Repositories) > 0 || len(role.Builds) > 0 || len(role.ReleaseBundles) > 0
+}
+
+// group reports whether the role issues a shared credential of its group rather than tokens of the requesting user
+func (role artifactoryRole) group() bool {
+	return role.TokenType == tokenTypeGroup
+}
+
+// groupTokenUsername returns the service identity tokens of a group role are issued to: its username, or its group
+// prefixed with groupTokenUsernamePrefix
+func (role artifactoryRole) groupTokenUsername() string {
+	if len(role.Username) > 0 || len(role.Groups) == 0 {
+		return role.Username
+	}
+	return groupTokenUsernamePrefix + role.Groups[0]
+}
+
+// checkConfigName refuses a token request choosing an admin configuration the role doesn't allow. The role's own
+// config_name is always allowed.
+func (role artifactoryRole) checkConfigName(roleName string, name string) error {
+	if name == role.ConfigName || strutil.StrListContains(role.AllowedConfigNames, name) {
+		return nil
+	}
+	return fmt.Errorf("admin configuration '%s' is not one of the allowed_config_names of role '%s'", name, roleName)
+}
+
+// retired reports whether the role's retire_at has passed at now
+func (role artifactoryRole) retired(now time.Time) bool {
+	return !role.RetireAt.IsZero() && !now.Before(role.RetireAt)
+}
+
+// checkRetired refuses issuance for a role whose retire_at has passed, with its retirement_message
+func (role artifactoryRole) checkRetired(roleName string, now time.Time) error {
+	if !role.retired(now) {
+		return nil
+	}
+	message := fmt.Sprintf("role '%s' was retired at %s", roleName, role.RetireAt.UTC().Format(time.RFC3339))
+	if len(role.RetirementMessage) > 0 {
+		message += ": " + role.RetirementMessage
+	}
+	return errors.New(message)
+}
+
+// defaultBreakGlassTTL is the ttl of break glass tokens for roles that don't set break_glass_ttl
+const defaultBreakGlassTTL = 15 * time.Minute
+
+func (role artifactoryRole) breakGlassTTL() time.Duration {
+	if role.BreakGlassTTL > 0 {
+		return role.BreakGlassTTL
+	}
+	return defaultBreakGlassTTL
 }
 
 func (b *backend) pathRoleList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
@@ -135,6 +403,10 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, data
 		return logical.ErrorResponse("missing role"), nil
 	}
 
+	if roleName == multiRoleName {
+		return logical.ErrorResponse("role name '%s' is reserved for token/%s", multiRoleName, multiRoleName), nil
+	}
+
 	createOperation := (req.Operation == logical.CreateOperation)
 
 	role := &artifactoryRole{}
@@ -158,7 +430,73 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, data
 	}
 
 	if value, ok := data.GetOk("scope"); ok {
-		role.Scope = value.(string)
+		role.Scope, err = normalizeScope(value.(string))
+		if err != nil {
+			return remediationResponse(remediationScopeGrammar, err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("applied_permissions"); ok {
+		role.AppliedPermissions = value.(string)
+		if err := validateAppliedPermissions(role.AppliedPermissions); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("groups"); ok {
+		role.Groups = value.([]string)
+		if err := validateScopeNames("groups", role.Groups); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("projects"); ok {
+		role.Projects = value.([]string)
+		if err := validateProjectRoles(role.Projects); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("repositories"); ok {
+		role.Repositories = value.([]string)
+		if err := validateRepositoryNames(role.Repositories); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("permissions"); ok {
+		role.Permissions = value.([]string)
+		if err := validateScopePermissions(role.Permissions); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("builds"); ok {
+		role.Builds = value.([]string)
+		if err := validateScopeNames("builds", role.Builds); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("build_permissions"); ok {
+		role.BuildPermissions = value.([]string)
+		if err := validateScopePermissions(role.BuildPermissions); err != nil {
+			return logical.ErrorResponse("build_permissions: %s", err), nil
+		}
+	}
+
+	if value, ok := data.GetOk("release_bundles"); ok {
+		role.ReleaseBundles = value.([]string)
+		if err := validateScopeNames("release_bundles", role.ReleaseBundles); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("release_bundle_permissions"); ok {
+		role.ReleaseBundlePermissions = value.([]string)
+		if err := validateScopePermissions(role.ReleaseBundlePermissions); err != nil {
+			return logical.ErrorResponse("release_bundle_permissions: %s", err), nil
+		}
 	}
 
 	if value, ok := data.GetOk("refreshable"); ok {
@@ -173,6 +511,21 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, data
 		role.IncludeReferenceToken = value.(bool)
 	}
 
+	if value, ok := data.GetOk("token_type"); ok {
+		role.TokenType = value.(string)
+		if role.TokenType != tokenTypeAccess && role.TokenType != tokenTypeIdentity && role.TokenType != tokenTypeGroup {
+			return logical.ErrorResponse("token_type must be '%s', '%s' or '%s'", tokenTypeAccess, tokenTypeIdentity, tokenTypeGroup), nil
+		}
+	}
+
+	if value, ok := data.GetOk("project_key"); ok {
+		role.ProjectKey = value.(string)
+	}
+
+	if value, ok := data.GetOk("generate_lease"); ok {
+		role.NoLease = !value.(bool)
+	}
+
 	// Looking at database/path_roles.go, it doesn't do any validation on these values during role creation.
 	if value, ok := data.GetOk("default_ttl"); ok {
 		role.DefaultTTL = time.Duration(value.(int)) * time.Second
@@ -182,8 +535,181 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, data
 		role.MaxTTL = time.Duration(value.(int)) * time.Second
 	}
 
-	if role.Scope == "" {
-		return logical.ErrorResponse("missing scope"), nil
+	if value, ok := data.GetOk("require_change_ref"); ok {
+		role.RequireChangeRef = value.(bool)
+	}
+
+	if value, ok := data.GetOk("change_ref_pattern"); ok {
+		role.ChangeRefPattern = value.(string)
+		if _, err := regexp.Compile(role.ChangeRefPattern); err != nil {
+			return logical.ErrorResponse("invalid change_ref_pattern: %s", err), nil
+		}
+	}
+
+	if value, ok := data.GetOk("require_provenance"); ok {
+		role.RequireProvenance = value.(bool)
+	}
+
+	if value, ok := data.GetOk("pipeline_id_pattern"); ok {
+		role.PipelineIDPattern = value.(string)
+		if _, err := regexp.Compile(role.PipelineIDPattern); err != nil {
+			return logical.ErrorResponse("invalid pipeline_id_pattern: %s", err), nil
+		}
+	}
+
+	if value, ok := data.GetOk("commit_sha_pattern"); ok {
+		role.CommitSHAPattern = value.(string)
+		if _, err := regexp.Compile(role.CommitSHAPattern); err != nil {
+			return logical.ErrorResponse("invalid commit_sha_pattern: %s", err), nil
+		}
+	}
+
+	if value, ok := data.GetOk("request_headers"); ok {
+		role.RequestHeaders = value.(map[string]string)
+		if err := validateRequestHeaders("request_headers", role.RequestHeaders); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("required_entity_metadata"); ok {
+		role.RequiredEntityMetadata = value.(map[string]string)
+	}
+
+	if value, ok := data.GetOk("config_name"); ok {
+		role.ConfigName = value.(string)
+		if len(role.ConfigName) > 0 {
+			named, err := b.fetchNamedConfiguration(ctx, req.Storage, role.ConfigName)
+			if err != nil {
+				return nil, err
+			}
+			if named == nil {
+				return logical.ErrorResponse("admin configuration '%s' does not exist", role.ConfigName), nil
+			}
+		}
+	}
+
+	if value, ok := data.GetOk("allowed_config_names"); ok {
+		role.AllowedConfigNames = value.([]string)
+		for _, name := range role.AllowedConfigNames {
+			named, err := b.fetchNamedConfiguration(ctx, req.Storage, name)
+			if err != nil {
+				return nil, err
+			}
+			if named == nil {
+				return logical.ErrorResponse("admin configuration '%s' does not exist", name), nil
+			}
+		}
+	}
+
+	if value, ok := data.GetOk("max_auth_age"); ok {
+		role.MaxAuthAge = time.Duration(value.(int)) * time.Second
+		if role.MaxAuthAge < 0 {
+			return logical.ErrorResponse("max_auth_age cannot be negative"), nil
+		}
+	}
+
+	if value, ok := data.GetOk("response_key_mapping"); ok {
+		role.ResponseKeyMapping = value.(map[string]string)
+		if err := validateResponseKeyMapping(role.ResponseKeyMapping); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if value, ok := data.GetOk("issuance_log_sample_rate"); ok {
+		role.IssuanceLogSampleRate = value.(float64)
+		if role.IssuanceLogSampleRate < 0 || role.IssuanceLogSampleRate > 1 {
+			return logical.ErrorResponse("issuance_log_sample_rate must be between 0.0 and 1.0"), nil
+		}
+	}
+
+	if value, ok := data.GetOk("multi_allowed_groups"); ok {
+		role.MultiAllowedGroups = value.([]string)
+	}
+
+	if value, ok := data.GetOk("allowed_app_names"); ok {
+		role.AllowedAppNames = value.([]string)
+	}
+
+	if value, ok := data.GetOk("refresh_after"); ok {
+		role.RefreshAfter = time.Duration(value.(int)) * time.Second
+	}
+
+	if value, ok := data.GetOk("escalated_scope"); ok {
+		role.EscalatedScope, err = normalizeScope(value.(string))
+		if err != nil {
+			return remediationResponse(remediationScopeGrammar, "escalated_scope: %s", err), nil
+		}
+	}
+
+	if value, ok := data.GetOk("break_glass_ttl"); ok {
+		role.BreakGlassTTL = time.Duration(value.(int)) * time.Second
+	}
+
+	if value, ok := data.GetOk("check_admin_scope"); ok {
+		role.CheckAdminScope = value.(bool)
+	}
+
+	if value, ok := data.GetOk("allowed_path_prefixes"); ok {
+		role.AllowedPathPrefixes = nil
+		for _, prefix := range value.([]string) {
+			normalized, err := normalizePathPrefix(prefix)
+			if err != nil {
+				return logical.ErrorResponse("invalid allowed_path_prefixes: %s", err), nil
+			}
+			role.AllowedPathPrefixes = append(role.AllowedPathPrefixes, normalized)
+		}
+	}
+
+	if value, ok := data.GetOk("max_tokens_per_entity"); ok {
+		role.MaxTokensPerEntity = value.(int)
+		if role.MaxTokensPerEntity < 0 {
+			return logical.ErrorResponse("max_tokens_per_entity must not be negative"), nil
+		}
+	}
+
+	if value, ok := data.GetOk("revoke_oldest_on_entity_limit"); ok {
+		role.RevokeOldestOnEntityLimit = value.(bool)
+	}
+
+	if value, ok := data.GetOk("retire_at"); ok {
+		role.RetireAt = value.(time.Time)
+	}
+
+	if value, ok := data.GetOk("retirement_message"); ok {
+		role.RetirementMessage = value.(string)
+	}
+
+	if value, ok := data.GetOk("revoke_at"); ok {
+		role.RevokeAt = value.(time.Time)
+	}
+
+	if role.Scope == "" && !role.structuredScope() && !role.identity() {
+		return remediationResponse(remediationScopeGrammar, "missing scope"), nil
+	}
+
+	if conflicts := roleConflicts(*role, *config); len(conflicts) > 0 {
+		return logical.ErrorResponse("conflicting role fields: %s", strings.Join(conflicts, "; ")), nil
+	}
+
+	// Only a warning, as such roles were always accepted: their tokens work, but never expire, so aren't refreshed
+	var warnings []string
+	if role.Refreshable && !config.UseExpiringTokens {
+		warnings = append(warnings, "refreshable=true has no effect until use_expiring_tokens=true is set in config/admin, since tokens that never expire are never refreshed")
+	}
+
+	if role.CheckAdminScope {
+		roleConfig, err := b.roleConfiguration(ctx, req.Storage, *config, *role)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if adminScope, missing := b.scopeBeyondAdmin(roleConfig, b.roleScope(*role)); len(missing) > 0 {
+			return logical.ErrorResponse("scope holds '%s', which the admin token can't grant: its scope is '%s'", strings.Join(missing, " "), adminScope), nil
+		}
+
+		if adminScope, missing := b.scopeBeyondAdmin(roleConfig, role.EscalatedScope); len(missing) > 0 {
+			return logical.ErrorResponse("escalated_scope holds '%s', which the admin token can't grant: its scope is '%s'", strings.Join(missing, " "), adminScope), nil
+		}
 	}
 
 	entry, err := logical.StorageEntryJSON("roles/"+roleName, role)
@@ -195,6 +721,10 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, data
 		return nil, err
 	}
 
+	if len(warnings) > 0 {
+		return &logical.Response{Warnings: warnings}, nil
+	}
+
 	return nil, nil
 }
 
@@ -231,10 +761,86 @@ func (b *backend) pathRoleRead(ctx context.Context, req *logical.Request, data *
 	}
 
 	return &logical.Response{
-		Data: b.roleToMap(roleName, *role),
+		Data:     b.roleToMap(roleName, *role),
+		Warnings: append(role.ttlBoundsWarnings(b.System().MaxLeaseTTL()), role.configTTLWarnings(*config)...),
 	}, nil
 }
 
+// checkMountTTLs logs the roles whose TTLs exceed the mount's max lease ttl whenever that changes, e.g. when the mount
+// is tuned, rather than leaving it to the next issuance to clamp them
+func (b *backend) checkMountTTLs(ctx context.Context, req *logical.Request) error {
+	maxLeaseTTL := b.System().MaxLeaseTTL()
+	if maxLeaseTTL == b.lastMaxLeaseTTL {
+		return nil
+	}
+	b.lastMaxLeaseTTL = maxLeaseTTL
+
+	b.rolesMutex.RLock()
+	defer b.rolesMutex.RUnlock()
+
+	roleNames, err := req.Storage.List(ctx, "roles/")
+	if err != nil {
+		return err
+	}
+
+	for _, roleName := range roleNames {
+		role, err := b.Role(ctx, req.Storage, roleName)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			continue
+		}
+
+		for _, warning := range role.ttlBoundsWarnings(maxLeaseTTL) {
+			b.Logger().Warn("role TTL out of bounds after mount max lease ttl changed", "role", roleName, "warning", warning)
+		}
+	}
+
+	return nil
+}
+
+// ttlBoundsWarnings describes the role TTLs that exceed the mount's max lease ttl, e.g. after the mount was tuned, and
+// so are clamped when tokens are issued
+func (role artifactoryRole) ttlBoundsWarnings(maxLeaseTTL time.Duration) []string {
+	if maxLeaseTTL <= 0 {
+		return nil
+	}
+
+	var warnings []string
+	if role.DefaultTTL > maxLeaseTTL {
+		warnings = append(warnings, fmt.Sprintf("default_ttl (%s) exceeds the mount's max lease ttl (%s), tokens are issued with at most %s", role.DefaultTTL, maxLeaseTTL, maxLeaseTTL))
+	}
+	if role.MaxTTL > maxLeaseTTL {
+		warnings = append(warnings, fmt.Sprintf("max_ttl (%s) exceeds the mount's max lease ttl (%s), tokens are issued with at most %s", role.MaxTTL, maxLeaseTTL, maxLeaseTTL))
+	}
+	return warnings
+}
+
+// clampTTLs lowers the TTLs of the role to the default_ttl and max_ttl ceilings of config/admin, which also apply to
+// roles that don't set them
+func (role *artifactoryRole) clampTTLs(config adminConfiguration) {
+	if config.MaxTTL > 0 && (role.MaxTTL == 0 || role.MaxTTL > config.MaxTTL) {
+		role.MaxTTL = config.MaxTTL
+	}
+	if config.DefaultTTL > 0 && (role.DefaultTTL == 0 || role.DefaultTTL > config.DefaultTTL) {
+		role.DefaultTTL = config.DefaultTTL
+	}
+}
+
+// configTTLWarnings describes the role TTLs that exceed the ceilings of config/admin, and so are clamped when tokens
+// are issued
+func (role artifactoryRole) configTTLWarnings(config adminConfiguration) []string {
+	var warnings []string
+	if config.DefaultTTL > 0 && role.DefaultTTL > config.DefaultTTL {
+		warnings = append(warnings, fmt.Sprintf("default_ttl (%s) exceeds the default_ttl of config/admin (%s), tokens are issued with at most %s by default", role.DefaultTTL, config.DefaultTTL, config.DefaultTTL))
+	}
+	if config.MaxTTL > 0 && role.MaxTTL > config.MaxTTL {
+		warnings = append(warnings, fmt.Sprintf("max_ttl (%s) exceeds the max_ttl of config/admin (%s), tokens are issued with at most %s", role.MaxTTL, config.MaxTTL, config.MaxTTL))
+	}
+	return warnings
+}
+
 func (b *backend) Role(ctx context.Context, storage logical.Storage, roleName string) (*artifactoryRole, error) {
 
 	entry, err := storage.Get(ctx, "roles/"+roleName)
@@ -256,15 +862,21 @@ func (b *backend) Role(ctx context.Context, storage logical.Storage, roleName st
 
 func (b *backend) roleToMap(roleName string, role artifactoryRole) (roleMap map[string]interface{}) {
 	roleMap = map[string]interface{}{
-		"role":                    roleName,
-		"scope":                   role.Scope,
-		"default_ttl":             role.DefaultTTL.Seconds(),
-		"max_ttl":                 role.MaxTTL.Seconds(),
-		"refreshable":             role.Refreshable,
-		"include_reference_token": role.IncludeReferenceToken,
+		"role":                     roleName,
+		"scope":                    role.Scope,
+		"default_ttl":              role.DefaultTTL.Seconds(),
+		"max_ttl":                  role.MaxTTL.Seconds(),
+		"refreshable":              role.Refreshable,
+		"include_reference_token":  role.IncludeReferenceToken,
+		"require_change_ref":       role.RequireChangeRef,
+		"issuance_log_sample_rate": role.IssuanceLogSampleRate,
+		"generate_lease":           !role.NoLease,
 	}
 
 	// Optional Attributes
+	if role.identity() || role.group() {
+		roleMap["token_type"] = role.TokenType
+	}
 	if len(role.GrantType) > 0 {
 		roleMap["grant_type"] = role.GrantType
 	}
@@ -274,6 +886,95 @@ func (b *backend) roleToMap(roleName string, role artifactoryRole) (roleMap map[
 	if len(role.Audience) > 0 {
 		roleMap["audience"] = role.Audience
 	}
+	if len(role.ProjectKey) > 0 {
+		roleMap["project_key"] = role.ProjectKey
+	}
+	if len(role.ChangeRefPattern) > 0 {
+		roleMap["change_ref_pattern"] = role.ChangeRefPattern
+	}
+	if role.RequireProvenance {
+		roleMap["require_provenance"] = true
+	}
+	if len(role.PipelineIDPattern) > 0 {
+		roleMap["pipeline_id_pattern"] = role.PipelineIDPattern
+	}
+	if len(role.CommitSHAPattern) > 0 {
+		roleMap["commit_sha_pattern"] = role.CommitSHAPattern
+	}
+	if len(role.RequestHeaders) > 0 {
+		roleMap["request_headers"] = role.RequestHeaders
+	}
+	if len(role.RequiredEntityMetadata) > 0 {
+		roleMap["required_entity_metadata"] = role.RequiredEntityMetadata
+	}
+	if len(role.ConfigName) > 0 {
+		roleMap["config_name"] = role.ConfigName
+	}
+	if len(role.AllowedConfigNames) > 0 {
+		roleMap["allowed_config_names"] = role.AllowedConfigNames
+	}
+	if role.MaxAuthAge > 0 {
+		roleMap["max_auth_age"] = role.MaxAuthAge.Seconds()
+	}
+	if len(role.ResponseKeyMapping) > 0 {
+		roleMap["response_key_mapping"] = role.ResponseKeyMapping
+	}
+	if len(role.AppliedPermissions) > 0 {
+		roleMap["applied_permissions"] = role.AppliedPermissions
+	}
+	if len(role.Groups) > 0 {
+		roleMap["groups"] = role.Groups
+	}
+	if len(role.Projects) > 0 {
+		roleMap["projects"] = role.Projects
+	}
+	if len(role.Repositories) > 0 {
+		roleMap["repositories"] = role.Repositories
+	}
+	if len(role.Permissions) > 0 {
+		roleMap["permissions"] = role.Permissions
+	}
+	if len(role.Builds) > 0 {
+		roleMap["builds"] = role.Builds
+		roleMap["build_permissions"] = role.BuildPermissions
+	}
+	if len(role.ReleaseBundles) > 0 {
+		roleMap["release_bundles"] = role.ReleaseBundles
+		roleMap["release_bundle_permissions"] = role.ReleaseBundlePermissions
+	}
+	if len(role.MultiAllowedGroups) > 0 {
+		roleMap["multi_allowed_groups"] = role.MultiAllowedGroups
+	}
+	if len(role.AllowedAppNames) > 0 {
+		roleMap["allowed_app_names"] = role.AllowedAppNames
+	}
+	if role.RefreshAfter > 0 {
+		roleMap["refresh_after"] = role.RefreshAfter.Seconds()
+	}
+	if len(role.EscalatedScope) > 0 {
+		roleMap["escalated_scope"] = role.EscalatedScope
+		roleMap["break_glass_ttl"] = role.breakGlassTTL().Seconds()
+	}
+	if role.CheckAdminScope {
+		roleMap["check_admin_scope"] = true
+	}
+	if len(role.AllowedPathPrefixes) > 0 {
+		roleMap["allowed_path_prefixes"] = role.AllowedPathPrefixes
+	}
+	if !role.RetireAt.IsZero() {
+		roleMap["retire_at"] = role.RetireAt.UTC().Format(time.RFC3339)
+		roleMap["retired"] = role.retired(time.Now())
+	}
+	if len(role.RetirementMessage) > 0 {
+		roleMap["retirement_message"] = role.RetirementMessage
+	}
+	if !role.RevokeAt.IsZero() {
+		roleMap["revoke_at"] = role.RevokeAt.UTC().Format(time.RFC3339)
+	}
+	if role.MaxTokensPerEntity > 0 {
+		roleMap["max_tokens_per_entity"] = role.MaxTokensPerEntity
+		roleMap["revoke_oldest_on_entity_limit"] = role.RevokeOldestOnEntityLimit
+	}
 
 	return
 }
@@ -295,14 +996,208 @@ func (b *backend) pathRoleDelete(ctx context.Context, req *logical.Request, data
 
 	go b.sendUsage(*config, "pathRoleDelete")
 
-	err = req.Storage.Delete(ctx, "roles/"+data.Get("role").(string))
+	roleName := data.Get("role").(string)
+
+	if !data.Get("force").(bool) {
+		count, newestExpiry, err := b.activeRoleTokens(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			message := fmt.Sprintf("role '%s' has %d active leases, the newest expiring at %s: revoke them first with "+
+				"'vault lease revoke -prefix %stoken/%s/', or delete the role with force=true",
+				roleName, count, newestExpiry.UTC().Format(time.RFC3339), req.MountPoint, roleName)
+			return logical.ErrorResponse(message), logical.CodedError(http.StatusConflict, message)
+		}
+	}
+
+	err = req.Storage.Delete(ctx, "roles/"+roleName)
 	if err != nil {
 		return nil, err
 	}
 
+	// A role written again under the name must not hand out the credential of this one
+	if err := req.Storage.Delete(ctx, groupTokenStoragePrefix+roleName); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
+// roleConflicts returns a message for each combination of role fields (and the admin config they depend on) that
+// contradict each other. Such roles are rejected at write time rather than behaving surprisingly at issuance.
+func roleConflicts(role artifactoryRole, config adminConfiguration) []string {
+	var conflicts []string
+
+	if role.DefaultTTL > 0 && role.MaxTTL > 0 && role.DefaultTTL > role.MaxTTL {
+		conflicts = append(conflicts, fmt.Sprintf("default_ttl (%s) must not exceed max_ttl (%s)", role.DefaultTTL, role.MaxTTL))
+	}
+
+	if role.RefreshAfter > 0 && role.MaxTTL > 0 && role.RefreshAfter > role.MaxTTL {
+		conflicts = append(conflicts, fmt.Sprintf("refresh_after (%s) must not exceed max_ttl (%s)", role.RefreshAfter, role.MaxTTL))
+	}
+
+	if len(role.Repositories) > 0 && len(role.Permissions) == 0 {
+		conflicts = append(conflicts, "repositories are set but permissions are not")
+	}
+
+	if len(role.Permissions) > 0 && len(role.Repositories) == 0 {
+		conflicts = append(conflicts, "permissions are set but repositories are not")
+	}
+
+	if (len(role.Builds) > 0) != (len(role.BuildPermissions) > 0) {
+		conflicts = append(conflicts, "builds and build_permissions must be set together")
+	}
+
+	if (len(role.ReleaseBundles) > 0) != (len(role.ReleaseBundlePermissions) > 0) {
+		conflicts = append(conflicts, "release_bundles and release_bundle_permissions must be set together")
+	}
+
+	if role.BreakGlassTTL > 0 && role.EscalatedScope == "" {
+		conflicts = append(conflicts, "break_glass_ttl is set but escalated_scope is not")
+	}
+
+	if role.identity() && (role.Scope != "" || role.structuredScope() || role.EscalatedScope != "") {
+		conflicts = append(conflicts, "token_type=identity issues tokens with the user's own permissions, so scope, applied_permissions, groups, projects, repositories, builds, release_bundles and escalated_scope must not be set")
+	}
+
+	if role.group() {
+		if len(role.Groups) != 1 || role.Scope != "" || role.AppliedPermissions != "" || len(role.Projects) > 0 || len(role.Repositories) > 0 || len(role.Builds) > 0 || len(role.ReleaseBundles) > 0 || role.EscalatedScope != "" {
+			conflicts = append(conflicts, "token_type=group issues the credential of a single group, so exactly one group must be set, and no scope, applied_permissions, projects, repositories, builds, release_bundles or escalated_scope")
+		}
+		if role.NoLease {
+			conflicts = append(conflicts, "token_type=group tracks and revokes the shared credential, so generate_lease must not be false")
+		}
+		if role.MaxTokensPerEntity > 0 {
+			conflicts = append(conflicts, "token_type=group keeps one credential for the role, so max_tokens_per_entity must not be set")
+		}
+	}
+
+	if len(role.AllowedPathPrefixes) > 0 && (len(role.Repositories) == 0 || role.Scope != "" || len(role.Groups) > 0) {
+		conflicts = append(conflicts, "allowed_path_prefixes narrow the role's repositories, so they require repositories, and no scope or groups")
+	}
+
+	if role.MaxTokensPerEntity > 0 && role.NoLease {
+		conflicts = append(conflicts, "max_tokens_per_entity counts the tracked leases of an entity, so it requires generate_lease=true")
+	}
+
+	if role.RevokeOldestOnEntityLimit && role.MaxTokensPerEntity == 0 {
+		conflicts = append(conflicts, "revoke_oldest_on_entity_limit is set but max_tokens_per_entity is not")
+	}
+
+	if !role.RevokeAt.IsZero() && (role.RetireAt.IsZero() || role.RevokeAt.Before(role.RetireAt)) {
+		conflicts = append(conflicts, "revoke_at requires retire_at, and must not be before it, so issuance stops before tokens are revoked")
+	}
+
+	if len(role.RetirementMessage) > 0 && role.RetireAt.IsZero() {
+		conflicts = append(conflicts, "retirement_message is set but retire_at is not")
+	}
+
+	return conflicts
+}
+
+// reservedRequestHeaders are set by the backend itself, and can't be overridden by request_headers or custom_headers
+var reservedRequestHeaders = []string{"Authorization", "X-Jfrog-Art-Api", "Content-Type", "User-Agent", "Host"}
+
+// validateRequestHeaders checks the headers written to field don't override those the backend sets
+func validateRequestHeaders(field string, headers map[string]string) error {
+	for name := range headers {
+		if name == "" {
+			return fmt.Errorf("%s: empty header name", field)
+		}
+		if strutil.StrListContains(reservedRequestHeaders, http.CanonicalHeaderKey(name)) {
+			return fmt.Errorf("%s: '%s' is set by the backend and can't be overridden", field, name)
+		}
+	}
+	return nil
+}
+
+// withRole returns config set up for calls made for the named role, if it still exists: with the admin configuration
+// the role uses, and the role's request headers
+func (b *backend) withRole(ctx context.Context, storage logical.Storage, config adminConfiguration, roleName string) adminConfiguration {
+	if roleName == "" {
+		return config
+	}
+
+	role, err := b.Role(ctx, storage, roleName)
+	if err != nil {
+		b.Logger().Warn("could not read role for its configuration", "role", roleName, "err", err)
+		return config
+	}
+
+	if role == nil {
+		return config
+	}
+
+	roleConfig, err := b.roleConfiguration(ctx, storage, config, *role)
+	if err != nil {
+		b.Logger().Warn("could not get the admin configuration of the role", "role", roleName, "err", err)
+	}
+
+	return roleConfig
+}
+
+// tokenResponseKeys are the keys a token/<role> response may contain, and so the keys response_key_mapping can rename
+var tokenResponseKeys = []string{
+	"access_token",
+	"refresh_token",
+	"role",
+	"scope",
+	"token_id",
+	"username",
+	"reference_token",
+	"change_ref",
+	"pipeline_id",
+	"commit_sha",
+	"app_name",
+	"ttl",
+	"refresh_after",
+	"break_glass",
+	"revocable",
+	"path_prefix",
+	"config_name",
+	"token_type",
+}
+
+func validateResponseKeyMapping(mapping map[string]string) error {
+	targets := map[string]string{}
+	for from, to := range mapping {
+		if !strutil.StrListContains(tokenResponseKeys, from) {
+			return fmt.Errorf("response_key_mapping: unknown response key '%s', must be one of %s", from, strings.Join(tokenResponseKeys, ", "))
+		}
+		if to == "" {
+			return fmt.Errorf("response_key_mapping: empty new name for '%s'", from)
+		}
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("response_key_mapping: '%s' and '%s' are both renamed to '%s'", other, from, to)
+		}
+		targets[to] = from
+	}
+
+	// A renamed key must not collide with a key that keeps its name
+	for to, from := range targets {
+		if _, renamed := mapping[to]; !renamed && to != from && strutil.StrListContains(tokenResponseKeys, to) {
+			return fmt.Errorf("response_key_mapping: '%s' would overwrite the existing '%s' key", from, to)
+		}
+	}
+
+	return nil
+}
+
+// applyResponseKeyMapping renames keys in data according to mapping
+func applyResponseKeyMapping(data map[string]interface{}, mapping map[string]string) {
+	renamed := map[string]interface{}{}
+	for from, to := range mapping {
+		if value, ok := data[from]; ok {
+			renamed[to] = value
+			delete(data, from)
+		}
+	}
+	for key, value := range renamed {
+		data[key] = value
+	}
+}
+
 func (b *backend) existenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
 	resp, err := b.pathRoleRead(ctx, req, data)
 	return resp != nil && !resp.IsError(), err
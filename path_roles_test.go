@@ -2,6 +2,7 @@ package artifactory
 
 import (
 	"context"
+	"net/http"
 	"testing"
 	"time"
 
@@ -181,3 +182,186 @@ func TestBackend_PathRoleWriteThenRead(t *testing.T) {
 	assert.EqualValues(t, 30*time.Minute.Seconds(), resp.Data["default_ttl"])
 	assert.EqualValues(t, 45*time.Minute.Seconds(), resp.Data["max_ttl"])
 }
+
+// Roles with contradictory fields must be rejected at write time, naming each conflict, and refreshable roles of a
+// config without expiring tokens must be accepted with a warning.
+func TestBackend_PathRoleWriteRejectsConflicts(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":       "test-scope",
+			"refreshable": true,
+			"default_ttl": 45 * time.Minute,
+			"max_ttl":     30 * time.Minute,
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "default_ttl (45m0s) must not exceed max_ttl (30m0s)")
+	assert.NotContains(t, resp.Error().Error(), "refreshable")
+
+	role, err := b.Role(context.Background(), config.StorageView, "test-role")
+	assert.NoError(t, err)
+	assert.Nil(t, role)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":       "test-scope",
+			"refreshable": true,
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Len(t, resp.Warnings, 1)
+	assert.Contains(t, resp.Warnings[0], "use_expiring_tokens=true")
+
+	role, err = b.Role(context.Background(), config.StorageView, "test-role")
+	assert.NoError(t, err)
+	assert.NotNil(t, role)
+}
+
+// Roles whose TTLs exceed the mount's max lease ttl after the mount is tuned must be reported.
+func TestBackend_RoleTTLOutOfBounds(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":   "test-scope",
+			"max_ttl": "24h",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	readRole := func() *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "roles/test-role",
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	assert.Empty(t, readRole().Warnings)
+
+	// Tune the mount below the role's max_ttl
+	config.System.(*logical.StaticSystemView).MaxLeaseTTLVal = time.Hour
+
+	resp = readRole()
+	assert.Len(t, resp.Warnings, 1)
+	assert.Contains(t, resp.Warnings[0], "max_ttl (24h0m0s) exceeds the mount's max lease ttl (1h0m0s)")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "analyze/roles",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test-role"}, resp.Data["ttl_out_of_bounds"])
+
+	assert.NoError(t, b.checkMountTTLs(context.Background(), &logical.Request{Storage: config.StorageView}))
+	assert.Equal(t, time.Hour, b.lastMaxLeaseTTL)
+}
+
+// Deleting a role with active leases must fail with a conflict unless forced.
+func TestBackend_PathRoleDeleteWithActiveLeases(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for _, roleName := range []string{"unused-role", "test-role"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data:      map[string]interface{}{"scope": "test-scope"},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "roles/unused-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.DeleteOperation,
+		Path:       "roles/test-role",
+		MountPoint: "artifactory/",
+		Storage:    config.StorageView,
+	})
+	assert.Error(t, err)
+	if coded, ok := err.(logical.HTTPCodedError); assert.True(t, ok) {
+		assert.Equal(t, http.StatusConflict, coded.Code())
+	}
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "has 1 active leases")
+	assert.Contains(t, resp.Error().Error(), "vault lease revoke -prefix artifactory/token/test-role/")
+
+	role, err := b.Role(context.Background(), config.StorageView, "test-role")
+	assert.NoError(t, err)
+	assert.NotNil(t, role)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"force": true},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	role, err = b.Role(context.Background(), config.StorageView, "test-role")
+	assert.NoError(t, err)
+	assert.Nil(t, role)
+}
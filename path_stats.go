@@ -0,0 +1,144 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathStats() *framework.Path {
+	return &framework.Path{
+		Pattern: "stats",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathStatsRead,
+				Summary:  `Report entry counts and storage usage of this mount.`,
+			},
+		},
+		HelpSynopsis: `Report entry counts and storage usage of this mount.`,
+		HelpDescription: `
+Returns, for each kind of entry this backend stores (roles, tracked tokens, queued revocations, issuance log
+events, changelog entries, and asynchronous token requests), the number of entries and the total size of their values in bytes, plus
+the totals across all kinds.
+
+Sizes are of the stored JSON values, before any encryption or overhead added by Vault's storage backend. Counting
+doesn't block issuance, so counts may be slightly off while tokens are being issued.
+
+When revocations are queued for retry, "revocation_queue" also reports when the oldest was queued and its age in
+seconds, and a warning is returned once that age exceeds an hour.
+
+"deprecated_usage" counts the requests that used each deprecated parameter or path since the plugin started, to track
+the migration of clients.
+
+"artifactory_latency" breaks down, for each Artifactory endpoint called since the plugin started (token_create,
+token_revoke, token_info, version, root_cert, ping, usage, permissions), the number of calls, how many failed or got a
+5xx response, and the 50th, 90th and 99th percentile and maximum latency in milliseconds of the last 1000 calls. The
+latency is the time Artifactory took to respond, so comparing it with the time of Vault requests tells whether
+slowness is on the Vault or the Artifactory side.
+`,
+	}
+}
+
+// statsPrefixes maps the names reported by the stats endpoint to the storage prefixes they measure
+var statsPrefixes = map[string]string{
+	"roles":            "roles/",
+	"tokens":           trackedTokenStoragePrefix,
+	"revocation_queue": revocationQueueStoragePrefix,
+	"issuance_log":     issuanceLogStoragePrefix,
+	"changelog":        changelogStoragePrefix,
+	"token_requests":   tokenRequestsStoragePrefix,
+}
+
+// storagePrefixStats returns the number of entries under prefix, including those of nested prefixes such as the shards
+// of tracked tokens, and the total size of their values
+func storagePrefixStats(ctx context.Context, storage logical.Storage, prefix string) (int, int, error) {
+	keys, err := storage.List(ctx, prefix)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	count, size := 0, 0
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			nestedCount, nestedSize, err := storagePrefixStats(ctx, storage, prefix+key)
+			if err != nil {
+				return 0, 0, err
+			}
+			count += nestedCount
+			size += nestedSize
+			continue
+		}
+
+		entry, err := storage.Get(ctx, prefix+key)
+		if err != nil {
+			return 0, 0, err
+		}
+		// Entries deleted since the listing have no value
+		if entry == nil {
+			continue
+		}
+		count++
+		size += len(entry.Value)
+	}
+
+	return count, size, nil
+}
+
+func (b *backend) pathStatsRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	// No locks are held: the scan reads every entry, and holding the issuance log's lock through it would stall
+	// issuance. Entries written or deleted during the scan may be missed, so counts are approximate under load.
+
+	data := map[string]interface{}{}
+	totalCount, totalSize := 0, 0
+
+	for name, prefix := range statsPrefixes {
+		count, size, err := storagePrefixStats(ctx, req.Storage, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		data[name] = map[string]interface{}{
+			"count":      count,
+			"size_bytes": size,
+		}
+		totalCount += count
+		totalSize += size
+	}
+
+	data["total_count"] = totalCount
+	data["total_size_bytes"] = totalSize
+
+	if usage := b.deprecationUsageCounts(); len(usage) > 0 {
+		data["deprecated_usage"] = usage
+	}
+
+	if latency := b.latency.snapshot(); len(latency) > 0 {
+		data["artifactory_latency"] = latency
+	}
+
+	resp := &logical.Response{
+		Data: data,
+	}
+
+	oldest, err := b.oldestQueuedRevocation(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if !oldest.IsZero() {
+		age := time.Since(oldest)
+		queueStats := data["revocation_queue"].(map[string]interface{})
+		queueStats["oldest_queued_at"] = oldest
+		queueStats["oldest_age_seconds"] = int64(age.Seconds())
+
+		if age > revocationBacklogWarningAge {
+			resp.AddWarning(fmt.Sprintf("the oldest queued revocation has been pending for %s; its token may still be usable in Artifactory", age.Round(time.Second)))
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,52 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Stored roles must be counted and sized, and totals must cover every kind of entry.
+func TestBackend_PathStats(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	for _, roleName := range []string{"role-a", "role-b"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data:      map[string]interface{}{"scope": "test-scope"},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "stats",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+
+	roles := resp.Data["roles"].(map[string]interface{})
+	assert.Equal(t, 2, roles["count"])
+	assert.Greater(t, roles["size_bytes"], 0)
+
+	tokens := resp.Data["tokens"].(map[string]interface{})
+	assert.Equal(t, 0, tokens["count"])
+
+	assert.Equal(t, 2, resp.Data["total_count"])
+	assert.Equal(t, roles["size_bytes"], resp.Data["total_size_bytes"])
+}
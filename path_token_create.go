@@ -2,9 +2,14 @@ package artifactory
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -24,6 +29,48 @@ func (b *backend) pathTokenCreate() *framework.Path {
 				Type:        framework.TypeDurationSecond,
 				Description: `Override the maximum TTL for this access token. Cannot exceed smallest (system, backend) maximum TTL.`,
 			},
+			"change_ref": {
+				Type:        framework.TypeString,
+				Description: `Change reference (e.g. a ticket number) for this request. Required if the role has 'require_change_ref' set. Recorded in the token description and the lease.`,
+			},
+			"pipeline_id": {
+				Type:        framework.TypeString,
+				Description: `Id of the build pipeline the token is for. Required if the role has 'require_provenance' set. Recorded in the token description, the response and the lease.`,
+			},
+			"commit_sha": {
+				Type:        framework.TypeString,
+				Description: `Commit the pipeline builds. Required if the role has 'require_provenance' set. Recorded in the token description, the response and the lease.`,
+			},
+			"break_glass": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: `Request a short-lived token with the role's 'escalated_scope' instead of its 'scope'. Requires 'justification'.`,
+			},
+			"justification": {
+				Type:        framework.TypeString,
+				Description: `Reason for a 'break_glass' request. Recorded in the token description, the lease, and the emitted event.`,
+			},
+			"app_name": {
+				Type:        framework.TypeString,
+				Description: `Name of the application the token is for. Must be one of the role's 'allowed_app_names'. Available to the username_template as '{{.AppName}}' and recorded in the token description.`,
+			},
+			"path_prefix": {
+				Type:        framework.TypeString,
+				Description: `Repository path prefix to narrow the token's repositories to, e.g. a build's output directory. Must be one of the role's 'allowed_path_prefixes', or a path under one. Recorded in the token description and the lease.`,
+			},
+			"async": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: `Return a request id at once and issue the token in the background. Poll token-requests/<request_id> for the token.`,
+			},
+			"config": {
+				Type:        framework.TypeString,
+				Description: `Name of the admin configuration (config/admin/<name>) whose Artifactory instance issues the token, e.g. a region-local replica. Must be the role's 'config_name' or one of its 'allowed_config_names'. Recorded in the response and the lease.`,
+			},
+			"max_issue_time": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Maximum time issuing the access token may take. If exceeded, a token already created in Artifactory is revoked and the request fails.`,
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
@@ -37,10 +84,39 @@ Create an Artifactory access token using paramters from the specified role.
 An optional 'ttl' parameter will override the role's 'default_ttl' parameter.
 
 An optional 'max_ttl' parameter will override the role's 'max_ttl' parameter.
+
+An optional 'change_ref' parameter records a change reference in the token description. It is mandatory for roles
+with 'require_change_ref' set, and must match the role's 'change_ref_pattern' if one is configured.
+
+Optional 'pipeline_id' and 'commit_sha' parameters record the build a token is for, so artifact pushes can be
+attributed to it. They are mandatory for roles with 'require_provenance' set, and must match the role's
+'pipeline_id_pattern' and 'commit_sha_pattern'.
+
+An optional 'app_name' parameter names the consuming application, so tokens from a role shared by several
+applications can be told apart. It is mandatory for roles with 'allowed_app_names' set, and rejected otherwise.
+
+An optional 'path_prefix' parameter narrows the role's repositories to the paths under a prefix, e.g. to isolate the
+outputs of each build in a shared repository: tokens are issued with 'artifact:<repository>/<path_prefix>/**' scopes
+instead of 'artifact:<repository>'. The prefix must be one of the role's 'allowed_path_prefixes', or a path under one.
+
+An optional 'config' parameter chooses the Artifactory instance that issues the token, among the admin configurations
+of the role's 'allowed_config_names', so the same role can issue tokens from the replica closest to the caller. The
+token is renewed and revoked through the same instance.
+
+An optional 'max_issue_time' parameter bounds how long issuing the token may take. If it is exceeded, the request
+fails, and a token already created in Artifactory is revoked instead of being left behind.
+
+An optional 'async' parameter returns a 'request_id' at once and issues the token in the background, for when
+Artifactory is slow to respond. Read token-requests/<request_id> until it returns the token.
+
+An optional 'break_glass' parameter requests a token with the role's 'escalated_scope', for emergencies. It requires a
+'justification', ignores 'ttl' in favor of the role's 'break_glass_ttl', and emits an "artifactory/break-glass" event.
 `,
 	}
 }
 
+const referenceOnlyWarning = "Artifactory returned only a reference token; access_token and reference_token hold the same opaque token."
+
 type systemVersionResponse struct {
 	Version  string `json:"version"`
 	Revision string `json:"revision"`
@@ -54,6 +130,8 @@ type createTokenResponse struct {
 	Scope          string `json:"scope"`
 	TokenType      string `json:"token_type"`
 	ReferenceToken string `json:"reference_token"`
+	ReferenceOnly  bool   `json:"-"`
+	Revocable      bool   `json:"-"`
 }
 
 func (b *backend) pathTokenCreatePerform(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -62,6 +140,18 @@ func (b *backend) pathTokenCreatePerform(ctx context.Context, req *logical.Reque
 	defer b.configMutex.RUnlock()
 	defer b.rolesMutex.RUnlock()
 
+	issueCtx := ctx
+	maxIssueTime := time.Duration(data.Get("max_issue_time").(int)) * time.Second
+	if maxIssueTime > 0 {
+		var cancel context.CancelFunc
+		issueCtx, cancel = context.WithTimeout(ctx, maxIssueTime)
+		defer cancel()
+	}
+
+	if resp, err := b.checkMaintenance(ctx, req.Storage); resp != nil || err != nil {
+		return resp, err
+	}
+
 	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
 	if err != nil {
 		return nil, err
@@ -73,6 +163,16 @@ func (b *backend) pathTokenCreatePerform(ctx context.Context, req *logical.Reque
 
 	go b.sendUsage(*config, "pathTokenCreatePerform")
 
+	if err := b.refreshStaleConfiguration(*config); err != nil {
+		return nil, err
+	}
+
+	if config.CheckHealthBeforeIssuance {
+		if err := b.checkHealth(*config); err != nil {
+			return logical.ErrorResponse("Artifactory unhealthy: %s", err), nil
+		}
+	}
+
 	// Read in the requested role
 	roleName := data.Get("role").(string)
 
@@ -85,17 +185,146 @@ func (b *backend) pathTokenCreatePerform(ctx context.Context, req *logical.Reque
 		return logical.ErrorResponse("no such role"), nil
 	}
 
+	if err := role.checkRetired(roleName, time.Now()); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if err := b.checkEntityMetadata(req, *role); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if err := checkAuthAge(req, *role); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// The request may choose another of the admin configurations the role allows
+	var configName string
+	if value, ok := data.GetOk("config"); ok && value.(string) != role.ConfigName {
+		configName = value.(string)
+		if err := role.checkConfigName(roleName, configName); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		role.ConfigName = configName
+	}
+
+	// Roles using a named admin configuration get their tokens from its Artifactory instance
+	roleConfig, err := b.roleConfiguration(ctx, req.Storage, *config, *role)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	config = &roleConfig
+
+	var changeRef string
+	if value, ok := data.GetOk("change_ref"); ok {
+		changeRef = value.(string)
+	}
+
+	if role.RequireChangeRef && changeRef == "" {
+		return logical.ErrorResponse("change_ref is required for role '%s'", roleName), nil
+	}
+
+	if changeRef != "" && role.ChangeRefPattern != "" {
+		matched, err := regexp.MatchString(role.ChangeRefPattern, changeRef)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			return logical.ErrorResponse("change_ref '%s' does not match pattern '%s'", changeRef, role.ChangeRefPattern), nil
+		}
+	}
+
+	pipelineID := data.Get("pipeline_id").(string)
+	commitSHA := data.Get("commit_sha").(string)
+
+	if err := role.checkProvenance(pipelineID, commitSHA); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	var appName string
+	if value, ok := data.GetOk("app_name"); ok {
+		appName = value.(string)
+	}
+
+	if len(role.AllowedAppNames) > 0 && appName == "" {
+		return logical.ErrorResponse("app_name is required for role '%s'", roleName), nil
+	}
+
+	if appName != "" && !strutil.StrListContains(role.AllowedAppNames, appName) {
+		return logical.ErrorResponse("app_name '%s' is not allowed for role '%s'", appName, roleName), nil
+	}
+
+	breakGlass := data.Get("break_glass").(bool)
+	justification := data.Get("justification").(string)
+
+	var pathPrefix string
+	if value, ok := data.GetOk("path_prefix"); ok {
+		if len(role.AllowedPathPrefixes) == 0 {
+			return logical.ErrorResponse("role '%s' has no allowed_path_prefixes", roleName), nil
+		}
+		pathPrefix, err = normalizePathPrefix(value.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		if !pathPrefixAllowed(role.AllowedPathPrefixes, pathPrefix) {
+			return logical.ErrorResponse("path_prefix '%s' is not under any of the allowed_path_prefixes of role '%s'", pathPrefix, roleName), nil
+		}
+		role.pathPrefix = pathPrefix
+	}
+
+	role.Scope = b.roleScope(*role)
+
+	if breakGlass {
+		if role.EscalatedScope == "" {
+			return logical.ErrorResponse("role '%s' has no escalated_scope", roleName), nil
+		}
+		if justification == "" {
+			return logical.ErrorResponse("justification is required for break_glass"), nil
+		}
+		role.Scope = role.EscalatedScope
+	}
+
+	var descriptions []string
+	if breakGlass {
+		descriptions = append(descriptions, "break_glass: "+justification)
+	}
+	if appName != "" {
+		descriptions = append(descriptions, "app_name: "+appName)
+	}
+	if pathPrefix != "" {
+		descriptions = append(descriptions, "path_prefix: "+pathPrefix)
+	}
+	if changeRef != "" {
+		descriptions = append(descriptions, "change_ref: "+changeRef)
+	}
+	if pipelineID != "" {
+		descriptions = append(descriptions, "pipeline_id: "+pipelineID)
+	}
+	if commitSHA != "" {
+		descriptions = append(descriptions, "commit_sha: "+commitSHA)
+	}
+	if len(descriptions) > 0 {
+		role.Description = strings.Join(descriptions, ", ")
+	}
+
+	// Group roles issue their tokens to the group's service identity
+	if role.group() {
+		role.Username = role.groupTokenUsername()
+	}
+
 	// Define username for token by template if a static one is not set
 	if len(role.Username) == 0 {
-		role.Username, err = b.usernameProducer.Generate(UsernameMetadata{
+		role.Username, err = b.currentSetup().usernameProducer.Generate(UsernameMetadata{
 			RoleName:    roleName,
 			DisplayName: req.DisplayName,
+			AppName:     appName,
 		})
 		if err != nil {
 			return logical.ErrorResponse("error generating username from template"), err
 		}
 	}
 
+	role.clampTTLs(*config)
+
 	var ttl time.Duration
 	if value, ok := data.GetOk("ttl"); ok {
 		ttl = time.Second * time.Duration(value.(int))
@@ -115,11 +344,107 @@ func (b *backend) pathTokenCreatePerform(ctx context.Context, req *logical.Reque
 		ttl = role.MaxTTL
 	}
 
-	resp, err := b.CreateToken(*config, *role)
+	// Escalated tokens get the role's short break glass ttl, and can't be renewed past it
+	if breakGlass {
+		ttl = role.breakGlassTTL()
+		if role.MaxTTL > 0 && ttl > role.MaxTTL {
+			ttl = role.MaxTTL
+		}
+		role.MaxTTL = ttl
+	}
+
+	if role.NoLease {
+		if ttl == 0 {
+			ttl = b.System().DefaultLeaseTTL()
+		}
+		role.MaxTTL = ttl
+	}
+
+	opts := tokenOptions{
+		TTL:           ttl,
+		ChangeRef:     changeRef,
+		PipelineID:    pipelineID,
+		CommitSHA:     commitSHA,
+		AppName:       appName,
+		PathPrefix:    pathPrefix,
+		BreakGlass:    breakGlass,
+		Justification: justification,
+		ConfigName:    configName,
+	}
+
+	if role.MaxTokensPerEntity > 0 && req.EntityID != "" {
+		b.entityLimitMutex.Lock()
+		defer b.entityLimitMutex.Unlock()
+
+		if err := b.enforceEntityLimit(ctx, req.Storage, *config, roleName, *role, req.EntityID); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	// Group roles hand out their current token until it is due for rotation
+	if role.group() {
+		b.groupTokenMutex.Lock()
+		defer b.groupTokenMutex.Unlock()
+
+		current, err := b.currentGroupToken(ctx, req.Storage, roleName, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if current != nil && !current.dueForRotation(time.Now()) {
+			response, err := b.groupTokenResponse(ctx, req, roleName, *role, current, ttl)
+			if response != nil {
+				addTLSBypassWarning(response, *config)
+			}
+			return response, err
+		}
+	}
+
+	if data.Get("async").(bool) {
+		response, err := b.startTokenRequest(ctx, req, *config, roleName, *role, opts, maxIssueTime)
+		addTLSBypassWarning(response, *config)
+		return response, err
+	}
+
+	if issueCtx.Err() != nil {
+		return nil, fmt.Errorf("max_issue_time of %s exceeded before the token was created: %w", maxIssueTime, context.DeadlineExceeded)
+	}
+
+	resp, err := b.CreateToken(issueCtx, *config, *role)
 	if err != nil {
+		if issueCtx.Err() != nil {
+			return nil, fmt.Errorf("max_issue_time of %s exceeded while creating the token: %w", maxIssueTime, err)
+		}
 		return nil, err
 	}
 
+	// The token was created too late for the caller to use, so don't leave it behind in Artifactory
+	if issueCtx.Err() != nil {
+		b.discardToken(ctx, req.Storage, *config, roleName, *role, resp)
+		return nil, fmt.Errorf("max_issue_time of %s exceeded, token %s was revoked: %w", maxIssueTime, resp.TokenId, context.DeadlineExceeded)
+	}
+
+	response := b.tokenResponse(ctx, req, roleName, *role, resp, opts)
+	addTLSBypassWarning(response, *config)
+
+	return response, nil
+}
+
+// tokenOptions are the parameters of a token/<role> request that shape the response and lease, beyond the role
+type tokenOptions struct {
+	TTL           time.Duration `json:"ttl"`
+	ChangeRef     string        `json:"change_ref,omitempty"`
+	PipelineID    string        `json:"pipeline_id,omitempty"`
+	CommitSHA     string        `json:"commit_sha,omitempty"`
+	AppName       string        `json:"app_name,omitempty"`
+	PathPrefix    string        `json:"path_prefix,omitempty"`
+	BreakGlass    bool          `json:"break_glass,omitempty"`
+	Justification string        `json:"justification,omitempty"`
+	ConfigName    string        `json:"config_name,omitempty"`
+}
+
+// tokenResponse builds the response, and lease, handing out a token created for a token/<role> request, and tracks
+// and records the issuance
+func (b *backend) tokenResponse(ctx context.Context, req *logical.Request, roleName string, role artifactoryRole, resp *createTokenResponse, opts tokenOptions) *logical.Response {
 	response := b.Secret(SecretArtifactoryAccessTokenType).Response(map[string]interface{}{
 		"access_token":    resp.AccessToken,
 		"refresh_token":   resp.RefreshToken,
@@ -128,6 +453,7 @@ func (b *backend) pathTokenCreatePerform(ctx context.Context, req *logical.Reque
 		"token_id":        resp.TokenId,
 		"username":        role.Username,
 		"reference_token": resp.ReferenceToken,
+		"revocable":       resp.Revocable,
 	}, map[string]interface{}{
 		"role":            roleName,
 		"access_token":    resp.AccessToken,
@@ -135,10 +461,232 @@ func (b *backend) pathTokenCreatePerform(ctx context.Context, req *logical.Reque
 		"token_id":        resp.TokenId,
 		"username":        role.Username,
 		"reference_token": resp.ReferenceToken,
+		"revocable":       resp.Revocable,
 	})
 
-	response.Secret.TTL = ttl
+	if resp.ReferenceOnly {
+		response.AddWarning(referenceOnlyWarning)
+	}
+
+	if role.identity() || role.group() {
+		response.Data["token_type"] = role.TokenType
+	}
+
+	if opts.ChangeRef != "" {
+		response.Data["change_ref"] = opts.ChangeRef
+		response.Secret.InternalData["change_ref"] = opts.ChangeRef
+	}
+
+	if opts.PipelineID != "" {
+		response.Data["pipeline_id"] = opts.PipelineID
+		response.Secret.InternalData["pipeline_id"] = opts.PipelineID
+	}
+
+	if opts.CommitSHA != "" {
+		response.Data["commit_sha"] = opts.CommitSHA
+		response.Secret.InternalData["commit_sha"] = opts.CommitSHA
+	}
+
+	if opts.AppName != "" {
+		response.Data["app_name"] = opts.AppName
+		response.Secret.InternalData["app_name"] = opts.AppName
+	}
+
+	if opts.PathPrefix != "" {
+		response.Data["path_prefix"] = opts.PathPrefix
+		response.Secret.InternalData["path_prefix"] = opts.PathPrefix
+	}
+
+	if opts.ConfigName != "" {
+		response.Data["config_name"] = opts.ConfigName
+	}
+
+	// The token is renewed and revoked through the admin configuration it was issued from, even if the role changes
+	response.Secret.InternalData["config_name"] = role.ConfigName
+
+	if role.group() {
+		response.Secret.InternalData["group_token"] = true
+	}
+
+	if opts.BreakGlass {
+		response.Data["break_glass"] = true
+		response.Secret.InternalData["break_glass"] = true
+		response.Secret.InternalData["justification"] = opts.Justification
+		response.AddWarning(fmt.Sprintf("Issued with escalated scope under break glass; the token expires in %s.", opts.TTL))
+		b.sendBreakGlassEvent(ctx, req, roleName, role, resp.TokenId, opts.Justification)
+	}
+
+	response.Secret.TTL = opts.TTL
 	response.Secret.MaxTTL = role.MaxTTL
 
-	return response, nil
+	if role.RefreshAfter > 0 {
+		// A zero ttl means Vault applies the mount's default lease ttl
+		leaseTTL := opts.TTL
+		if leaseTTL == 0 {
+			leaseTTL = b.System().DefaultLeaseTTL()
+		}
+		setCacheHints(response, leaseTTL, role.RefreshAfter)
+	}
+
+	// Tokens of roles without leases expire on their own in Artifactory, so Vault neither tracks nor revokes them
+	if role.NoLease {
+		response.Secret = nil
+		response.Data["ttl"] = int64(opts.TTL.Seconds())
+		b.appendUntrackedChangelog(ctx, req, response, roleName, opts.TTL)
+	} else {
+		b.trackSecret(ctx, req, response, roleName)
+	}
+
+	// The new token of a group role is handed out until it is due for rotation, and replaces the group's previous ones
+	// when the periodic function rotates them. The caller holds groupTokenMutex.
+	if role.group() && response.Secret != nil {
+		if err := b.storeGroupToken(ctx, req.Storage, roleName, resp, response); err != nil {
+			b.Logger().Warn("could not store the current group token", "role", roleName, "err", err)
+			response.AddWarning(fmt.Sprintf("Could not store the token as the current one of group role '%s', so the next request issues another: %s", roleName, err))
+		}
+	}
+
+	b.recordIssuance(ctx, req, roleName, role, resp.TokenId)
+
+	applyResponseKeyMapping(response.Data, role.ResponseKeyMapping)
+
+	return response
+}
+
+// defaultCommitSHAPattern matches abbreviated and full SHA-1 and SHA-256 git commit ids
+const defaultCommitSHAPattern = `^[0-9a-fA-F]{7,64}$`
+
+// checkProvenance validates the build provenance parameters of a token request against the role
+func (role artifactoryRole) checkProvenance(pipelineID, commitSHA string) error {
+	if role.RequireProvenance && (pipelineID == "" || commitSHA == "") {
+		return fmt.Errorf("pipeline_id and commit_sha are required for this role")
+	}
+
+	if pipelineID != "" && role.PipelineIDPattern != "" {
+		matched, err := regexp.MatchString(role.PipelineIDPattern, pipelineID)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("pipeline_id '%s' does not match pattern '%s'", pipelineID, role.PipelineIDPattern)
+		}
+	}
+
+	if commitSHA != "" {
+		pattern := role.CommitSHAPattern
+		if pattern == "" {
+			pattern = defaultCommitSHAPattern
+		}
+		matched, err := regexp.MatchString(pattern, commitSHA)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("commit_sha '%s' does not match pattern '%s'", commitSHA, pattern)
+		}
+	}
+
+	return nil
+}
+
+// discardToken revokes a token that was created but is not handed out. If revoking fails, the revocation is queued
+// for retry like that of a lease.
+func (b *backend) discardToken(ctx context.Context, storage logical.Storage, config adminConfiguration, roleName string, role artifactoryRole, token *createTokenResponse) {
+	config.roleHeaders = role.RequestHeaders
+	secret := logical.Secret{
+		InternalData: map[string]interface{}{
+			"role":         roleName,
+			"access_token": token.AccessToken,
+			"token_id":     token.TokenId,
+			"username":     role.Username,
+		},
+	}
+
+	revokeCtx, cancel := revokeContext(ctx)
+	defer cancel()
+
+	if err := b.RevokeToken(revokeCtx, config, secret); err != nil {
+		b.Logger().Warn("could not revoke discarded token, queued for retry", "tokenId", token.TokenId, "err", err)
+		if err := b.queueRevocation(context.WithoutCancel(ctx), storage, secret, err); err != nil {
+			b.Logger().Error("could not queue revocation of discarded token", "tokenId", token.TokenId, "err", err)
+		}
+	}
+}
+
+// sendBreakGlassEvent logs a break glass issuance and emits it as an event. Event delivery is best effort: the token
+// has already been issued, and the log line and audit log still record it.
+func (b *backend) sendBreakGlassEvent(ctx context.Context, req *logical.Request, roleName string, role artifactoryRole, tokenID string, justification string) {
+	b.Logger().Warn("break glass token issued", "role", roleName, "entityId", req.EntityID, "tokenId", tokenID, "scope", role.Scope, "justification", justification)
+
+	err := logical.SendEvent(ctx, b, "artifactory/break-glass",
+		logical.EventMetadataOperation, "read",
+		logical.EventMetadataDataPath, req.Path,
+		"role", roleName,
+		"entity_id", req.EntityID,
+		"token_id", tokenID,
+		"scope", role.Scope,
+		"justification", justification)
+	if err != nil && !errors.Is(err, framework.ErrNoEvents) {
+		b.Logger().Warn("could not send break glass event", "err", err)
+	}
+}
+
+// setCacheHints tells Vault Agent, consul-template, and other caching clients when to fetch a new token: the lease
+// ttl and refresh_after in the response data, and refresh_after as a Cache-Control max-age header
+func setCacheHints(response *logical.Response, ttl time.Duration, refreshAfter time.Duration) {
+	if refreshAfter > ttl {
+		refreshAfter = ttl
+	}
+
+	response.Data["ttl"] = int64(ttl.Seconds())
+	response.Data["refresh_after"] = int64(refreshAfter.Seconds())
+	response.Headers = map[string][]string{
+		"Cache-Control": {fmt.Sprintf("max-age=%d", int64(refreshAfter.Seconds()))},
+	}
+}
+
+// checkEntityMetadata verifies the requesting entity's metadata satisfies the role's required_entity_metadata
+func (b *backend) checkEntityMetadata(req *logical.Request, role artifactoryRole) error {
+	if len(role.RequiredEntityMetadata) == 0 {
+		return nil
+	}
+
+	if req.EntityID == "" {
+		return fmt.Errorf("role requires entity metadata, but the request has no associated entity")
+	}
+
+	entity, err := b.System().EntityInfo(req.EntityID)
+	if err != nil {
+		return fmt.Errorf("could not look up entity: %w", err)
+	}
+	if entity == nil {
+		return fmt.Errorf("role requires entity metadata, but entity %s was not found", req.EntityID)
+	}
+
+	for key, expected := range role.RequiredEntityMetadata {
+		actual, ok := entity.Metadata[key]
+		if !ok || !strutil.GlobbedStringsMatch(expected, actual) {
+			return fmt.Errorf("entity metadata '%s' does not match the role's required_entity_metadata", key)
+		}
+	}
+
+	return nil
+}
+
+// checkAuthAge verifies the requesting Vault token was created within the role's max_auth_age
+func checkAuthAge(req *logical.Request, role artifactoryRole) error {
+	if role.MaxAuthAge == 0 {
+		return nil
+	}
+
+	te := req.TokenEntry()
+	if te == nil || te.CreationTime == 0 {
+		return fmt.Errorf("role requires recent authentication, but the age of the request's token is unknown")
+	}
+
+	if age := time.Since(time.Unix(te.CreationTime, 0)); age > role.MaxAuthAge {
+		return fmt.Errorf("authentication is %s old, more than the role's max_auth_age of %s; log in again", age.Truncate(time.Second), role.MaxAuthAge)
+	}
+
+	return nil
 }
@@ -1,7 +1,15 @@
 package artifactory
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestAcceptanceBackend_PathTokenCreate(t *testing.T) {
@@ -20,3 +28,945 @@ func TestAcceptanceBackend_PathTokenCreate(t *testing.T) {
 	t.Run("delete role", accTestEnv.DeletePathRole)
 	t.Run("cleanup backend", accTestEnv.DeletePathConfig)
 }
+
+// A role with require_change_ref must reject requests without a matching change_ref,
+// and record the change_ref in the token description when one is supplied.
+func TestBackend_PathTokenCreateRequireChangeRef(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":           "test-username",
+			"scope":              "test-scope",
+			"require_change_ref": true,
+			"change_ref_pattern": "^CR-[0-9]+$",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	// Missing change_ref
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "change_ref is required")
+
+	// Non-matching change_ref
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"change_ref": "whenever"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "does not match pattern")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"change_ref": "CR-1234"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "CR-1234", resp.Data["change_ref"])
+	assert.Equal(t, "CR-1234", resp.Secret.InternalData["change_ref"])
+	assert.Equal(t, "change_ref: CR-1234", createRequest.Description)
+}
+
+// A role with required_entity_metadata must only issue tokens to entities whose metadata matches.
+func TestBackend_PathTokenCreateRequiredEntityMetadata(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":                 "test-username",
+			"scope":                    "test-scope",
+			"required_entity_metadata": []string{"team=payments", "env=prod*"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	// No entity
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	systemView := config.System.(*logical.StaticSystemView)
+
+	// Mismatched metadata
+	systemView.EntityVal = &logical.Entity{
+		ID:       "test-entity",
+		Metadata: map[string]string{"team": "checkout", "env": "production"},
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		EntityID:  "test-entity",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "team")
+
+	// Matching metadata
+	systemView.EntityVal.Metadata["team"] = "payments"
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		EntityID:  "test-entity",
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.EqualValues(t, "eyXsdgbtybbeeyh...", resp.Data["access_token"])
+}
+
+// A role with max_auth_age must only issue tokens to requests whose Vault token was created recently.
+func TestBackend_PathTokenCreateMaxAuthAge(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":     "test-username",
+			"scope":        "test-scope",
+			"max_auth_age": "15m",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	for _, tc := range []struct {
+		name       string
+		tokenEntry *logical.TokenEntry
+		allowed    bool
+	}{
+		{"unknown", nil, false},
+		{"stale", &logical.TokenEntry{CreationTime: time.Now().Add(-time.Hour).Unix()}, false},
+		{"fresh", &logical.TokenEntry{CreationTime: time.Now().Add(-time.Minute).Unix()}, true},
+	} {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/test-role",
+			Storage:   config.StorageView,
+		}
+		req.SetTokenEntry(tc.tokenEntry)
+
+		resp, err = b.HandleRequest(context.Background(), req)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, !tc.allowed, resp.IsError(), tc.name)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 900, resp.Data["max_auth_age"])
+}
+
+// A role's response_key_mapping must rename keys in the token response, and reject colliding mappings.
+func TestBackend_PathTokenCreateResponseKeyMapping(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":             "test-username",
+			"scope":                "test-scope",
+			"response_key_mapping": "access_token=username",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "overwrite")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":             "test-username",
+			"scope":                "test-scope",
+			"response_key_mapping": []string{"path_prefix=prefix", "config_name=instance", "token_type=kind"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":             "test-username",
+			"scope":                "test-scope",
+			"response_key_mapping": []string{"access_token=password", "username=login"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.EqualValues(t, "eyXsdgbtybbeeyh...", resp.Data["password"])
+	assert.EqualValues(t, "test-username", resp.Data["login"])
+	assert.NotContains(t, resp.Data, "access_token")
+	assert.NotContains(t, resp.Data, "username")
+	assert.EqualValues(t, "eyXsdgbtybbeeyh...", resp.Secret.InternalData["access_token"])
+}
+
+// With check_health_before_issuance set, an unhealthy Artifactory must fail issuance before the token API is
+// called, and the probe result must be cached.
+func TestBackend_PathTokenCreateHealthCheck(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/artifactory/api/system/ping",
+		httpmock.NewStringResponder(503, "Service Unavailable"))
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":                 "test-access-token",
+		"url":                          "http://myserver.com:80/artifactory",
+		"check_health_before_issuance": true,
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	for i := 0; i < 2; i++ {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/test-role",
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), "Artifactory unhealthy")
+	}
+
+	callCounts := httpmock.GetCallCountInfo()
+	assert.Equal(t, 1, callCounts["GET http://myserver.com:80/artifactory/api/system/ping"])
+	assert.Equal(t, 0, callCounts["POST http://myserver.com:80/artifactory/api/security/token"])
+}
+
+// A role with allowed_app_names must require an allowed app_name, and make it available to the username template
+// and token description.
+func TestBackend_PathTokenCreateAppName(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":      "test-access-token",
+		"url":               "http://myserver.com:80/artifactory",
+		"username_template": "v-{{.RoleName}}-{{.AppName}}",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":             "test-scope",
+			"allowed_app_names": "billing,search",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	// Missing app_name
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "app_name is required")
+
+	// app_name not in the allow-list
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"app_name": "payroll"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "is not allowed")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"app_name": "search"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "search", resp.Data["app_name"])
+	assert.Equal(t, "v-test-role-search", resp.Data["username"])
+	assert.Equal(t, "app_name: search", createRequest.Description)
+}
+
+// A role with refresh_after must add ttl and refresh_after to the response, and a matching Cache-Control header.
+func TestBackend_PathTokenCreateCacheHints(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":      "test-username",
+			"scope":         "test-scope",
+			"default_ttl":   "1h",
+			"max_ttl":       "2h",
+			"refresh_after": "40m",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.EqualValues(t, 3600, resp.Data["ttl"])
+	assert.EqualValues(t, 2400, resp.Data["refresh_after"])
+	assert.Equal(t, []string{"max-age=2400"}, resp.Headers["Cache-Control"])
+
+	// refresh_after is capped at the token's ttl
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"ttl": "30m"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.EqualValues(t, 1800, resp.Data["ttl"])
+	assert.EqualValues(t, 1800, resp.Data["refresh_after"])
+}
+
+// A break glass request must require a justification, and issue a short-lived token with the escalated scope.
+func TestBackend_PathTokenCreateBreakGlass(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":        "test-username",
+			"scope":           "applied-permissions/groups:readers",
+			"escalated_scope": "applied-permissions/groups:prod-admins",
+			"break_glass_ttl": "10m",
+			"default_ttl":     "1h",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	// Missing justification
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"break_glass": true},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "justification is required")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"break_glass":   true,
+			"justification": "INC-42 checkout outage",
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Len(t, resp.Warnings, 1)
+	assert.Equal(t, true, resp.Data["break_glass"])
+	assert.Equal(t, "applied-permissions/groups:prod-admins", createRequest.Scope)
+	assert.Equal(t, "break_glass: INC-42 checkout outage", createRequest.Description)
+	assert.Equal(t, 10*time.Minute, resp.Secret.TTL)
+	assert.Equal(t, 10*time.Minute, resp.Secret.MaxTTL)
+}
+
+func TestBackend_PathTokenCreateProvenance(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":               "test-scope",
+			"require_provenance":  true,
+			"pipeline_id_pattern": "^[0-9]+$",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	for _, tc := range []struct {
+		data map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"pipeline_id": "1234"}, "pipeline_id and commit_sha are required"},
+		{map[string]interface{}{"pipeline_id": "build-1234", "commit_sha": "0f3a9c2"}, "pipeline_id 'build-1234' does not match"},
+		{map[string]interface{}{"pipeline_id": "1234", "commit_sha": "main"}, "commit_sha 'main' does not match"},
+	} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/test-role",
+			Storage:   config.StorageView,
+			Data:      tc.data,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), tc.err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"pipeline_id": "1234", "commit_sha": "0f3a9c2"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "1234", resp.Data["pipeline_id"])
+	assert.Equal(t, "0f3a9c2", resp.Data["commit_sha"])
+	assert.Equal(t, "0f3a9c2", resp.Secret.InternalData["commit_sha"])
+	assert.Equal(t, "pipeline_id: 1234, commit_sha: 0f3a9c2", createRequest.Description)
+}
+
+func TestBackend_PathTokenCreateRequestHeaders(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	requireTenant := func(body string) httpmock.Responder {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("X-Tenant-ID") != "acme" {
+				return httpmock.NewStringResponse(404, ""), nil
+			}
+			return httpmock.NewStringResponse(200, body), nil
+		}
+	}
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		requireTenant(canonicalAccessToken))
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		requireTenant(""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":        "test-username",
+			"scope":           "test-scope",
+			"request_headers": "authorization=Bearer other",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "can't be overridden")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":        "test-username",
+			"scope":           "test-scope",
+			"request_headers": "X-Tenant-ID=acme",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestBackend_PathTokenCreateWithoutLease(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":       "test-username",
+			"scope":          "test-scope",
+			"generate_lease": false,
+			"default_ttl":    "30m",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, false, resp.Data["generate_lease"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Nil(t, resp.Secret)
+	assert.NotEmpty(t, resp.Data["access_token"])
+	assert.EqualValues(t, 1800, resp.Data["ttl"])
+	assert.EqualValues(t, 1800, createRequest.ExpiresIn)
+
+	tracked, err := config.StorageView.List(context.Background(), trackedTokenStoragePrefix)
+	assert.NoError(t, err)
+	assert.Empty(t, tracked)
+}
+
+// A token request must fail once max_issue_time is exceeded, and tokens discarded after creation must be revoked.
+func TestBackend_PathTokenCreateMaxIssueTime(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			time.Sleep(1500 * time.Millisecond)
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(500, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"max_issue_time": "1s"},
+	})
+	assert.ErrorContains(t, err, "max_issue_time of 1s exceeded")
+
+	// A discarded token whose revocation fails is queued for retry
+	role, err := b.Role(context.Background(), config.StorageView, "test-role")
+	assert.NoError(t, err)
+
+	b.discardToken(context.Background(), config.StorageView, adminConfiguration{
+		AccessToken:    "test-access-token",
+		ArtifactoryURL: "http://myserver.com:80/artifactory",
+	}, "test-role", *role, &createTokenResponse{AccessToken: "discarded-token", TokenId: "discarded-id"})
+
+	queued, err := config.StorageView.List(context.Background(), revocationQueueStoragePrefix)
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+}
+
+// A path_prefix under one of the role's allowed_path_prefixes must narrow the repositories of the token to it.
+func TestBackend_PathTokenCreatePathPrefix(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.55.6", "revision" : "75506900"}`)
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/access/api/v1/tokens",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	// Prefixes can't narrow a role's scope or groups
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":                 "test-scope",
+			"allowed_path_prefixes": "builds",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"repositories":          "generic-local",
+			"permissions":           "read,deploy",
+			"allowed_path_prefixes": "builds/../secrets",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "invalid allowed_path_prefixes")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"repositories":          "generic-local",
+			"permissions":           "read,deploy",
+			"allowed_path_prefixes": "/builds/",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	for _, prefix := range []string{"buildsx/1", "other", "builds/*"} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/test-role",
+			Storage:   config.StorageView,
+			Data:      map[string]interface{}{"path_prefix": prefix},
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError(), prefix)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"path_prefix": "builds/1234"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "builds/1234", resp.Data["path_prefix"])
+	assert.Equal(t, "artifact:generic-local/builds/1234/**:r,w", createRequest.Scope)
+	assert.Contains(t, createRequest.Description, "path_prefix: builds/1234")
+
+	// Without a path_prefix the role's repositories are granted whole
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "artifact:generic-local:r,w", createRequest.Scope)
+}
+
+// A config written by another node must be picked up at issuance, even though this node didn't set it up.
+func TestBackend_PathTokenCreateStaleConfig(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"scope": "test-scope"},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	// Another node writes a username template, and this node misses the invalidation
+	adminConfig, err := b.fetchAdminConfiguration(context.Background(), config.StorageView)
+	assert.NoError(t, err)
+	adminConfig.UsernameTemplate = "v-other-node-{{.RoleName}}"
+	adminConfig.Generation = nextConfigGeneration(adminConfig.Generation)
+	entry, err := logical.StorageEntryJSON("config/admin", adminConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, config.StorageView.Put(context.Background(), entry))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, "v-other-node-test-role", createRequest.Username)
+
+	// An invalidation marks the config stale even if the generation matches
+	b.invalidate(context.Background(), "config/admin")
+	assert.True(t, b.configStale)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.False(t, b.configStale)
+}
@@ -0,0 +1,324 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// multiRoleName is the path segment of token/multi, and so can't be used as a role name
+const multiRoleName = "multi"
+
+func (b *backend) pathTokenMulti() *framework.Path {
+	return &framework.Path{
+		Pattern: "token/" + multiRoleName,
+		Fields: map[string]*framework.FieldSchema{
+			"roles": {
+				Type:        framework.TypeCommaStringSlice,
+				Required:    true,
+				Description: `Names of the roles whose scopes the access token is issued with.`,
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Override the default TTL when issuing this access token. Cannot exceed the smallest maximum TTL of the roles.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathTokenMultiPerform,
+			},
+		},
+		HelpSynopsis: `Create one Artifactory access token with the scopes of several roles.`,
+		HelpDescription: `
+Create an Artifactory access token whose scope is the union of the scopes of the given 'roles', e.g. for builds that
+read from one set of repositories and deploy to another, without a role for every combination.
+
+The roles must agree on the settings that apply to the whole token: username, grant_type, audience, refreshable,
+include_reference_token, project_key, generate_lease, request_headers and config_name. Roles that need request parameters
+('require_change_ref', 'require_provenance' or 'allowed_app_names') can't be combined.
+
+Each role must list, in 'multi_allowed_groups', a Vault identity group the requesting entity is a member of; roles
+without it can't be combined. Policies apply to the token/multi path rather than the roles, so this keeps callers from
+getting the scopes of roles they couldn't read at token/<role>.
+
+The token gets the smallest default_ttl and max_ttl of the roles, counts against the max_tokens_per_entity of each of
+them, and is listed with the secrets of each.
+`,
+	}
+}
+
+func (b *backend) pathTokenMultiPerform(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+	defer b.rolesMutex.RUnlock()
+
+	if resp, err := b.checkMaintenance(ctx, req.Storage); resp != nil || err != nil {
+		return resp, err
+	}
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	go b.sendUsage(*config, "pathTokenMultiPerform")
+
+	if err := b.refreshStaleConfiguration(*config); err != nil {
+		return nil, err
+	}
+
+	if config.CheckHealthBeforeIssuance {
+		if err := b.checkHealth(*config); err != nil {
+			return logical.ErrorResponse("Artifactory unhealthy: %s", err), nil
+		}
+	}
+
+	roleNames := strutil.RemoveDuplicatesStable(data.Get("roles").([]string), false)
+	if len(roleNames) == 0 {
+		return logical.ErrorResponse("missing roles"), nil
+	}
+
+	roles := make([]artifactoryRole, 0, len(roleNames))
+	for _, roleName := range roleNames {
+		role, err := b.Role(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+
+		if role == nil {
+			return logical.ErrorResponse("no such role '%s'", roleName), nil
+		}
+
+		if err := role.checkRetired(roleName, time.Now()); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if err := b.checkEntityMetadata(req, *role); err != nil {
+			return logical.ErrorResponse("role '%s': %s", roleName, err), nil
+		}
+
+		if err := checkAuthAge(req, *role); err != nil {
+			return logical.ErrorResponse("role '%s': %s", roleName, err), nil
+		}
+
+		if err := b.checkMultiAllowed(req, roleName, *role); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		roles = append(roles, *role)
+	}
+
+	role, err := b.unionRole(roleNames, roles)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	roleConfig, err := b.roleConfiguration(ctx, req.Storage, *config, role)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	config = &roleConfig
+
+	// Define username for token by template if a static one is not set
+	if len(role.Username) == 0 {
+		role.Username, err = b.currentSetup().usernameProducer.Generate(UsernameMetadata{
+			RoleName:    strings.Join(roleNames, "-"),
+			DisplayName: req.DisplayName,
+		})
+		if err != nil {
+			return logical.ErrorResponse("error generating username from template"), err
+		}
+	}
+
+	role.clampTTLs(*config)
+
+	var ttl time.Duration
+	if value, ok := data.GetOk("ttl"); ok {
+		ttl = time.Second * time.Duration(value.(int))
+	} else {
+		ttl = role.DefaultTTL
+	}
+
+	maxLeaseTTL := b.Backend.System().MaxLeaseTTL()
+
+	if role.MaxTTL == 0 || role.MaxTTL > maxLeaseTTL {
+		role.MaxTTL = maxLeaseTTL
+	}
+
+	if role.MaxTTL > 0 && ttl > role.MaxTTL {
+		ttl = role.MaxTTL
+	}
+
+	if role.NoLease {
+		if ttl == 0 {
+			ttl = b.System().DefaultLeaseTTL()
+		}
+		role.MaxTTL = ttl
+	}
+
+	if req.EntityID != "" && !role.NoLease {
+		b.entityLimitMutex.Lock()
+		defer b.entityLimitMutex.Unlock()
+
+		for i, roleName := range roleNames {
+			if roles[i].MaxTokensPerEntity == 0 {
+				continue
+			}
+			if err := b.enforceEntityLimit(ctx, req.Storage, *config, roleName, roles[i], req.EntityID); err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+		}
+	}
+
+	resp, err := b.CreateToken(ctx, *config, role)
+	if err != nil {
+		return nil, err
+	}
+
+	// Renewal and revocation read the role from the lease; the roles agree on everything those use, and the lease's
+	// max ttl keeps renewals within the smallest max_ttl of the roles.
+	response := b.Secret(SecretArtifactoryAccessTokenType).Response(map[string]interface{}{
+		"access_token":    resp.AccessToken,
+		"refresh_token":   resp.RefreshToken,
+		"roles":           roleNames,
+		"scope":           resp.Scope,
+		"token_id":        resp.TokenId,
+		"username":        role.Username,
+		"reference_token": resp.ReferenceToken,
+		"revocable":       resp.Revocable,
+	}, map[string]interface{}{
+		"role":            roleNames[0],
+		"roles":           roleNames,
+		"access_token":    resp.AccessToken,
+		"refresh_token":   resp.RefreshToken,
+		"token_id":        resp.TokenId,
+		"username":        role.Username,
+		"reference_token": resp.ReferenceToken,
+		"revocable":       resp.Revocable,
+		"config_name":     role.ConfigName,
+	})
+
+	if resp.ReferenceOnly {
+		response.AddWarning(referenceOnlyWarning)
+	}
+
+	response.Secret.TTL = ttl
+	response.Secret.MaxTTL = role.MaxTTL
+
+	if role.NoLease {
+		response.Secret = nil
+		response.Data["ttl"] = int64(ttl.Seconds())
+		b.appendUntrackedChangelog(ctx, req, response, strings.Join(roleNames, ","), ttl)
+	} else {
+		b.trackSecret(ctx, req, response, roleNames[0])
+	}
+
+	for i, roleName := range roleNames {
+		roles[i].Username = role.Username
+		b.recordIssuance(ctx, req, roleName, roles[i], resp.TokenId)
+	}
+
+	addTLSBypassWarning(response, *config)
+
+	return response, nil
+}
+
+// checkMultiAllowed refuses a token/multi request combining a role whose multi_allowed_groups the requesting entity
+// isn't a member of
+func (b *backend) checkMultiAllowed(req *logical.Request, roleName string, role artifactoryRole) error {
+	if len(role.MultiAllowedGroups) == 0 {
+		return fmt.Errorf("role '%s' has no multi_allowed_groups, so it can't be combined with other roles", roleName)
+	}
+
+	if req.EntityID == "" {
+		return fmt.Errorf("role '%s' can only be combined by members of its multi_allowed_groups, but the request has no associated entity", roleName)
+	}
+
+	groups, err := b.System().GroupsForEntity(req.EntityID)
+	if err != nil {
+		return fmt.Errorf("could not look up the groups of the entity: %w", err)
+	}
+
+	for _, group := range groups {
+		if strutil.StrListContains(role.MultiAllowedGroups, group.Name) || strutil.StrListContains(role.MultiAllowedGroups, group.ID) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("entity is not a member of any of the multi_allowed_groups of role '%s'", roleName)
+}
+
+// unionRole combines roles into one whose scope is the union of their scopes. The roles must agree on every setting
+// that applies to the whole token; ttls are the smallest the roles set.
+func (b *backend) unionRole(roleNames []string, roles []artifactoryRole) (artifactoryRole, error) {
+	union := roles[0]
+	var scopes []string
+
+	for i, role := range roles {
+		roleName := roleNames[i]
+
+		if role.RequireChangeRef || role.RequireProvenance || len(role.AllowedAppNames) > 0 {
+			return artifactoryRole{}, fmt.Errorf("role '%s' requires request parameters and can't be combined with other roles", roleName)
+		}
+
+		if role.group() {
+			return artifactoryRole{}, fmt.Errorf("role '%s' issues its group's shared credential and can't be combined with other roles", roleName)
+		}
+
+		mismatch := ""
+		switch {
+		case role.Username != union.Username:
+			mismatch = "username"
+		case role.GrantType != union.GrantType:
+			mismatch = "grant_type"
+		case role.Audience != union.Audience:
+			mismatch = "audience"
+		case role.Refreshable != union.Refreshable:
+			mismatch = "refreshable"
+		case role.IncludeReferenceToken != union.IncludeReferenceToken:
+			mismatch = "include_reference_token"
+		case role.ProjectKey != union.ProjectKey:
+			mismatch = "project_key"
+		case role.NoLease != union.NoLease:
+			mismatch = "generate_lease"
+		case !maps.Equal(role.RequestHeaders, union.RequestHeaders):
+			mismatch = "request_headers"
+		case role.ConfigName != union.ConfigName:
+			mismatch = "config_name"
+		}
+		if mismatch != "" {
+			return artifactoryRole{}, fmt.Errorf("roles '%s' and '%s' have different %s", roleNames[0], roleName, mismatch)
+		}
+
+		scopes = append(scopes, strings.Fields(b.roleScope(role))...)
+
+		if role.DefaultTTL > 0 && (union.DefaultTTL == 0 || role.DefaultTTL < union.DefaultTTL) {
+			union.DefaultTTL = role.DefaultTTL
+		}
+		if role.MaxTTL > 0 && (union.MaxTTL == 0 || role.MaxTTL < union.MaxTTL) {
+			union.MaxTTL = role.MaxTTL
+		}
+	}
+
+	union.Scope = strings.Join(strutil.RemoveDuplicatesStable(scopes, false), " ")
+	union.Groups = nil
+	union.Repositories = nil
+	union.Permissions = nil
+	union.Builds = nil
+	union.BuildPermissions = nil
+	union.ReleaseBundles = nil
+	union.ReleaseBundlePermissions = nil
+
+	return union, nil
+}
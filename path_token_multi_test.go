@@ -0,0 +1,154 @@
+package artifactory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// token/multi must issue one token with the union of the roles' scopes, and reject roles that can't be combined or that
+// the requesting entity isn't allowed to combine.
+func TestBackend_PathTokenMulti(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var createRequest CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	roles := map[string]map[string]interface{}{
+		"readers": {
+			"username":    "build",
+			"scope":       "api:*",
+			"groups":      "readers",
+			"max_ttl":     "1h",
+			"default_ttl": "30m",
+
+			"multi_allowed_groups": "builders",
+		},
+		"deployers": {
+			"username":     "build",
+			"repositories": "libs-release",
+			"permissions":  "deploy",
+			"max_ttl":      "2h",
+
+			"multi_allowed_groups":  "builders",
+			"max_tokens_per_entity": 1,
+		},
+		"other-user": {
+			"username":             "someone-else",
+			"scope":                "api:*",
+			"multi_allowed_groups": "builders",
+		},
+		"with-change-ref": {
+			"username":           "build",
+			"scope":              "api:*",
+			"require_change_ref": true,
+
+			"multi_allowed_groups": "builders",
+		},
+		"not-combinable": {
+			"username": "build",
+			"scope":    "api:*",
+		},
+		"other-group": {
+			"username":             "build",
+			"scope":                "api:*",
+			"multi_allowed_groups": "admins",
+		},
+	}
+	for roleName, roleData := range roles {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data:      roleData,
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	config.System.(*logical.StaticSystemView).GroupsVal = []*logical.Group{{ID: "builders-id", Name: "builders"}}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/multi",
+		Storage:   config.StorageView,
+		EntityID:  "build-entity",
+		Data:      map[string]interface{}{"roles": "readers,deployers"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, []string{"readers", "deployers"}, resp.Data["roles"])
+	assert.Equal(t, "api:* member-of-groups:readers artifact:libs-release:w", createRequest.Scope)
+	assert.Equal(t, "build", createRequest.Username)
+	assert.Equal(t, "readers", resp.Secret.InternalData["role"])
+	assert.Equal(t, 30*time.Minute, resp.Secret.TTL)
+	assert.Equal(t, time.Hour, resp.Secret.MaxTTL)
+
+	// The token is tracked under each of its roles
+	for _, roleName := range []string{"readers", "deployers"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "roles/" + roleName + "/secrets/",
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Data["keys"], 1)
+	}
+
+	for _, tc := range []struct {
+		roles    string
+		entityID string
+		error    string
+	}{
+		{"readers,other-user", "build-entity", "different username"},
+		{"readers,with-change-ref", "build-entity", "can't be combined"},
+		{"readers,missing", "build-entity", "no such role"},
+		{"readers,not-combinable", "build-entity", "no multi_allowed_groups"},
+		{"readers,other-group", "build-entity", "not a member"},
+		{"readers,deployers", "", "no associated entity"},
+		{"readers,deployers", "build-entity", "max_tokens_per_entity"},
+	} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/multi",
+			Storage:   config.StorageView,
+			EntityID:  tc.entityID,
+			Data:      map[string]interface{}{"roles": tc.roles},
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), tc.error)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/multi",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"scope": "api:*"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "reserved")
+}
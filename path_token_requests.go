@@ -0,0 +1,309 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// tokenRequestsStoragePrefix holds asynchronous token requests, as token_requests/<request_id>. Completed requests
+	// hold the token until it is picked up, so the prefix is seal wrapped.
+	tokenRequestsStoragePrefix = "token_requests/"
+
+	// tokenRequestRetention is how long a token request is kept. Tokens not picked up by then are revoked.
+	tokenRequestRetention = time.Hour
+
+	tokenRequestPending   = "pending"
+	tokenRequestCompleted = "completed"
+	tokenRequestFailed    = "failed"
+)
+
+func (b *backend) pathTokenRequests() *framework.Path {
+	return &framework.Path{
+		Pattern: "token-requests/" + framework.GenericNameRegex("request_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"request_id": {
+				Type:        framework.TypeString,
+				Description: `Id returned by an 'async' token/<role> request.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathTokenRequestsRead,
+				Summary:  `Get the result of an asynchronous token request.`,
+			},
+		},
+		HelpSynopsis: `Get the result of an asynchronous token request.`,
+		HelpDescription: `
+Returns the status of a token/<role> request made with 'async=true' while the token is being issued. Once it is, the
+response is that of the token/<role> request, lease included, and the request is removed: the token can only be
+picked up once, and only by the Vault entity that requested it.
+
+Requests are kept for an hour. Tokens not picked up by then are revoked.
+`,
+	}
+}
+
+type tokenRequest struct {
+	Role          string               `json:"role"`
+	RoleConfig    artifactoryRole      `json:"role_config"`
+	Options       tokenOptions         `json:"options"`
+	EntityID      string               `json:"entity_id,omitempty"`
+	Status        string               `json:"status"`
+	CreatedAt     time.Time            `json:"created_at"`
+	Error         string               `json:"error,omitempty"`
+	Token         *createTokenResponse `json:"token,omitempty"`
+	ReferenceOnly bool                 `json:"reference_only,omitempty"`
+	Revocable     bool                 `json:"revocable,omitempty"`
+}
+
+// startTokenRequest stores a token request and issues its token in the background, returning the request id
+func (b *backend) startTokenRequest(ctx context.Context, req *logical.Request, config adminConfiguration, roleName string, role artifactoryRole, opts tokenOptions, maxIssueTime time.Duration) (*logical.Response, error) {
+	requestID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	request := tokenRequest{
+		Role:       roleName,
+		RoleConfig: role,
+		Options:    opts,
+		EntityID:   req.EntityID,
+		Status:     tokenRequestPending,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := b.putTokenRequest(ctx, req.Storage, requestID, request); err != nil {
+		return nil, err
+	}
+
+	// Until its token is picked up and tracked, the request counts against max_tokens_per_entity
+	if request.EntityID != "" {
+		entry := &logical.StorageEntry{Key: pendingTokenRequestIndexPrefix(roleName, request.EntityID) + requestID, Value: []byte(requestID)}
+		if err := req.Storage.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	go b.completeTokenRequest(context.WithoutCancel(ctx), req.Storage, config, requestID, request, maxIssueTime)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"request_id": requestID,
+			"role":       roleName,
+			"status":     tokenRequestPending,
+		},
+	}, nil
+}
+
+// completeTokenRequest creates the token of a token request, and stores it, or the error, in the request. A token
+// whose request has been removed meanwhile is revoked.
+func (b *backend) completeTokenRequest(ctx context.Context, storage logical.Storage, config adminConfiguration, requestID string, request tokenRequest, maxIssueTime time.Duration) {
+	issueCtx := ctx
+	if maxIssueTime > 0 {
+		var cancel context.CancelFunc
+		issueCtx, cancel = context.WithTimeout(ctx, maxIssueTime)
+		defer cancel()
+	}
+
+	resp, err := b.CreateToken(issueCtx, config, request.RoleConfig)
+	if err == nil && issueCtx.Err() != nil {
+		b.discardToken(ctx, storage, config, request.Role, request.RoleConfig, resp)
+		err = fmt.Errorf("max_issue_time of %s exceeded, token %s was revoked", maxIssueTime, resp.TokenId)
+		resp = nil
+	}
+
+	b.tokenRequestsMutex.Lock()
+	defer b.tokenRequestsMutex.Unlock()
+
+	entry, getErr := storage.Get(ctx, tokenRequestsStoragePrefix+requestID)
+	if getErr != nil || entry == nil {
+		b.Logger().Warn("token request is gone, discarding its token", "requestId", requestID, "err", getErr)
+		if resp != nil {
+			b.discardToken(ctx, storage, config, request.Role, request.RoleConfig, resp)
+		}
+		return
+	}
+
+	if err != nil {
+		b.Logger().Warn("asynchronous token request failed", "requestId", requestID, "role", request.Role, "err", err)
+		request.Status = tokenRequestFailed
+		request.Error = err.Error()
+		if err := b.deletePendingTokenRequest(ctx, storage, requestID, request); err != nil {
+			b.Logger().Warn("could not remove failed token request from max_tokens_per_entity", "requestId", requestID, "err", err)
+		}
+	} else {
+		request.Status = tokenRequestCompleted
+		request.Token = resp
+		request.ReferenceOnly = resp.ReferenceOnly
+		request.Revocable = resp.Revocable
+	}
+
+	if err := b.putTokenRequest(ctx, storage, requestID, request); err != nil {
+		b.Logger().Error("could not store token request result", "requestId", requestID, "err", err)
+		if resp != nil {
+			b.discardToken(ctx, storage, config, request.Role, request.RoleConfig, resp)
+		}
+	}
+}
+
+func (b *backend) putTokenRequest(ctx context.Context, storage logical.Storage, requestID string, request tokenRequest) error {
+	entry, err := logical.StorageEntryJSON(tokenRequestsStoragePrefix+requestID, request)
+	if err != nil {
+		return err
+	}
+	return storage.Put(ctx, entry)
+}
+
+// deletePendingTokenRequest stops counting a token request against max_tokens_per_entity
+func (b *backend) deletePendingTokenRequest(ctx context.Context, storage logical.Storage, requestID string, request tokenRequest) error {
+	if request.EntityID == "" {
+		return nil
+	}
+	return storage.Delete(ctx, pendingTokenRequestIndexPrefix(request.Role, request.EntityID)+requestID)
+}
+
+func (b *backend) pathTokenRequestsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	go b.sendUsage(*config, "pathTokenRequestsRead")
+
+	b.tokenRequestsMutex.Lock()
+	defer b.tokenRequestsMutex.Unlock()
+
+	requestID := data.Get("request_id").(string)
+	key := tokenRequestsStoragePrefix + requestID
+
+	entry, err := req.Storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return logical.ErrorResponse("no such token request; its token may have been picked up already, or expired"), nil
+	}
+
+	var request tokenRequest
+	if err := entry.DecodeJSON(&request); err != nil {
+		return nil, err
+	}
+
+	if request.EntityID != req.EntityID {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	switch request.Status {
+	case tokenRequestPending:
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"request_id": requestID,
+				"role":       request.Role,
+				"status":     request.Status,
+				"created_at": request.CreatedAt,
+			},
+		}, nil
+	case tokenRequestFailed:
+		if err := req.Storage.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+		return logical.ErrorResponse("issuing the token failed: %s", request.Error), nil
+	}
+
+	if err := req.Storage.Delete(ctx, key); err != nil {
+		return nil, err
+	}
+
+	request.Token.ReferenceOnly = request.ReferenceOnly
+	request.Token.Revocable = request.Revocable
+
+	// The token is tracked before the request stops counting against max_tokens_per_entity, so it is counted throughout
+	b.entityLimitMutex.Lock()
+	defer b.entityLimitMutex.Unlock()
+
+	if request.RoleConfig.group() {
+		b.groupTokenMutex.Lock()
+		defer b.groupTokenMutex.Unlock()
+	}
+
+	response := b.tokenResponse(ctx, req, request.Role, request.RoleConfig, request.Token, request.Options)
+
+	if err := b.deletePendingTokenRequest(ctx, req.Storage, requestID, request); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// expireTokenRequests removes token requests older than tokenRequestRetention, revoking tokens that weren't picked
+// up. It runs periodically on the active node.
+func (b *backend) expireTokenRequests(ctx context.Context, req *logical.Request) error {
+	b.tokenRequestsMutex.Lock()
+	defer b.tokenRequestsMutex.Unlock()
+
+	keys, err := req.Storage.List(ctx, tokenRequestsStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	for _, requestID := range keys {
+		entry, err := req.Storage.Get(ctx, tokenRequestsStoragePrefix+requestID)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var request tokenRequest
+		if err := entry.DecodeJSON(&request); err != nil {
+			return err
+		}
+
+		if time.Since(request.CreatedAt) < tokenRequestRetention {
+			continue
+		}
+
+		if request.Token != nil && config != nil {
+			b.Logger().Info("token of asynchronous token request was not picked up, revoking it", "requestId", requestID, "tokenId", request.Token.TokenId)
+			roleConfig, err := b.roleConfiguration(ctx, req.Storage, *config, request.RoleConfig)
+			if err != nil {
+				b.Logger().Warn("could not get the admin configuration of the role", "role", request.Role, "err", err)
+			}
+			b.discardToken(ctx, req.Storage, roleConfig, request.Role, request.RoleConfig, request.Token)
+		}
+
+		if err := b.deletePendingTokenRequest(ctx, req.Storage, requestID, request); err != nil {
+			return err
+		}
+
+		if err := req.Storage.Delete(ctx, tokenRequestsStoragePrefix+requestID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
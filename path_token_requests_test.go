@@ -0,0 +1,134 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// An async token request must return a request id at once, and hand the token out once to the requesting entity.
+func TestBackend_PathTokenRequests(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	release := make(chan struct{})
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			<-release
+			return httpmock.NewStringResponse(200, canonicalAccessToken), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+		EntityID:  "test-entity",
+		Data:      map[string]interface{}{"async": true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, tokenRequestPending, resp.Data["status"])
+	assert.Nil(t, resp.Secret)
+	requestID := resp.Data["request_id"].(string)
+
+	poll := func(entityID string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token-requests/" + requestID,
+			Storage:   config.StorageView,
+			EntityID:  entityID,
+		})
+	}
+
+	resp, err = poll("test-entity")
+	assert.NoError(t, err)
+	assert.Equal(t, tokenRequestPending, resp.Data["status"])
+
+	_, err = poll("other-entity")
+	assert.ErrorIs(t, err, logical.ErrPermissionDenied)
+
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		resp, err = poll("test-entity")
+		return err == nil && resp.Data["status"] != tokenRequestPending
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, "eyXsdgbtybbeeyh...", resp.Data["access_token"])
+	assert.NotNil(t, resp.Secret)
+	assert.Equal(t, "test-role", resp.Secret.InternalData["role"])
+
+	// The token can only be picked up once
+	resp, err = poll("test-entity")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+}
+
+// Tokens of async requests that are not picked up must be revoked when the request expires.
+func TestBackend_ExpireTokenRequests(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	var revocations atomic.Int32
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		func(req *http.Request) (*http.Response, error) {
+			revocations.Add(1)
+			return httpmock.NewStringResponse(200, ""), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for requestID, createdAt := range map[string]time.Time{
+		"recent":  time.Now(),
+		"expired": time.Now().Add(-2 * tokenRequestRetention),
+	} {
+		err := b.putTokenRequest(context.Background(), config.StorageView, requestID, tokenRequest{
+			Role:       "test-role",
+			RoleConfig: artifactoryRole{Username: "test-username", Scope: "test-scope"},
+			Status:     tokenRequestCompleted,
+			CreatedAt:  createdAt,
+			Token:      &createTokenResponse{AccessToken: "access-token-" + requestID, TokenId: requestID},
+		})
+		assert.NoError(t, err)
+	}
+
+	err := b.expireTokenRequests(context.Background(), &logical.Request{Storage: config.StorageView})
+	assert.NoError(t, err)
+
+	keys, err := config.StorageView.List(context.Background(), tokenRequestsStoragePrefix)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"recent"}, keys)
+	assert.EqualValues(t, 1, revocations.Load())
+}
@@ -0,0 +1,225 @@
+package artifactory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathTokenSimulate() *framework.Path {
+	return &framework.Path{
+		Pattern: "token/" + framework.GenericNameWithAtRegex("role") + "/simulate",
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: `Use the configuration of the specified role.`,
+			},
+			"path": {
+				Type:        framework.TypeString,
+				Required:    true,
+				Description: `Repository path to check, starting with the repository key (e.g. 'libs-release/org/acme/app/1.0/app-1.0.jar').`,
+			},
+			"action": {
+				Type:        framework.TypeString,
+				Default:     "read",
+				Description: `Action to check: one of read, annotate, deploy, delete and manage. Defaults to 'read'.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathTokenSimulateRead,
+				Summary:  `Check whether a token from the role would be allowed an action on a path, without creating one.`,
+			},
+		},
+		HelpSynopsis: `Check whether a token from the role would be allowed an action on a path, without creating one.`,
+		HelpDescription: `
+Evaluates the scope tokens of the role are issued with against 'path' and 'action', to debug scope definitions without
+creating tokens. Artifact scopes are evaluated by the backend. Group and user scopes are evaluated against the
+permissions Artifactory reports for the path ("Effective Item Permissions"), which requires an admin token.
+
+The response says whether the action is 'allowed', which scope entries grant it, and which entries couldn't be
+evaluated, such as project roles.
+`,
+	}
+}
+
+type effectivePermissionsResponse struct {
+	Principals struct {
+		Users  map[string][]string `json:"users"`
+		Groups map[string][]string `json:"groups"`
+	} `json:"principals"`
+}
+
+func (b *backend) pathTokenSimulateRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
+	defer b.rolesMutex.RUnlock()
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	go b.sendUsage(*config, "pathTokenSimulateRead")
+
+	roleName := data.Get("role").(string)
+
+	role, err := b.Role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return logical.ErrorResponse("no such role"), nil
+	}
+
+	repoPath := strings.Trim(data.Get("path").(string), "/")
+	if repoPath == "" {
+		return logical.ErrorResponse("missing path"), nil
+	}
+	repoKey, _, _ := strings.Cut(repoPath, "/")
+
+	action := data.Get("action").(string)
+	actionCode, ok := scopePermissionActions[action]
+	if !ok {
+		return logical.ErrorResponse(validateScopePermissions([]string{action}).Error()), nil
+	}
+
+	username := role.Username
+	if role.group() {
+		username = role.groupTokenUsername()
+	}
+	if len(username) == 0 {
+		username, err = b.currentSetup().usernameProducer.Generate(UsernameMetadata{
+			RoleName:    roleName,
+			DisplayName: req.DisplayName,
+		})
+		if err != nil {
+			return logical.ErrorResponse("error generating username from template"), err
+		}
+	}
+
+	scope := b.roleScope(*role)
+
+	var grantedBy, unevaluated []string
+	var permissions *effectivePermissionsResponse
+
+	for _, entry := range strings.Fields(scope) {
+		var principals map[string][]string
+		var names []string
+
+		parsed, err := parseScopeEntry(entry)
+		if err != nil {
+			unevaluated = append(unevaluated, entry)
+			continue
+		}
+
+		switch {
+		case parsed.kind == scopeKindLegacyAPI:
+			continue
+		case parsed.kind == scopeKindAdmin:
+			grantedBy = append(grantedBy, entry)
+			continue
+		case parsed.kind == scopeKindArtifact:
+			if artifactScopeAllows(parsed, repoKey, actionCode) {
+				grantedBy = append(grantedBy, entry)
+			}
+			continue
+		case parsed.kind == scopeKindUser:
+			names = []string{username}
+		case parsed.kind == scopeKindGroups,
+			parsed.kind == scopeKindLegacyGroups && !strutil.StrListContains(parsed.names, "*"):
+			names = parsed.names
+		default:
+			unevaluated = append(unevaluated, entry)
+			continue
+		}
+
+		if permissions == nil {
+			permissions, err = b.effectivePermissions(b.withRole(ctx, req.Storage, *config, roleName), repoPath)
+			if err != nil {
+				return logical.ErrorResponse("could not get effective permissions of '%s': %s", repoPath, err), nil
+			}
+		}
+
+		principals = permissions.Principals.Groups
+		if parsed.kind == scopeKindUser {
+			principals = permissions.Principals.Users
+		}
+
+		for _, name := range names {
+			if strutil.StrListContains(principals[name], actionCode) {
+				grantedBy = append(grantedBy, entry)
+				break
+			}
+		}
+	}
+
+	response := &logical.Response{
+		Data: map[string]interface{}{
+			"allowed":    len(grantedBy) > 0,
+			"role":       roleName,
+			"path":       repoPath,
+			"action":     action,
+			"username":   username,
+			"scope":      scope,
+			"granted_by": grantedBy,
+		},
+	}
+
+	if len(unevaluated) > 0 {
+		response.Data["unevaluated"] = unevaluated
+		response.AddWarning(fmt.Sprintf("Scope entries %s were not evaluated; they may grant the action too.", strings.Join(unevaluated, ", ")))
+	}
+
+	return response, nil
+}
+
+// artifactScopeAllows reports whether an artifact scope entry grants actionCode on repoKey. The repository may be a
+// glob, and '*' grants every action.
+func artifactScopeAllows(entry scopeEntry, repoKey string, actionCode string) bool {
+	if !strutil.GlobbedStringsMatch(entry.path, repoKey) {
+		return false
+	}
+
+	return strutil.StrListContains(entry.actions, "*") || strutil.StrListContains(entry.actions, actionCode)
+}
+
+// effectivePermissions gets the users and groups with permissions on a repository path, and the actions each may take
+func (b *backend) effectivePermissions(config adminConfiguration, repoPath string) (*effectivePermissionsResponse, error) {
+	u, err := url.Parse(config.ArtifactoryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.performArtifactoryGet(config, u.Path+"/api/storage/"+repoPath+"?permissions")
+	if err != nil {
+		return nil, err
+	}
+
+	//noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP response %v", resp.StatusCode)
+	}
+
+	var permissions effectivePermissionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&permissions); err != nil {
+		return nil, err
+	}
+
+	return &permissions, nil
+}
@@ -0,0 +1,101 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// token/<role>/simulate must evaluate artifact scopes locally, and group scopes against Artifactory's effective
+// permissions for the path.
+func TestBackend_PathTokenSimulate(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/artifactory/api/storage/libs-release/org/acme/app.jar?permissions",
+		httpmock.NewStringResponder(200, `{
+			"uri": "http://myserver.com:80/artifactory/api/storage/libs-release/org/acme/app.jar",
+			"principals": {
+				"users": {"admin": ["r", "w", "d", "n", "m"]},
+				"groups": {"readers": ["r"]}
+			}
+		}`))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for roleName, roleData := range map[string]map[string]interface{}{
+		"readers": {
+			"username": "test-username",
+			"groups":   "readers",
+		},
+		"deployers": {
+			"username":     "test-username",
+			"repositories": "libs-*",
+			"permissions":  "deploy",
+		},
+		"project": {
+			"username": "test-username",
+			"scope":    "applied-permissions/roles:acme:developer",
+		},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data:      roleData,
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	simulate := func(roleName string, action string) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/" + roleName + "/simulate",
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"path":   "libs-release/org/acme/app.jar",
+				"action": action,
+			},
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		return resp
+	}
+
+	resp := simulate("readers", "read")
+	assert.Equal(t, true, resp.Data["allowed"])
+	assert.Equal(t, []string{"member-of-groups:readers"}, resp.Data["granted_by"])
+
+	assert.Equal(t, false, simulate("readers", "deploy").Data["allowed"])
+	assert.Equal(t, true, simulate("deployers", "deploy").Data["allowed"])
+	assert.Equal(t, false, simulate("deployers", "read").Data["allowed"])
+
+	resp = simulate("project", "read")
+	assert.Equal(t, false, resp.Data["allowed"])
+	assert.Equal(t, []string{"applied-permissions/roles:acme:developer"}, resp.Data["unevaluated"])
+	assert.NotEmpty(t, resp.Warnings)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/readers/simulate",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"path":   "libs-release/org/acme/app.jar",
+			"action": "write",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+}
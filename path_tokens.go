@@ -0,0 +1,625 @@
+package artifactory
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// trackedTokenStoragePrefix holds the metadata of issued tokens, as tokens/<shard>/<tracking id>. Spreading them over
+// shards keeps each listing small at high issuance rates. Tokens tracked before sharding are stored directly under the
+// prefix until compaction moves them.
+const trackedTokenStoragePrefix = "tokens/"
+
+func (b *backend) pathListTokens() *framework.Path {
+	return &framework.Path{
+		Pattern: "tokens/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: `Optional. Only list tokens issued for this role.`,
+			},
+			"username_prefix": {
+				Type:        framework.TypeString,
+				Description: `Optional. Only list tokens whose Artifactory username starts with this prefix.`,
+			},
+			"expiring_within": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Optional. Only list tokens whose lease expires within this duration from now.`,
+			},
+			"after": {
+				Type:        framework.TypeString,
+				Description: `Optional. Pagination cursor; only list tokens after the token with this tracking id, the last key of the previous page.`,
+			},
+			"limit": {
+				Type:        framework.TypeInt,
+				Description: `Optional. Maximum number of tokens to return. Defaults to all.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathTokenList,
+				Summary:  `List access tokens issued by this backend.`,
+			},
+		},
+		HelpSynopsis: `List access tokens issued by this backend that have not been revoked.`,
+		HelpDescription: `
+Lists the tracking ids of access tokens issued by this backend whose leases have not been revoked, along with
+the role, username, and issue/expiry times of each token.
+
+The optional 'role', 'username_prefix', and 'expiring_within' parameters filter the list. The optional 'limit'
+parameter bounds the number of returned tokens; pass the last returned key as 'after' to fetch the next page. Tokens
+are listed in storage order, which is stable between pages but not sorted by tracking id, or by tracking id when
+filtered by 'role'. Each page only reads the tokens from its cursor on.
+`,
+	}
+}
+
+func (b *backend) pathListRoleSecrets() *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameWithAtRegex("role") + "/secrets/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Required:    true,
+				Description: `The name of the role.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathRoleSecretsList,
+				Summary:  `List the active access tokens issued for a role.`,
+			},
+		},
+		HelpSynopsis: `List the active access tokens issued for a role, and the prefix of their leases.`,
+		HelpDescription: `
+Lists the tracking ids of access tokens issued for the role whose leases have not been revoked, like tokens/ filtered
+by role.
+
+Vault doesn't tell plugins the ids of the leases it creates, so the response also includes "lease_prefix", the prefix
+all of the role's lease ids share. Pass it to "vault lease revoke -prefix" to revoke every lease of the role, or list
+sys/leases/lookup/<lease_prefix> to see the individual lease ids.
+`,
+	}
+}
+
+// trackedToken is the metadata stored for each access token issued by this backend, keyed by tracking id.
+type trackedToken struct {
+	TokenID   string    `json:"token_id,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Username  string    `json:"username"`
+	Scope     string    `json:"scope,omitempty"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// RevokedInArtifactory is set once Artifactory reports the token revoked or expired
+	RevokedInArtifactory bool `json:"revoked_in_artifactory,omitempty"`
+
+	// AccessTokenSHA256 identifies the token when it is presented for delegation, without storing it
+	AccessTokenSHA256 string `json:"access_token_sha256,omitempty"`
+
+	// ParentID is the tracking id of the token this one was delegated from
+	ParentID string `json:"parent_id,omitempty"`
+
+	// RevokedWithParent is set once the token was revoked in Artifactory because its parent's lease was revoked
+	RevokedWithParent bool `json:"revoked_with_parent,omitempty"`
+
+	// EntityID is the Vault entity the token was issued to, if any
+	EntityID string `json:"entity_id,omitempty"`
+
+	// RevokedForEntityLimit is set once the token was revoked in Artifactory to make room for a newer token of the same
+	// entity under the role's max_tokens_per_entity
+	RevokedForEntityLimit bool `json:"revoked_for_entity_limit,omitempty"`
+
+	// RevokedWithRole is set once the token was revoked in Artifactory because its role's revoke_at passed
+	RevokedWithRole bool `json:"revoked_with_role,omitempty"`
+
+	// RevokedForRotation is set once the token was revoked in Artifactory because a newer token of its group role
+	// replaced it
+	RevokedForRotation bool `json:"revoked_for_rotation,omitempty"`
+
+	// Roles are the roles a token/multi token combines. Role is the first of them.
+	Roles []string `json:"roles,omitempty"`
+
+	// ConfigName is the admin configuration the token was issued from, or empty for config/admin
+	ConfigName string `json:"config_name,omitempty"`
+
+	// NotRevocable is set for tokens Artifactory neither persisted nor can look up, those expiring within its revocable
+	// threshold that weren't created with force_revocable
+	NotRevocable bool `json:"not_revocable,omitempty"`
+
+	// Sequence is the number of the token's issuance in the changelog, or 0 if it couldn't be appended
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+// trackingID returns the id a token is tracked under. The Artifactory token id is used when present; older
+// Artifactory versions don't return one, in which case a random id is generated.
+func (b *backend) trackingID(tokenID string) (string, error) {
+	if tokenID != "" {
+		return tokenID, nil
+	}
+	return uuid.GenerateUUID()
+}
+
+// trackedTokenShard returns the shard a token is tracked in, the first byte of the sha256 hash of its tracking id in
+// hex, so tokens are spread evenly over 256 shards whatever their ids look like
+func trackedTokenShard(trackingID string) string {
+	sum := sha256.Sum256([]byte(trackingID))
+	return fmt.Sprintf("%02x", sum[0])
+}
+
+// trackedTokenKey returns the storage key of a tracked token
+func trackedTokenKey(trackingID string) string {
+	return trackedTokenStoragePrefix + trackedTokenShard(trackingID) + "/" + trackingID
+}
+
+// listTrackedTokens returns the sorted tracking ids of all tracked tokens, including those tracked before sharding
+func (b *backend) listTrackedTokens(ctx context.Context, storage logical.Storage) ([]string, error) {
+	keys, err := storage.List(ctx, trackedTokenStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	trackingIDs := []string{}
+	for _, key := range keys {
+		ids := []string{key}
+		if strings.HasSuffix(key, "/") {
+			ids, err = storage.List(ctx, trackedTokenStoragePrefix+key)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				trackingIDs = append(trackingIDs, id)
+			}
+		}
+	}
+	sort.Strings(trackingIDs)
+
+	return trackingIDs, nil
+}
+
+// walkTrackedTokens calls fn with each tracked token after the one tracked as after, if set, until fn returns false.
+// Tokens are walked shard by shard, and by tracking id within a shard, listing one shard at a time from the cursor's,
+// so a page only reads the tokens it returns or skips. Tokens tracked before sharding are walked with the shard
+// compaction moves them to.
+func (b *backend) walkTrackedTokens(ctx context.Context, storage logical.Storage, after string, fn func(trackingID string, token *trackedToken) bool) error {
+	keys, err := storage.List(ctx, trackedTokenStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	var shards []string
+	legacy := map[string][]string{}
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			shards = append(shards, strings.TrimSuffix(key, "/"))
+			continue
+		}
+		shard := trackedTokenShard(key)
+		if len(legacy[shard]) == 0 {
+			shards = append(shards, shard)
+		}
+		legacy[shard] = append(legacy[shard], key)
+	}
+	shards = strutil.RemoveDuplicates(shards, false)
+
+	var cursorShard string
+	if after != "" {
+		cursorShard = trackedTokenShard(after)
+	}
+
+	for _, shard := range shards {
+		// Shards before the cursor's were walked by previous pages, so they aren't listed again
+		if shard < cursorShard {
+			continue
+		}
+
+		ids, err := storage.List(ctx, trackedTokenStoragePrefix+shard+"/")
+		if err != nil {
+			return err
+		}
+		ids = strutil.RemoveDuplicates(append(ids, legacy[shard]...), false)
+
+		if shard == cursorShard {
+			ids = ids[sort.Search(len(ids), func(i int) bool { return ids[i] > after }):]
+		}
+
+		for _, trackingID := range ids {
+			token, err := b.fetchTrackedToken(ctx, storage, trackingID)
+			if err != nil {
+				return err
+			}
+			if token == nil {
+				continue
+			}
+
+			if !fn(trackingID, token) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkRoleTokens calls fn with each token of a role whose tracking id sorts after after, by tracking id, until fn
+// returns false, reading only the tokens of the role from its index
+func (b *backend) walkRoleTokens(ctx context.Context, storage logical.Storage, roleName string, after string, fn func(trackingID string, token *trackedToken) bool) error {
+	ids, err := storage.List(ctx, roleTokenIndexPrefix(roleName))
+	if err != nil {
+		return err
+	}
+	sort.Strings(ids)
+
+	if after != "" {
+		ids = ids[sort.Search(len(ids), func(i int) bool { return ids[i] > after }):]
+	}
+
+	for _, trackingID := range ids {
+		token, err := b.fetchTrackedToken(ctx, storage, trackingID)
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			continue
+		}
+
+		if !fn(trackingID, token) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (b *backend) putTrackedToken(ctx context.Context, storage logical.Storage, trackingID string, token trackedToken) error {
+	entry, err := logical.StorageEntryJSON(trackedTokenKey(trackingID), token)
+	if err != nil {
+		return err
+	}
+	return storage.Put(ctx, entry)
+}
+
+// fetchTrackedToken will return nil,nil if the token is not tracked
+func (b *backend) fetchTrackedToken(ctx context.Context, storage logical.Storage, trackingID string) (*trackedToken, error) {
+	entry, err := storage.Get(ctx, trackedTokenKey(trackingID))
+	if err != nil {
+		return nil, err
+	}
+
+	// Fall back to where tokens were tracked before sharding
+	if entry == nil {
+		entry, err = storage.Get(ctx, trackedTokenStoragePrefix+trackingID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var token trackedToken
+	if err := entry.DecodeJSON(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// findTrackedToken returns the tracking id and metadata of the tracked token whose access token is accessToken, or
+// "", nil, nil if it isn't tracked
+func (b *backend) findTrackedToken(ctx context.Context, storage logical.Storage, accessToken string) (string, *trackedToken, error) {
+	hash := accessTokenSHA256(accessToken)
+
+	entry, err := storage.Get(ctx, tokenHashIndexKey(hash))
+	if err != nil {
+		return "", nil, err
+	}
+	if entry == nil {
+		return "", nil, nil
+	}
+
+	trackingID := string(entry.Value)
+	token, err := b.fetchTrackedToken(ctx, storage, trackingID)
+	if err != nil {
+		return "", nil, err
+	}
+	if token == nil || subtle.ConstantTimeCompare([]byte(token.AccessTokenSHA256), []byte(hash)) != 1 {
+		return "", nil, nil
+	}
+
+	return trackingID, token, nil
+}
+
+func accessTokenSHA256(accessToken string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(accessToken)))
+}
+
+// deleteTrackedToken stops tracking a token, and removes its index entries
+func (b *backend) deleteTrackedToken(ctx context.Context, storage logical.Storage, trackingID string) error {
+	token, err := b.fetchTrackedToken(ctx, storage, trackingID)
+	if err != nil {
+		return err
+	}
+	if token != nil {
+		if err := b.unindexTrackedToken(ctx, storage, trackingID, *token); err != nil {
+			return err
+		}
+	}
+
+	if err := storage.Delete(ctx, trackedTokenKey(trackingID)); err != nil {
+		return err
+	}
+	return storage.Delete(ctx, trackedTokenStoragePrefix+trackingID)
+}
+
+// trackSecret records a newly issued token, and appends it to the changelog. Failure to track is logged but does not
+// fail issuance, since the token already exists in Artifactory and its lease still revokes it.
+func (b *backend) trackSecret(ctx context.Context, req *logical.Request, response *logical.Response, roleName string) {
+	tokenID, _ := response.Secret.InternalData["token_id"].(string)
+
+	trackingID, err := b.trackingID(tokenID)
+	if err != nil {
+		b.Logger().Warn("could not generate tracking id", "err", err)
+		return
+	}
+
+	// A zero ttl means the lease gets the mount's default lease ttl
+	ttl := response.Secret.TTL
+	if ttl == 0 {
+		ttl = b.System().DefaultLeaseTTL()
+	}
+
+	now := time.Now()
+	token := trackedToken{
+		TokenID:   tokenID,
+		Role:      roleName,
+		Username:  response.Data["username"].(string),
+		EntityID:  req.EntityID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if scope, ok := response.Data["scope"].(string); ok {
+		token.Scope = scope
+	}
+	if accessToken, ok := response.Secret.InternalData["access_token"].(string); ok && accessToken != "" {
+		token.AccessTokenSHA256 = accessTokenSHA256(accessToken)
+	}
+	if roles, ok := response.Secret.InternalData["roles"].([]string); ok && len(roles) > 1 {
+		token.Roles = roles
+	}
+	if parentID, ok := response.Secret.InternalData["parent_tracking_id"].(string); ok {
+		token.ParentID = parentID
+	}
+	if configName, ok := response.Secret.InternalData["config_name"].(string); ok {
+		token.ConfigName = configName
+	}
+	if revocable, ok := response.Secret.InternalData["revocable"].(bool); ok && !revocable {
+		token.NotRevocable = true
+	}
+
+	token.Sequence = b.appendChangelog(ctx, req.Storage, changelogEntry{
+		TrackingID: trackingID,
+		TokenID:    tokenID,
+		Role:       strings.Join(token.roles(), ","),
+		Username:   token.Username,
+		Scope:      token.Scope,
+		EntityID:   req.EntityID,
+		ParentID:   token.ParentID,
+		IssuedAt:   token.IssuedAt,
+		ExpiresAt:  token.ExpiresAt,
+	})
+
+	if err := b.putTrackedToken(ctx, req.Storage, trackingID, token); err != nil {
+		b.Logger().Warn("could not track access token", "tokenId", tokenID, "err", err)
+		return
+	}
+
+	if err := b.indexTrackedToken(ctx, req.Storage, trackingID, token); err != nil {
+		b.Logger().Warn("could not index tracked access token", "tokenId", tokenID, "err", err)
+	}
+
+	response.Secret.InternalData["tracking_id"] = trackingID
+
+	if token.ParentID != "" {
+		if err := b.recordChild(ctx, req.Storage, token.ParentID, trackingID, response.Secret.InternalData); err != nil {
+			b.Logger().Warn("could not record delegated token with its parent", "tokenId", tokenID, "parent", token.ParentID, "err", err)
+		}
+	}
+}
+
+// active reports whether a tracked token hasn't expired or been revoked at now
+func (t trackedToken) active(now time.Time) bool {
+	return !t.RevokedInArtifactory && !t.RevokedWithParent && !t.RevokedForEntityLimit && !t.RevokedWithRole && !t.RevokedForRotation && t.ExpiresAt.After(now)
+}
+
+// activeTokens returns how many tracked tokens, of any role, haven't expired or been revoked
+func (b *backend) activeTokens(ctx context.Context, storage logical.Storage) (int, error) {
+	keys, err := b.listTrackedTokens(ctx, storage)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	count := 0
+	for _, key := range keys {
+		token, err := b.fetchTrackedToken(ctx, storage, key)
+		if err != nil {
+			return 0, err
+		}
+		if token != nil && token.active(now) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// activeRoleTokens returns how many tracked tokens of a role haven't expired or been revoked, and when the newest of them
+// expires
+func (b *backend) activeRoleTokens(ctx context.Context, storage logical.Storage, roleName string) (count int, newestExpiry time.Time, err error) {
+	ids, tokens, err := b.activeIndexedTrackedTokens(ctx, storage, roleTokenIndexPrefix(roleName))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	for _, trackingID := range ids {
+		if expiresAt := tokens[trackingID].ExpiresAt; expiresAt.After(newestExpiry) {
+			newestExpiry = expiresAt
+		}
+	}
+
+	return len(ids), newestExpiry, nil
+}
+
+func (b *backend) pathRoleSecretsList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rolesMutex.RLock()
+	defer b.rolesMutex.RUnlock()
+
+	roleName := data.Get("role").(string)
+
+	role, err := b.Role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return logical.ErrorResponse("role '%s' does not exist", roleName), nil
+	}
+
+	keys, tokens, err := b.indexedTrackedTokens(ctx, req.Storage, roleTokenIndexPrefix(roleName))
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []string{}
+	keyInfo := map[string]interface{}{}
+
+	for _, key := range keys {
+		token := tokens[key]
+
+		matched = append(matched, key)
+		keyInfo[key] = map[string]interface{}{
+			"token_id":   token.TokenID,
+			"username":   token.Username,
+			"issued_at":  token.IssuedAt,
+			"expires_at": token.ExpiresAt,
+		}
+		if token.RevokedInArtifactory {
+			keyInfo[key].(map[string]interface{})["revoked_in_artifactory"] = true
+		}
+		if len(token.Roles) > 0 {
+			keyInfo[key].(map[string]interface{})["roles"] = token.Roles
+		}
+		if token.ParentID != "" {
+			keyInfo[key].(map[string]interface{})["parent_id"] = token.ParentID
+		}
+		if token.RevokedWithParent {
+			keyInfo[key].(map[string]interface{})["revoked_with_parent"] = true
+		}
+		if token.RevokedForEntityLimit {
+			keyInfo[key].(map[string]interface{})["revoked_for_entity_limit"] = true
+		}
+		if token.RevokedWithRole {
+			keyInfo[key].(map[string]interface{})["revoked_with_role"] = true
+		}
+		if token.RevokedForRotation {
+			keyInfo[key].(map[string]interface{})["revoked_for_rotation"] = true
+		}
+		if token.Sequence > 0 {
+			keyInfo[key].(map[string]interface{})["sequence"] = token.Sequence
+		}
+	}
+
+	resp := logical.ListResponseWithInfo(matched, keyInfo)
+	resp.Data["lease_prefix"] = req.MountPoint + "token/" + roleName + "/"
+
+	return resp, nil
+}
+
+func (b *backend) pathTokenList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+	usernamePrefix := data.Get("username_prefix").(string)
+	expiringWithin := time.Duration(data.Get("expiring_within").(int)) * time.Second
+	after := data.Get("after").(string)
+	limit := data.Get("limit").(int)
+
+	if limit < 0 {
+		return logical.ErrorResponse("limit must not be negative"), nil
+	}
+
+	now := time.Now()
+	matched := []string{}
+	keyInfo := map[string]interface{}{}
+
+	add := func(key string, token *trackedToken) bool {
+		if usernamePrefix != "" && !strings.HasPrefix(token.Username, usernamePrefix) {
+			return true
+		}
+		if expiringWithin > 0 && token.ExpiresAt.After(now.Add(expiringWithin)) {
+			return true
+		}
+
+		matched = append(matched, key)
+		keyInfo[key] = map[string]interface{}{
+			"token_id":   token.TokenID,
+			"role":       token.Role,
+			"username":   token.Username,
+			"issued_at":  token.IssuedAt,
+			"expires_at": token.ExpiresAt,
+		}
+		if token.RevokedInArtifactory {
+			keyInfo[key].(map[string]interface{})["revoked_in_artifactory"] = true
+		}
+		if len(token.Roles) > 0 {
+			keyInfo[key].(map[string]interface{})["roles"] = token.Roles
+		}
+		if token.ParentID != "" {
+			keyInfo[key].(map[string]interface{})["parent_id"] = token.ParentID
+		}
+		if token.RevokedWithParent {
+			keyInfo[key].(map[string]interface{})["revoked_with_parent"] = true
+		}
+		if token.RevokedForEntityLimit {
+			keyInfo[key].(map[string]interface{})["revoked_for_entity_limit"] = true
+		}
+		if token.RevokedWithRole {
+			keyInfo[key].(map[string]interface{})["revoked_with_role"] = true
+		}
+		if token.RevokedForRotation {
+			keyInfo[key].(map[string]interface{})["revoked_for_rotation"] = true
+		}
+		if token.Sequence > 0 {
+			keyInfo[key].(map[string]interface{})["sequence"] = token.Sequence
+		}
+
+		return limit == 0 || len(matched) < limit
+	}
+
+	var err error
+	if roleName != "" {
+		err = b.walkRoleTokens(ctx, req.Storage, roleName, after, add)
+	} else {
+		err = b.walkTrackedTokens(ctx, req.Storage, after, add)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponseWithInfo(matched, keyInfo), nil
+}
@@ -0,0 +1,160 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Issued tokens must be listed until their lease is revoked, and the list filters must apply.
+func TestBackend_PathTokenListTracksTokens(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(200, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for _, roleName := range []string{"role-a", "role-b"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"username": "user-" + roleName,
+				"scope":    "test-scope",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	var secrets []*logical.Secret
+	for _, roleName := range []string{"role-a", "role-a", "role-b"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/" + roleName,
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.Secret.InternalData["tracking_id"])
+		secrets = append(secrets, resp.Secret)
+	}
+
+	list := func(data map[string]interface{}) []string {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "tokens/",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		keys, _ := resp.Data["keys"].([]string)
+		return keys
+	}
+
+	assert.Len(t, list(nil), 3)
+	assert.Len(t, list(map[string]interface{}{"role": "role-a"}), 2)
+	assert.Len(t, list(map[string]interface{}{"username_prefix": "user-role-b"}), 1)
+	assert.Len(t, list(map[string]interface{}{"expiring_within": "1s"}), 0)
+
+	firstPage := list(map[string]interface{}{"limit": 2})
+	assert.Len(t, firstPage, 2)
+	assert.Len(t, list(map[string]interface{}{"limit": 2, "after": firstPage[1]}), 1)
+
+	firstRolePage := list(map[string]interface{}{"role": "role-a", "limit": 1})
+	assert.Len(t, firstRolePage, 1)
+	secondRolePage := list(map[string]interface{}{"role": "role-a", "limit": 1, "after": firstRolePage[0]})
+	assert.Len(t, secondRolePage, 1)
+	assert.NotEqual(t, firstRolePage, secondRolePage)
+	assert.Len(t, list(map[string]interface{}{"role": "role-a", "limit": 1, "after": secondRolePage[0]}), 0)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    secrets[0],
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	assert.Len(t, list(nil), 2)
+}
+
+// A role's secrets must list only the tokens issued for that role, along with the prefix of their leases.
+func TestBackend_PathRoleSecretsList(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	for _, roleName := range []string{"role-a", "role-b"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"username": "user-" + roleName,
+				"scope":    "test-scope",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	for _, roleName := range []string{"role-a", "role-a", "role-b"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/" + roleName,
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.ListOperation,
+		Path:       "roles/role-a/secrets/",
+		MountPoint: "artifactory/",
+		Storage:    config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Len(t, resp.Data["keys"], 2)
+	assert.Equal(t, "artifactory/token/role-a/", resp.Data["lease_prefix"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "roles/missing/secrets/",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+}
@@ -21,6 +21,10 @@ func (b *backend) pathUserTokenCreate() *framework.Path {
 				Type:        framework.TypeString,
 				Description: `Optional. Description for the user token.`,
 			},
+			"audience": {
+				Type:        framework.TypeString,
+				Description: `Optional. Overrides the default audience set in config/user_token. See the JFrog Artifactory REST documentation on "Create Token" for a full and up to date description.`,
+			},
 			"refreshable": {
 				Type:        framework.TypeBool,
 				Default:     false,
@@ -54,6 +58,10 @@ func (b *backend) pathUserTokenCreatePerform(ctx context.Context, req *logical.R
 	b.configMutex.RLock()
 	defer b.configMutex.RUnlock()
 
+	if resp, err := b.checkMaintenance(ctx, req.Storage); resp != nil || err != nil {
+		return resp, err
+	}
+
 	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
 	if err != nil {
 		return nil, err
@@ -65,17 +73,30 @@ func (b *backend) pathUserTokenCreatePerform(ctx context.Context, req *logical.R
 
 	go b.sendUsage(*config, "pathUserTokenCreatePerform")
 
+	if err := b.refreshStaleConfiguration(*config); err != nil {
+		return nil, err
+	}
+
+	if config.CheckHealthBeforeIssuance {
+		if err := b.checkHealth(*config); err != nil {
+			return logical.ErrorResponse("Artifactory unhealthy: %s", err), nil
+		}
+	}
+
 	userTokenConfig, err := b.fetchUserTokenConfiguration(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
 
 	role := artifactoryRole{
-		GrantType:   "client_credentials",
-		Username:    data.Get("username").(string),
-		Scope:       "applied-permissions/user",
-		MaxTTL:      b.Backend.System().MaxLeaseTTL(),
-		Description: userTokenConfig.DefaultDescription,
+		GrantType:             "client_credentials",
+		Username:              data.Get("username").(string),
+		Scope:                 "applied-permissions/user",
+		MaxTTL:                b.Backend.System().MaxLeaseTTL(),
+		Description:           userTokenConfig.DefaultDescription,
+		Audience:              userTokenConfig.Audience,
+		Refreshable:           userTokenConfig.Refreshable,
+		IncludeReferenceToken: userTokenConfig.IncludeReferenceToken,
 	}
 
 	if userTokenConfig.MaxTTL != 0 && userTokenConfig.MaxTTL < role.MaxTTL {
@@ -118,7 +139,7 @@ func (b *backend) pathUserTokenCreatePerform(ctx context.Context, req *logical.R
 		role.Description = value.(string)
 	}
 
-	resp, err := b.CreateToken(*config, role)
+	resp, err := b.CreateToken(ctx, *config, role)
 	if err != nil {
 		return nil, err
 	}
@@ -131,16 +152,26 @@ func (b *backend) pathUserTokenCreatePerform(ctx context.Context, req *logical.R
 		"username":        role.Username,
 		"description":     role.Description,
 		"reference_token": resp.ReferenceToken,
+		"revocable":       resp.Revocable,
 	}, map[string]interface{}{
 		"access_token":    resp.AccessToken,
 		"refresh_token":   resp.RefreshToken,
 		"token_id":        resp.TokenId,
 		"username":        role.Username,
 		"reference_token": resp.ReferenceToken,
+		"revocable":       resp.Revocable,
 	})
 
+	if resp.ReferenceOnly {
+		response.AddWarning(referenceOnlyWarning)
+	}
+
 	response.Secret.TTL = ttl
 	response.Secret.MaxTTL = role.MaxTTL
 
+	b.trackSecret(ctx, req, response, "")
+
+	addTLSBypassWarning(response, *config)
+
 	return response, nil
 }
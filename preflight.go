@@ -0,0 +1,55 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// preflightUsername is the user the preflight token is issued for. Artifactory creates it for the token's lifetime.
+	preflightUsername = "vault-preflight"
+
+	// preflightGroup is a group every Artifactory instance has by default, for the scope of the preflight token
+	preflightGroup = "readers"
+
+	// preflightTokenTTL bounds the preflight token, where Artifactory supports expiring revocable tokens, in case it
+	// can't be revoked
+	preflightTokenTTL = 10 * time.Minute
+)
+
+// preflightCheck creates and revokes a short-lived token with config, so an admin token that can't manage tokens is
+// found when the config is written rather than at the first issuance. It returns the privileges the admin token lacks,
+// each with the error Artifactory returned.
+func (b *backend) preflightCheck(ctx context.Context, config adminConfiguration) []string {
+	role := artifactoryRole{
+		Username:    preflightUsername,
+		Groups:      []string{preflightGroup},
+		Description: "vault-plugin-secrets-artifactory preflight check",
+		MaxTTL:      preflightTokenTTL,
+	}
+	role.Scope = b.roleScope(role)
+
+	// Expiring tokens are only used when Artifactory can make them revocable, otherwise the token never expires
+	config.UseExpiringTokens = true
+
+	token, err := b.CreateToken(ctx, config, role)
+	if err != nil {
+		return []string{fmt.Sprintf("create access tokens (%s)", err)}
+	}
+
+	err = b.RevokeToken(ctx, config, logical.Secret{
+		InternalData: map[string]interface{}{
+			"access_token": token.AccessToken,
+			"token_id":     token.TokenId,
+		},
+	})
+	if err != nil {
+		b.Logger().Warn("could not revoke the preflight token, delete it in Artifactory", "tokenId", token.TokenId, "err", err)
+		return []string{fmt.Sprintf("revoke access tokens (%s; delete the preflight token %s in Artifactory)", err, token.TokenId)}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package artifactory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// With preflight_check, config writes must be rejected naming what the admin token can't do, and accepted once it can
+// create and revoke tokens.
+func TestBackend_PreflightCheck(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	writeConfig := func() (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/admin",
+			Storage:   config.StorageView,
+			Data:      map[string]interface{}{"preflight_check": true},
+		})
+	}
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(http.StatusForbidden, `{"detail": "forbidden"}`))
+
+	resp, err := writeConfig()
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Equal(t, "preflight check failed, the admin token can't: create access tokens (could not create access token: HTTP response: forbidden)", resp.Error().Error())
+
+	var request CreateTokenRequest
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(http.StatusOK, canonicalAccessToken), nil
+		})
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(http.StatusForbidden, `{"detail": "forbidden"}`))
+
+	resp, err = writeConfig()
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "the admin token can't: revoke access tokens")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(http.StatusOK, ""))
+
+	resp, err = writeConfig()
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+	assert.Equal(t, preflightUsername, request.Username)
+	assert.Equal(t, "api:* member-of-groups:readers", request.Scope)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, true, resp.Data["preflight_check"])
+}
@@ -0,0 +1,62 @@
+package artifactory
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// remediation is the runbook action that fixes a common failure, appended to the error of error responses as
+// "(remediation:<code>: <hint>)". Automation matches the code, which never changes, while the hint is for humans and
+// may be reworded. Vault only treats responses holding nothing but an error as errors, so the remediation can't be a
+// field of its own.
+type remediation struct {
+	code string
+	hint string
+}
+
+func (r remediation) String() string {
+	return fmt.Sprintf("remediation:%s: %s", r.code, r.hint)
+}
+
+// Remediations are part of the API: add codes only along with the README, and never change or reuse one.
+var (
+	remediationRotateAdminToken   = remediation{code: "rotate_admin_token", hint: "run config/rotate, or write a new admin token to config/admin/credentials if it already expired"}
+	remediationUpgradeArtifactory = remediation{code: "upgrade_artifactory", hint: "upgrade Artifactory"}
+	remediationScopeGrammar       = remediation{code: "scope_grammar", hint: "see the scope grammar at https://www.jfrog.com/confluence/display/JFROG/JFrog+Platform+REST+API#JFrogPlatformRESTAPI-CreateToken"}
+)
+
+// remediationError is an error with a remediation. A handler returning it without a response gets an error response
+// with the remediation instead.
+type remediationError struct {
+	err         error
+	remediation remediation
+}
+
+func (e *remediationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *remediationError) Unwrap() error {
+	return e.err
+}
+
+// withRemediation attaches r to err
+func withRemediation(err error, r remediation) error {
+	return &remediationError{err: err, remediation: r}
+}
+
+// remediationResponse returns an error response with r appended to its error
+func remediationResponse(r remediation, format string, args ...interface{}) *logical.Response {
+	return logical.ErrorResponse("%s (%s)", fmt.Sprintf(format, args...), r)
+}
+
+// remediationErrorResponse turns err into an error response if it carries a remediation, or returns nil
+func remediationErrorResponse(err error) *logical.Response {
+	var remediable *remediationError
+	if !errors.As(err, &remediable) {
+		return nil
+	}
+	return remediationResponse(remediable.remediation, "%s", err.Error())
+}
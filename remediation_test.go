@@ -0,0 +1,44 @@
+package artifactory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Errors carrying a remediation must become error responses with it, however deeply they are wrapped.
+func TestBackend_RemediationErrorResponse(t *testing.T) {
+	assert.Nil(t, remediationErrorResponse(fmt.Errorf("boom")))
+
+	err := fmt.Errorf("creating the token: %w", withRemediation(fmt.Errorf("HTTP response 401"), remediationRotateAdminToken))
+	resp := remediationErrorResponse(err)
+	assert.True(t, resp.IsError())
+	assert.Equal(t, "creating the token: HTTP response 401 (remediation:rotate_admin_token: "+remediationRotateAdminToken.hint+")", resp.Error().Error())
+}
+
+// A role without scope must point at the scope grammar.
+func TestBackend_RemediationMissingScope(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"username": "test-username"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "("+remediationScopeGrammar.String()+")")
+}
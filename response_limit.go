@@ -0,0 +1,64 @@
+package artifactory
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// defaultMaxResponseSize bounds Artifactory response bodies when config/admin doesn't set max_response_size. Token,
+// version and certificate responses are a few kilobytes; anything this large is an error page from a proxy.
+const defaultMaxResponseSize = 1 << 20
+
+// responseLimitingTransport wraps an http.RoundTripper, bounding how much of each response body is read into memory.
+type responseLimitingTransport struct {
+	limit int64
+	next  http.RoundTripper
+}
+
+func (t *responseLimitingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Body = &limitedBody{
+		ReadCloser: resp.Body,
+		remaining:  t.limit,
+		limit:      t.limit,
+	}
+
+	return resp, nil
+}
+
+// limitedBody returns the first limit bytes of a response body, then an error instead of the rest
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// A body of exactly limit bytes is fine, so only fail if there is more to read
+		var probe [1]byte
+		n, err := l.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("response body from Artifactory exceeds max_response_size of %d bytes", l.limit)
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
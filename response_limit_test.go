@@ -0,0 +1,79 @@
+package artifactory
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// A huge error page from Artifactory must fail the request without being read into memory in full.
+func TestBackend_MaxResponseSize(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(502, "<html>"+strings.Repeat("proxy error ", 1000)+"</html>"))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":      "test-access-token",
+		"url":               "http://myserver.com:80/artifactory",
+		"max_response_size": 1024,
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1024, resp.Data["max_response_size"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.ErrorContains(t, err, "HTTP response 502")
+}
+
+func TestLimitedBody(t *testing.T) {
+	transport := &responseLimitingTransport{limit: 4, next: httpmock.NewMockTransport()}
+	transport.next.(*httpmock.MockTransport).RegisterResponder(http.MethodGet, "http://example.com/exact", httpmock.NewStringResponder(200, "1234"))
+	transport.next.(*httpmock.MockTransport).RegisterResponder(http.MethodGet, "http://example.com/large", httpmock.NewStringResponder(200, "12345"))
+
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get("http://example.com/exact")
+	assert.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", string(body))
+
+	resp, err = client.Get("http://example.com/large")
+	assert.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	assert.ErrorContains(t, err, "exceeds max_response_size of 4 bytes")
+	assert.Equal(t, "1234", string(body))
+}
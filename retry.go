@@ -0,0 +1,48 @@
+package artifactory
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// defaultRetryBackoff is the wait before the first retry of a call to Artifactory when config/admin sets max_retries
+// but not retry_backoff
+const defaultRetryBackoff = time.Second
+
+// maxRetryWait bounds the wait between retries, however many there are
+const maxRetryWait = time.Minute
+
+func (c adminConfiguration) retryBackoff() time.Duration {
+	if c.RetryBackoff > 0 {
+		return c.RetryBackoff
+	}
+	return defaultRetryBackoff
+}
+
+// retryingClient returns an http client making calls through transport, each attempt bounded by request_timeout, and
+// retrying failed calls as set by max_retries and retry_backoff of config
+func (b *backend) retryingClient(config adminConfiguration, transport http.RoundTripper) *http.Client {
+	attemptClient := &http.Client{
+		Transport: transport,
+		Timeout:   config.RequestTimeout,
+	}
+
+	if config.MaxRetries <= 0 {
+		return attemptClient
+	}
+
+	retryClient := retryablehttp.NewClient()
+	retryClient.HTTPClient = attemptClient
+	retryClient.Logger = b.Logger()
+	retryClient.RetryMax = config.MaxRetries
+	retryClient.RetryWaitMin = config.retryBackoff()
+	retryClient.RetryWaitMax = maxRetryWait
+	// Callers handle error statuses themselves, so the last response is returned rather than an error
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
+
+	return &http.Client{
+		Transport: &retryablehttp.RoundTripper{Client: retryClient},
+	}
+}
@@ -0,0 +1,86 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Calls failing with a 5xx must be retried up to max_retries times, and the last response returned once they run out.
+func TestBackend_RetryingClient(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	adminConfig := adminConfiguration{
+		ArtifactoryURL: "http://myserver.com:80",
+		MaxRetries:     2,
+		RetryBackoff:   time.Millisecond,
+	}
+
+	calls := 0
+	httpmock.RegisterResponder(http.MethodGet, "http://myserver.com:80/artifactory/api/system/ping",
+		func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls < 3 {
+				return httpmock.NewStringResponse(http.StatusServiceUnavailable, ""), nil
+			}
+			return httpmock.NewStringResponse(http.StatusOK, "OK"), nil
+		})
+
+	client := b.retryingClient(adminConfig, nil)
+	b.storeSetup(func(s *configSetup) { s.httpClient = client })
+	pingResp, err := b.performArtifactoryGet(adminConfig, "/artifactory/api/system/ping")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, pingResp.StatusCode)
+	assert.Equal(t, 3, calls)
+
+	calls = -10
+	pingResp, err = b.performArtifactoryGet(adminConfig, "/artifactory/api/system/ping")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, pingResp.StatusCode)
+	assert.Equal(t, -7, calls)
+
+	// Client errors are not retried, so writing the config doesn't wait on retries of the root certificate fetch
+	httpmock.RegisterResponder(http.MethodGet, "http://myserver.com:80/access/api/v1/cert/root",
+		httpmock.NewStringResponder(http.StatusNotFound, ""))
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"max_retries": -1},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"request_timeout": "30s", "max_retries": 2},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 30, resp.Data["request_timeout"])
+	assert.EqualValues(t, 2, resp.Data["max_retries"])
+	assert.EqualValues(t, defaultRetryBackoff.Seconds(), resp.Data["retry_backoff"])
+}
@@ -0,0 +1,115 @@
+package artifactory
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// tokenChildrenStoragePrefix indexes delegated tokens under their parent's tracking id, as
+// token_children/<parent>/<child>, with what is needed to revoke each child. Legacy Artifactory versions only revoke
+// tokens given the token itself, so the prefix is seal wrapped.
+const tokenChildrenStoragePrefix = "token_children/"
+
+// recordChild adds a delegated token to its parent's children
+func (b *backend) recordChild(ctx context.Context, storage logical.Storage, parentID string, childID string, internalData map[string]interface{}) error {
+	entry, err := logical.StorageEntryJSON(tokenChildrenStoragePrefix+parentID+"/"+childID, internalData)
+	if err != nil {
+		return err
+	}
+	return storage.Put(ctx, entry)
+}
+
+// revokeChildren revokes the tokens delegated from the token tracked as parentID, and their descendants. Their leases
+// outlive them, so they stay tracked, marked as revoked with their parent, until their leases are revoked. Failed
+// revocations are queued for retry.
+func (b *backend) revokeChildren(ctx context.Context, storage logical.Storage, config adminConfiguration, parentID string) error {
+	prefix := tokenChildrenStoragePrefix + parentID + "/"
+
+	keys, err := storage.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, childID := range keys {
+		entry, err := storage.Get(ctx, prefix+childID)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var internalData map[string]interface{}
+		if err := entry.DecodeJSON(&internalData); err != nil {
+			return err
+		}
+
+		if err := b.revokeChildren(ctx, storage, config, childID); err != nil {
+			return err
+		}
+
+		secret := logical.Secret{InternalData: internalData}
+
+		tokenConfig, err := b.withSecret(ctx, storage, config, internalData)
+		if err == nil {
+			revokeCtx, cancel := revokeContext(ctx)
+			err = b.RevokeToken(revokeCtx, tokenConfig, secret)
+			cancel()
+		}
+
+		if err != nil {
+			b.Logger().Warn("could not revoke child token with its parent, queued for retry", "tokenId", internalData["token_id"], "parent", parentID, "err", err)
+			if err := b.queueRevocation(context.WithoutCancel(ctx), storage, secret, err); err != nil {
+				return err
+			}
+		}
+
+		token, err := b.fetchTrackedToken(ctx, storage, childID)
+		if err != nil {
+			return err
+		}
+		if token != nil {
+			token.RevokedWithParent = true
+			if err := b.putTrackedToken(ctx, storage, childID, *token); err != nil {
+				return err
+			}
+		}
+
+		if err := storage.Delete(ctx, prefix+childID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finishRevocation cleans up after a token was revoked in Artifactory: its children are revoked, and it is removed
+// from tracking and from its parent's children. Tokens revoked with their parent stay tracked until their own lease
+// is revoked.
+func (b *backend) finishRevocation(ctx context.Context, storage logical.Storage, config adminConfiguration, internalData map[string]interface{}) error {
+	trackingID, ok := internalData["tracking_id"].(string)
+	if !ok {
+		return nil
+	}
+
+	if err := b.revokeChildren(ctx, storage, config, trackingID); err != nil {
+		return err
+	}
+
+	if parentID, ok := internalData["parent_tracking_id"].(string); ok {
+		if err := storage.Delete(ctx, tokenChildrenStoragePrefix+parentID+"/"+trackingID); err != nil {
+			return err
+		}
+	}
+
+	token, err := b.fetchTrackedToken(ctx, storage, trackingID)
+	if err != nil {
+		return err
+	}
+	if token != nil && token.RevokedWithParent {
+		return nil
+	}
+
+	return b.deleteTrackedToken(ctx, storage, trackingID)
+}
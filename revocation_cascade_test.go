@@ -0,0 +1,101 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Revoking a parent lease must revoke its delegated descendants, whose own leases then don't call Artifactory again.
+func TestBackend_RevocationCascade(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+	mockDelegationTokens()
+
+	var revoked []string
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		func(req *http.Request) (*http.Response, error) {
+			if err := req.ParseForm(); err != nil {
+				return nil, err
+			}
+			revoked = append(revoked, req.PostForm.Get("token"))
+			return httpmock.NewStringResponse(200, ""), nil
+		})
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "api:* member-of-groups:readers",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	parent := resp.Secret
+
+	var secrets []*logical.Secret
+	for _, accessToken := range []string{"access-token-1", "access-token-2"} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "delegate",
+			Storage:   config.StorageView,
+			Data:      map[string]interface{}{"access_token": accessToken, "scope": "api:*"},
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		secrets = append(secrets, resp.Secret)
+	}
+	child, grandchild := secrets[0], secrets[1]
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    parent,
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+	assert.ElementsMatch(t, []string{"access-token-1", "access-token-2", "access-token-3"}, revoked)
+
+	token, err := b.fetchTrackedToken(context.Background(), config.StorageView, child.InternalData["tracking_id"].(string))
+	assert.NoError(t, err)
+	assert.True(t, token.RevokedWithParent)
+
+	for _, secret := range []*logical.Secret{child, grandchild} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+	assert.Len(t, revoked, 3)
+
+	for _, prefix := range []string{trackedTokenStoragePrefix, tokenChildrenStoragePrefix} {
+		keys, err := config.StorageView.List(context.Background(), prefix)
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+	}
+}
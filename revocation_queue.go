@@ -0,0 +1,175 @@
+package artifactory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	revocationQueueStoragePrefix = "revocation_queue/"
+
+	// revokeTimeout bounds a single token revocation call to Artifactory
+	revokeTimeout = 30 * time.Second
+
+	// revocationBacklogWarningAge is how long a revocation may stay queued before the backlog is reported as a risk
+	revocationBacklogWarningAge = time.Hour
+)
+
+// pendingRevocation is a token revocation that didn't complete within revokeTimeout, or was interrupted by Vault
+// sealing or stepping down, and is retried by processRevocationQueue.
+type pendingRevocation struct {
+	InternalData map[string]interface{} `json:"internal_data"`
+	QueuedAt     time.Time              `json:"queued_at"`
+	Attempts     int                    `json:"attempts"`
+	LastError    string                 `json:"last_error,omitempty"`
+}
+
+// revokeContext returns a context for revoking a token on behalf of a request. Vault cancels the request context
+// on seal and step-down, which would abort the call to Artifactory mid-flight and orphan the token, so the returned
+// context ignores that cancellation and is instead bounded by revokeTimeout.
+func revokeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), revokeTimeout)
+}
+
+// shouldQueueRevocation reports whether a failed revocation should be queued for retry rather than returned to Vault:
+// it timed out, or Vault is shutting down and may not get the chance to retry the lease itself.
+func shouldQueueRevocation(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil
+}
+
+func (b *backend) queueRevocation(ctx context.Context, storage logical.Storage, secret logical.Secret, revokeErr error) error {
+	key, _ := secret.InternalData["tracking_id"].(string)
+	if key == "" {
+		var err error
+		key, err = uuid.GenerateUUID()
+		if err != nil {
+			return err
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(revocationQueueStoragePrefix+key, pendingRevocation{
+		InternalData: secret.InternalData,
+		QueuedAt:     time.Now(),
+		LastError:    revokeErr.Error(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, entry)
+}
+
+// processRevocationQueue retries queued token revocations. It runs periodically on the active node.
+func (b *backend) processRevocationQueue(ctx context.Context, req *logical.Request) error {
+	keys, err := req.Storage.List(ctx, revocationQueueStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	var oldest time.Time
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		entry, err := req.Storage.Get(ctx, revocationQueueStoragePrefix+key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var pending pendingRevocation
+		if err := entry.DecodeJSON(&pending); err != nil {
+			return err
+		}
+
+		roleConfig, err := b.withSecret(ctx, req.Storage, *config, pending.InternalData)
+		if err == nil {
+			revokeCtx, cancel := context.WithTimeout(ctx, revokeTimeout)
+			err = b.RevokeToken(revokeCtx, roleConfig, logical.Secret{InternalData: pending.InternalData})
+			cancel()
+		}
+
+		if err != nil {
+			pending.Attempts++
+			pending.LastError = err.Error()
+			b.Logger().Warn("queued token revocation failed", "tokenId", pending.InternalData["token_id"], "attempts", pending.Attempts, "err", err)
+
+			entry, err := logical.StorageEntryJSON(revocationQueueStoragePrefix+key, pending)
+			if err != nil {
+				return err
+			}
+			if err := req.Storage.Put(ctx, entry); err != nil {
+				return err
+			}
+
+			if oldest.IsZero() || pending.QueuedAt.Before(oldest) {
+				oldest = pending.QueuedAt
+			}
+			continue
+		}
+
+		if err := b.finishRevocation(ctx, req.Storage, *config, pending.InternalData); err != nil {
+			return err
+		}
+
+		if err := req.Storage.Delete(ctx, revocationQueueStoragePrefix+key); err != nil {
+			return err
+		}
+	}
+
+	if !oldest.IsZero() && time.Since(oldest) > revocationBacklogWarningAge {
+		b.Logger().Warn("revocation backlog is growing old, tokens may be orphaned in Artifactory", "oldestQueuedAt", oldest, "age", time.Since(oldest).Round(time.Second))
+	}
+
+	return nil
+}
+
+// oldestQueuedRevocation returns when the oldest queued revocation was queued, or the zero time if none are queued
+func (b *backend) oldestQueuedRevocation(ctx context.Context, storage logical.Storage) (time.Time, error) {
+	keys, err := storage.List(ctx, revocationQueueStoragePrefix)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var oldest time.Time
+	for _, key := range keys {
+		entry, err := storage.Get(ctx, revocationQueueStoragePrefix+key)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var pending pendingRevocation
+		if err := entry.DecodeJSON(&pending); err != nil {
+			return time.Time{}, err
+		}
+
+		if oldest.IsZero() || pending.QueuedAt.Before(oldest) {
+			oldest = pending.QueuedAt
+		}
+	}
+
+	return oldest, nil
+}
@@ -0,0 +1,126 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// A revocation that times out must be queued rather than failed, and retried by the periodic func until it succeeds.
+func TestBackend_RevocationQueue(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewErrorResponder(context.DeadlineExceeded))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	queued, err := config.StorageView.List(context.Background(), revocationQueueStoragePrefix)
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+
+	// Still failing: the revocation stays queued
+	err = b.processRevocationQueue(context.Background(), &logical.Request{Storage: config.StorageView})
+	assert.NoError(t, err)
+
+	queued, err = config.StorageView.List(context.Background(), revocationQueueStoragePrefix)
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token/revoke",
+		httpmock.NewStringResponder(200, ""))
+
+	err = b.processRevocationQueue(context.Background(), &logical.Request{Storage: config.StorageView})
+	assert.NoError(t, err)
+
+	queued, err = config.StorageView.List(context.Background(), revocationQueueStoragePrefix)
+	assert.NoError(t, err)
+	assert.Empty(t, queued)
+
+	tracked, err := config.StorageView.List(context.Background(), trackedTokenStoragePrefix)
+	assert.NoError(t, err)
+	assert.Empty(t, tracked)
+}
+
+// A revocation queued for longer than revocationBacklogWarningAge must be reported by the stats endpoint with a warning.
+func TestBackend_RevocationBacklogAge(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	queuedAt := time.Now().Add(-2 * time.Hour)
+	entry, err := logical.StorageEntryJSON(revocationQueueStoragePrefix+"test-token", pendingRevocation{
+		InternalData: map[string]interface{}{"token_id": "test-token"},
+		QueuedAt:     queuedAt,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, config.StorageView.Put(context.Background(), entry))
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "stats",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Len(t, resp.Warnings, 1)
+
+	queue := resp.Data["revocation_queue"].(map[string]interface{})
+	assert.Equal(t, 1, queue["count"])
+	assert.True(t, queuedAt.Equal(queue["oldest_queued_at"].(time.Time)))
+	assert.GreaterOrEqual(t, queue["oldest_age_seconds"].(int64), int64(7200))
+}
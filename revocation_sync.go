@@ -0,0 +1,74 @@
+package artifactory
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// syncRevokedTokens looks up every tracked token in Artifactory, at most once per revocation_sync_interval, and marks
+// the ones revoked there (e.g. from the Artifactory UI) or expired. Vault doesn't let a backend revoke its own leases,
+// so their leases aren't renewed anymore and are revoked when their ttl runs out.
+func (b *backend) syncRevokedTokens(ctx context.Context, req *logical.Request) error {
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	if config == nil || config.RevocationSyncInterval <= 0 || config.OfflineMode {
+		return nil
+	}
+
+	if time.Since(b.lastRevocationSync) < config.RevocationSyncInterval {
+		return nil
+	}
+	b.lastRevocationSync = time.Now()
+
+	keys, err := b.listTrackedTokens(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		token, err := b.fetchTrackedToken(ctx, req.Storage, key)
+		if err != nil {
+			return err
+		}
+		// Tokens that weren't revocable aren't persisted, so looking them up always reports them gone
+		if token == nil || token.RevokedInArtifactory || token.TokenID == "" || token.NotRevocable {
+			continue
+		}
+
+		tokenConfig, err := b.withSecret(ctx, req.Storage, *config, map[string]interface{}{
+			"role":        token.Role,
+			"config_name": token.ConfigName,
+		})
+		if err != nil {
+			b.Logger().Warn("could not look up tracked token", "tokenId", token.TokenID, "err", err)
+			continue
+		}
+
+		active, err := b.tokenActive(tokenConfig, token.TokenID)
+		if err != nil {
+			b.Logger().Warn("could not look up tracked token", "tokenId", token.TokenID, "err", err)
+			continue
+		}
+		if active {
+			continue
+		}
+
+		b.Logger().Warn("token was revoked or has expired in Artifactory, its lease will not be renewed", "tokenId", token.TokenID, "role", token.Role)
+
+		token.RevokedInArtifactory = true
+		if err := b.putTrackedToken(ctx, req.Storage, key, *token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
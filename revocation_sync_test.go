@@ -0,0 +1,94 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tracked tokens Artifactory no longer has must be marked, and listed as such. Tokens that weren't revocable aren't
+// looked up, as Artifactory never has them.
+func TestBackend_SyncRevokedTokens(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.55.6", "revision" : "75506900"}`)
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/access/api/v1/system/ping",
+		httpmock.NewStringResponder(200, "OK"))
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/access/api/v1/tokens/active-token",
+		httpmock.NewStringResponder(200, `{"token_id": "active-token", "expiry": 0}`))
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/access/api/v1/tokens/revoked-token",
+		httpmock.NewStringResponder(404, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":             "test-access-token",
+		"url":                      "http://myserver.com:80",
+		"revocation_sync_interval": "1h",
+	})
+
+	for _, tokenID := range []string{"active-token", "revoked-token"} {
+		err := b.putTrackedToken(context.Background(), config.StorageView, tokenID, trackedToken{
+			TokenID:   tokenID,
+			Role:      "test-role",
+			Username:  "test-username",
+			IssuedAt:  time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		assert.NoError(t, err)
+	}
+
+	err := b.putTrackedToken(context.Background(), config.StorageView, "short-lived-token", trackedToken{
+		TokenID:      "short-lived-token",
+		Role:         "test-role",
+		Username:     "test-username",
+		IssuedAt:     time.Now(),
+		ExpiresAt:    time.Now().Add(time.Hour),
+		NotRevocable: true,
+	})
+	assert.NoError(t, err)
+
+	err = b.periodicFunc(context.Background(), &logical.Request{Storage: config.StorageView})
+	assert.NoError(t, err)
+
+	shortLived, err := b.fetchTrackedToken(context.Background(), config.StorageView, "short-lived-token")
+	assert.NoError(t, err)
+	assert.False(t, shortLived.RevokedInArtifactory)
+	assert.Equal(t, 0, httpmock.GetCallCountInfo()["GET http://myserver.com:80/access/api/v1/tokens/short-lived-token"])
+
+	active, err := b.fetchTrackedToken(context.Background(), config.StorageView, "active-token")
+	assert.NoError(t, err)
+	assert.False(t, active.RevokedInArtifactory)
+
+	revoked, err := b.fetchTrackedToken(context.Background(), config.StorageView, "revoked-token")
+	assert.NoError(t, err)
+	assert.True(t, revoked.RevokedInArtifactory)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "tokens/",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	keyInfo := resp.Data["key_info"].(map[string]interface{})
+	assert.Equal(t, true, keyInfo["revoked-token"].(map[string]interface{})["revoked_in_artifactory"])
+	assert.NotContains(t, keyInfo["active-token"], "revoked_in_artifactory")
+
+	// Within the interval, Artifactory isn't asked again
+	err = b.periodicFunc(context.Background(), &logical.Request{Storage: config.StorageView})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET http://myserver.com:80/access/api/v1/tokens/active-token"])
+}
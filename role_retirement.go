@@ -0,0 +1,105 @@
+package artifactory
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// revokeRetiredRoleTokens revokes, in Artifactory, the outstanding tokens of roles whose revoke_at has passed. The
+// backend can't revoke the leases themselves, so the tokens stay tracked, marked as revoked with their role, and their
+// leases can't be renewed. Failed revocations are queued for retry.
+func (b *backend) revokeRetiredRoleTokens(ctx context.Context, req *logical.Request) error {
+	b.rolesMutex.RLock()
+	defer b.rolesMutex.RUnlock()
+
+	roleNames, err := req.Storage.List(ctx, "roles/")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	revoked := make(map[string]bool)
+	for _, roleName := range roleNames {
+		role, err := b.Role(ctx, req.Storage, roleName)
+		if err != nil {
+			return err
+		}
+		if role != nil && !role.RevokeAt.IsZero() && !now.Before(role.RevokeAt) {
+			revoked[roleName] = true
+		}
+	}
+
+	if len(revoked) == 0 {
+		return nil
+	}
+
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	var keys []string
+	tokens := make(map[string]*trackedToken)
+	for roleName := range revoked {
+		roleKeys, roleTokens, err := b.activeIndexedTrackedTokens(ctx, req.Storage, roleTokenIndexPrefix(roleName))
+		if err != nil {
+			return err
+		}
+		for _, trackingID := range roleKeys {
+			if tokens[trackingID] == nil {
+				keys = append(keys, trackingID)
+				tokens[trackingID] = roleTokens[trackingID]
+			}
+		}
+	}
+
+	for _, trackingID := range keys {
+		token := tokens[trackingID]
+
+		secret := logical.Secret{InternalData: map[string]interface{}{
+			"role":        token.Role,
+			"token_id":    token.TokenID,
+			"tracking_id": trackingID,
+			"config_name": token.ConfigName,
+		}}
+
+		// Legacy Artifactory versions only revoke tokens given the token itself, which isn't tracked
+		if token.TokenID == "" || !b.useNewAccessAPI() {
+			b.Logger().Warn("can't revoke token of a role past its revoke_at without its token id", "role", token.Role, "trackingId", trackingID)
+			continue
+		}
+
+		tokenConfig, err := b.withSecret(ctx, req.Storage, *config, secret.InternalData)
+		if err == nil {
+			revokeCtx, cancel := revokeContext(ctx)
+			err = b.RevokeToken(revokeCtx, tokenConfig, secret)
+			cancel()
+		}
+
+		if err != nil {
+			b.Logger().Warn("could not revoke token of a role past its revoke_at, queued for retry", "role", token.Role, "tokenId", token.TokenID, "err", err)
+			if err := b.queueRevocation(context.WithoutCancel(ctx), req.Storage, secret, err); err != nil {
+				return err
+			}
+		} else {
+			b.Logger().Info("revoked token of a role past its revoke_at", "role", token.Role, "tokenId", token.TokenID)
+		}
+
+		if err := b.revokeChildren(ctx, req.Storage, *config, trackingID); err != nil {
+			return err
+		}
+
+		token.RevokedWithRole = true
+		if err := b.putTrackedToken(ctx, req.Storage, trackingID, *token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
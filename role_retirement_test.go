@@ -0,0 +1,152 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Once retire_at passes, token requests must be refused with the retirement message.
+func TestBackend_RoleRetireAt(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/legacy",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":              "api:*",
+			"retire_at":          time.Now().Add(-time.Minute).Format(time.RFC3339),
+			"retirement_message": "use roles/ci instead",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/legacy",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "role 'legacy' was retired at")
+	assert.Contains(t, resp.Error().Error(), "use roles/ci instead")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/legacy",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, true, resp.Data["retired"])
+}
+
+func TestBackend_RoleRevokeAtConflicts(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	now := time.Now()
+	for _, data := range []map[string]interface{}{
+		{"scope": "api:*", "revoke_at": now.Format(time.RFC3339)},
+		{"scope": "api:*", "retire_at": now.Format(time.RFC3339), "revoke_at": now.Add(-time.Hour).Format(time.RFC3339)},
+		{"scope": "api:*", "retirement_message": "use roles/ci instead"},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/legacy",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError(), "%v", data)
+	}
+}
+
+// Once revoke_at passes, the outstanding tokens of the role must be revoked in Artifactory, and only those.
+func TestBackend_RoleRevokeAt(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.55.6", "revision" : "75506900"}`)
+
+	httpmock.RegisterResponder(
+		http.MethodDelete,
+		"http://myserver.com:80/access/api/v1/tokens/legacy-token",
+		httpmock.NewStringResponder(200, ""))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	now := time.Now()
+	for roleName, data := range map[string]map[string]interface{}{
+		"legacy": {
+			"scope":     "api:*",
+			"retire_at": now.Add(-time.Hour).Format(time.RFC3339),
+			"revoke_at": now.Add(-time.Minute).Format(time.RFC3339),
+		},
+		"ci": {
+			"scope": "api:*",
+		},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+
+	for tokenID, roleName := range map[string]string{"legacy-token": "legacy", "ci-token": "ci"} {
+		token := trackedToken{
+			TokenID:   tokenID,
+			Role:      roleName,
+			Username:  "test-username",
+			IssuedAt:  now.Add(-time.Hour),
+			ExpiresAt: now.Add(time.Hour),
+		}
+		assert.NoError(t, b.putTrackedToken(context.Background(), config.StorageView, tokenID, token))
+		assert.NoError(t, b.indexTrackedToken(context.Background(), config.StorageView, tokenID, token))
+	}
+
+	err := b.revokeRetiredRoleTokens(context.Background(), &logical.Request{Storage: config.StorageView})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["DELETE http://myserver.com:80/access/api/v1/tokens/legacy-token"])
+
+	legacy, err := b.fetchTrackedToken(context.Background(), config.StorageView, "legacy-token")
+	assert.NoError(t, err)
+	assert.True(t, legacy.RevokedWithRole)
+
+	ci, err := b.fetchTrackedToken(context.Background(), config.StorageView, "ci-token")
+	assert.NoError(t, err)
+	assert.False(t, ci.RevokedWithRole)
+
+	// Revoked tokens aren't revoked again
+	err = b.revokeRetiredRoleTokens(context.Background(), &logical.Request{Storage: config.StorageView})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["DELETE http://myserver.com:80/access/api/v1/tokens/legacy-token"])
+}
@@ -0,0 +1,265 @@
+package artifactory
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+)
+
+// scopePermissionActions maps the permissions a role can grant on its repositories to the action codes Artifactory
+// uses in artifact scopes
+var scopePermissionActions = map[string]string{
+	"read":     "r",
+	"annotate": "n",
+	"deploy":   "w",
+	"delete":   "d",
+	"manage":   "m",
+}
+
+// validateScopePermissions returns an error naming any permission that isn't one of scopePermissionActions
+func validateScopePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		if _, ok := scopePermissionActions[permission]; !ok {
+			known := make([]string, 0, len(scopePermissionActions))
+			for name := range scopePermissionActions {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown permission '%s', must be one of %s", permission, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+const (
+	// buildInfoRepository holds build info, and the permissions on builds are permissions on its paths. Builds of a
+	// project are in "<project key>-build-info".
+	buildInfoRepository = "artifactory-build-info"
+
+	// releaseBundlesRepository holds release bundles v1, created by Distribution
+	releaseBundlesRepository = "release-bundles"
+
+	// releaseBundlesV2Repository holds release bundles v2. Release bundles of a project are in
+	// "<project key>-release-bundles-v2".
+	releaseBundlesV2Repository = "release-bundles-v2"
+)
+
+// validateScopeNames returns an error if a build or release bundle name of field is empty or has characters of the
+// scope syntax
+func validateScopeNames(field string, names []string) error {
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, ":, \\") {
+			return fmt.Errorf("invalid %s name '%s': must not be empty or contain ':', ',', spaces or backslashes", field, name)
+		}
+	}
+	return nil
+}
+
+// appliedPermissions are the values of applied_permissions, the permission sets a token can have without naming groups
+// or roles
+var appliedPermissions = []string{"user", "admin"}
+
+// validateAppliedPermissions returns an error if value isn't one of appliedPermissions
+func validateAppliedPermissions(value string) error {
+	if !strutil.StrListContains(appliedPermissions, value) {
+		return fmt.Errorf("applied_permissions must be one of %s", strings.Join(appliedPermissions, ", "))
+	}
+	return nil
+}
+
+// projectKeyPattern matches JFrog Project keys: a lowercase letter followed by 1 to 31 lowercase letters or digits
+var projectKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9]{1,31}$`)
+
+// validateProjectRoles returns an error for an entry of projects that isn't '<project key>:<role>', or whose key or
+// role can't be written in a scope
+func validateProjectRoles(projects []string) error {
+	for _, entry := range projects {
+		key, role, found := strings.Cut(entry, ":")
+		if !found {
+			return fmt.Errorf("invalid projects entry '%s': must be '<project key>:<role>'", entry)
+		}
+		if !projectKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid project key '%s' in projects: must be a lowercase letter followed by 1 to 31 lowercase letters or digits", key)
+		}
+		if err := validateScopeNames("project role", []string{role}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// projectScopes returns the scopes granting projects, one per project key in order of appearance, listing its roles
+func projectScopes(projects []string) []string {
+	var keys []string
+	roles := make(map[string][]string)
+	for _, entry := range projects {
+		key, role, _ := strings.Cut(entry, ":")
+		if _, ok := roles[key]; !ok {
+			keys = append(keys, key)
+		}
+		roles[key] = append(roles[key], role)
+	}
+
+	scopes := make([]string, 0, len(keys))
+	for _, key := range keys {
+		scopes = append(scopes, fmt.Sprintf("applied-permissions/roles:%s:%s", key, strings.Join(strutil.RemoveDuplicatesStable(roles[key], false), ",")))
+	}
+	return scopes
+}
+
+// validateRepositoryNames returns an error if a repository is empty or has characters of the scope syntax. Repository
+// names may use '*' as a wildcard.
+func validateRepositoryNames(repositories []string) error {
+	for _, repository := range repositories {
+		if strings.TrimSpace(repository) == "" || strings.ContainsAny(repository, ":, \\/") {
+			return fmt.Errorf("invalid repositories name '%s': must not be empty or contain ':', ',', '/', spaces or backslashes", repository)
+		}
+	}
+	return nil
+}
+
+// artifactScopes returns the artifact scopes granting permissions on the paths of names in repository, e.g. builds in
+// the build info repository
+func artifactScopes(repository string, names []string, permissions []string) []string {
+	actions := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		actions = append(actions, scopePermissionActions[permission])
+	}
+
+	scopes := make([]string, 0, len(names))
+	for _, name := range names {
+		scopes = append(scopes, fmt.Sprintf("artifact:%s/%s/**:%s", repository, name, strings.Join(actions, ",")))
+	}
+	return scopes
+}
+
+// roleScope returns the scope tokens of the role are issued with: its scope, followed by the scope compiled from its
+// applied permissions, groups, projects, repositories, builds and release bundles, and their permissions, in the syntax of the connected Artifactory
+// version. Identity tokens always have
+// the user's own permissions. A path prefix requested for the token narrows the repositories to paths under it.
+func (b *backend) roleScope(role artifactoryRole) string {
+	if role.identity() {
+		return identityTokenScope
+	}
+
+	scopes := strings.Fields(role.Scope)
+
+	if len(role.AppliedPermissions) > 0 {
+		scopes = append(scopes, "applied-permissions/"+role.AppliedPermissions)
+	}
+
+	if len(role.Groups) > 0 {
+		groups := strings.Join(role.Groups, ",")
+		if b.useNewAccessAPI() {
+			scopes = append(scopes, "applied-permissions/groups:"+groups)
+		} else {
+			scopes = append(scopes, "api:*", "member-of-groups:"+groups)
+		}
+	}
+
+	scopes = append(scopes, projectScopes(role.Projects)...)
+
+	if len(role.Repositories) > 0 && len(role.Permissions) > 0 {
+		actions := make([]string, 0, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			actions = append(actions, scopePermissionActions[permission])
+		}
+		for _, repository := range role.Repositories {
+			if len(role.pathPrefix) > 0 {
+				repository += "/" + role.pathPrefix + "/**"
+			}
+			scopes = append(scopes, fmt.Sprintf("artifact:%s:%s", repository, strings.Join(actions, ",")))
+		}
+	}
+
+	if len(role.Builds) > 0 && len(role.BuildPermissions) > 0 {
+		repository := buildInfoRepository
+		if len(role.ProjectKey) > 0 {
+			repository = role.ProjectKey + "-build-info"
+		}
+		scopes = append(scopes, artifactScopes(repository, role.Builds, role.BuildPermissions)...)
+	}
+
+	// Release bundles v1 have no projects, so they are always in the global repository
+	if len(role.ReleaseBundles) > 0 && len(role.ReleaseBundlePermissions) > 0 {
+		repository := releaseBundlesV2Repository
+		if len(role.ProjectKey) > 0 {
+			repository = role.ProjectKey + "-" + releaseBundlesV2Repository
+		}
+		scopes = append(scopes, artifactScopes(releaseBundlesRepository, role.ReleaseBundles, role.ReleaseBundlePermissions)...)
+		scopes = append(scopes, artifactScopes(repository, role.ReleaseBundles, role.ReleaseBundlePermissions)...)
+	}
+
+	return strings.Join(strutil.RemoveDuplicatesStable(scopes, false), " ")
+}
+
+// scopeBeyondAdmin returns the entries of scope that the admin token of config may not be able to grant, along with
+// the admin token's scope. Admin tokens can grant any scope; other tokens only what their own scope covers, as
+// scopeCovers compares entries. Nothing is returned when the admin token's scope can't be read, e.g. for reference
+// tokens and API keys.
+func (b *backend) scopeBeyondAdmin(config adminConfiguration, scope string) (string, []string) {
+	info, err := b.inspectToken(config.AccessToken)
+	if err != nil {
+		return "", nil
+	}
+
+	normalized, err := normalizeScope(info.Scope)
+	if err != nil {
+		return "", nil
+	}
+
+	adminScope, err := parseScope(normalized)
+	if err != nil {
+		return "", nil
+	}
+
+	for _, entry := range adminScope {
+		if entry.kind == scopeKindAdmin || (entry.kind == scopeKindLegacyGroups && strutil.StrListContains(entry.names, "*")) {
+			return info.Scope, nil
+		}
+	}
+
+	var missing []string
+	for _, s := range strings.Fields(scope) {
+		entry, err := parseScopeEntry(s)
+		if err != nil || !scopeCovers(adminScope, entry) {
+			missing = append(missing, s)
+		}
+	}
+
+	return info.Scope, missing
+}
+
+// normalizePathPrefix returns a repository path prefix without leading and trailing slashes, or an error if it is
+// empty or has wildcards, relative segments or characters of the scope syntax
+func normalizePathPrefix(prefix string) (string, error) {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return "", fmt.Errorf("path prefix must not be empty")
+	}
+
+	if strings.ContainsAny(prefix, "*?:, \\") {
+		return "", fmt.Errorf("path prefix '%s' must not contain wildcards, ':', ',', spaces or backslashes", prefix)
+	}
+
+	for _, segment := range strings.Split(prefix, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("path prefix '%s' must not contain empty, '.' or '..' segments", prefix)
+		}
+	}
+
+	return prefix, nil
+}
+
+// pathPrefixAllowed reports whether prefix is one of the allowed prefixes, or a path under one of them
+func pathPrefixAllowed(allowed []string, prefix string) bool {
+	for _, a := range allowed {
+		if prefix == a || strings.HasPrefix(prefix, a+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackend_RoleScope(t *testing.T) {
+	b, _ := makeBackend(t)
+
+	role := artifactoryRole{
+		Scope:        "applied-permissions/user",
+		Groups:       []string{"readers", "ci"},
+		Repositories: []string{"libs-release", "docker-local"},
+		Permissions:  []string{"read", "deploy"},
+	}
+
+	b.setVersion("7.55.6")
+	assert.Equal(t, "applied-permissions/user applied-permissions/groups:readers,ci artifact:libs-release:r,w artifact:docker-local:r,w", b.roleScope(role))
+
+	// Before the Access API changed in 7.21.1, groups were granted with member-of-groups
+	b.setVersion("7.10.2")
+	role.Scope = "api:*"
+	assert.Equal(t, "api:* member-of-groups:readers,ci artifact:libs-release:r,w artifact:docker-local:r,w", b.roleScope(role))
+
+	assert.Equal(t, "test-scope", b.roleScope(artifactoryRole{Scope: "test-scope"}))
+}
+
+// Builds and release bundles must compile to artifact scopes on the repositories Artifactory checks their permissions
+// on, the project's own ones where there are.
+func TestBackend_RoleScopeBuildsAndReleaseBundles(t *testing.T) {
+	b, _ := makeBackend(t)
+	b.setVersion("7.55.6")
+
+	role := artifactoryRole{
+		Builds:                   []string{"app-build"},
+		BuildPermissions:         []string{"read", "deploy"},
+		ReleaseBundles:           []string{"app"},
+		ReleaseBundlePermissions: []string{"read", "deploy"},
+	}
+	assert.Equal(t, "artifact:artifactory-build-info/app-build/**:r,w artifact:release-bundles/app/**:r,w artifact:release-bundles-v2/app/**:r,w", b.roleScope(role))
+
+	role.ProjectKey = "proj"
+	assert.Equal(t, "artifact:proj-build-info/app-build/**:r,w artifact:release-bundles/app/**:r,w artifact:proj-release-bundles-v2/app/**:r,w", b.roleScope(role))
+
+	assert.NoError(t, validateScopeNames("builds", []string{"app-build", "team/*"}))
+	assert.ErrorContains(t, validateScopeNames("builds", []string{"app:build"}), "invalid builds name 'app:build'")
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	for message, data := range map[string]map[string]interface{}{
+		"builds and build_permissions must be set together":                   {"builds": "app-build"},
+		"release_bundles and release_bundle_permissions must be set together": {"release_bundles": "app", "scope": "test-scope"},
+		"unknown permission 'publish'":                                        {"builds": "app-build", "build_permissions": "publish"},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/release",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), message)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/release",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"builds":                     "app-build",
+			"build_permissions":          "read,deploy",
+			"release_bundles":            "app",
+			"release_bundle_permissions": "read,deploy",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/release",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app-build"}, resp.Data["builds"])
+	assert.Equal(t, []string{"read", "deploy"}, resp.Data["release_bundle_permissions"])
+}
+
+// Applied permissions and project roles must compile to Access scopes, with every part validated when the role is
+// written.
+func TestBackend_RoleScopeStructured(t *testing.T) {
+	b, _ := makeBackend(t)
+	b.setVersion("7.55.6")
+
+	role := artifactoryRole{
+		AppliedPermissions: "user",
+		Groups:             []string{"readers"},
+		Projects:           []string{"payments:Developer", "search:Viewer", "payments:Contributor"},
+	}
+	assert.Equal(t, "applied-permissions/user applied-permissions/groups:readers applied-permissions/roles:payments:Developer,Contributor applied-permissions/roles:search:Viewer", b.roleScope(role))
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	for message, data := range map[string]map[string]interface{}{
+		"applied_permissions must be one of user, admin": {"applied_permissions": "users"},
+		"invalid projects entry 'payments'":              {"projects": "payments"},
+		"invalid project key 'Payments'":                 {"projects": "Payments:Developer"},
+		"invalid project role name 'Release Manager'":    {"projects": "payments:Release Manager"},
+		"invalid groups name 'ci readers'":               {"groups": "ci readers"},
+		"invalid repositories name 'libs-release:local'": {"repositories": "libs-release:local", "permissions": "read"},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/structured",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), message)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/structured",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"applied_permissions": "user",
+			"projects":            "payments:Developer",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/structured",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "user", resp.Data["applied_permissions"])
+	assert.Equal(t, []string{"payments:Developer"}, resp.Data["projects"])
+}
+
+func TestValidateScopePermissions(t *testing.T) {
+	assert.NoError(t, validateScopePermissions([]string{"read", "annotate", "deploy", "delete", "manage"}))
+	assert.ErrorContains(t, validateScopePermissions([]string{"read", "write"}), "unknown permission 'write'")
+}
+
+// testAdminJWT returns a JWT admin token with scope. The scope checks read it without verifying its signature.
+func testAdminJWT(t *testing.T, scope string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "jfac@01fr1x1h805xmg0t17xhqr1v7a/users/vault",
+		"jti": "test-admin-token-id",
+		"scp": scope,
+	}).SignedString([]byte("test-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestBackend_ScopeBeyondAdmin(t *testing.T) {
+	b, _ := makeBackend(t)
+
+	config := adminConfiguration{AccessToken: testAdminJWT(t, "applied-permissions/admin")}
+	_, missing := b.scopeBeyondAdmin(config, "applied-permissions/groups:anything artifact:repo:r")
+	assert.Empty(t, missing)
+
+	config.AccessToken = testAdminJWT(t, "applied-permissions/user applied-permissions/groups:readers,ci")
+	adminScope, missing := b.scopeBeyondAdmin(config, "applied-permissions/user applied-permissions/groups:ci applied-permissions/groups:deployers applied-permissions/admin")
+	assert.Equal(t, "applied-permissions/user applied-permissions/groups:readers,ci", adminScope)
+	assert.Equal(t, []string{"applied-permissions/groups:deployers", "applied-permissions/admin"}, missing)
+
+	// The scope of reference tokens and API keys can't be read, so nothing is reported
+	config.AccessToken = "test-access-token"
+	_, missing = b.scopeBeyondAdmin(config, "applied-permissions/admin")
+	assert.Empty(t, missing)
+}
+
+// Roles checking the admin scope must be rejected on write, and issuance failures must name the missing scope.
+func TestBackend_CheckAdminScope(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(http.StatusBadRequest, `{"error_description": "bad request"}`))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": testAdminJWT(t, "applied-permissions/groups:readers"),
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username":          "test-username",
+			"scope":             "applied-permissions/groups:readers,deployers",
+			"check_admin_scope": true,
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "scope holds 'applied-permissions/groups:readers,deployers', which the admin token can't grant")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "applied-permissions/groups:readers,deployers",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.ErrorContains(t, err, "the admin token can't grant 'applied-permissions/groups:readers,deployers', its scope is 'applied-permissions/groups:readers'")
+}
@@ -0,0 +1,270 @@
+package artifactory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+)
+
+// Prefixes and entries of the Access scope grammar the backend interprets:
+//
+//	scope        = entry *( " " entry )
+//	entry        = "applied-permissions/admin" | "applied-permissions/user"
+//	             | "applied-permissions/groups:" names
+//	             | "applied-permissions/roles:" project-key ":" names
+//	             | "member-of-groups:" names          ; before Artifactory 7.21.1
+//	             | "api:*"                            ; before Artifactory 7.21.1
+//	             | "artifact:" repository-path ":" actions
+//	             | other
+//	names        = name *( "," name ) | "*"
+//	actions      = action *( "," action ) | "*"      ; action is one of r, n, w, d, m
+//
+// Entries starting with any of these prefixes must follow the grammar. Entries of other forms, such as the
+// "<service>:<resource>:<action>" scopes of other JFrog services, are passed to Artifactory as they are.
+const (
+	scopeAdmin              = "applied-permissions/admin"
+	scopeUser               = "applied-permissions/user"
+	scopeAppliedPermissions = "applied-permissions/"
+	scopeGroupsPrefix       = "applied-permissions/groups:"
+	scopeRolesPrefix        = "applied-permissions/roles:"
+	scopeLegacyGroupsPrefix = "member-of-groups:"
+	scopeLegacyAPI          = "api:*"
+	scopeLegacyAPIPrefix    = "api:"
+	scopeArtifactPrefix     = "artifact:"
+)
+
+type scopeKind int
+
+const (
+	scopeKindOther scopeKind = iota
+	scopeKindAdmin
+	scopeKindUser
+	scopeKindGroups
+	scopeKindRoles
+	scopeKindLegacyGroups
+	scopeKindLegacyAPI
+	scopeKindArtifact
+)
+
+// scopeEntry is an entry of a parsed scope
+type scopeEntry struct {
+	kind scopeKind
+
+	// names are the groups of group entries, and the roles of role entries
+	names []string
+
+	// project is the project key of role entries
+	project string
+
+	// path and actions are the repository path and action codes of artifact entries
+	path    string
+	actions []string
+
+	// raw is the entry as written, for entries of other forms
+	raw string
+}
+
+// scopeActionOrder is the order of action codes in normalized artifact entries
+var scopeActionOrder = []string{"r", "n", "w", "d", "m"}
+
+// String returns the entry in canonical form
+func (e scopeEntry) String() string {
+	switch e.kind {
+	case scopeKindAdmin:
+		return scopeAdmin
+	case scopeKindUser:
+		return scopeUser
+	case scopeKindGroups:
+		return scopeGroupsPrefix + strings.Join(e.names, ",")
+	case scopeKindRoles:
+		return scopeRolesPrefix + e.project + ":" + strings.Join(e.names, ",")
+	case scopeKindLegacyGroups:
+		return scopeLegacyGroupsPrefix + strings.Join(e.names, ",")
+	case scopeKindLegacyAPI:
+		return scopeLegacyAPI
+	case scopeKindArtifact:
+		return scopeArtifactPrefix + e.path + ":" + strings.Join(e.actions, ",")
+	}
+	return e.raw
+}
+
+// parseScope parses the space-separated entries of scope
+func parseScope(scope string) ([]scopeEntry, error) {
+	var entries []scopeEntry
+	for _, s := range strings.Fields(scope) {
+		entry, err := parseScopeEntry(s)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseScopeEntry parses a single scope entry
+func parseScopeEntry(s string) (scopeEntry, error) {
+	switch {
+	case s == scopeAdmin:
+		return scopeEntry{kind: scopeKindAdmin}, nil
+	case s == scopeUser:
+		return scopeEntry{kind: scopeKindUser}, nil
+	case strings.HasPrefix(s, scopeGroupsPrefix):
+		names, err := parseScopeNames(s, strings.TrimPrefix(s, scopeGroupsPrefix))
+		return scopeEntry{kind: scopeKindGroups, names: names}, err
+	case strings.HasPrefix(s, scopeRolesPrefix):
+		project, roles, found := strings.Cut(strings.TrimPrefix(s, scopeRolesPrefix), ":")
+		if !found || !projectKeyPattern.MatchString(project) {
+			return scopeEntry{}, fmt.Errorf("invalid scope '%s': must be '%s<project key>:<roles>'", s, scopeRolesPrefix)
+		}
+		names, err := parseScopeNames(s, roles)
+		return scopeEntry{kind: scopeKindRoles, project: project, names: names}, err
+	case strings.HasPrefix(s, scopeAppliedPermissions):
+		return scopeEntry{}, fmt.Errorf("invalid scope '%s': applied permissions must be admin, user, groups:<groups> or roles:<project key>:<roles>", s)
+	case strings.HasPrefix(s, scopeLegacyGroupsPrefix):
+		names, err := parseScopeNames(s, strings.TrimPrefix(s, scopeLegacyGroupsPrefix))
+		return scopeEntry{kind: scopeKindLegacyGroups, names: names}, err
+	case s == scopeLegacyAPI:
+		return scopeEntry{kind: scopeKindLegacyAPI}, nil
+	case strings.HasPrefix(s, scopeLegacyAPIPrefix):
+		return scopeEntry{}, fmt.Errorf("invalid scope '%s': the only api scope is '%s'", s, scopeLegacyAPI)
+	case strings.HasPrefix(s, scopeArtifactPrefix):
+		return parseArtifactScope(s)
+	}
+	return scopeEntry{kind: scopeKindOther, raw: s}, nil
+}
+
+// parseScopeNames parses the comma-separated names of entry, without duplicates. A wildcard stands for every name, so
+// it can't be listed with others.
+func parseScopeNames(entry string, list string) ([]string, error) {
+	names := strings.Split(list, ",")
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, ":\\") {
+			return nil, fmt.Errorf("invalid scope '%s': names must not be empty or contain ':' or backslashes", entry)
+		}
+	}
+
+	names = strutil.RemoveDuplicatesStable(names, false)
+	if len(names) > 1 && strutil.StrListContains(names, "*") {
+		return nil, fmt.Errorf("invalid scope '%s': '*' must not be listed with other names", entry)
+	}
+	return names, nil
+}
+
+// parseArtifactScope parses an artifact entry, with its action codes in scopeActionOrder
+func parseArtifactScope(s string) (scopeEntry, error) {
+	path, list, found := strings.Cut(strings.TrimPrefix(s, scopeArtifactPrefix), ":")
+	if !found || path == "" || strings.ContainsAny(path, ",\\") {
+		return scopeEntry{}, fmt.Errorf("invalid scope '%s': must be '%s<repository path>:<actions>'", s, scopeArtifactPrefix)
+	}
+
+	actions := strings.Split(list, ",")
+	if len(actions) == 1 && actions[0] == "*" {
+		return scopeEntry{kind: scopeKindArtifact, path: path, actions: actions}, nil
+	}
+
+	var ordered []string
+	for _, action := range scopeActionOrder {
+		if strutil.StrListContains(actions, action) {
+			ordered = append(ordered, action)
+		}
+	}
+	for _, action := range actions {
+		if !strutil.StrListContains(scopeActionOrder, action) {
+			return scopeEntry{}, fmt.Errorf("invalid scope '%s': actions must be '*' or any of %s", s, strings.Join(scopeActionOrder, ", "))
+		}
+	}
+
+	return scopeEntry{kind: scopeKindArtifact, path: path, actions: ordered}, nil
+}
+
+// normalizeScope returns scope in canonical form: single-spaced entries without duplicates, group entries of each form
+// merged into one, role entries merged per project, and artifact actions in scopeActionOrder. Entries keep the order
+// of their first appearance. It returns an error for entries that don't follow the grammar.
+func normalizeScope(scope string) (string, error) {
+	entries, err := parseScope(scope)
+	if err != nil {
+		return "", err
+	}
+
+	var merged []scopeEntry
+	for _, entry := range entries {
+		i := mergeableScopeEntry(merged, entry)
+		if i < 0 {
+			merged = append(merged, entry)
+			continue
+		}
+		names := strutil.RemoveDuplicatesStable(append(append([]string{}, merged[i].names...), entry.names...), false)
+		if strutil.StrListContains(names, "*") {
+			names = []string{"*"}
+		}
+		merged[i].names = names
+	}
+
+	normalized := make([]string, 0, len(merged))
+	for _, entry := range merged {
+		normalized = append(normalized, entry.String())
+	}
+	return strings.Join(strutil.RemoveDuplicatesStable(normalized, false), " "), nil
+}
+
+// mergeableScopeEntry returns the index of the entry of entries that entry's names can be merged into, or -1
+func mergeableScopeEntry(entries []scopeEntry, entry scopeEntry) int {
+	if entry.kind != scopeKindGroups && entry.kind != scopeKindLegacyGroups && entry.kind != scopeKindRoles {
+		return -1
+	}
+	for i, e := range entries {
+		if e.kind == entry.kind && e.project == entry.project {
+			return i
+		}
+	}
+	return -1
+}
+
+// scopeCovers reports whether the entries of granted include entry: the same entry, a group or role entry of the same
+// form and project with a wildcard or all of its names, or an artifact entry on the same path with a wildcard or all
+// of its actions
+func scopeCovers(granted []scopeEntry, entry scopeEntry) bool {
+	for _, g := range granted {
+		if g.kind != entry.kind {
+			continue
+		}
+		switch entry.kind {
+		case scopeKindGroups, scopeKindLegacyGroups, scopeKindRoles:
+			if g.project == entry.project && (strutil.StrListContains(g.names, "*") || strutil.StrListSubset(g.names, entry.names)) {
+				return true
+			}
+		case scopeKindArtifact:
+			if g.path == entry.path && (strutil.StrListContains(g.actions, "*") || strutil.StrListSubset(g.actions, entry.actions)) {
+				return true
+			}
+		default:
+			if g.String() == entry.String() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scopeContains reports whether scope covers every entry of other, as scopeCovers compares them. Scopes that don't
+// follow the grammar contain nothing.
+func scopeContains(scope string, other string) bool {
+	normalized, err := normalizeScope(scope)
+	if err != nil {
+		return false
+	}
+	granted, _ := parseScope(normalized)
+
+	entries, err := parseScope(other)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if !scopeCovers(granted, entry) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeScope(t *testing.T) {
+	for scope, normalized := range map[string]string{
+		"":                                 "",
+		"  applied-permissions/user  ":     "applied-permissions/user",
+		"applied-permissions/groups:a,b,a": "applied-permissions/groups:a,b",
+		"applied-permissions/groups:a applied-permissions/user applied-permissions/groups:b":                                             "applied-permissions/groups:a,b applied-permissions/user",
+		"applied-permissions/groups:a applied-permissions/groups:*":                                                                      "applied-permissions/groups:*",
+		"applied-permissions/roles:payments:Developer applied-permissions/roles:search:Viewer applied-permissions/roles:payments:Viewer": "applied-permissions/roles:payments:Developer,Viewer applied-permissions/roles:search:Viewer",
+		"api:* member-of-groups:readers member-of-groups:ci":                                                                             "api:* member-of-groups:readers,ci",
+		"artifact:libs-release:w,r artifact:libs-release:w,r":                                                                            "artifact:libs-release:r,w",
+		"artifact:libs-release/app/**:*":                                                                                                 "artifact:libs-release/app/**:*",
+		"system:metrics:r system:metrics:r":                                                                                              "system:metrics:r",
+	} {
+		actual, err := normalizeScope(scope)
+		assert.NoError(t, err, scope)
+		assert.Equal(t, normalized, actual, scope)
+	}
+
+	for scope, message := range map[string]string{
+		"applied-permissions/users":                 "applied permissions must be admin, user",
+		"applied-permissions/groups:":               "names must not be empty",
+		"applied-permissions/groups:a,,b":           "names must not be empty",
+		"applied-permissions/groups:a,*":            "'*' must not be listed with other names",
+		"applied-permissions/roles:Payments:Viewer": "must be 'applied-permissions/roles:<project key>:<roles>'",
+		"applied-permissions/roles:payments":        "must be 'applied-permissions/roles:<project key>:<roles>'",
+		"api:read":                                  "the only api scope is 'api:*'",
+		"artifact:libs-release":                     "must be 'artifact:<repository path>:<actions>'",
+		"artifact:libs-release:rw":                  "actions must be '*' or any of r, n, w, d, m",
+		"member-of-groups:a:b":                      "names must not be empty or contain ':'",
+	} {
+		_, err := normalizeScope(scope)
+		assert.ErrorContains(t, err, message, scope)
+	}
+}
+
+func TestScopeContains(t *testing.T) {
+	assert.True(t, scopeContains("applied-permissions/groups:a,b", "applied-permissions/groups:b"))
+	assert.True(t, scopeContains("applied-permissions/groups:a applied-permissions/groups:b", "applied-permissions/groups:a,b"))
+	assert.True(t, scopeContains("applied-permissions/groups:*", "applied-permissions/groups:a"))
+	assert.False(t, scopeContains("applied-permissions/groups:a", "member-of-groups:a"))
+	assert.True(t, scopeContains("applied-permissions/roles:payments:Developer,Viewer", "applied-permissions/roles:payments:Viewer"))
+	assert.False(t, scopeContains("applied-permissions/roles:payments:Viewer", "applied-permissions/roles:search:Viewer"))
+	assert.True(t, scopeContains("artifact:libs-release:r,w", "artifact:libs-release:r"))
+	assert.False(t, scopeContains("artifact:libs-release:r", "artifact:libs-release:r,w"))
+	assert.True(t, scopeContains("system:metrics:r", "system:metrics:r"))
+	assert.False(t, scopeContains("applied-permissions/users", "applied-permissions/users"))
+}
+
+// Role scopes must be stored in canonical form, and scopes that don't follow the grammar rejected.
+func TestBackend_RoleScopeNormalized(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"scope":           "applied-permissions/groups:readers  applied-permissions/groups:ci",
+			"escalated_scope": "artifact:libs-release:w,r",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "applied-permissions/groups:readers,ci", resp.Data["scope"])
+	assert.Equal(t, "artifact:libs-release:r,w", resp.Data["escalated_scope"])
+
+	for _, data := range []map[string]interface{}{
+		{"scope": "applied-permissions/group:readers"},
+		{"scope": "test-scope", "escalated_scope": "artifact:libs-release"},
+	} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/test-role",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError(), "%v", data)
+		assert.Contains(t, resp.Error().Error(), "("+remediationScopeGrammar.String()+")")
+	}
+}
@@ -0,0 +1,60 @@
+#!/bin/bash
+set -e
+
+############################################################################
+# shell script for revoking access tokens left behind by acceptance tests  #
+#                                                                          #
+# Acceptance test runs tag the tokens they create with a description or   #
+# change_ref of "vault-acc-test-<run id>". Runs are cleaned up as they go, #
+# but an interrupted run against a shared instance (e.g. JFrog SaaS) can   #
+# leave tokens behind; this revokes every token carrying the tag.          #
+#                                                                          #
+# WARNING: This script is designed as a test script!                       #
+# Use otherwise at your own peril!                                         #
+#                                                                          #
+# Globals (env variables)                                                  #
+#     JFROG_URL                - artifactory base url                      #
+#     JFROG_ACCESS_TOKEN       - admin access token                        #
+#     TEST_TAG                 - description tag to match                  #
+############################################################################
+
+# defaulted variables
+JFROG_URL="${JFROG_URL:-http://localhost:8082}"
+TEST_TAG="${TEST_TAG:-vault-acc-test-}"
+
+if [ -z "${JFROG_ACCESS_TOKEN}" ]; then
+    echo "JFROG_ACCESS_TOKEN must be set" >&2
+    exit 1
+fi
+
+# function to list access tokens
+listTokens() {
+    curl "${JFROG_URL}/access/api/v1/tokens" \
+    --fail \
+    --silent \
+    --show-error \
+    --location \
+    --header "Authorization: Bearer ${JFROG_ACCESS_TOKEN}"
+}
+
+# function to revoke an access token by id
+revokeToken() {
+    curl "${JFROG_URL}/access/api/v1/tokens/$1" \
+    --request DELETE \
+    --fail \
+    --silent \
+    --show-error \
+    --location \
+    --header "Authorization: Bearer ${JFROG_ACCESS_TOKEN}"
+}
+
+echo "Listing access tokens on ${JFROG_URL} tagged ${TEST_TAG} ..." >&2
+token_ids=$(listTokens | jq -r --arg tag "${TEST_TAG}" '.tokens[] | select((.description // "") | contains($tag)) | .token_id') || {
+    echo "Failed to list access tokens" >&2
+    exit 1
+}
+
+for token_id in ${token_ids}; do
+    echo "Revoking ${token_id}" >&2
+    revokeToken "${token_id}" || echo "Failed to revoke ${token_id}" >&2
+done
@@ -3,6 +3,7 @@ package artifactory
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -49,6 +50,26 @@ func (b *backend) secretAccessTokenRenew(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("lease cannot be renewed")
 	}
 
+	// Don't extend leases of tokens that were revoked from Artifactory or have expired there. The lease is then revoked
+	// when its current ttl runs out, which succeeds for tokens Artifactory no longer has. Tokens that weren't revocable
+	// aren't persisted, so Artifactory can't look them up, and leases of older versions don't record it.
+	roleConfig, err := b.withSecret(ctx, req.Storage, *config, req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenId, _ := req.Secret.InternalData["token_id"].(string)
+	if revocable, ok := req.Secret.InternalData["revocable"].(bool); !ok || revocable {
+		active, err := b.tokenActive(roleConfig, tokenId)
+		if err != nil {
+			// Artifactory being unreachable isn't a reason to let the lease expire
+			b.Logger().Warn("could not look up token in Artifactory, renewing its lease anyway", "tokenId", tokenId, "err", err)
+		} else if !active {
+			b.Logger().Warn("not renewing lease of token revoked or expired in Artifactory", "tokenId", tokenId)
+			return nil, fmt.Errorf("lease cannot be renewed: token %s was revoked or has expired in Artifactory", tokenId)
+		}
+	}
+
 	role, err := b.Role(ctx, req.Storage, req.Secret.InternalData["role"].(string))
 	if err != nil {
 		return nil, fmt.Errorf("error during renew: could not get role: %q", req.Secret.InternalData["role"])
@@ -57,6 +78,8 @@ func (b *backend) secretAccessTokenRenew(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("error during renew: could not find role with name: %q", req.Secret.InternalData["role"])
 	}
 
+	role.clampTTLs(*config)
+
 	ttl, warnings, err :=
 		framework.CalculateTTL(b.System(), req.Secret.Increment, role.DefaultTTL, 0, role.MaxTTL, req.Secret.MaxTTL, req.Secret.IssueTime)
 	if err != nil {
@@ -70,6 +93,19 @@ func (b *backend) secretAccessTokenRenew(ctx context.Context, req *logical.Reque
 
 	resp.Secret.TTL = ttl
 
+	if trackingID, ok := req.Secret.InternalData["tracking_id"].(string); ok {
+		token, err := b.fetchTrackedToken(ctx, req.Storage, trackingID)
+		if err != nil {
+			return nil, err
+		}
+		if token != nil {
+			token.ExpiresAt = time.Now().Add(ttl)
+			if err := b.putTrackedToken(ctx, req.Storage, trackingID, *token); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return resp, nil
 }
 
@@ -84,7 +120,48 @@ func (b *backend) secretAccessTokenRevoke(ctx context.Context, req *logical.Requ
 		return logical.ErrorResponse("backend not configured"), nil
 	}
 
-	if err := b.RevokeToken(*config, *req.Secret); err != nil {
+	// Tokens revoked along with the token they were delegated from are already gone from Artifactory
+	if trackingID, ok := req.Secret.InternalData["tracking_id"].(string); ok {
+		token, err := b.fetchTrackedToken(ctx, req.Storage, trackingID)
+		if err != nil {
+			return nil, err
+		}
+		if token != nil && token.RevokedWithParent {
+			return nil, b.deleteTrackedToken(ctx, req.Storage, trackingID)
+		}
+
+		// Other leases may still hand out the current token of a group role
+		if groupToken, _ := req.Secret.InternalData["group_token"].(bool); groupToken {
+			shared, err := b.releaseGroupToken(ctx, req.Storage, req.Secret.InternalData["role"].(string), trackingID)
+			if err != nil || shared {
+				return nil, err
+			}
+		}
+	}
+
+	roleConfig, err := b.withSecret(ctx, req.Storage, *config, req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+
+	revokeCtx, cancel := revokeContext(ctx)
+	defer cancel()
+
+	if err := b.RevokeToken(revokeCtx, roleConfig, *req.Secret); err != nil {
+		if !shouldQueueRevocation(ctx, err) {
+			return nil, err
+		}
+
+		// Storage writes must also outlive a cancelled request context, or the token is orphaned anyway
+		if err := b.queueRevocation(context.WithoutCancel(ctx), req.Storage, *req.Secret, err); err != nil {
+			return nil, err
+		}
+
+		b.Logger().Warn("token revocation did not complete, queued for retry", "tokenId", req.Secret.InternalData["token_id"], "err", err)
+		return nil, nil
+	}
+
+	if err := b.finishRevocation(ctx, req.Storage, *config, req.Secret.InternalData); err != nil {
 		return nil, err
 	}
 
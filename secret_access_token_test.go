@@ -0,0 +1,121 @@
+package artifactory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// A lease must not be renewed once Artifactory reports its token gone, and revoking it must then succeed. Failed
+// lookups, and tokens that weren't revocable, which Artifactory can't look up, don't stop renewals.
+func TestBackend_RenewRevokedToken(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests(`{"version" : "7.55.6", "revision" : "75506900"}`)
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		"http://myserver.com:80/access/api/v1/system/ping",
+		httpmock.NewStringResponder(200, "OK"))
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/access/api/v1/tokens",
+		httpmock.NewStringResponder(200, jwtAccessToken))
+
+	tokenURL := "http://myserver.com:80/access/api/v1/tokens/59e39159-19eb-463d-953d-1d6baf567db6"
+
+	httpmock.RegisterResponder(
+		http.MethodGet,
+		tokenURL,
+		httpmock.NewStringResponder(200, `{"token_id": "59e39159-19eb-463d-953d-1d6baf567db6", "expiry": 0}`))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test-role",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"username": "test-username",
+			"scope":    "test-scope",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "token/test-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+
+	secret := resp.Secret
+	secret.IssueTime = time.Now()
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    secret,
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, true, secret.InternalData["revocable"])
+
+	httpmock.RegisterResponder(http.MethodGet, tokenURL, httpmock.NewStringResponder(503, ""))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    secret,
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+
+	// Revoked from the Artifactory UI
+	httpmock.RegisterResponder(http.MethodGet, tokenURL, httpmock.NewStringResponder(404, ""))
+	httpmock.RegisterResponder(http.MethodDelete, tokenURL, httpmock.NewStringResponder(404, ""))
+
+	notRevocable := *secret
+	notRevocable.InternalData = map[string]interface{}{}
+	for k, v := range secret.InternalData {
+		notRevocable.InternalData[k] = v
+	}
+	notRevocable.InternalData["revocable"] = false
+	lookups := httpmock.GetCallCountInfo()["GET "+tokenURL]
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    &notRevocable,
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, lookups, httpmock.GetCallCountInfo()["GET "+tokenURL])
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    secret,
+		Storage:   config.StorageView,
+	})
+	assert.ErrorContains(t, err, "was revoked or has expired in Artifactory")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    secret,
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,166 @@
+package artifactory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// configSourceShared is the source of a config/admin referencing the one of another mount
+const configSourceShared = "shared"
+
+// errSharedConfigNotLoaded is returned while the mount a config/admin references isn't loaded, e.g. while the mounts
+// of a Vault server are being set up
+var errSharedConfigNotLoaded = errors.New("shared_config: the referenced mount is not loaded")
+
+// sharedConfigOwners are the backends served by this plugin process, by the uuid of their mount. The plugin is
+// multiplexed, so every mount of it on a Vault server is served by the same process, and a mount can read the
+// config/admin of another one, if that one allows it with shared_with.
+var sharedConfigOwners = struct {
+	sync.RWMutex
+	backends map[string]*backend
+}{backends: make(map[string]*backend)}
+
+// registerSharedConfigOwner lets mounts referencing the mount of conf find its config/admin
+func (b *backend) registerSharedConfigOwner(conf *logical.BackendConfig) {
+	if conf == nil || conf.BackendUUID == "" || conf.StorageView == nil {
+		return
+	}
+
+	b.backendUUID = conf.BackendUUID
+	b.storageView = conf.StorageView
+
+	sharedConfigOwners.Lock()
+	defer sharedConfigOwners.Unlock()
+	sharedConfigOwners.backends[b.backendUUID] = b
+}
+
+// clean removes the backend from the shared config owners when its mount is unloaded
+func (b *backend) clean(_ context.Context) {
+	if b.backendUUID == "" {
+		return
+	}
+
+	sharedConfigOwners.Lock()
+	defer sharedConfigOwners.Unlock()
+	if sharedConfigOwners.backends[b.backendUUID] == b {
+		delete(sharedConfigOwners.backends, b.backendUUID)
+	}
+}
+
+// resolveSharedConfiguration returns the config/admin of the mount with uuid ownerUUID, as shared with this backend's
+// mount. It is read from the owner's storage for every call, so rotations and changes there apply at once.
+func (b *backend) resolveSharedConfiguration(ctx context.Context, ownerUUID string) (*adminConfiguration, error) {
+	sharedConfigOwners.RLock()
+	owner, ok := sharedConfigOwners.backends[ownerUUID]
+	sharedConfigOwners.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: no mount of this plugin with uuid '%s' is loaded on this Vault server", errSharedConfigNotLoaded, ownerUUID)
+	}
+
+	entry, err := owner.storageView.Get(ctx, "config/admin")
+	if err != nil {
+		return nil, fmt.Errorf("shared_config: could not read the config of mount '%s': %w", ownerUUID, err)
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("shared_config: mount '%s' is not configured", ownerUUID)
+	}
+
+	var config adminConfiguration
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	if config.SharedConfig != "" {
+		return nil, fmt.Errorf("shared_config: mount '%s' references another mount's config itself", ownerUUID)
+	}
+
+	if b.backendUUID == "" || !strutil.StrListContains(config.SharedWith, b.backendUUID) {
+		return nil, fmt.Errorf("shared_config: mount '%s' doesn't list this mount's uuid '%s' in shared_with", ownerUUID, b.backendUUID)
+	}
+
+	namespace, known := b.namespace()
+	if !known {
+		return nil, fmt.Errorf("%w: this mount's namespace is only known once it serves a request", errSharedConfigNotLoaded)
+	}
+
+	if config.Namespace != namespace {
+		return nil, fmt.Errorf("shared_config: mount '%s' is in namespace '%s', not in this mount's namespace '%s'", ownerUUID, config.Namespace, namespace)
+	}
+
+	config.sharedFrom = ownerUUID
+	return &config, nil
+}
+
+// mountNamespace returns the namespace of the mount served at mountPoint. Vault doesn't tell plugins the namespace of
+// their mounts, but serves them at their full path, so it is the path before the mount's own name, e.g. "team-a/" for
+// "team-a/artifactory/". Mounts at nested paths, e.g. "platform/artifactory/", are taken to be in a namespace of their
+// own, which can only refuse references within a namespace, never allow them across namespaces.
+func mountNamespace(mountPoint string) string {
+	trimmed := strings.TrimSuffix(mountPoint, "/")
+	i := strings.LastIndex(trimmed, "/")
+	if i < 0 {
+		return ""
+	}
+	return trimmed[:i+1]
+}
+
+// namespace returns the namespace of the backend's mount, and whether it is known, which it is once the backend has
+// served a request
+func (b *backend) namespace() (string, bool) {
+	b.userAgentMutex.Lock()
+	defer b.userAgentMutex.Unlock()
+
+	return mountNamespace(b.mountPoint), b.mountPoint != ""
+}
+
+// sharedConfigResponse refuses changes to a config/admin referencing another mount's, which belongs to that mount
+func sharedConfigResponse(config adminConfiguration) *logical.Response {
+	return logical.ErrorResponse("the configuration is shared from mount '%s', change it there, or delete this mount's config/admin first", config.sharedFrom)
+}
+
+// referenceSharedConfiguration makes config/admin reference the config of the mount with uuid ownerUUID, which must
+// share it with this mount
+func (b *backend) referenceSharedConfiguration(ctx context.Context, req *logical.Request, data *framework.FieldData, config *adminConfiguration, ownerUUID string) (*logical.Response, error) {
+	if len(data.Raw) > 1 {
+		return logical.ErrorResponse("shared_config can't be combined with other parameters, the shared configuration is changed on its mount"), nil
+	}
+
+	if ownerUUID == "" {
+		return logical.ErrorResponse("shared_config must not be empty, delete config/admin to stop using a shared configuration"), nil
+	}
+
+	if config != nil && config.sharedFrom == "" && !config.fromEnv {
+		return logical.ErrorResponse("the mount has its own configuration, delete config/admin before referencing another mount's"), nil
+	}
+
+	if ownerUUID == b.backendUUID {
+		return logical.ErrorResponse("shared_config must be another mount's uuid"), nil
+	}
+
+	shared, err := b.resolveSharedConfiguration(ctx, ownerUUID)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Set up the http client, version and username template from the shared config. On failure they stay stale, so
+	// they are set up again from the stored config on next use.
+	b.invalidate(ctx, "config/admin")
+	if err := b.refreshStaleConfiguration(*shared); err != nil {
+		return logical.ErrorResponse("the shared configuration doesn't work: %s", err), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config/admin", adminConfiguration{SharedConfig: ownerUUID})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, req.Storage.Put(ctx, entry)
+}
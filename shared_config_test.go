@@ -0,0 +1,199 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeMountedBackend returns a backend for a mount with uuid
+func makeMountedBackend(t *testing.T, uuid string) (*backend, *logical.BackendConfig) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.BackendUUID = uuid
+
+	b, err := Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Cleanup(context.Background()) })
+
+	return b, config
+}
+
+// A mount must be able to use the config of a mount listing it in shared_with, and only change it there.
+func TestBackend_SharedConfig(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	owner, ownerConfig := makeMountedBackend(t, "owner-uuid")
+	team, teamConfig := makeMountedBackend(t, "team-uuid")
+	other, otherConfig := makeMountedBackend(t, "other-uuid")
+
+	resp, err := owner.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "config/admin",
+		MountPoint: "artifactory/",
+		Storage:    ownerConfig.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "test-access-token",
+			"url":          "http://myserver.com:80/artifactory",
+			"shared_with":  "team-uuid",
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+
+	resp, err = team.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "config/admin",
+		MountPoint: "artifactory-team/",
+		Storage:    teamConfig.StorageView,
+		Data:       map[string]interface{}{"shared_config": "owner-uuid"},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	config, err := team.fetchAdminConfiguration(context.Background(), teamConfig.StorageView)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-access-token", config.AccessToken)
+
+	resp, err = team.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.ReadOperation,
+		Path:       "config/admin",
+		MountPoint: "artifactory-team/",
+		Storage:    teamConfig.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, configSourceShared, resp.Data["source"])
+	assert.Equal(t, "owner-uuid", resp.Data["shared_config"])
+	assert.Equal(t, "http://myserver.com:80/artifactory", resp.Data["url"])
+
+	for _, path := range []string{"config/admin", "config/admin/credentials", "config/rotate"} {
+		resp, err = team.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       path,
+			MountPoint: "artifactory-team/",
+			Storage:    teamConfig.StorageView,
+			Data:       map[string]interface{}{"access_token": "team-access-token"},
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError(), path)
+		assert.Contains(t, resp.Error().Error(), "shared from mount 'owner-uuid'", path)
+	}
+
+	// Not listed in shared_with
+	resp, err = other.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "config/admin",
+		MountPoint: "artifactory-other/",
+		Storage:    otherConfig.StorageView,
+		Data:       map[string]interface{}{"shared_config": "owner-uuid"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "shared_with")
+
+	// Removing the mount from shared_with takes effect at once
+	resp, err = owner.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "config/admin",
+		MountPoint: "artifactory/",
+		Storage:    ownerConfig.StorageView,
+		Data:       map[string]interface{}{"shared_with": ""},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	_, err = team.fetchAdminConfiguration(context.Background(), teamConfig.StorageView)
+	assert.Error(t, err)
+}
+
+func TestBackend_SharedConfigUnknownMount(t *testing.T) {
+	b, config := makeMountedBackend(t, "team-uuid")
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"shared_config": "missing-uuid"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "is loaded on this Vault server")
+}
+
+// A mount must not be able to use the config of a mount in another namespace, even when listed in its shared_with.
+func TestBackend_SharedConfigOtherNamespace(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	owner, ownerConfig := makeMountedBackend(t, "owner-uuid")
+	team, teamConfig := makeMountedBackend(t, "team-uuid")
+
+	resp, err := owner.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "config/admin",
+		MountPoint: "ns-a/artifactory/",
+		Storage:    ownerConfig.StorageView,
+		Data: map[string]interface{}{
+			"access_token": "test-access-token",
+			"url":          "http://myserver.com:80/artifactory",
+			"shared_with":  "team-uuid",
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.IsError())
+
+	resp, err = team.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "config/admin",
+		MountPoint: "ns-b/artifactory/",
+		Storage:    teamConfig.StorageView,
+		Data:       map[string]interface{}{"shared_config": "owner-uuid"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "is in namespace 'ns-a/', not in this mount's namespace 'ns-b/'")
+}
+
+func TestMountNamespace(t *testing.T) {
+	assert.Equal(t, "", mountNamespace("artifactory/"))
+	assert.Equal(t, "team-a/", mountNamespace("team-a/artifactory/"))
+	assert.Equal(t, "team-a/child/", mountNamespace("team-a/child/artifactory/"))
+}
+
+// A mount must only be found by other mounts once its setup succeeded, and no longer once it is cleaned up.
+func TestBackend_SharedConfigRegisteredAfterSetup(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.BackendUUID = "setup-uuid"
+
+	registered := func() bool {
+		sharedConfigOwners.RLock()
+		defer sharedConfigOwners.RUnlock()
+		_, ok := sharedConfigOwners.backends["setup-uuid"]
+		return ok
+	}
+
+	b, err := Backend(config)
+	assert.NoError(t, err)
+	assert.False(t, registered())
+
+	assert.NoError(t, b.Setup(context.Background(), config))
+	assert.True(t, registered())
+
+	b.Cleanup(context.Background())
+	assert.False(t, registered())
+}
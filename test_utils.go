@@ -8,6 +8,7 @@ import (
 	"time"
 
 	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/sdk/helper/logging"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/jarcoal/httpmock"
@@ -22,6 +23,12 @@ type accTestEnv struct {
 	AccessToken string
 	URL         string
 
+	// AdminUsername is the user the admin token belongs to: "admin" for self-hosted instances, usually the
+	// account's email address on JFrog SaaS
+	AdminUsername string
+	// RunID tags Artifactory objects created by this test run, so leftovers can be found and cleaned up
+	RunID string
+
 	Backend logical.Backend
 	Context context.Context
 	Storage logical.Storage
@@ -38,9 +45,10 @@ func (e *accTestEnv) createNewTestToken(t *testing.T) (string, string) {
 	}
 
 	role := artifactoryRole{
-		GrantType: "client_credentials",
-		Username:  "admin",
-		Scope:     "applied-permissions/admin",
+		GrantType:   "client_credentials",
+		Username:    e.AdminUsername,
+		Scope:       "applied-permissions/admin",
+		Description: e.RunID,
 	}
 
 	e.Backend.(*backend).InitializeHttpClient(&config)
@@ -50,7 +58,7 @@ func (e *accTestEnv) createNewTestToken(t *testing.T) (string, string) {
 		t.Fatal(err)
 	}
 
-	resp, err := e.Backend.(*backend).CreateToken(config, role)
+	resp, err := e.Backend.(*backend).CreateToken(context.Background(), config, role)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -67,9 +75,10 @@ func (e *accTestEnv) createNewNonAdminTestToken(t *testing.T) (string, string) {
 	}
 
 	role := artifactoryRole{
-		GrantType: "client_credentials",
-		Username:  "notTheAdmin",
-		Scope:     "applied-permissions/groups:readers",
+		GrantType:   "client_credentials",
+		Username:    "notTheAdmin",
+		Scope:       "applied-permissions/groups:readers",
+		Description: e.RunID,
 	}
 
 	err := e.Backend.(*backend).getVersion(config)
@@ -77,7 +86,7 @@ func (e *accTestEnv) createNewNonAdminTestToken(t *testing.T) (string, string) {
 		t.Fatal(err)
 	}
 
-	resp, err := e.Backend.(*backend).CreateToken(config, role)
+	resp, err := e.Backend.(*backend).CreateToken(context.Background(), config, role)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -103,24 +112,35 @@ func (e *accTestEnv) revokeTestToken(t *testing.T, accessToken string, tokenID s
 		},
 	}
 
-	err = e.Backend.(*backend).RevokeToken(config, secret)
+	err = e.Backend.(*backend).RevokeToken(context.Background(), config, secret)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
 func (e *accTestEnv) UpdatePathConfig(t *testing.T) {
-	e.UpdateConfigAdmin(t, testData{
+	e.UpdateConfigAdminCredentials(t, testData{
 		"access_token": e.AccessToken,
 		"url":          e.URL,
 	})
+	e.UpdateConfigAdmin(t, testData{
+		"use_expiring_tokens": true,
+	})
 }
 
-// UpdateConfigAdmin will send a POST/PUT to the /config/admin endpoint with testData (vault write artifactory/config/admin)
+// UpdateConfigAdmin will send a POST/PUT to the /config/admin endpoint with testData (vault write artifactory/config/admin).
+// Warnings, e.g. about deprecated parameters, are allowed.
 func (e *accTestEnv) UpdateConfigAdmin(t *testing.T, data testData) {
 	resp, err := e.update("config/admin", data)
 	assert.NoError(t, err)
-	assert.Nil(t, resp)
+	assert.True(t, resp == nil || !resp.IsError(), "unexpected error response: %v", resp)
+}
+
+// UpdateConfigAdminCredentials will send a POST/PUT to the /config/admin/credentials endpoint with testData (vault write artifactory/config/admin/credentials)
+func (e *accTestEnv) UpdateConfigAdminCredentials(t *testing.T, data testData) {
+	resp, err := e.update("config/admin/credentials", data)
+	assert.NoError(t, err)
+	assert.True(t, resp == nil || !resp.IsError(), "unexpected error response: %v", resp)
 }
 
 // UpdateConfigAdmin will send a POST/PUT to the /config/user_token endpoint with testData (vault write artifactory/config/user_token)
@@ -138,6 +158,16 @@ func (e *accTestEnv) ReadPathConfig(t *testing.T) {
 func (e *accTestEnv) ReadConfigAdmin(t *testing.T) testData {
 	resp, err := e.read("config/admin")
 
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.NotEmpty(t, resp.Data["url"])
+	return resp.Data
+}
+
+// ReadConfigAdminCredentials will send a GET to the /config/admin/credentials endpoint (vault read artifactory/config/admin/credentials)
+func (e *accTestEnv) ReadConfigAdminCredentials(t *testing.T) testData {
+	resp, err := e.read("config/admin/credentials")
+
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
 	assert.NotEmpty(t, resp.Data["access_token_sha256"])
@@ -198,7 +228,7 @@ func (e *accTestEnv) update(path string, data testData) (*logical.Response, erro
 func (e *accTestEnv) CreatePathRole(t *testing.T) {
 	roleData := map[string]interface{}{
 		"role":                    "test-role",
-		"username":                "admin",
+		"username":                e.AdminUsername,
 		"scope":                   "applied-permissions/user",
 		"audience":                "*@*",
 		"refreshable":             true,
@@ -228,7 +258,7 @@ func (e *accTestEnv) ReadPathRole(t *testing.T) {
 	assert.NotNil(t, resp)
 	assert.NoError(t, err)
 
-	assert.EqualValues(t, "admin", resp.Data["username"])
+	assert.EqualValues(t, e.AdminUsername, resp.Data["username"])
 	assert.EqualValues(t, "applied-permissions/user", resp.Data["scope"])
 	assert.EqualValues(t, "*@*", resp.Data["audience"])
 	assert.EqualValues(t, true, resp.Data["refreshable"])
@@ -253,13 +283,17 @@ func (e *accTestEnv) CreatePathToken(t *testing.T) {
 		Operation: logical.ReadOperation,
 		Path:      "token/test-role",
 		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"change_ref": e.RunID,
+		},
 	})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
+	e.revokeOnCleanup(t, resp)
 	assert.NotEmpty(t, resp.Data["access_token"])
 	assert.NotEmpty(t, resp.Data["token_id"])
-	assert.Equal(t, "admin", resp.Data["username"])
+	assert.Equal(t, e.AdminUsername, resp.Data["username"])
 	assert.Equal(t, "test-role", resp.Data["role"])
 	assert.Equal(t, "applied-permissions/user", resp.Data["scope"])
 	assert.NotEmpty(t, resp.Data["refresh_token"])
@@ -267,12 +301,15 @@ func (e *accTestEnv) CreatePathToken(t *testing.T) {
 }
 
 func (e *accTestEnv) CreatePathUserToken(t *testing.T) {
+	// Tagged with the run id, so cleanupAcceptanceTestTokens.sh finds the token if the run is interrupted
+	description := "buffalo " + e.RunID
+
 	resp, err := e.Backend.HandleRequest(context.Background(), &logical.Request{
 		Operation: logical.ReadOperation,
-		Path:      "user_token/admin",
+		Path:      "user_token/" + e.AdminUsername,
 		Storage:   e.Storage,
 		Data: map[string]interface{}{
-			"description":             "buffalo",
+			"description":             description,
 			"refreshable":             true,
 			"include_reference_token": true,
 		},
@@ -280,18 +317,39 @@ func (e *accTestEnv) CreatePathUserToken(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
+	e.revokeOnCleanup(t, resp)
 	assert.NotEmpty(t, resp.Data["access_token"])
 	assert.NotEmpty(t, resp.Data["token_id"])
-	assert.Equal(t, "admin", resp.Data["username"])
+	assert.Equal(t, e.AdminUsername, resp.Data["username"])
 	assert.Equal(t, "applied-permissions/user", resp.Data["scope"])
-	assert.Equal(t, "buffalo", resp.Data["description"])
+	assert.Equal(t, description, resp.Data["description"])
 	assert.NotEmpty(t, resp.Data["refresh_token"])
 	assert.NotEmpty(t, resp.Data["reference_token"])
 }
 
+// revokeOnCleanup revokes the lease of a token issued by the backend when the (sub)test completes, so test runs
+// against shared instances such as JFrog SaaS don't leave tokens behind
+func (e *accTestEnv) revokeOnCleanup(t *testing.T, resp *logical.Response) {
+	if resp == nil || resp.Secret == nil {
+		return
+	}
+
+	secret := resp.Secret
+	t.Cleanup(func() {
+		_, err := e.Backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Storage:   e.Storage,
+		})
+		if err != nil {
+			t.Logf("could not revoke token %v tagged %s: %s", secret.InternalData["token_id"], e.RunID, err)
+		}
+	})
+}
+
 // Cleanup will delete the admin configuration and revoke the token
 func (e *accTestEnv) Cleanup(t *testing.T) {
-	data := e.ReadConfigAdmin(t)
+	data := e.ReadConfigAdminCredentials(t)
 	e.DeleteConfigAdmin(t)
 
 	// revoke the test token
@@ -309,12 +367,25 @@ func newAcceptanceTestEnv() (*accTestEnv, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	adminUsername := os.Getenv("JFROG_ADMIN_USERNAME")
+	if adminUsername == "" {
+		adminUsername = "admin"
+	}
+
+	runID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
 	return &accTestEnv{
-		AccessToken: os.Getenv("JFROG_ACCESS_TOKEN"),
-		URL:         os.Getenv("JFROG_URL"),
-		Backend:     backend,
-		Context:     ctx,
-		Storage:     &logical.InmemStorage{},
+		AccessToken:   os.Getenv("JFROG_ACCESS_TOKEN"),
+		URL:           os.Getenv("JFROG_URL"),
+		AdminUsername: adminUsername,
+		RunID:         "vault-acc-test-" + runID[:8],
+		Backend:       backend,
+		Context:       ctx,
+		Storage:       &logical.InmemStorage{},
 	}, nil
 }
 
@@ -329,9 +400,11 @@ func NewConfiguredAcceptanceTestEnv(t *testing.T) (e *accTestEnv) {
 	_, accessToken := e.createNewTestToken(t)
 
 	// setup new path configuration
+	e.UpdateConfigAdminCredentials(t, testData{
+		"access_token": accessToken,
+		"url":          e.URL,
+	})
 	e.UpdateConfigAdmin(t, testData{
-		"access_token":                        accessToken,
-		"url":                                 e.URL,
 		"bypass_artifactory_tls_verification": false,
 	})
 
@@ -374,6 +447,21 @@ const jwtAccessToken string = `
 		"token_type" : "Bearer"
 	}`
 
+// adminJWTAccessToken is an admin access token signed by rootCert
+// TokenID: 84c0626b-7973-40c9-9d37-701aaf73cfb4
+const adminJWTAccessToken = `eyJ2ZXIiOiIyIiwidHlwIjoiSldUIiwiYWxnIjoiUlMyNTYiLCJraW` +
+	`QiOiJkMUxJUFRHbmY0RHZzQ2k0MzhodU9KdWN3bi1lSTBHc0lVR2g0bGhhdE53In0.eyJ` +
+	`zdWIiOiJqZmFjQDAxaDQyNGh2d3B5dHprMWF6eGg2azgwN2U1L3VzZXJzL2FkbWluIiwi` +
+	`c2NwIjoiYXBwbGllZC1wZXJtaXNzaW9ucy9hZG1pbiIsImF1ZCI6IipAKiIsImlzcyI6I` +
+	`mpmZmVAMDFoNDI0aHZ3cHl0emsxYXp4aDZrODA3ZTUiLCJleHAiOjE3NTMyOTM5OTMsIm` +
+	`lhdCI6MTY5MDIyMTk5MywianRpIjoiODRjMDYyNmItNzk3My00MGM5LTlkMzctNzAxYWF` +
+	`mNzNjZmI0In0.VXoZR--oQLRTqTLx3Ogz1UUrzT9hlihWQ8m_JgOucZEYwIjGa2P58wUW` +
+	`vUAxonkiqyvmFfEk8H1vyiaBQ0F9vQ7v16d3D3nfEDW71g09M3NnsKu065-pbjPRGUmSi` +
+	`SvV0WC3Gla5Ui31IA_vVhyc-kPDzoWpHwBWgOMWkJwP0ZrvQ5bwzKrwNQi6YB0SIX2eSH` +
+	`RpReef19W_4BpOUrqMrcDamB3mskwxcYFUMA45FRoV_JVxZsIMOyNNfDlNy01r5bA6ZcY` +
+	`EaseaQpU7skMCW07rUiWq4Z6U0xZEduKPlowJm9xbrBM13FEQTG4b4mW7yyOD4gqQ49wD` +
+	`GGXvhLVFoQ`
+
 // Literally https://www.jfrog.com/confluence/display/JFROG/Artifactory+REST+API#ArtifactoryRESTAPI-CreateToken
 const canonicalAccessToken = `{
    "access_token":   "eyXsdgbtybbeeyh...",
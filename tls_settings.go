@@ -0,0 +1,89 @@
+package artifactory
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// tlsVersions are the values of tls_min_version, named as Vault's own listener settings name them
+var tlsVersions = map[string]uint16{
+	"tls10": tls.VersionTLS10,
+	"tls11": tls.VersionTLS11,
+	"tls12": tls.VersionTLS12,
+	"tls13": tls.VersionTLS13,
+}
+
+// parseTLSMinVersion returns the TLS version named by tls_min_version
+func parseTLSMinVersion(name string) (uint16, error) {
+	v, ok := tlsVersions[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		names := make([]string, 0, len(tlsVersions))
+		for n := range tlsVersions {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return 0, fmt.Errorf("invalid tls_min_version '%s': must be one of %s", name, strings.Join(names, ", "))
+	}
+	return v, nil
+}
+
+// parseTLSCipherSuites returns the ids of the cipher suites named by tls_cipher_suites, in their IANA names (e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256). TLS 1.3 suites, which Go doesn't make configurable, and suites Go considers
+// insecure are rejected.
+func parseTLSCipherSuites(names []string) ([]uint16, error) {
+	secure := make(map[string]uint16)
+	tls13 := make(map[string]bool)
+	for _, suite := range tls.CipherSuites() {
+		if slices.Equal(suite.SupportedVersions, []uint16{tls.VersionTLS13}) {
+			tls13[suite.Name] = true
+			continue
+		}
+		secure[suite.Name] = suite.ID
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if tls13[name] {
+			return nil, fmt.Errorf("invalid tls_cipher_suites entry '%s': TLS 1.3 cipher suites aren't configurable", name)
+		}
+		if insecure[name] {
+			return nil, fmt.Errorf("invalid tls_cipher_suites entry '%s': the cipher suite is insecure", name)
+		}
+		id, ok := secure[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls_cipher_suites entry '%s': unknown cipher suite", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// applyTLSVersionSettings sets the minimum TLS version and the cipher suites of config on tlsConfig. Go doesn't make
+// the TLS 1.3 cipher suites configurable, so the suites only apply to TLS 1.2 and lower.
+func applyTLSVersionSettings(tlsConfig *tls.Config, config adminConfiguration) error {
+	if len(config.TLSMinVersion) > 0 {
+		v, err := parseTLSMinVersion(config.TLSMinVersion)
+		if err != nil {
+			return err
+		}
+		tlsConfig.MinVersion = v
+	}
+
+	if len(config.TLSCipherSuites) > 0 {
+		suites, err := parseTLSCipherSuites(config.TLSCipherSuites)
+		if err != nil {
+			return err
+		}
+		tlsConfig.CipherSuites = suites
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package artifactory
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// tls_min_version and tls_cipher_suites must be applied to the TLS settings, and may only narrow those of fips_mode.
+func TestBackend_TLSVersionSettings(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	tlsConfig, err := artifactoryTLSConfig(adminConfiguration{TLSMinVersion: "tls13"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, tls.VersionTLS13, tlsConfig.MinVersion)
+
+	tlsConfig, err = artifactoryTLSConfig(adminConfiguration{TLSCipherSuites: []string{"tls_ecdhe_rsa_with_aes_256_gcm_sha384"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, tlsConfig.CipherSuites)
+
+	_, err = artifactoryTLSConfig(adminConfiguration{TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}})
+	assert.ErrorContains(t, err, "insecure")
+
+	tlsConfig, err = artifactoryTLSConfig(adminConfiguration{FIPSMode: true, TLSMinVersion: "tls13"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, tls.VersionTLS13, tlsConfig.MinVersion)
+
+	_, err = artifactoryTLSConfig(adminConfiguration{FIPSMode: true, TLSMinVersion: "tls11"})
+	assert.ErrorContains(t, err, "not allowed in fips_mode")
+
+	_, err = artifactoryTLSConfig(adminConfiguration{TLSCipherSuites: []string{"TLS_AES_128_GCM_SHA256"}})
+	assert.ErrorContains(t, err, "TLS 1.3 cipher suites aren't configurable")
+
+	_, err = artifactoryTLSConfig(adminConfiguration{FIPSMode: true, TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}})
+	assert.ErrorContains(t, err, "not allowed in fips_mode")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"tls_min_version": "ssl3"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "invalid tls_min_version 'ssl3'")
+}
@@ -0,0 +1,174 @@
+package artifactory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// trackedTokenCompactionInterval is how often tracked tokens are compacted
+	trackedTokenCompactionInterval = time.Hour
+
+	// defaultTrackedTokenRetention is how long a tracked token is kept after its lease expired when config/admin
+	// doesn't set tracked_token_retention. Vault revokes leases, and so untracks their tokens, once they expire; entries
+	// left that long after are those whose revocation never reached the backend.
+	defaultTrackedTokenRetention = 24 * time.Hour
+)
+
+// compactTrackedTokens moves tokens tracked before sharding into their shard, indexes tokens tracked before the indexes
+// existed, removes tokens whose lease expired more than tracked_token_retention ago, and then, beyond
+// max_tracked_tokens, those expired or revoked in Artifactory, oldest first. Changelog entries past their retention are pruned along with them. It runs at most once per
+// trackedTokenCompactionInterval. Tokens with an active lease are never removed.
+func (b *backend) compactTrackedTokens(ctx context.Context, req *logical.Request) error {
+	config, err := b.fetchAdminConfiguration(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	if time.Since(b.lastTrackedTokenCompaction) < trackedTokenCompactionInterval {
+		return nil
+	}
+	b.lastTrackedTokenCompaction = time.Now()
+
+	if err := b.shardLegacyTrackedTokens(ctx, req.Storage); err != nil {
+		return err
+	}
+
+	retention := config.TrackedTokenRetention
+	if retention == 0 {
+		retention = defaultTrackedTokenRetention
+	}
+
+	trackingIDs, err := b.listTrackedTokens(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	indexBuilt, err := b.trackedTokenIndexBuilt(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	type inactiveToken struct {
+		trackingID string
+		expiresAt  time.Time
+	}
+
+	now := time.Now()
+	tracked, removed := 0, 0
+	var inactive []inactiveToken
+
+	for _, trackingID := range trackingIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		token, err := b.fetchTrackedToken(ctx, req.Storage, trackingID)
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			continue
+		}
+
+		if token.ExpiresAt.Add(retention).Before(now) {
+			if err := b.deleteTrackedToken(ctx, req.Storage, trackingID); err != nil {
+				return err
+			}
+			removed++
+			continue
+		}
+
+		// Tokens tracked before the indexes existed are indexed once
+		if !indexBuilt {
+			if err := b.indexTrackedToken(ctx, req.Storage, trackingID, *token); err != nil {
+				return err
+			}
+		}
+
+		tracked++
+		if !token.active(now) {
+			inactive = append(inactive, inactiveToken{trackingID: trackingID, expiresAt: token.ExpiresAt})
+		}
+	}
+
+	if !indexBuilt {
+		if err := req.Storage.Put(ctx, &logical.StorageEntry{Key: trackedTokenIndexBuiltKey, Value: []byte("1")}); err != nil {
+			return err
+		}
+	}
+
+	if config.MaxTrackedTokens > 0 && tracked > config.MaxTrackedTokens {
+		sort.Slice(inactive, func(i, j int) bool {
+			return inactive[i].expiresAt.Before(inactive[j].expiresAt)
+		})
+
+		for _, token := range inactive {
+			if tracked <= config.MaxTrackedTokens {
+				break
+			}
+			if err := b.deleteTrackedToken(ctx, req.Storage, token.trackingID); err != nil {
+				return err
+			}
+			tracked--
+			removed++
+		}
+
+		if tracked > config.MaxTrackedTokens {
+			b.Logger().Warn("more tokens with active leases are tracked than max_tracked_tokens allows", "tracked", tracked, "max_tracked_tokens", config.MaxTrackedTokens)
+		}
+	}
+
+	if removed > 0 {
+		b.Logger().Info("compacted tracked tokens", "removed", removed, "tracked", tracked)
+	}
+
+	return b.pruneChangelog(ctx, req.Storage, now)
+}
+
+// shardLegacyTrackedTokens moves tokens tracked directly under trackedTokenStoragePrefix into their shard
+func (b *backend) shardLegacyTrackedTokens(ctx context.Context, storage logical.Storage) error {
+	keys, err := storage.List(ctx, trackedTokenStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, trackingID := range keys {
+		if strings.HasSuffix(trackingID, "/") {
+			continue
+		}
+
+		entry, err := storage.Get(ctx, trackedTokenStoragePrefix+trackingID)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		// A token updated since sharding already has a newer entry in its shard
+		sharded, err := storage.Get(ctx, trackedTokenKey(trackingID))
+		if err != nil {
+			return err
+		}
+		if sharded == nil {
+			if err := storage.Put(ctx, &logical.StorageEntry{Key: trackedTokenKey(trackingID), Value: entry.Value, SealWrap: entry.SealWrap}); err != nil {
+				return err
+			}
+		}
+
+		if err := storage.Delete(ctx, trackedTokenStoragePrefix+trackingID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package artifactory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Compaction must move legacy entries into shards, remove entries past their retention, and bound the number of
+// tracked tokens by removing inactive ones.
+func TestBackend_CompactTrackedTokens(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token":            "test-access-token",
+		"url":                     "http://myserver.com:80",
+		"tracked_token_retention": "1h",
+		"max_tracked_tokens":      3,
+	})
+
+	ctx := context.Background()
+	storage := config.StorageView
+	now := time.Now()
+
+	tokens := map[string]trackedToken{
+		"active-1":         {Username: "user", ExpiresAt: now.Add(time.Hour)},
+		"active-2":         {Username: "user", ExpiresAt: now.Add(2 * time.Hour)},
+		"revoked":          {Username: "user", ExpiresAt: now.Add(time.Hour), RevokedInArtifactory: true},
+		"recently-expired": {Username: "user", ExpiresAt: now.Add(-time.Minute)},
+		"long-expired":     {Username: "user", ExpiresAt: now.Add(-2 * time.Hour)},
+	}
+	for trackingID, token := range tokens {
+		assert.NoError(t, b.putTrackedToken(ctx, storage, trackingID, token))
+	}
+
+	// An entry tracked before sharding
+	legacy, err := logical.StorageEntryJSON(trackedTokenStoragePrefix+"legacy", trackedToken{Username: "user", ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put(ctx, legacy))
+
+	token, err := b.fetchTrackedToken(ctx, storage, "legacy")
+	assert.NoError(t, err)
+	assert.NotNil(t, token)
+
+	trackingIDs, err := b.listTrackedTokens(ctx, storage)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"active-1", "active-2", "legacy", "long-expired", "recently-expired", "revoked"}, trackingIDs)
+
+	err = b.compactTrackedTokens(ctx, &logical.Request{Storage: storage})
+	assert.NoError(t, err)
+
+	// The long expired entry is past its retention, and the inactive ones go beyond max_tracked_tokens, oldest first
+	trackingIDs, err = b.listTrackedTokens(ctx, storage)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"active-1", "active-2", "legacy"}, trackingIDs)
+
+	keys, err := storage.List(ctx, trackedTokenStoragePrefix)
+	assert.NoError(t, err)
+	assert.NotContains(t, keys, "legacy")
+
+	entry, err := storage.Get(ctx, trackedTokenKey("legacy"))
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+
+	// Compaction runs at most once per interval
+	assert.NoError(t, b.deleteTrackedToken(ctx, storage, "active-1"))
+	assert.NoError(t, b.putTrackedToken(ctx, storage, "long-expired", tokens["long-expired"]))
+
+	err = b.compactTrackedTokens(ctx, &logical.Request{Storage: storage})
+	assert.NoError(t, err)
+
+	token, err = b.fetchTrackedToken(ctx, storage, "long-expired")
+	assert.NoError(t, err)
+	assert.NotNil(t, token)
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "stats",
+		Storage:   storage,
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, resp.Data["tokens"].(map[string]interface{})["count"])
+}
+
+// Tokens tracked before the indexes existed must be indexed by the first compaction.
+func TestBackend_CompactTrackedTokensIndexesTokens(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80",
+	})
+
+	ctx := context.Background()
+	storage := config.StorageView
+	now := time.Now()
+
+	err := b.putTrackedToken(ctx, storage, "unindexed", trackedToken{Role: "test-role", Username: "user", EntityID: "ci-runner", AccessTokenSHA256: accessTokenSHA256("access-token"), IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+
+	count, _, err := b.activeRoleTokens(ctx, storage, "test-role")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	err = b.compactTrackedTokens(ctx, &logical.Request{Storage: storage})
+	assert.NoError(t, err)
+
+	count, _, err = b.activeRoleTokens(ctx, storage, "test-role")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	ids, _, err := b.entityRoleTokens(ctx, storage, "test-role", "ci-runner")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unindexed"}, ids)
+
+	trackingID, _, err := b.findTrackedToken(ctx, storage, "access-token")
+	assert.NoError(t, err)
+	assert.Equal(t, "unindexed", trackingID)
+
+	// Index entries are removed with the token
+	assert.NoError(t, b.deleteTrackedToken(ctx, storage, "unindexed"))
+	keys, err := storage.List(ctx, roleTokenIndexPrefix("test-role"))
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	trackingID, _, err = b.findTrackedToken(ctx, storage, "access-token")
+	assert.NoError(t, err)
+	assert.Empty(t, trackingID)
+}
@@ -0,0 +1,145 @@
+package artifactory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// trackedTokenIndexStoragePrefix holds secondary indexes of tracked tokens, so finding the tokens of a role or of an
+// entity doesn't read every tracked token:
+//
+//	token_index/role/<role>/<tracking id>
+//	token_index/entity/<role>/<entity id>/<tracking id>
+//	token_index/hash/<sha256 of the access token>, holding the tracking id
+//	token_index/pending/<role>/<entity id>/<request id>, for async token requests whose token isn't tracked yet
+//
+// Entries are written when a token is tracked and deleted with it. Readers skip entries whose token is gone.
+const trackedTokenIndexStoragePrefix = "token_index/"
+
+// trackedTokenIndexBuiltKey marks that tokens tracked before the indexes existed were indexed by compaction
+const trackedTokenIndexBuiltKey = trackedTokenIndexStoragePrefix + "built"
+
+func roleTokenIndexPrefix(roleName string) string {
+	return trackedTokenIndexStoragePrefix + "role/" + roleName + "/"
+}
+
+func entityTokenIndexPrefix(roleName string, entityID string) string {
+	return trackedTokenIndexStoragePrefix + "entity/" + roleName + "/" + entityID + "/"
+}
+
+func pendingTokenRequestIndexPrefix(roleName string, entityID string) string {
+	return trackedTokenIndexStoragePrefix + "pending/" + roleName + "/" + entityID + "/"
+}
+
+func tokenHashIndexKey(accessTokenSHA256 string) string {
+	return trackedTokenIndexStoragePrefix + "hash/" + accessTokenSHA256
+}
+
+// trackedTokenIndexKeys returns the index entries of a tracked token
+func trackedTokenIndexKeys(trackingID string, token trackedToken) []string {
+	var keys []string
+	for _, roleName := range token.roles() {
+		keys = append(keys, roleTokenIndexPrefix(roleName)+trackingID)
+		if token.EntityID != "" {
+			keys = append(keys, entityTokenIndexPrefix(roleName, token.EntityID)+trackingID)
+		}
+	}
+	if token.AccessTokenSHA256 != "" {
+		keys = append(keys, tokenHashIndexKey(token.AccessTokenSHA256))
+	}
+	return keys
+}
+
+// roles returns the roles a token was issued for
+func (t trackedToken) roles() []string {
+	if len(t.Roles) > 0 {
+		return t.Roles
+	}
+	if t.Role == "" {
+		return nil
+	}
+	return []string{t.Role}
+}
+
+// indexTrackedToken writes the index entries of a tracked token
+func (b *backend) indexTrackedToken(ctx context.Context, storage logical.Storage, trackingID string, token trackedToken) error {
+	for _, key := range trackedTokenIndexKeys(trackingID, token) {
+		if err := storage.Put(ctx, &logical.StorageEntry{Key: key, Value: []byte(trackingID)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexTrackedToken deletes the index entries of a tracked token
+func (b *backend) unindexTrackedToken(ctx context.Context, storage logical.Storage, trackingID string, token trackedToken) error {
+	for _, key := range trackedTokenIndexKeys(trackingID, token) {
+		if err := storage.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexedTrackedTokens returns the tracked tokens listed under an index prefix, by tracking id, and their sorted ids.
+// Entries of tokens removed since are skipped.
+func (b *backend) indexedTrackedTokens(ctx context.Context, storage logical.Storage, prefix string) ([]string, map[string]*trackedToken, error) {
+	keys, err := storage.List(ctx, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(keys)
+
+	ids := make([]string, 0, len(keys))
+	tokens := make(map[string]*trackedToken, len(keys))
+	for _, trackingID := range keys {
+		token, err := b.fetchTrackedToken(ctx, storage, trackingID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if token == nil {
+			continue
+		}
+		ids = append(ids, trackingID)
+		tokens[trackingID] = token
+	}
+
+	return ids, tokens, nil
+}
+
+// activeIndexedTrackedTokens returns, oldest first, the tokens listed under an index prefix that haven't expired or
+// been revoked
+func (b *backend) activeIndexedTrackedTokens(ctx context.Context, storage logical.Storage, prefix string) ([]string, map[string]*trackedToken, error) {
+	ids, tokens, err := b.indexedTrackedTokens(ctx, storage, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	active := ids[:0]
+	for _, trackingID := range ids {
+		if tokens[trackingID].active(now) {
+			active = append(active, trackingID)
+		} else {
+			delete(tokens, trackingID)
+		}
+	}
+
+	sort.SliceStable(active, func(i, j int) bool {
+		return tokens[active[i]].IssuedAt.Before(tokens[active[j]].IssuedAt)
+	})
+
+	return active, tokens, nil
+}
+
+// trackedTokenIndexBuilt reports whether every tracked token has been indexed
+func (b *backend) trackedTokenIndexBuilt(ctx context.Context, storage logical.Storage) (bool, error) {
+	entry, err := storage.Get(ctx, trackedTokenIndexBuiltKey)
+	if err != nil {
+		return false, err
+	}
+	return entry != nil, nil
+}
@@ -370,3 +370,65 @@ func TestBackend_UserTokenDefaultTTL(t *testing.T) {
 
 	assert.EqualValues(t, 42*time.Minute, resp.Secret.TTL)
 }
+
+// The default_ttl and max_ttl of config/admin must clamp the TTLs of every role, with warnings on reads of roles
+// exceeding them.
+func TestBackend_ConfigTTLCeilings(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockArtifactoryUsageVersionRequests("")
+
+	httpmock.RegisterResponder(
+		http.MethodPost,
+		"http://myserver.com:80/artifactory/api/security/token",
+		httpmock.NewStringResponder(200, canonicalAccessToken))
+
+	b, config := configuredBackend(t, map[string]interface{}{
+		"access_token": "test-access-token",
+		"url":          "http://myserver.com:80/artifactory",
+		"default_ttl":  "30m",
+		"max_ttl":      "1h",
+	})
+
+	for roleName, roleData := range map[string]map[string]interface{}{
+		"long-role":    {"username": "test-username", "scope": "test-scope", "default_ttl": "90m", "max_ttl": "2h"},
+		"no-ttls-role": {"username": "test-username", "scope": "test-scope"},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/" + roleName,
+			Storage:   config.StorageView,
+			Data:      roleData,
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/" + roleName,
+			Storage:   config.StorageView,
+		})
+		assert.NoError(t, err)
+		assert.EqualValues(t, 30*time.Minute, resp.Secret.TTL, roleName)
+		assert.EqualValues(t, time.Hour, resp.Secret.MaxTTL, roleName)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/long-role",
+		Storage:   config.StorageView,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Warnings, 2)
+	assert.Contains(t, resp.Warnings[1], "max_ttl (2h0m0s) exceeds the max_ttl of config/admin (1h0m0s)")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/admin",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"default_ttl": "2h"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.IsError())
+}